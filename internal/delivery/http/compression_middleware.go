@@ -0,0 +1,171 @@
+package http
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultCompressionMinSize is the smallest response body compressed when no
+// threshold is configured; below it gzip's overhead outweighs the savings
+const DefaultCompressionMinSize = 1024
+
+// compressibleContentTypes are the media types worth compressing. Downloaded
+// files such as proof pictures and PDFs are already compressed formats, so they
+// are sent as stored.
+var compressibleContentTypes = []string{"application/json", "application/xml", "text/"}
+
+// NewCompressionMiddleware creates a middleware that gzips JSON, XML and text
+// responses of at least minSize bytes for clients accepting gzip
+func NewCompressionMiddleware(minSize int) gin.HandlerFunc {
+	if minSize <= 0 {
+		minSize = DefaultCompressionMinSize
+	}
+
+	return func(c *gin.Context) {
+		// Caches must keep the compressed and plain representations apart
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if c.Request.Method == http.MethodHead || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		writer := &compressWriter{ResponseWriter: c.Writer, minSize: minSize}
+		c.Writer = writer
+		c.Next()
+		writer.finish()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(acceptEncoding string) bool {
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		name = strings.TrimSpace(name)
+		if name != "gzip" && name != "*" {
+			continue
+		}
+
+		// gzip;q=0 explicitly refuses the coding
+		quality, found := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !found {
+			return true
+		}
+		if q, err := strconv.ParseFloat(quality, 64); err == nil && q > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter buffers the start of the body until it knows whether the
+// response reaches the threshold, then writes it through gzip or as is
+type compressWriter struct {
+	gin.ResponseWriter
+	minSize int
+
+	buffer  []byte
+	gzip    *gzip.Writer
+	decided bool
+}
+
+// Write buffers the body until the compression decision is made
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.buffer = append(w.buffer, data...)
+		if len(w.buffer) < w.minSize {
+			return len(data), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
+	if w.gzip != nil {
+		return w.gzip.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString implements gin.ResponseWriter through Write
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow sends the headers, deciding first for responses without a body
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide()
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Flush sends what was written so far, so streamed responses keep streaming
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.gzip != nil {
+		w.gzip.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide picks plain or gzip output for the response and writes the buffered body
+func (w *compressWriter) decide() error {
+	w.decided = true
+
+	if len(w.buffer) >= w.minSize && w.compressible() {
+		header := w.Header()
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.gzip = gzip.NewWriter(w.ResponseWriter)
+	}
+
+	buffer := w.buffer
+	w.buffer = nil
+	if len(buffer) == 0 {
+		return nil
+	}
+	if w.gzip != nil {
+		_, err := w.gzip.Write(buffer)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buffer)
+	return err
+}
+
+// compressible reports whether the response is a body type worth compressing
+// that isn't encoded already
+func (w *compressWriter) compressible() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, compressible := range compressibleContentTypes {
+		if mediaType == compressible || (strings.HasSuffix(compressible, "/") && strings.HasPrefix(mediaType, compressible)) {
+			return true
+		}
+	}
+	return false
+}
+
+// finish writes a body left under the threshold and completes the gzip stream
+func (w *compressWriter) finish() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.gzip != nil {
+		w.gzip.Close()
+	}
+}
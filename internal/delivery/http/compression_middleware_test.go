@@ -0,0 +1,94 @@
+package http
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// largeBody is well above the compression threshold used by the tests
+var largeBody = strings.Repeat(`{"ID":1,"InvestorEmail":"investor@example.com","Amount":100}`, 100)
+
+func newCompressionRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(NewCompressionMiddleware(1024))
+	router.GET("/large", func(c *gin.Context) { c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(largeBody)) })
+	router.GET("/small", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
+	router.GET("/file", func(c *gin.Context) { c.Data(http.StatusOK, "application/pdf", []byte(largeBody)) })
+	return router
+}
+
+// requestWithEncoding sends a GET to path accepting acceptEncoding
+func requestWithEncoding(router *gin.Engine, path, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCompressionMiddlewareGzipsLargeResponses(t *testing.T) {
+	router := newCompressionRouter(t)
+
+	w := requestWithEncoding(router, "/large", "br, gzip;q=0.8")
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("got Content-Encoding %q, want gzip", got)
+	}
+	if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("got Vary %q, want Accept-Encoding", got)
+	}
+	if w.Body.Len() >= len(largeBody) {
+		t.Errorf("got %d compressed bytes, want fewer than the %d plain ones", w.Body.Len(), len(largeBody))
+	}
+
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("body is not gzip: %v", err)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to decompress body: %v", err)
+	}
+	if string(body) != largeBody {
+		t.Errorf("got a %d byte decompressed body, want the original %d bytes", len(body), len(largeBody))
+	}
+}
+
+func TestCompressionMiddlewareSendsOtherResponsesAsIs(t *testing.T) {
+	router := newCompressionRouter(t)
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		want           string
+	}{
+		{"no Accept-Encoding", "/large", "", largeBody},
+		{"gzip refused", "/large", "gzip;q=0", largeBody},
+		{"other codings only", "/large", "br, deflate", largeBody},
+		{"under the threshold", "/small", "gzip", `{"status":"ok"}`},
+		{"file download", "/file", "gzip", largeBody},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := requestWithEncoding(router, tt.path, tt.acceptEncoding)
+			if got := w.Header().Get("Content-Encoding"); got != "" {
+				t.Errorf("got Content-Encoding %q, want none", got)
+			}
+			if w.Body.String() != tt.want {
+				t.Errorf("got a %d byte body, want the %d plain bytes", w.Body.Len(), len(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// negotiatedFormats are the formats of the routes that also offer XML, JSON
+// first so it stays the default for clients without an XML Accept header
+var negotiatedFormats = []string{binding.MIMEJSON, binding.MIMEXML, binding.MIMEXML2}
+
+// negotiateFormat returns binding.MIMEXML to clients asking for application/xml
+// or text/xml in their Accept header, and binding.MIMEJSON to everyone else.
+// It also sets Vary: Accept, since caches must keep the two representations apart
+func negotiateFormat(c *gin.Context) string {
+	c.Writer.Header().Add("Vary", "Accept")
+
+	switch c.NegotiateFormat(negotiatedFormats...) {
+	case binding.MIMEXML, binding.MIMEXML2:
+		return binding.MIMEXML
+	default:
+		return binding.MIMEJSON
+	}
+}
+
+// respondNegotiated writes data in the format negotiateFormat picks
+func respondNegotiated(c *gin.Context, status int, data interface{}) {
+	respondInFormat(c, negotiateFormat(c), status, data)
+}
+
+// respondInFormat writes data as XML when format is binding.MIMEXML, and as
+// JSON otherwise
+func respondInFormat(c *gin.Context, format string, status int, data interface{}) {
+	if format == binding.MIMEXML {
+		c.XML(status, data)
+		return
+	}
+	c.JSON(status, data)
+}
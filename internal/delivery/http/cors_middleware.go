@@ -0,0 +1,66 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// Default CORS methods and headers, used when none are configured
+var (
+	DefaultCORSAllowedMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	DefaultCORSAllowedHeaders = []string{"Origin", "Content-Type", "Authorization", "Idempotency-Key", "If-None-Match", RequestIDHeader}
+)
+
+// CORSConfig holds which browser origins may call the API
+type CORSConfig struct {
+	AllowedOrigins   []string // Origins such as https://app.example.com, or "*" for any origin
+	AllowedMethods   []string // Defaults to DefaultCORSAllowedMethods
+	AllowedHeaders   []string // Defaults to DefaultCORSAllowedHeaders
+	AllowCredentials bool     // Let browsers send cookies and authorization headers
+}
+
+// NewCORSMiddleware creates a middleware answering CORS requests from the
+// configured origins; requests from other origins are refused with 403. It
+// returns nil when no origin is allowed, leaving browsers to block every
+// cross-origin request.
+func NewCORSMiddleware(config CORSConfig) (gin.HandlerFunc, error) {
+	if len(config.AllowedOrigins) == 0 {
+		return nil, nil
+	}
+
+	corsConfig := cors.Config{
+		AllowMethods:     config.AllowedMethods,
+		AllowHeaders:     config.AllowedHeaders,
+		AllowCredentials: config.AllowCredentials,
+		ExposeHeaders:    []string{RequestIDHeader, "Retry-After", "Content-Disposition", "ETag"},
+		MaxAge:           12 * time.Hour,
+	}
+	if len(corsConfig.AllowMethods) == 0 {
+		corsConfig.AllowMethods = DefaultCORSAllowedMethods
+	}
+	if len(corsConfig.AllowHeaders) == 0 {
+		corsConfig.AllowHeaders = DefaultCORSAllowedHeaders
+	}
+
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			corsConfig.AllowAllOrigins = true
+		}
+	}
+	if corsConfig.AllowAllOrigins {
+		// Browsers refuse credentials on a wildcard origin, so the combination never works
+		if config.AllowCredentials {
+			return nil, errors.New("credentials cannot be allowed for every origin, list the origins instead of *")
+		}
+	} else {
+		corsConfig.AllowOrigins = config.AllowedOrigins
+	}
+
+	if err := corsConfig.Validate(); err != nil {
+		return nil, err
+	}
+	return cors.New(corsConfig), nil
+}
@@ -0,0 +1,97 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newCORSRouter(t *testing.T, config CORSConfig) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	middleware, err := NewCORSMiddleware(config)
+	if err != nil {
+		t.Fatalf("failed to create CORS middleware: %v", err)
+	}
+	router := gin.New()
+	if middleware != nil {
+		router.Use(middleware)
+	}
+	router.GET("/api/loans", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+// requestWithOrigin sends a request from a page served by origin
+func requestWithOrigin(router *gin.Engine, method, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/loans", nil)
+	req.Header.Set("Origin", origin)
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSMiddlewareAllowsOnlyConfiguredOrigins(t *testing.T) {
+	router := newCORSRouter(t, CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true})
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		wantStatus  int
+		wantAllowed string
+	}{
+		{"allowed origin", http.MethodGet, "https://app.example.com", http.StatusOK, "https://app.example.com"},
+		{"allowed origin preflight", http.MethodOptions, "https://app.example.com", http.StatusNoContent, "https://app.example.com"},
+		{"other origin", http.MethodGet, "https://evil.example.com", http.StatusForbidden, ""},
+		{"other origin preflight", http.MethodOptions, "https://evil.example.com", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := requestWithOrigin(router, tt.method, tt.origin)
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowed {
+				t.Errorf("got Access-Control-Allow-Origin %q, want %q", got, tt.wantAllowed)
+			}
+			wantCredentials := ""
+			if tt.wantAllowed != "" {
+				wantCredentials = "true"
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != wantCredentials {
+				t.Errorf("got Access-Control-Allow-Credentials %q, want %q", got, wantCredentials)
+			}
+		})
+	}
+}
+
+func TestCORSMiddlewareWithoutOriginsSendsNoCORSHeaders(t *testing.T) {
+	router := newCORSRouter(t, CORSConfig{})
+
+	w := requestWithOrigin(router, http.MethodGet, "https://app.example.com")
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d, want 200", w.Code)
+	}
+	// Without the header browsers block the response to the other origin
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("got Access-Control-Allow-Origin %q, want none", got)
+	}
+}
+
+func TestNewCORSMiddlewareRefusesCredentialsForAnyOrigin(t *testing.T) {
+	if _, err := NewCORSMiddleware(CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}); err == nil {
+		t.Error("got no error, want credentials with a wildcard origin refused")
+	}
+
+	router := newCORSRouter(t, CORSConfig{AllowedOrigins: []string{"*"}})
+	if got := requestWithOrigin(router, http.MethodGet, "https://any.example.com").Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("got Access-Control-Allow-Origin %q, want *", got)
+	}
+}
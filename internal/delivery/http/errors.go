@@ -0,0 +1,105 @@
+package http
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Machine-readable error codes returned to clients
+const (
+	CodeInvalidRequest     = "INVALID_REQUEST"
+	CodeValidation         = "VALIDATION_ERROR"
+	CodeLoanNotFound       = "LOAN_NOT_FOUND"
+	CodeInvestmentNotFound = "INVESTMENT_NOT_FOUND"
+	CodeInvalidState       = "INVALID_STATE"
+	CodeInvestmentExceeds  = "INVESTMENT_EXCEEDS"
+	CodeAlreadyInvested    = "ALREADY_INVESTED"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeForbidden          = "FORBIDDEN"
+	CodeTimeout            = "TIMEOUT"
+	CodeRateLimited        = "RATE_LIMITED"
+	CodeConflict           = "CONCURRENT_MODIFICATION"
+	CodeFileNotFound       = "FILE_NOT_FOUND"
+	CodeConstraint         = "CONSTRAINT_VIOLATION"
+	CodeInternal           = "INTERNAL_ERROR"
+)
+
+// ErrorResponse is the body returned for every failed request
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// errorMappings ties domain errors to their HTTP status and error code
+var errorMappings = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{entity.ErrLoanNotFound, http.StatusNotFound, CodeLoanNotFound},
+	{entity.ErrInvestmentNotFound, http.StatusNotFound, CodeInvestmentNotFound},
+	{entity.ErrInvalidState, http.StatusConflict, CodeInvalidState},
+	{entity.ErrInvestmentExceeds, http.StatusBadRequest, CodeInvestmentExceeds},
+	{entity.ErrDuplicateInvestment, http.StatusConflict, CodeAlreadyInvested},
+	{entity.ErrValidation, http.StatusBadRequest, CodeValidation},
+	{entity.ErrForbidden, http.StatusForbidden, CodeForbidden},
+	{entity.ErrConcurrentModification, http.StatusConflict, CodeConflict},
+	{entity.ErrFileNotFound, http.StatusNotFound, CodeFileNotFound},
+	{entity.ErrConstraintViolation, http.StatusConflict, CodeConstraint},
+}
+
+// respondError maps a usecase error to its HTTP status and error code
+func (h *LoanHandler) respondError(c *gin.Context, err error) {
+	status, response := describeError(err)
+	c.JSON(status, response)
+}
+
+// describeError returns the HTTP status and error body for a usecase error
+func describeError(err error) (int, ErrorResponse) {
+	// The request deadline set by the timeout middleware expired mid-query
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout, ErrorResponse{Code: CodeTimeout, Message: "request timed out"}
+	}
+
+	for _, mapping := range errorMappings {
+		if errors.Is(err, mapping.err) {
+			return mapping.status, ErrorResponse{Code: mapping.code, Message: errorMessage(err, mapping.err)}
+		}
+	}
+
+	return http.StatusInternalServerError, ErrorResponse{Code: CodeInternal, Message: err.Error()}
+}
+
+// respondBadRequest rejects a malformed request before it reaches the usecase
+func (h *LoanHandler) respondBadRequest(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, ErrorResponse{Code: CodeInvalidRequest, Message: message})
+}
+
+// respondForbidden rejects a request the authenticated employee may not make
+func (h *LoanHandler) respondForbidden(c *gin.Context, message string) {
+	c.JSON(http.StatusForbidden, ErrorResponse{Code: CodeForbidden, Message: message})
+}
+
+// respondInternalError reports a server-side failure with a fixed message
+func (h *LoanHandler) respondInternalError(c *gin.Context, message string) {
+	c.JSON(http.StatusInternalServerError, ErrorResponse{Code: CodeInternal, Message: message})
+}
+
+// abortWithError stops the handler chain, used by middlewares to reject a request
+func abortWithError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, ErrorResponse{Code: code, Message: message})
+}
+
+// errorMessage returns the most specific domain message in the chain, dropping
+// the "failed to ..." context added by the usecase layer
+func errorMessage(err, kind error) string {
+	var domainErr *entity.DomainError
+	if errors.As(err, &domainErr) {
+		return domainErr.Message
+	}
+	return kind.Error()
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds how long the readiness probe waits for the database
+const readinessTimeout = 2 * time.Second
+
+// Pinger checks that a dependency is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthHandler handles liveness and readiness probes
+type HealthHandler struct {
+	db Pinger
+}
+
+// NewHealthHandler creates a new health handler
+func NewHealthHandler(db Pinger) *HealthHandler {
+	return &HealthHandler{
+		db: db,
+	}
+}
+
+// RegisterRoutes registers the probe routes outside the /api group
+func (h *HealthHandler) RegisterRoutes(r *gin.Engine) {
+	r.GET("/healthz", h.Liveness) // Process is up
+	r.GET("/readyz", h.Readiness) // Database is reachable
+}
+
+// Liveness handles GET /healthz
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness handles GET /readyz
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := h.db.Ping(ctx)
+	latency := time.Since(start)
+
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":        "unavailable",
+			"database":      "down",
+			"db_latency_ms": latency.Milliseconds(),
+			"error":         err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":        "ready",
+		"database":      "up",
+		"db_latency_ms": latency.Milliseconds(),
+	})
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServerInfo describes the running build and the backends it was configured
+// with. It names backends only, credentials and hosts are never part of it.
+type ServerInfo struct {
+	Version        string `json:"version"`
+	Commit         string `json:"commit"`
+	EmailProvider  string `json:"email_provider"`  // sendgrid, smtp or mock
+	StorageBackend string `json:"storage_backend"` // s3 or local
+	DatabaseDriver string `json:"database_driver"` // sqlite3 or postgres
+}
+
+// InfoHandler serves the build and configuration summary to operators
+type InfoHandler struct {
+	info ServerInfo
+}
+
+// NewInfoHandler creates a new info handler
+func NewInfoHandler(info ServerInfo) *InfoHandler {
+	return &InfoHandler{
+		info: info,
+	}
+}
+
+// RegisterRoutes registers the info route
+func (h *InfoHandler) RegisterRoutes(r *gin.Engine) {
+	r.GET("/api/info", h.GetInfo) // Running build and backends
+}
+
+// GetInfo handles GET /api/info
+func (h *InfoHandler) GetInfo(c *gin.Context) {
+	c.JSON(http.StatusOK, h.info)
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetInfoListsBuildAndBackendsOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	NewInfoHandler(ServerInfo{
+		Version:        "1.4.0",
+		Commit:         "8eb5a72",
+		EmailProvider:  "sendgrid",
+		StorageBackend: "s3",
+		DatabaseDriver: "postgres",
+	}).RegisterRoutes(router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/info", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	var info map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := map[string]string{
+		"version":         "1.4.0",
+		"commit":          "8eb5a72",
+		"email_provider":  "sendgrid",
+		"storage_backend": "s3",
+		"database_driver": "postgres",
+	}
+	for field, value := range want {
+		if info[field] != value {
+			t.Errorf("got %s %q, want %q", field, info[field], value)
+		}
+	}
+
+	// Nothing beyond the known fields is exposed, so no key, password or DSN can leak
+	var fields []string
+	for field := range info {
+		if _, known := want[field]; !known {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	if len(fields) > 0 {
+		t.Errorf("got unexpected fields %v", fields)
+	}
+	body := strings.ToLower(w.Body.String())
+	for _, secret := range []string{"key", "secret", "password", "dsn", "token"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("got %q in the response: %s", secret, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"strconv"
+	"time"
+)
+
+// loanCSVHeader is the header row of the loan export, in the order of toLoanCSVRecord
+var loanCSVHeader = []string{
+	"id", "borrower_id_number", "borrower_email", "principal_amount", "currency", "rate", "roi", "term_weeks",
+	"min_investment", "max_investment", "max_per_investor", "investment_increment", "allow_multiple_investments",
+	"state", "agreement_letter_link",
+	"approval_proof_picture", "approval_employee_id", "approval_date", "funding_deadline", "approval_effective_at",
+	"signed_agreement_doc", "disbursement_employee_id", "disbursement_date", "maturity_date",
+	"rejection_reason", "rejection_employee_id", "rejection_date",
+	"cancellation_reason", "cancellation_employee_id", "cancellation_date",
+	"created_at", "updated_at", "deleted_at",
+}
+
+// toLoanCSVRecord converts a loan to an export row. Unset optional fields are left empty.
+func (h *LoanHandler) toLoanCSVRecord(loan *entity.Loan) []string {
+	response := h.toLoanResponse(loan)
+
+	return []string{
+		strconv.FormatInt(response.ID, 10),
+		response.BorrowerIDNumber,
+		csvString(response.BorrowerEmail),
+		csvFloat(&response.PrincipalAmount),
+		response.Currency,
+		csvFloat(&response.Rate),
+		csvFloat(&response.ROI),
+		strconv.Itoa(response.TermWeeks),
+		csvFloat(response.MinInvestment),
+		csvFloat(response.MaxInvestment),
+		csvFloat(response.MaxPerInvestor),
+		csvFloat(response.InvestmentIncrement),
+		strconv.FormatBool(response.AllowMultipleInvestmentsPerInvestor),
+		response.State,
+		response.AgreementLetterLink,
+		csvString(response.ApprovalProofPictureURL),
+		csvString(response.ApprovalEmployeeID),
+		csvTime(response.ApprovalDate),
+		csvTime(response.FundingDeadline),
+		csvTime(response.ApprovalEffectiveAt),
+		csvString(response.SignedAgreementDocURL),
+		csvString(response.DisbursementEmployeeID),
+		csvTime(response.DisbursementDate),
+		csvTime(response.MaturityDate),
+		csvString(response.RejectionReason),
+		csvString(response.RejectionEmployeeID),
+		csvTime(response.RejectionDate),
+		csvString(response.CancellationReason),
+		csvString(response.CancellationEmployeeID),
+		csvTime(response.CancellationDate),
+		csvTime(&response.CreatedAt),
+		csvTime(&response.UpdatedAt),
+		csvTime(response.DeletedAt),
+	}
+}
+
+func csvString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func csvFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+func csvTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.Format(time.RFC3339)
+}
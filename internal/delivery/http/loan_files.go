@@ -0,0 +1,128 @@
+package http
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Loan files served by GET /api/loans/:id/files/:type
+const (
+	FileTypeApprovalProof   = "approval_proof"   // Proof picture uploaded on approval
+	FileTypeSignedAgreement = "signed_agreement" // Signed agreement uploaded on disbursement
+)
+
+// fileDownloadURL returns the path of the endpoint serving one of a loan's files
+func fileDownloadURL(loanID int64, fileType string) string {
+	return fmt.Sprintf("/api/loans/%d/files/%s", loanID, fileType)
+}
+
+// DownloadFile handles GET /api/loans/:id/files/:type
+// signed_agreement serves the latest tranche's agreement, the one of the
+// tranche given by the optional disbursement_id query parameter, or any signed
+// document given by the optional document_id query parameter.
+func (h *LoanHandler) DownloadFile(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
+	}
+
+	fileType := c.Param("type")
+	if fileType != FileTypeApprovalProof && fileType != FileTypeSignedAgreement {
+		h.respondBadRequest(c, fmt.Sprintf("file type must be %s or %s", FileTypeApprovalProof, FileTypeSignedAgreement))
+		return
+	}
+
+	var disbursementID int64
+	if value := c.Query("disbursement_id"); value != "" {
+		if fileType != FileTypeSignedAgreement {
+			h.respondBadRequest(c, "disbursement_id only applies to the signed_agreement file")
+			return
+		}
+		disbursementID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			h.respondBadRequest(c, "Invalid disbursement ID")
+			return
+		}
+	}
+
+	var documentID int64
+	if value := c.Query("document_id"); value != "" {
+		if fileType != FileTypeSignedAgreement {
+			h.respondBadRequest(c, "document_id only applies to the signed_agreement file")
+			return
+		}
+		documentID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			h.respondBadRequest(c, "Invalid document ID")
+			return
+		}
+	}
+
+	location, subdirectory, err := h.storedFileLocation(c, loanID, fileType, disbursementID, documentID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	file, err := h.fileStorage.Open(c.Request.Context(), h.fileURL(subdirectory, location))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	defer file.Body.Close()
+
+	extraHeaders := map[string]string{
+		"Content-Disposition": fmt.Sprintf("inline; filename=%q", path.Base(location)),
+	}
+	c.DataFromReader(http.StatusOK, file.Size, file.ContentType, file.Body, extraHeaders)
+}
+
+// storedFileLocation looks up where a loan's file is stored, returning the
+// stored location and the subdirectory legacy bare filenames live in
+func (h *LoanHandler) storedFileLocation(c *gin.Context, loanID int64, fileType string, disbursementID, documentID int64) (string, string, error) {
+	if fileType == FileTypeSignedAgreement && disbursementID != 0 {
+		disbursements, err := h.loanUsecase.ListDisbursements(c.Request.Context(), loanID)
+		if err != nil {
+			return "", "", err
+		}
+		for _, disbursement := range disbursements {
+			if disbursement.ID == disbursementID {
+				return disbursement.SignedAgreementDoc, "signed_agreements", nil
+			}
+		}
+		return "", "", entity.NewDomainError(entity.ErrFileNotFound, "loan has no disbursement with this ID")
+	}
+
+	summary, err := h.loanUsecase.GetLoan(c.Request.Context(), loanID, false)
+	if err != nil {
+		return "", "", err
+	}
+
+	if documentID != 0 {
+		for _, document := range summary.Documents {
+			if document.ID == documentID {
+				return document.Document, "signed_agreements", nil
+			}
+		}
+		return "", "", entity.NewDomainError(entity.ErrFileNotFound, "loan has no document with this ID")
+	}
+
+	loan := summary.Loan
+	if fileType == FileTypeApprovalProof {
+		if loan.ApprovalProofPicture == nil || *loan.ApprovalProofPicture == "" {
+			return "", "", entity.NewDomainError(entity.ErrFileNotFound, "loan has no approval proof picture")
+		}
+		return *loan.ApprovalProofPicture, "proof_pictures", nil
+	}
+	if loan.SignedAgreementDoc == nil || *loan.SignedAgreementDoc == "" {
+		return "", "", entity.NewDomainError(entity.ErrFileNotFound, "loan has no signed agreement")
+	}
+	return *loan.SignedAgreementDoc, "signed_agreements", nil
+}
@@ -3,84 +3,252 @@ package http
 import (
 	"amartha-andreas/internal/domain/entity"
 	"amartha-andreas/internal/domain/repository"
+	"amartha-andreas/internal/domain/service"
 	"amartha-andreas/internal/usecase"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math"
 	"mime/multipart"
 	"net/http"
-	"os"
+	"net/mail"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 // LoanHandler handles HTTP requests for loan operations
 type LoanHandler struct {
-	loanUsecase usecase.LoanUsecase
+	loanUsecase    usecase.LoanUsecase
+	fileStorage    service.FileStorage
+	baseFileURL    string
+	authMiddleware gin.HandlerFunc
+	uploadLimits   UploadLimits
+	pageLimits     PageLimits
 }
 
-// NewLoanHandler creates a new loan handler
-func NewLoanHandler(loanUsecase usecase.LoanUsecase) *LoanHandler {
+// NewLoanHandler creates a new loan handler.
+// Uploaded files are saved through fileStorage. baseFileURL is the storage URL of the
+// uploads directory, used for loans that stored a bare filename; DefaultBaseFileURL is used when empty.
+// authMiddleware guards the endpoints that change the state of a loan. Unset
+// uploadLimits fall back to DefaultMaxImageSize and DefaultMaxDocumentSize, and
+// unset pageLimits to DefaultPageSize and DefaultMaxPageSize.
+func NewLoanHandler(loanUsecase usecase.LoanUsecase, fileStorage service.FileStorage, baseFileURL string, authMiddleware gin.HandlerFunc, uploadLimits UploadLimits, pageLimits PageLimits) *LoanHandler {
+	if baseFileURL == "" {
+		baseFileURL = DefaultBaseFileURL
+	}
+
 	return &LoanHandler{
-		loanUsecase: loanUsecase,
+		loanUsecase:    loanUsecase,
+		fileStorage:    fileStorage,
+		baseFileURL:    strings.TrimSuffix(baseFileURL, "/"),
+		authMiddleware: authMiddleware,
+		uploadLimits:   uploadLimits.withDefaults(),
+		pageLimits:     pageLimits.withDefaults(),
 	}
 }
 
 // RegisterRoutes registers all loan-related routes
 func (h *LoanHandler) RegisterRoutes(r *gin.Engine) {
-	// Serve uploaded files
-	r.Static("/files", "./uploads")
-
 	// API routes
 	api := r.Group("/api")
 	{
 		// Loan routes
 		loans := api.Group("/loans")
 		{
-			loans.POST("", h.CreateLoan)                // Create new loan
-			loans.GET("", h.ListLoans)                  // List all loans (with optional filters)
-			loans.GET("/:id", h.GetLoan)                // Get loan by ID with investments
-			loans.POST("/:id/approve", h.ApproveLoan)   // Approve a loan
-			loans.POST("/:id/invest", h.InvestInLoan)   // Invest in a loan
-			loans.POST("/:id/disburse", h.DisburseLoan) // Disburse a loan
+			loans.POST("", h.CreateLoan)                                                              // Create new loan
+			loans.GET("", h.ListLoans)                                                                // List all loans (with optional filters)
+			loans.GET("/export", h.ExportLoans)                                                       // Export loans as CSV (same filters as list)
+			loans.POST("/batch-get", h.BatchGetLoans)                                                 // Get several loans by ID in one call
+			loans.GET("/:id", h.GetLoan)                                                              // Get loan by ID with investments
+			loans.PUT("/:id", h.UpdateLoan)                                                           // Edit a proposed loan
+			loans.DELETE("/:id", h.authMiddleware, RequireRole(RoleOfficer), h.DeleteLoan)            // Soft-delete a proposed or rejected loan
+			loans.GET("/:id/returns", h.GetInvestorReturns)                                           // Get expected returns per investor
+			loans.GET("/:id/funding", h.GetLoanFunding)                                               // Get funding progress without the investments
+			loans.GET("/:id/history", h.GetLoanHistory)                                               // Get state transition audit log
+			loans.GET("/:id/actions", h.GetLoanActions)                                               // Get the actions the loan's state allows
+			loans.GET("/:id/investments", h.ListInvestments)                                          // List investments in a loan (paginated)
+			loans.POST("/:id/approve", h.authMiddleware, RequireRole(RoleApprover), h.ApproveLoan)    // Approve a loan
+			loans.POST("/:id/reject", h.authMiddleware, RequireRole(RoleOfficer), h.RejectLoan)       // Reject a loan
+			loans.POST("/:id/cancel", h.authMiddleware, RequireRole(RoleOfficer), h.CancelLoan)       // Cancel a loan
+			loans.POST("/:id/invest", h.InvestInLoan)                                                 // Invest in a loan
+			loans.DELETE("/:id/investments/:investment_id", h.WithdrawInvestment)                     // Withdraw an investment
+			loans.POST("/:id/disburse", h.authMiddleware, RequireRole(RoleDisburser), h.DisburseLoan) // Disburse a loan
+
+			// Reconciliation: mark a fully funded loan invested
+			loans.POST("/:id/force-invested", h.authMiddleware, RequireRole(RoleAdmin), h.ForceInvested)
+
+			// Expire an approved loan without waiting for its funding deadline
+			loans.POST("/:id/expire", h.authMiddleware, RequireRole(RoleAdmin), h.ExpireLoan)
+
+			// Send the fully invested notification again, e.g. after the email provider failed
+			loans.POST("/:id/notify", h.authMiddleware, RequireRole(RoleAdmin), h.ResendInvestedNotification)
+
+			// Disbursement tranches paid out so far
+			loans.GET("/:id/disbursements", h.ListDisbursements)
+
+			// Uploaded documents are only served to employees
+			loans.GET("/:id/files/:type", h.authMiddleware, RequireRole(RoleOfficer, RoleApprover, RoleDisburser, RoleAdmin), h.DownloadFile)
+
+			// Approvers may reprice an approved loan until the first investment
+			loans.PATCH("/:id/roi", h.authMiddleware, RequireRole(RoleApprover), h.UpdateROI)
+
+			// Approvers may fix a wrong proof picture until the loan is disbursed
+			loans.PUT("/:id/approval-proof", h.authMiddleware, RequireRole(RoleApprover), h.ReplaceApprovalProof)
 		}
+
+		// Portfolio statistics
+		api.GET("/stats", h.GetStats)
+
+		// Find loans by borrower ID or investor email fragment
+		api.GET("/search", h.SearchLoans)
+
+		// Disbursed principal and loans of one borrower, for risk
+		api.GET("/borrowers/:id/exposure", h.GetBorrowerExposure)
+
+		// Invest in several loans at once
+		api.POST("/investments/bulk", h.BulkInvest)
+
+		// PDF receipt of one investment
+		api.GET("/investments/:id/receipt", h.GetInvestmentReceipt)
+
+		// One investor's investments across all loans
+		api.GET("/investors/:email/portfolio", h.GetInvestorPortfolio)
+
+		// Investment ledger across all loans
+		api.GET("/admin/investments", h.authMiddleware, RequireRole(RoleAdmin), h.ListAllInvestments)
+
+		// Blended ROI of the funded loans
+		api.GET("/admin/roi-summary", h.authMiddleware, RequireRole(RoleAdmin), h.GetROISummary)
 	}
 }
 
 // CreateLoan handles POST /api/loans
+// With ?validate_only=true the loan is only validated and returned with its
+// derived fields, without being saved.
 func (h *LoanHandler) CreateLoan(c *gin.Context) {
 	var req CreateLoanRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.respondBadRequest(c, err.Error())
+		return
+	}
+
+	// Convert to domain parameters
+	params := entity.CreateLoanParams{
+		BorrowerIDNumber:    req.BorrowerIDNumber,
+		BorrowerEmail:       req.BorrowerEmail,
+		PrincipalAmount:     entity.NewMoney(req.PrincipalAmount),
+		Currency:            strings.ToUpper(req.Currency),
+		Rate:                req.Rate,
+		ROI:                 req.ROI,
+		TermWeeks:           req.TermWeeks,
+		MinInvestment:       entity.NewMoneyPtr(req.MinInvestment),
+		MaxInvestment:       entity.NewMoneyPtr(req.MaxInvestment),
+		MaxPerInvestor:      entity.NewMoneyPtr(req.MaxPerInvestor),
+		InvestmentIncrement: entity.NewMoneyPtr(req.InvestmentIncrement),
+		AgreementLetterLink: req.AgreementLetterLink,
+
+		AllowMultipleInvestmentsPerInvestor: req.AllowMultipleInvestmentsPerInvestor,
+	}
+
+	if c.Query("validate_only") == "true" {
+		loan, warnings, err := h.loanUsecase.ValidateLoan(c.Request.Context(), params)
+		if err != nil {
+			h.respondError(c, err)
+			return
+		}
+
+		response := h.toLoanResponse(loan)
+		response.Warnings = warnings
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	loan, warnings, err := h.loanUsecase.CreateLoan(c.Request.Context(), params)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	response := h.toLoanResponse(loan)
+	response.Warnings = warnings
+	c.JSON(http.StatusCreated, response)
+}
+
+// UpdateLoan handles PUT /api/loans/:id
+func (h *LoanHandler) UpdateLoan(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
 		return
 	}
 
-	// Additional validation at handler level
-	if !strings.HasPrefix(req.AgreementLetterLink, "http") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "agreement letter link must be a valid URL"})
+	var req UpdateLoanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondBadRequest(c, err.Error())
 		return
 	}
 
 	// Convert to domain parameters
-	params := entity.CreateLoanParams{
+	params := entity.UpdateLoanParams{
 		BorrowerIDNumber:    req.BorrowerIDNumber,
-		PrincipalAmount:     req.PrincipalAmount,
+		PrincipalAmount:     entity.NewMoney(req.PrincipalAmount),
 		Rate:                req.Rate,
 		ROI:                 req.ROI,
+		TermWeeks:           req.TermWeeks,
 		AgreementLetterLink: req.AgreementLetterLink,
 	}
 
-	loan, err := h.loanUsecase.CreateLoan(c.Request.Context(), params)
+	loan, err := h.loanUsecase.UpdateLoan(c.Request.Context(), loanID, params)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.toLoanResponse(loan))
+}
+
+// UpdateROI handles PATCH /api/loans/:id/roi
+func (h *LoanHandler) UpdateROI(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
+	}
+
+	var req UpdateROIRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
+	}
+
+	employeeID, err := h.employeeID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.respondForbidden(c, err.Error())
+		return
+	}
+	if err := h.validateEmployeeID(employeeID); err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
+	}
+
+	loan, err := h.loanUsecase.UpdateROI(c.Request.Context(), loanID, req.ROI, employeeID)
+	if err != nil {
+		h.respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, h.toLoanResponse(loan))
+	c.JSON(http.StatusOK, h.toLoanResponse(loan))
 }
 
 // ApproveLoan handles POST /api/loans/:id/approve (multipart/form-data)
@@ -88,280 +256,1366 @@ func (h *LoanHandler) ApproveLoan(c *gin.Context) {
 	loanIDStr := c.Param("id")
 	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		h.respondBadRequest(c, "Invalid loan ID")
 		return
 	}
 
 	// Get form fields
-	employeeID := c.PostForm("employee_id")
+	employeeID, err := h.employeeID(c)
+	if err != nil {
+		h.respondForbidden(c, err.Error())
+		return
+	}
 	approvalDate := c.PostForm("approval_date")
 
 	// Get uploaded file
 	file, header, err := c.Request.FormFile("proof_picture")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "proof_picture file is required"})
+		h.respondBadRequest(c, "proof_picture file is required")
 		return
 	}
 	defer file.Close()
 
 	// Validate file
 	imageExts := []string{".jpg", ".jpeg", ".png"}
-	if err := h.validateUploadedFile(header, imageExts, "proof picture"); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := h.validateUploadedFile(file, header, imageExts, "proof picture", h.uploadLimits.MaxImageSize); err != nil {
+		h.respondBadRequest(c, err.Error())
 		return
 	}
 
 	// Validate form fields
 	parsedApprovalDate, err := h.validateEmployeeIDAndDateFormat(employeeID, approvalDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.respondBadRequest(c, err.Error())
 		return
 	}
 
+	// Optional time the approval is scheduled to take effect at
+	var effectiveAt *time.Time
+	if value := c.PostForm("effective_at"); value != "" {
+		parsed, err := time.Parse("2006-01-02 15:04:05", value)
+		if err != nil {
+			h.respondBadRequest(c, "effective_at must be in YYYY-MM-DD HH:MM:SS format (e.g., 2023-12-25 10:30:00)")
+			return
+		}
+		effectiveAt = &parsed
+	}
+
 	// Save uploaded file
-	proofPicturePath, err := h.saveUploadedFile(file, header, loanID, "proof_pictures", "proof")
+	proofPictureURL, err := h.saveUploadedFile(c.Request.Context(), file, header, loanID, "proof_pictures", "proof")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save proof picture"})
+		h.respondInternalError(c, "Failed to save proof picture")
 		return
 	}
 
 	// Convert to domain parameters
 	params := entity.ApproveLoanParams{
-		ProofPicture: proofPicturePath,
+		ProofPicture: proofPictureURL,
 		EmployeeID:   employeeID,
 		ApprovalDate: parsedApprovalDate,
+		EffectiveAt:  effectiveAt,
 	}
 
 	loan, err := h.loanUsecase.ApproveLoan(c.Request.Context(), loanID, params)
 	if err != nil {
-		if err.Error() == "loan not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.deleteStoredFile(c.Request.Context(), proofPictureURL)
+		h.respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, h.toLoanResponse(loan))
 }
 
-// InvestInLoan handles POST /api/loans/:id/invest
-func (h *LoanHandler) InvestInLoan(c *gin.Context) {
+// ReplaceApprovalProof handles PUT /api/loans/:id/approval-proof (multipart/form-data)
+func (h *LoanHandler) ReplaceApprovalProof(c *gin.Context) {
 	loanIDStr := c.Param("id")
 	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		h.respondBadRequest(c, "Invalid loan ID")
 		return
 	}
 
-	var req InvestLoanRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	// Get uploaded file
+	file, header, err := c.Request.FormFile("proof_picture")
+	if err != nil {
+		h.respondBadRequest(c, "proof_picture file is required")
 		return
 	}
+	defer file.Close()
 
-	// Convert to domain parameters
-	params := entity.InvestLoanParams{
-		InvestorEmail: req.InvestorEmail,
-		Amount:        req.Amount,
+	// Validate file
+	imageExts := []string{".jpg", ".jpeg", ".png"}
+	if err := h.validateUploadedFile(file, header, imageExts, "proof picture", h.uploadLimits.MaxImageSize); err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
 	}
 
-	investment, err := h.loanUsecase.InvestInLoan(c.Request.Context(), loanID, params)
+	// Save uploaded file
+	proofPictureURL, err := h.saveUploadedFile(c.Request.Context(), file, header, loanID, "proof_pictures", "proof")
 	if err != nil {
-		if err.Error() == "loan not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.respondInternalError(c, "Failed to save proof picture")
+		return
+	}
+
+	loan, previous, err := h.loanUsecase.ReplaceApprovalProof(c.Request.Context(), loanID, proofPictureURL)
+	if err != nil {
+		// Don't keep a file no loan refers to
+		h.deleteStoredFile(c.Request.Context(), proofPictureURL)
+		h.respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, h.toInvestmentResponse(investment))
+	// Remove the replaced file, unless the new upload was saved over it
+	if previous != "" {
+		if previousURL := h.fileURL("proof_pictures", previous); previousURL != proofPictureURL {
+			h.deleteStoredFile(c.Request.Context(), previousURL)
+		}
+	}
+
+	c.JSON(http.StatusOK, h.toLoanResponse(loan))
 }
 
-// DisburseLoan handles POST /api/loans/:id/disburse (multipart/form-data)
-func (h *LoanHandler) DisburseLoan(c *gin.Context) {
+// RejectLoan handles POST /api/loans/:id/reject (multipart/form-data)
+func (h *LoanHandler) RejectLoan(c *gin.Context) {
 	loanIDStr := c.Param("id")
 	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		h.respondBadRequest(c, "Invalid loan ID")
 		return
 	}
 
 	// Get form fields
-	employeeID := c.PostForm("employee_id")
-	disbursementDate := c.PostForm("disbursement_date")
-
-	// Get uploaded file
-	file, header, err := c.Request.FormFile("signed_agreement_doc")
+	employeeID, err := h.employeeID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "signed_agreement_doc file is required"})
+		h.respondForbidden(c, err.Error())
 		return
 	}
-	defer file.Close()
+	reason := strings.TrimSpace(c.PostForm("reason"))
+	rejectionDate := c.PostForm("rejection_date")
 
-	// Validate file
-	docExts := []string{".pdf", ".jpg", ".jpeg", ".png"}
-	if err := h.validateUploadedFile(header, docExts, "signed agreement"); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if reason == "" {
+		h.respondBadRequest(c, "rejection reason is required")
 		return
 	}
 
 	// Validate form fields
-	parseDisbursementDate, err := h.validateEmployeeIDAndDateFormat(employeeID, disbursementDate)
+	parsedRejectionDate, err := h.validateEmployeeIDAndDateFormat(employeeID, rejectionDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.respondBadRequest(c, err.Error())
 		return
 	}
 
-	// Save uploaded file
-	signedAgreementPath, err := h.saveUploadedFile(file, header, loanID, "signed_agreements", "agreement")
+	// Convert to domain parameters
+	params := entity.RejectLoanParams{
+		Reason:        reason,
+		EmployeeID:    employeeID,
+		RejectionDate: parsedRejectionDate,
+	}
+
+	loan, err := h.loanUsecase.RejectLoan(c.Request.Context(), loanID, params)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.toLoanResponse(loan))
+}
+
+// CancelLoan handles POST /api/loans/:id/cancel (multipart/form-data)
+func (h *LoanHandler) CancelLoan(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save signed agreement document"})
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
+	}
+
+	// Get form fields
+	employeeID, err := h.employeeID(c)
+	if err != nil {
+		h.respondForbidden(c, err.Error())
+		return
+	}
+	reason := strings.TrimSpace(c.PostForm("reason"))
+	force := c.PostForm("force") == "true"
+
+	if reason == "" {
+		h.respondBadRequest(c, "cancellation reason is required")
+		return
+	}
+
+	// Validate form fields
+	if err := h.validateEmployeeID(employeeID); err != nil {
+		h.respondBadRequest(c, err.Error())
 		return
 	}
 
 	// Convert to domain parameters
-	params := entity.DisburseLoanParams{
-		SignedAgreementDoc: signedAgreementPath,
-		EmployeeID:         employeeID,
-		DisbursementDate:   parseDisbursementDate,
+	params := entity.CancelLoanParams{
+		Reason:     reason,
+		EmployeeID: employeeID,
+		Force:      force,
 	}
 
-	loan, err := h.loanUsecase.DisburseLoan(c.Request.Context(), loanID, params)
+	loan, err := h.loanUsecase.CancelLoan(c.Request.Context(), loanID, params)
 	if err != nil {
-		if err.Error() == "loan not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, h.toLoanResponse(loan))
 }
 
-// GetLoan handles GET /api/loans/:id
-func (h *LoanHandler) GetLoan(c *gin.Context) {
+// ForceInvested handles POST /api/loans/:id/force-invested
+func (h *LoanHandler) ForceInvested(c *gin.Context) {
 	loanIDStr := c.Param("id")
 	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		h.respondBadRequest(c, "Invalid loan ID")
 		return
 	}
 
-	summary, err := h.loanUsecase.GetLoan(c.Request.Context(), loanID)
+	employeeID, err := h.employeeID(c)
 	if err != nil {
-		if err.Error() == "loan not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondForbidden(c, err.Error())
+		return
+	}
+	if err := h.validateEmployeeID(employeeID); err != nil {
+		h.respondBadRequest(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, h.toLoanSummaryResponse(summary))
+	loan, err := h.loanUsecase.ForceInvested(c.Request.Context(), loanID, employeeID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.toLoanResponse(loan))
 }
 
-// ListLoans handles GET /api/loans
-func (h *LoanHandler) ListLoans(c *gin.Context) {
-	filter := repository.LoanFilter{}
+// InvestInLoan handles POST /api/loans/:id/invest
+func (h *LoanHandler) InvestInLoan(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
+	}
 
-	// Parse query parameters
-	if state := c.Query("state"); state != "" {
-		loanState := entity.LoanState(state)
-		filter.State = &loanState
+	var req InvestLoanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
 	}
 
-	if borrowerID := c.Query("borrower_id"); borrowerID != "" {
-		filter.BorrowerID = &borrowerID
+	// Convert to domain parameters
+	params := entity.InvestLoanParams{
+		InvestorEmail:  req.InvestorEmail,
+		Amount:         req.Amount,
+		Currency:       strings.ToUpper(req.Currency),
+		IdempotencyKey: c.GetHeader("Idempotency-Key"),
+		Language:       req.Language,
+		AllowPartial:   req.AllowPartial,
 	}
 
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
-			filter.Limit = &limit
-		}
+	investment, replayed, err := h.loanUsecase.InvestInLoan(c.Request.Context(), loanID, params)
+	if err != nil {
+		h.respondError(c, err)
+		return
 	}
 
-	if offsetStr := c.Query("offset"); offsetStr != "" {
-		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
-			filter.Offset = &offset
-		}
+	response := h.toInvestmentResponse(investment)
+	if req.AllowPartial {
+		requestedAmount := entity.NewMoney(req.Amount).Float64()
+		response.RequestedAmount = &requestedAmount
 	}
 
-	loans, err := h.loanUsecase.ListLoans(c.Request.Context(), filter)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// A replayed request returns the original investment without creating a new one
+	if replayed {
+		c.JSON(http.StatusOK, response)
 		return
 	}
 
-	// Convert to response DTOs
-	var loanResponses []*LoanResponse
-	for _, loan := range loans {
-		loanResponses = append(loanResponses, h.toLoanResponse(loan))
+	c.JSON(http.StatusCreated, response)
+}
+
+// BulkInvest handles POST /api/investments/bulk
+func (h *LoanHandler) BulkInvest(c *gin.Context) {
+	var req BulkInvestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"loans": loanResponses,
-		"count": len(loanResponses),
-	})
+	// Convert to domain parameters
+	params := entity.BulkInvestParams{AllowPartial: req.AllowPartial}
+	for _, item := range req.Items {
+		params.Items = append(params.Items, entity.BulkInvestmentItem{
+			LoanID:        item.LoanID,
+			InvestorEmail: item.InvestorEmail,
+			Amount:        item.Amount,
+			Currency:      strings.ToUpper(item.Currency),
+			Language:      item.Language,
+		})
+	}
+
+	results, err := h.loanUsecase.BulkInvest(c.Request.Context(), params)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	response := h.toBulkInvestmentResponse(results)
+	switch {
+	case response.Failed == 0:
+		c.JSON(http.StatusCreated, response)
+	case req.AllowPartial:
+		c.JSON(http.StatusOK, response)
+	default:
+		// The batch was rolled back, nothing was saved
+		c.JSON(http.StatusUnprocessableEntity, response)
+	}
 }
 
-// File handling and validation methods
-func (h *LoanHandler) validateUploadedFile(header *multipart.FileHeader, allowedExts []string, fileType string) error {
-	// Check file size (5MB max)
-	if header.Size > 5*1024*1024 {
-		return fmt.Errorf("%s file size must not exceed 5MB", fileType)
+// WithdrawInvestment handles DELETE /api/loans/:id/investments/:investment_id
+func (h *LoanHandler) WithdrawInvestment(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
 	}
 
-	// Check file extension
-	ext := strings.ToLower(filepath.Ext(header.Filename))
+	investmentIDStr := c.Param("investment_id")
+	investmentID, err := strconv.ParseInt(investmentIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid investment ID")
+		return
+	}
 
-	for _, allowedExt := range allowedExts {
-		if ext == allowedExt {
-			return nil
-		}
+	summary, err := h.loanUsecase.WithdrawInvestment(c.Request.Context(), loanID, investmentID)
+	if err != nil {
+		h.respondError(c, err)
+		return
 	}
 
-	// Build allowed extensions string for error message
-	extString := strings.Join(allowedExts, ", ")
-	return fmt.Errorf("%s must be one of the following file types: %s", fileType, extString)
+	c.JSON(http.StatusOK, h.toLoanSummaryResponse(summary))
 }
 
-func (h *LoanHandler) validateEmployeeIDAndDateFormat(employeeID, dateField string) (time.Time, error) {
-	var date time.Time
+// ExpireLoan handles POST /api/loans/:id/expire
+func (h *LoanHandler) ExpireLoan(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
+	}
 
-	if len(employeeID) < 3 {
-		return date, errors.New("employee ID must be at least 3 characters")
+	employeeID, err := h.employeeID(c)
+	if err != nil {
+		h.respondForbidden(c, err.Error())
+		return
+	}
+	if err := h.validateEmployeeID(employeeID); err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
 	}
 
-	// Validate date format (YYYY-MM-DD HH:MM:SS)
-	parsedDate, err := time.Parse("2006-01-02 15:04:05", dateField)
+	loan, err := h.loanUsecase.ExpireLoan(c.Request.Context(), loanID, employeeID)
 	if err != nil {
-		return date, errors.New("date must be in YYYY-MM-DD HH:MM:SS format (e.g., 2023-12-25 10:30:00)")
+		h.respondError(c, err)
+		return
 	}
 
-	return parsedDate, nil
+	c.JSON(http.StatusOK, h.toLoanResponse(loan))
 }
 
-func (h *LoanHandler) saveUploadedFile(file multipart.File, header *multipart.FileHeader, loanID int64, subdirectory, filePrefix string) (string, error) {
-	// Generate unique filename
-	ext := filepath.Ext(header.Filename)
-	filename := fmt.Sprintf("loan_%d_%s_%d%s", loanID, filePrefix, time.Now().Unix(), ext)
-	filePath := filepath.Join("uploads", subdirectory, filename)
-
-	// Create the file
-	dst, err := os.Create(filePath)
+// ResendInvestedNotification handles POST /api/loans/:id/notify
+func (h *LoanHandler) ResendInvestedNotification(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
 	if err != nil {
-		return "", err
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
 	}
-	defer dst.Close()
 
-	// Copy file content
-	_, err = io.Copy(dst, file)
+	recipients, err := h.loanUsecase.ResendInvestedNotification(c.Request.Context(), loanID)
 	if err != nil {
-		return "", err
+		h.respondError(c, err)
+		return
 	}
 
-	return filePath, nil
+	// Emails are queued for the background workers, not sent yet
+	c.JSON(http.StatusAccepted, &NotificationResponse{
+		LoanID:     loanID,
+		Recipients: recipients,
+	})
+}
+
+// MaxSignedAgreementDocs is the number of signed documents one disbursement accepts
+const MaxSignedAgreementDocs = 10
+
+// DisburseLoan handles POST /api/loans/:id/disburse (multipart/form-data)
+// Signed documents are uploaded as signed_agreement_docs[], or as a single
+// signed_agreement_doc; the first one is the tranche's signed agreement.
+func (h *LoanHandler) DisburseLoan(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
+	}
+
+	// Get form fields
+	employeeID, err := h.employeeID(c)
+	if err != nil {
+		h.respondForbidden(c, err.Error())
+		return
+	}
+	disbursementDate := c.PostForm("disbursement_date")
+
+	// Optional tranche amount, the remaining principal is disbursed without it
+	var amount *entity.Money
+	if amountStr := c.PostForm("amount"); amountStr != "" {
+		parsed, err := strconv.ParseFloat(amountStr, 64)
+		if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) || parsed <= 0 {
+			h.respondBadRequest(c, "amount must be a number greater than zero")
+			return
+		}
+		amount = entity.NewMoneyPtr(&parsed)
+	}
+
+	// Get uploaded files
+	var headers []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		headers = append(headers, form.File["signed_agreement_doc"]...)
+		headers = append(headers, form.File["signed_agreement_docs[]"]...)
+	}
+	if len(headers) == 0 {
+		h.respondBadRequest(c, "signed_agreement_docs[] or signed_agreement_doc file is required")
+		return
+	}
+	if len(headers) > MaxSignedAgreementDocs {
+		h.respondBadRequest(c, fmt.Sprintf("at most %d signed agreement documents can be uploaded", MaxSignedAgreementDocs))
+		return
+	}
+
+	files := make([]multipart.File, 0, len(headers))
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	// Validate files
+	docExts := []string{".pdf", ".jpg", ".jpeg", ".png"}
+	for _, header := range headers {
+		file, err := header.Open()
+		if err != nil {
+			h.respondBadRequest(c, "failed to read signed agreement file")
+			return
+		}
+		files = append(files, file)
+
+		if err := h.validateUploadedFile(file, header, docExts, "signed agreement", h.uploadLimits.MaxDocumentSize); err != nil {
+			h.respondBadRequest(c, err.Error())
+			return
+		}
+	}
+
+	// Validate form fields
+	parseDisbursementDate, err := h.validateEmployeeIDAndDateFormat(employeeID, disbursementDate)
+	if err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
+	}
+
+	// Save uploaded files, removing the saved ones if any fails
+	signedAgreementURLs := make([]string, 0, len(files))
+	for i, file := range files {
+		signedAgreementURL, err := h.saveUploadedFile(c.Request.Context(), file, headers[i], loanID, "signed_agreements", "agreement")
+		if err != nil {
+			h.deleteStoredFiles(c.Request.Context(), signedAgreementURLs)
+			h.respondInternalError(c, "Failed to save signed agreement document")
+			return
+		}
+		signedAgreementURLs = append(signedAgreementURLs, signedAgreementURL)
+	}
+
+	// Convert to domain parameters
+	params := entity.DisburseLoanParams{
+		SignedAgreementDocs: signedAgreementURLs,
+		EmployeeID:          employeeID,
+		DisbursementDate:    parseDisbursementDate,
+		Amount:              amount,
+	}
+
+	loan, err := h.loanUsecase.DisburseLoan(c.Request.Context(), loanID, params)
+	if err != nil {
+		// Don't keep files no tranche refers to
+		h.deleteStoredFiles(c.Request.Context(), signedAgreementURLs)
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.toLoanResponse(loan))
+}
+
+// DeleteLoan handles DELETE /api/loans/:id
+func (h *LoanHandler) DeleteLoan(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
+	}
+
+	if err := h.loanUsecase.DeleteLoan(c.Request.Context(), loanID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetLoan handles GET /api/loans/:id
+func (h *LoanHandler) GetLoan(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
+	}
+
+	includeDeleted := c.Query("include_deleted") == "true"
+	summary, err := h.loanUsecase.GetLoan(c.Request.Context(), loanID, includeDeleted)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	// Let polling clients skip the body when nothing changed since their last
+	// read. The 304 carries the same Vary: Accept as the 200 it stands for.
+	format := negotiateFormat(c)
+	etag := loanETag(summary, format)
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	respondInFormat(c, format, http.StatusOK, h.toLoanSummaryResponse(summary))
+}
+
+// loanETag identifies the version of a loan summary in the given format. Every
+// change to the loan moves UpdatedAt, while investments only add to the count
+// and the total invested, so all of them are needed. The format keeps the JSON
+// and XML representations from sharing an ETag.
+func loanETag(summary *usecase.LoanSummary, format string) string {
+	representation := "json"
+	if format == binding.MIMEXML {
+		representation = "xml"
+	}
+	return fmt.Sprintf(`"%d-%d-%d-%d-%s"`, summary.Loan.ID, summary.Loan.UpdatedAt.UnixNano(),
+		summary.InvestmentCount, int64(summary.TotalInvested), representation)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing
+// weakly as RFC 9110 requires for GET
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// GetInvestorReturns handles GET /api/loans/:id/returns
+func (h *LoanHandler) GetInvestorReturns(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
+	}
+
+	returns, err := h.loanUsecase.GetInvestorReturns(c.Request.Context(), loanID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.toLoanReturnsResponse(returns))
+}
+
+// GetLoanFunding handles GET /api/loans/:id/funding
+func (h *LoanHandler) GetLoanFunding(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
+	}
+
+	funding, err := h.loanUsecase.GetLoanFunding(c.Request.Context(), loanID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.toLoanFundingResponse(funding))
+}
+
+// GetLoanHistory handles GET /api/loans/:id/history
+func (h *LoanHandler) GetLoanHistory(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
+	}
+
+	filter := repository.StateTransitionFilter{}
+
+	// Parse query parameters
+	if fromState := c.Query("from_state"); fromState != "" {
+		state := entity.LoanState(fromState)
+		filter.FromState = &state
+	}
+
+	if toState := c.Query("to_state"); toState != "" {
+		state := entity.LoanState(toState)
+		filter.ToState = &state
+	}
+
+	// Newest first by default, sort=asc for the order the transitions happened
+	switch c.Query("sort") {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		h.respondBadRequest(c, "sort must be asc or desc")
+		return
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
+			filter.Limit = &limit
+		}
+	}
+
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
+			filter.Offset = &offset
+		}
+	}
+
+	page, err := h.loanUsecase.GetLoanHistory(c.Request.Context(), loanID, filter)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	// Convert to response DTOs
+	transitionResponses := make([]*StateTransitionResponse, 0, len(page.Transitions))
+	for _, transition := range page.Transitions {
+		transitionResponses = append(transitionResponses, h.toStateTransitionResponse(transition))
+	}
+
+	c.JSON(http.StatusOK, &StateTransitionListResponse{
+		Transitions: transitionResponses,
+		Count:       len(transitionResponses),
+		Total:       page.Total,
+	})
+}
+
+// GetLoanActions handles GET /api/loans/:id/actions
+func (h *LoanHandler) GetLoanActions(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
+	}
+
+	loan, actions, err := h.loanUsecase.GetLoanActions(c.Request.Context(), loanID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	actionNames := make([]string, 0, len(actions))
+	for _, action := range actions {
+		actionNames = append(actionNames, string(action))
+	}
+
+	c.JSON(http.StatusOK, &LoanActionsResponse{
+		LoanID:  loan.ID,
+		State:   string(loan.State),
+		Actions: actionNames,
+	})
+}
+
+// ListDisbursements handles GET /api/loans/:id/disbursements
+func (h *LoanHandler) ListDisbursements(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
+	}
+
+	disbursements, err := h.loanUsecase.ListDisbursements(c.Request.Context(), loanID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	// Convert to response DTOs
+	var totalDisbursed entity.Money
+	disbursementResponses := make([]*DisbursementResponse, 0, len(disbursements))
+	for _, disbursement := range disbursements {
+		totalDisbursed += disbursement.Amount
+		disbursementResponses = append(disbursementResponses, h.toDisbursementResponse(disbursement))
+	}
+
+	c.JSON(http.StatusOK, &DisbursementListResponse{
+		Disbursements:  disbursementResponses,
+		Count:          len(disbursementResponses),
+		TotalDisbursed: totalDisbursed.Float64(),
+	})
+}
+
+// ListInvestments handles GET /api/loans/:id/investments
+func (h *LoanHandler) ListInvestments(c *gin.Context) {
+	loanIDStr := c.Param("id")
+	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid loan ID")
+		return
+	}
+
+	filter := repository.InvestmentFilter{}
+
+	// Parse query parameters
+	if investorEmail := c.Query("investor_email"); investorEmail != "" {
+		filter.InvestorEmail = &investorEmail
+	}
+
+	if err := h.parseInvestmentRanges(c, &filter); err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
+	}
+
+	if err := h.parseInvestmentPagination(c, &filter); err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
+	}
+
+	page, err := h.loanUsecase.ListInvestments(c.Request.Context(), loanID, filter)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	// Convert to response DTOs
+	investmentResponses := make([]*InvestmentResponse, 0, len(page.Investments))
+	for _, investment := range page.Investments {
+		investmentResponses = append(investmentResponses, h.toInvestmentResponse(investment))
+	}
+
+	c.JSON(http.StatusOK, &InvestmentListResponse{
+		Investments: investmentResponses,
+		Count:       len(investmentResponses),
+		Total:       page.Total,
+		NextCursor:  nextCursor(page),
+	})
+}
+
+// GetInvestmentReceipt handles GET /api/investments/:id/receipt
+func (h *LoanHandler) GetInvestmentReceipt(c *gin.Context) {
+	investmentIDStr := c.Param("id")
+	investmentID, err := strconv.ParseInt(investmentIDStr, 10, 64)
+	if err != nil {
+		h.respondBadRequest(c, "Invalid investment ID")
+		return
+	}
+
+	receipt, err := h.loanUsecase.GetInvestmentReceipt(c.Request.Context(), investmentID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"investment-%d-receipt.pdf\"", investmentID))
+	c.Data(http.StatusOK, "application/pdf", receipt)
+}
+
+// GetInvestorPortfolio handles GET /api/investors/:email/portfolio
+func (h *LoanHandler) GetInvestorPortfolio(c *gin.Context) {
+	investorEmail := c.Param("email")
+	if _, err := mail.ParseAddress(investorEmail); err != nil {
+		h.respondBadRequest(c, "Invalid investor email")
+		return
+	}
+
+	filter := repository.InvestmentFilter{}
+	if err := h.parseInvestmentPagination(c, &filter); err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
+	}
+
+	portfolio, err := h.loanUsecase.GetInvestorPortfolio(c.Request.Context(), investorEmail, filter)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.toInvestorPortfolioResponse(portfolio))
+}
+
+// ListAllInvestments handles GET /api/admin/investments
+func (h *LoanHandler) ListAllInvestments(c *gin.Context) {
+	filter := repository.InvestmentFilter{}
+
+	// Parse query parameters
+	if investorEmail := c.Query("investor_email"); investorEmail != "" {
+		filter.InvestorEmail = &investorEmail
+	}
+
+	if loanIDStr := c.Query("loan_id"); loanIDStr != "" {
+		loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
+		if err != nil {
+			h.respondBadRequest(c, "Invalid loan ID")
+			return
+		}
+		filter.LoanID = &loanID
+	}
+
+	if err := h.parseInvestmentRanges(c, &filter); err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
+	}
+
+	if err := h.parseInvestmentPagination(c, &filter); err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
+	}
+
+	ledger, err := h.loanUsecase.ListAllInvestments(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	// Convert to response DTOs
+	investmentResponses := make([]*InvestmentResponse, 0, len(ledger.Page.Investments))
+	for _, investment := range ledger.Page.Investments {
+		response := h.toInvestmentResponse(investment)
+		response.LoanState = string(ledger.LoanStates[investment.ID])
+		investmentResponses = append(investmentResponses, response)
+	}
+
+	c.JSON(http.StatusOK, &InvestmentListResponse{
+		Investments: investmentResponses,
+		Count:       len(investmentResponses),
+		Total:       ledger.Page.Total,
+		NextCursor:  nextCursor(ledger.Page),
+	})
+}
+
+// parseInvestmentRanges reads the inclusive created_after/created_before and
+// min_amount/max_amount bounds into the filter
+func (h *LoanHandler) parseInvestmentRanges(c *gin.Context, filter *repository.InvestmentFilter) error {
+	var err error
+	if filter.CreatedAfter, err = h.parseTimeQuery(c, "created_after"); err != nil {
+		return err
+	}
+	if filter.CreatedBefore, err = h.parseTimeQuery(c, "created_before"); err != nil {
+		return err
+	}
+
+	if filter.MinAmount, err = h.parseAmountQuery(c, "min_amount"); err != nil {
+		return err
+	}
+	if filter.MaxAmount, err = h.parseAmountQuery(c, "max_amount"); err != nil {
+		return err
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return errors.New("min_amount must not exceed max_amount")
+	}
+	return nil
+}
+
+// parseInvestmentPagination reads limit, offset and cursor into the filter.
+// Cursor pagination is used unless the client falls back to an offset. The
+// limit defaults to and is capped by the handler's page limits.
+func (h *LoanHandler) parseInvestmentPagination(c *gin.Context, filter *repository.InvestmentFilter) error {
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
+			filter.Limit = &limit
+		}
+	}
+
+	filter.Limit = h.pageLimits.clamp(filter.Limit)
+
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
+			filter.Offset = &offset
+		}
+	}
+
+	if filter.Offset != nil {
+		if c.Query("cursor") != "" {
+			return errors.New("cursor and offset cannot be combined")
+		}
+		return nil
+	}
+
+	afterID, err := decodeCursor(c.Query("cursor"))
+	if err != nil {
+		return err
+	}
+	filter.AfterID = &afterID
+	return nil
+}
+
+// nextCursor returns the cursor of the page after this one, or nil on the last page
+func nextCursor(page *usecase.InvestmentPage) *string {
+	if page.NextAfterID == nil {
+		return nil
+	}
+	cursor := encodeCursor(*page.NextAfterID)
+	return &cursor
+}
+
+// encodeCursor turns the last investment ID of a page into an opaque cursor
+func encodeCursor(afterID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(afterID, 10)))
+}
+
+// decodeCursor reads a cursor from encodeCursor; an empty cursor starts at the first page
+func decodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	afterID, err := strconv.ParseInt(string(decoded), 10, 64)
+	if err != nil || afterID < 0 {
+		return 0, errors.New("invalid cursor")
+	}
+
+	return afterID, nil
+}
+
+// BatchGetLoans handles POST /api/loans/batch-get
+func (h *LoanHandler) BatchGetLoans(c *gin.Context) {
+	var req BatchGetLoansRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
+	}
+
+	loans, err := h.loanUsecase.GetLoansByIDs(c.Request.Context(), req.IDs)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	response := LoanBatchResponse{
+		Loans:      make([]*LoanResponse, len(loans)),
+		MissingIDs: []int64{},
+	}
+	for i, loan := range loans {
+		if loan == nil {
+			response.MissingIDs = append(response.MissingIDs, req.IDs[i])
+			continue
+		}
+		response.Loans[i] = h.toLoanResponse(loan)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ListLoans handles GET /api/loans
+func (h *LoanHandler) ListLoans(c *gin.Context) {
+	filter, err := h.parseLoanFilter(c)
+	if err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
+	}
+	filter.Limit = h.pageLimits.clamp(filter.Limit)
+
+	loans, err := h.loanUsecase.ListLoans(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	// Convert to response DTOs
+	var loanResponses []*LoanResponse
+	for _, loan := range loans {
+		loanResponses = append(loanResponses, h.toLoanResponse(loan))
+	}
+
+	respondNegotiated(c, http.StatusOK, &LoanListResponse{
+		Loans: loanResponses,
+		Count: len(loanResponses),
+	})
+}
+
+// DefaultSearchLimit is the number of loans a search returns when no limit is given
+const DefaultSearchLimit = 20
+
+// SearchLoans handles GET /api/search
+func (h *LoanHandler) SearchLoans(c *gin.Context) {
+	search := strings.TrimSpace(c.Query("q"))
+	if search == "" {
+		h.respondBadRequest(c, "q is required")
+		return
+	}
+
+	filter := repository.LoanFilter{Search: &search}
+
+	limit := DefaultSearchLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = min(parsed, h.pageLimits.MaxSize)
+		}
+	}
+	filter.Limit = &limit
+
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
+			filter.Offset = &offset
+		}
+	}
+
+	loans, err := h.loanUsecase.ListLoans(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	// Convert to response DTOs
+	loanResponses := make([]*LoanResponse, 0, len(loans))
+	for _, loan := range loans {
+		loanResponses = append(loanResponses, h.toLoanResponse(loan))
+	}
+
+	c.JSON(http.StatusOK, &LoanListResponse{
+		Loans: loanResponses,
+		Count: len(loanResponses),
+	})
+}
+
+// ExportLoans handles GET /api/loans/export, streaming the loans as CSV
+func (h *LoanHandler) ExportLoans(c *gin.Context) {
+	filter, err := h.parseLoanFilter(c)
+	if err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
+	}
+
+	writer := csv.NewWriter(c.Writer)
+	started := false
+
+	// Headers are written with the first row so a failing query can still
+	// be reported as a JSON error
+	start := func() error {
+		started = true
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", `attachment; filename="loans.csv"`)
+		c.Status(http.StatusOK)
+		return writer.Write(loanCSVHeader)
+	}
+
+	err = h.loanUsecase.ExportLoans(c.Request.Context(), filter, func(loan *entity.Loan) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		// The csv writer buffers a few rows at a time, so memory stays flat
+		return writer.Write(h.toLoanCSVRecord(loan))
+	})
+	if err != nil {
+		if !started {
+			h.respondError(c, err)
+			return
+		}
+		// The status has already been sent, so the export can only be cut short
+		slog.ErrorContext(c.Request.Context(), "failed to export loans", "error", err)
+		c.Abort()
+		return
+	}
+
+	if !started {
+		if err := start(); err != nil {
+			slog.ErrorContext(c.Request.Context(), "failed to export loans", "error", err)
+			return
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to export loans", "error", err)
+	}
+}
+
+// parseLoanFilter reads the loan list query parameters shared by ListLoans and ExportLoans
+func (h *LoanHandler) parseLoanFilter(c *gin.Context) (repository.LoanFilter, error) {
+	filter := repository.LoanFilter{}
+
+	// Parse query parameters
+	if state := c.Query("state"); state != "" {
+		loanState := entity.LoanState(state)
+		filter.State = &loanState
+	}
+
+	if borrowerID := c.Query("borrower_id"); borrowerID != "" {
+		filter.BorrowerID = &borrowerID
+	}
+
+	if fundingStatus := c.Query("funding_status"); fundingStatus != "" {
+		status := entity.FundingStatus(fundingStatus)
+		filter.FundingStatus = &status
+	}
+
+	filter.IncludeDeleted = c.Query("include_deleted") == "true"
+
+	var err error
+	if filter.CreatedAfter, err = h.parseTimeQuery(c, "created_after"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = h.parseTimeQuery(c, "created_before"); err != nil {
+		return filter, err
+	}
+
+	if filter.MinPrincipal, err = h.parseAmountQuery(c, "min_principal"); err != nil {
+		return filter, err
+	}
+	if filter.MaxPrincipal, err = h.parseAmountQuery(c, "max_principal"); err != nil {
+		return filter, err
+	}
+	if filter.MinPrincipal != nil && filter.MaxPrincipal != nil && *filter.MinPrincipal > *filter.MaxPrincipal {
+		return filter, errors.New("min_principal must not exceed max_principal")
+	}
+
+	// Sort by a field, prefixed with "-" for descending order (default -created_at)
+	if sort := c.Query("sort"); sort != "" {
+		filter.SortDesc = strings.HasPrefix(sort, "-")
+		filter.SortBy = strings.TrimPrefix(sort, "-")
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
+			filter.Limit = &limit
+		}
+	}
+
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
+			filter.Offset = &offset
+		}
+	}
+
+	return filter, nil
+}
+
+// GetStats handles GET /api/stats
+func (h *LoanHandler) GetStats(c *gin.Context) {
+	filter := repository.StatsFilter{}
+
+	var err error
+	if filter.CreatedAfter, err = h.parseTimeQuery(c, "created_after"); err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
+	}
+	if filter.CreatedBefore, err = h.parseTimeQuery(c, "created_before"); err != nil {
+		h.respondBadRequest(c, err.Error())
+		return
+	}
+
+	stats, err := h.loanUsecase.GetStats(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.toLoanStatsResponse(stats))
+}
+
+// GetROISummary handles GET /api/admin/roi-summary
+func (h *LoanHandler) GetROISummary(c *gin.Context) {
+	summary, err := h.loanUsecase.GetROISummary(c.Request.Context())
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.toROISummaryResponse(summary))
+}
+
+// GetBorrowerExposure handles GET /api/borrowers/:id/exposure
+func (h *LoanHandler) GetBorrowerExposure(c *gin.Context) {
+	exposure, err := h.loanUsecase.GetBorrowerExposure(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.toBorrowerExposureResponse(exposure))
+}
+
+// parseTimeQuery parses an optional RFC3339 query parameter, returning nil when it is absent
+func (h *LoanHandler) parseTimeQuery(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC3339 timestamp (e.g., 2023-12-25T10:30:00Z)", name)
+	}
+	return &parsed, nil
+}
+
+// parseAmountQuery parses an optional non-negative amount query parameter, returning nil when it is absent
+func (h *LoanHandler) parseAmountQuery(c *gin.Context, name string) (*entity.Money, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+		return nil, fmt.Errorf("%s must be a number", name)
+	}
+	if parsed < 0 {
+		return nil, fmt.Errorf("%s must not be negative", name)
+	}
+	return entity.NewMoneyPtr(&parsed), nil
+}
+
+// extensionContentTypes maps each accepted upload extension to the MIME type
+// http.DetectContentType reports for genuine files of that kind
+var extensionContentTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".pdf":  "application/pdf",
+}
+
+// File handling and validation methods
+func (h *LoanHandler) validateUploadedFile(file multipart.File, header *multipart.FileHeader, allowedExts []string, fileType string, maxSize int64) error {
+	// Check file size
+	if header.Size > maxSize {
+		return fmt.Errorf("%s file size must not exceed %s", fileType, formatSize(maxSize))
+	}
+
+	// Check file extension
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+
+	for _, allowedExt := range allowedExts {
+		if ext == allowedExt {
+			return h.validateContentType(file, ext, fileType)
+		}
+	}
+
+	// Build allowed extensions string for error message
+	extString := strings.Join(allowedExts, ", ")
+	return fmt.Errorf("%s must be one of the following file types: %s", fileType, extString)
+}
+
+// validateContentType sniffs the first 512 bytes of the file and checks they match the
+// declared extension, then rewinds the file so it can still be saved in full
+func (h *LoanHandler) validateContentType(file multipart.File, ext, fileType string) error {
+	buffer := make([]byte, 512)
+	n, err := io.ReadFull(file, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("failed to read %s file", fileType)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read %s file", fileType)
+	}
+
+	detected := http.DetectContentType(buffer[:n])
+	expected := extensionContentTypes[ext]
+	if expected == "" || !strings.HasPrefix(detected, expected) {
+		return fmt.Errorf("%s content (%s) does not match its %s extension", fileType, detected, ext)
+	}
+
+	return nil
+}
+
+// employeeID returns the authenticated caller's employee ID. The employee_id form
+// field is optional but must match the token, so the four-eyes check on
+// disbursement cannot be bypassed by naming another employee.
+func (h *LoanHandler) employeeID(c *gin.Context) (string, error) {
+	authenticated := c.GetString(ContextEmployeeID)
+	employeeID := c.PostForm("employee_id")
+	if employeeID == "" {
+		return authenticated, nil
+	}
+	if employeeID != authenticated {
+		return "", errors.New("employee_id does not match the authenticated employee")
+	}
+	return employeeID, nil
+}
+
+func (h *LoanHandler) validateEmployeeID(employeeID string) error {
+	if len(employeeID) < 3 {
+		return errors.New("employee ID must be at least 3 characters")
+	}
+	return nil
+}
+
+func (h *LoanHandler) validateEmployeeIDAndDateFormat(employeeID, dateField string) (time.Time, error) {
+	var date time.Time
+
+	if err := h.validateEmployeeID(employeeID); err != nil {
+		return date, err
+	}
+
+	// Validate date format (YYYY-MM-DD HH:MM:SS)
+	parsedDate, err := time.Parse("2006-01-02 15:04:05", dateField)
+	if err != nil {
+		return date, errors.New("date must be in YYYY-MM-DD HH:MM:SS format (e.g., 2023-12-25 10:30:00)")
+	}
+
+	return parsedDate, nil
+}
+
+// deleteStoredFile removes a file that is no longer referenced. Failures only
+// leave an orphaned file behind, so they are logged rather than returned.
+func (h *LoanHandler) deleteStoredFile(ctx context.Context, fileURL string) {
+	if err := h.fileStorage.Delete(ctx, fileURL); err != nil {
+		slog.ErrorContext(ctx, "failed to delete stored file", "file_url", fileURL, "error", err)
+	}
+}
+
+// deleteStoredFiles removes several files that are no longer referenced
+func (h *LoanHandler) deleteStoredFiles(ctx context.Context, fileURLs []string) {
+	for _, fileURL := range fileURLs {
+		h.deleteStoredFile(ctx, fileURL)
+	}
+}
+
+// saveUploadedFile stores the file as <subdirectory>/<filename> in the file storage
+// and returns the URL of the stored file, which is persisted on the loan as-is.
+func (h *LoanHandler) saveUploadedFile(ctx context.Context, file multipart.File, header *multipart.FileHeader, loanID int64, subdirectory, filePrefix string) (string, error) {
+	// Generate unique filename
+	ext := filepath.Ext(header.Filename)
+	filename := fmt.Sprintf("loan_%d_%s_%d%s", loanID, filePrefix, time.Now().UnixNano(), ext)
+	key := subdirectory + "/" + filename
+
+	return h.fileStorage.Save(ctx, key, file, header.Header.Get("Content-Type"))
 }
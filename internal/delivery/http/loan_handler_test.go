@@ -0,0 +1,1683 @@
+package http
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"amartha-andreas/internal/infrastructure/database"
+	"amartha-andreas/internal/infrastructure/email"
+	"amartha-andreas/internal/infrastructure/fx"
+	"amartha-andreas/internal/infrastructure/metrics"
+	"amartha-andreas/internal/infrastructure/receipt"
+	"amartha-andreas/internal/infrastructure/storage"
+	"amartha-andreas/internal/infrastructure/webhook"
+	"amartha-andreas/internal/repository"
+	"amartha-andreas/internal/usecase"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// testJWTSecret signs the tokens of testToken
+var testJWTSecret = []byte("test-secret")
+
+// handlerEnv is the loan API routed through gin, backed by a fresh SQLite
+// database and local file storage
+type handlerEnv struct {
+	router    *gin.Engine
+	uc        usecase.LoanUsecase
+	uploadDir string
+}
+
+// handlerOptions configures the API built by newHandlerEnv
+type handlerOptions struct {
+	uploadLimits   UploadLimits
+	pageLimits     PageLimits
+	requestTimeout time.Duration // Routes requests through the timeout middleware when set
+}
+
+func newHandlerEnv(t *testing.T, opts handlerOptions) *handlerEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	db, err := database.NewDatabase(filepath.Join(dir, "test.db") + "?_txlock=immediate&_busy_timeout=5000&_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	prometheusMetrics := metrics.NewPrometheus(prometheus.NewRegistry())
+	uc := usecase.NewLoanUsecase(
+		repository.NewLoanRepository(db),
+		repository.NewInvestmentRepository(db),
+		repository.NewStateTransitionRepository(db),
+		repository.NewDisbursementRepository(db),
+		repository.NewLoanDocumentRepository(db),
+		repository.NewLoanApprovalRepository(db),
+		db,
+		email.NewMockEmailService(),
+		webhook.NewNoopNotifier(),
+		fx.NewStaticConverter(nil),
+		receipt.NewPDFGenerator(),
+		prometheusMetrics,
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		usecase.LoanUsecaseConfig{},
+	)
+
+	uploadDir := filepath.Join(dir, "uploads")
+	fileStorage := storage.NewLocalStorage(uploadDir, DefaultBaseFileURL)
+	handler := NewLoanHandler(uc, fileStorage, DefaultBaseFileURL, NewJWTAuthMiddleware(testJWTSecret), opts.uploadLimits, opts.pageLimits)
+
+	router := gin.New()
+	router.Use(NewMetricsMiddleware(prometheusMetrics))
+	if opts.requestTimeout > 0 {
+		router.Use(NewTimeoutMiddleware(opts.requestTimeout))
+	}
+	router.GET("/metrics", gin.WrapH(prometheusMetrics.Handler()))
+	handler.RegisterRoutes(router)
+
+	return &handlerEnv{router: router, uc: uc, uploadDir: uploadDir}
+}
+
+// testToken returns a bearer token for employeeID with role, signed with testJWTSecret
+func testToken(t *testing.T, employeeID, role string) string {
+	t.Helper()
+
+	claims := EmployeeClaims{
+		EmployeeID: employeeID,
+		Role:       role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testJWTSecret)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+// serve sends req through the router and returns the recorded response
+func (env *handlerEnv) serve(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+// createLoan creates a proposed loan of principal through the usecase
+func (env *handlerEnv) createLoan(t *testing.T, principal float64) *entity.Loan {
+	t.Helper()
+
+	loan, _, err := env.uc.CreateLoan(context.Background(), entity.CreateLoanParams{
+		BorrowerIDNumber:    "3171234567890123",
+		PrincipalAmount:     entity.NewMoney(principal),
+		Rate:                12,
+		ROI:                 10,
+		TermWeeks:           52,
+		AgreementLetterLink: "https://example.com/agreements/1.pdf",
+	})
+	if err != nil {
+		t.Fatalf("failed to create loan: %v", err)
+	}
+	return loan
+}
+
+// createApprovedLoan creates and approves a loan of principal through the usecase
+func (env *handlerEnv) createApprovedLoan(t *testing.T, principal float64) *entity.Loan {
+	t.Helper()
+
+	loan, err := env.uc.ApproveLoan(context.Background(), env.createLoan(t, principal).ID, entity.ApproveLoanParams{
+		ProofPicture: "uploads/proof_pictures/proof.jpg",
+		EmployeeID:   "EMP-APPROVER",
+		ApprovalDate: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("failed to approve loan: %v", err)
+	}
+	return loan
+}
+
+// invest invests amount in loanID on behalf of investorEmail through the usecase
+func (env *handlerEnv) invest(t *testing.T, loanID int64, investorEmail string, amount float64) *entity.Investment {
+	t.Helper()
+
+	investment, _, err := env.uc.InvestInLoan(context.Background(), loanID, entity.InvestLoanParams{
+		InvestorEmail: investorEmail,
+		Amount:        amount,
+	})
+	if err != nil {
+		t.Fatalf("failed to invest: %v", err)
+	}
+	return investment
+}
+
+// Minimal file contents that http.DetectContentType recognizes
+var (
+	testJPEG = append([]byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), make([]byte, 64)...)
+	testPNG  = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+	testPDF  = []byte("%PDF-1.4\n1 0 obj\n<<>>\nendobj\ntrailer\n<<>>\n%%EOF\n")
+)
+
+// testUpload returns content as an uploaded multipart file named filename
+func testUpload(t *testing.T, filename string, content []byte) (multipart.File, *multipart.FileHeader) {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(int64(len(content)) + 1024)
+	if err != nil {
+		t.Fatalf("failed to read form: %v", err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+
+	header := form.File["file"][0]
+	file, err := header.Open()
+	if err != nil {
+		t.Fatalf("failed to open uploaded file: %v", err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file, header
+}
+
+// formFile is a file field of a multipart request
+type formFile struct {
+	field    string
+	filename string
+	content  []byte
+}
+
+// multipartRequest builds a multipart/form-data request with the fields and
+// files, sending token as bearer token when it is set
+func multipartRequest(t *testing.T, method, path, token string, fields map[string]string, files ...formFile) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	for _, f := range files {
+		part, err := writer.CreateFormFile(f.field, f.filename)
+		if err != nil {
+			t.Fatalf("failed to create form file: %v", err)
+		}
+		part.Write(f.content)
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+// formNow formats the current time like the date fields of the forms
+func formNow() string {
+	return time.Now().Format("2006-01-02 15:04:05")
+}
+
+// approveRequest builds the request approving loanID with a JPEG proof
+func approveRequest(t *testing.T, loanID int64, token string) *http.Request {
+	t.Helper()
+	return multipartRequest(t, http.MethodPost, fmt.Sprintf("/api/loans/%d/approve", loanID), token,
+		map[string]string{"approval_date": formNow()},
+		formFile{"proof_picture", "proof.jpg", testJPEG})
+}
+
+// disburseRequest builds the request disbursing loanID with a PDF agreement
+func disburseRequest(t *testing.T, loanID int64, token string) *http.Request {
+	t.Helper()
+	return multipartRequest(t, http.MethodPost, fmt.Sprintf("/api/loans/%d/disburse", loanID), token,
+		map[string]string{"disbursement_date": formNow()},
+		formFile{"signed_agreement_doc", "agreement.pdf", testPDF})
+}
+
+// decodeJSON decodes a JSON response body into v
+func decodeJSON(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+	}
+}
+
+// decodeError decodes an error response body
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) ErrorResponse {
+	t.Helper()
+
+	var response ErrorResponse
+	decodeJSON(t, w, &response)
+	return response
+}
+
+func TestWithdrawInvestment(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+	other := env.createApprovedLoan(t, 1000)
+	first := env.invest(t, loan.ID, "alice@example.com", 400)
+	second := env.invest(t, loan.ID, "bob@example.com", 600)
+
+	tests := []struct {
+		name          string
+		loanID        int64
+		investmentID  int64
+		wantStatus    int
+		wantCode      string
+		wantState     entity.LoanState
+		wantRemaining float64
+	}{
+		{"investment of another loan", other.ID, first.ID, http.StatusNotFound, CodeInvestmentNotFound, "", 0},
+		{"fully invested loan reverts to approved", loan.ID, second.ID, http.StatusOK, "", entity.StateApproved, 600},
+		{"approved loan", loan.ID, first.ID, http.StatusOK, "", entity.StateApproved, 1000},
+		{"already withdrawn", loan.ID, first.ID, http.StatusNotFound, CodeInvestmentNotFound, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := fmt.Sprintf("/api/loans/%d/investments/%d", tt.loanID, tt.investmentID)
+			w := env.serve(httptest.NewRequest(http.MethodDelete, path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantCode != "" {
+				if code := decodeError(t, w).Code; code != tt.wantCode {
+					t.Errorf("got code %q, want %q", code, tt.wantCode)
+				}
+				return
+			}
+
+			var summary LoanSummaryResponse
+			decodeJSON(t, w, &summary)
+			if summary.Loan.State != string(tt.wantState) {
+				t.Errorf("got state %q, want %q", summary.Loan.State, tt.wantState)
+			}
+			if summary.RemainingAmount != tt.wantRemaining {
+				t.Errorf("got remaining amount %v, want %v", summary.RemainingAmount, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestListLoansRejectsInvalidCreationDates(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+
+	for _, query := range []string{"created_after=2024-01-01", "created_before=yesterday"} {
+		t.Run(query, func(t *testing.T) {
+			w := env.serve(httptest.NewRequest(http.MethodGet, "/api/loans?"+query, nil))
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("got status %d, want 400: %s", w.Code, w.Body.String())
+			}
+			if code := decodeError(t, w).Code; code != CodeInvalidRequest {
+				t.Errorf("got code %q, want %q", code, CodeInvalidRequest)
+			}
+		})
+	}
+}
+
+func TestListLoansSortsByPrincipal(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	for _, principal := range []float64{2000, 500, 1000} {
+		env.createLoan(t, principal)
+	}
+
+	tests := []struct {
+		sort string
+		want []float64
+	}{
+		{"principal_amount", []float64{500, 1000, 2000}},
+		{"-principal_amount", []float64{2000, 1000, 500}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			w := env.serve(httptest.NewRequest(http.MethodGet, "/api/loans?sort="+tt.sort, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+			}
+
+			var response struct {
+				Loans []*LoanResponse `json:"loans"`
+			}
+			decodeJSON(t, w, &response)
+			if len(response.Loans) != len(tt.want) {
+				t.Fatalf("got %d loans, want %d", len(response.Loans), len(tt.want))
+			}
+			for i, loan := range response.Loans {
+				if loan.PrincipalAmount != tt.want[i] {
+					t.Errorf("loan %d has principal %v, want %v", i, loan.PrincipalAmount, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestListLoansRejectsUnknownSortField(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+
+	w := env.serve(httptest.NewRequest(http.MethodGet, "/api/loans?sort=borrower_id_number%20DESC%2C%20rate", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d, want 400: %s", w.Code, w.Body.String())
+	}
+	if code := decodeError(t, w).Code; code != CodeValidation {
+		t.Errorf("got code %q, want %q", code, CodeValidation)
+	}
+}
+
+func TestValidateUploadedFileSniffsContent(t *testing.T) {
+	h := NewLoanHandler(nil, nil, "", nil, UploadLimits{}, PageLimits{})
+	allowed := []string{".jpg", ".jpeg", ".png", ".pdf"}
+
+	tests := []struct {
+		name     string
+		filename string
+		content  []byte
+		wantErr  string
+	}{
+		{"valid PNG", "proof.png", testPNG, ""},
+		{"valid PDF", "agreement.pdf", testPDF, ""},
+		{"valid JPEG", "proof.JPG", testJPEG, ""},
+		{"executable renamed to jpg", "proof.jpg", []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00"), "does not match its .jpg extension"},
+		{"PDF renamed to png", "proof.png", testPDF, "file content (application/pdf) does not match its .png extension"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, header := testUpload(t, tt.filename, tt.content)
+
+			err := h.validateUploadedFile(file, header, allowed, "file", DefaultMaxDocumentSize)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v, want none", err)
+			}
+
+			// The file must be rewound so it is saved in full
+			saved, err := io.ReadAll(file)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if !bytes.Equal(saved, tt.content) {
+				t.Errorf("read %d bytes after validation, want the full %d", len(saved), len(tt.content))
+			}
+		})
+	}
+}
+
+func TestDisburseLoanEnforcesFourEyes(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	loan := env.createLoan(t, 1000)
+
+	// Only approvers may approve
+	w := env.serve(approveRequest(t, loan.ID, testToken(t, "EMP-1", RoleDisburser)))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("approval by a disburser: got status %d, want 403: %s", w.Code, w.Body.String())
+	}
+
+	w = env.serve(approveRequest(t, loan.ID, testToken(t, "EMP-1", RoleApprover)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("approval: got status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	env.invest(t, loan.ID, "alice@example.com", 1000)
+
+	// The approver cannot also disburse, even holding the disburser role
+	w = env.serve(disburseRequest(t, loan.ID, testToken(t, "EMP-1", RoleDisburser)))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("disbursement by the approver: got status %d, want 403: %s", w.Code, w.Body.String())
+	}
+	response := decodeError(t, w)
+	if response.Code != CodeForbidden || !strings.Contains(response.Message, "cannot also disburse") {
+		t.Errorf("got error %+v, want the four-eyes message", response)
+	}
+
+	w = env.serve(disburseRequest(t, loan.ID, testToken(t, "EMP-2", RoleDisburser)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("disbursement by another employee: got status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var disbursed LoanResponse
+	decodeJSON(t, w, &disbursed)
+	if disbursed.State != string(entity.StateDisbursed) {
+		t.Errorf("got state %q, want disbursed", disbursed.State)
+	}
+}
+
+// listLoans lists loans with the query and returns their IDs
+func (env *handlerEnv) listLoans(t *testing.T, query string) []int64 {
+	t.Helper()
+
+	w := env.serve(httptest.NewRequest(http.MethodGet, "/api/loans?"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Loans []*LoanResponse `json:"loans"`
+	}
+	decodeJSON(t, w, &response)
+
+	ids := make([]int64, len(response.Loans))
+	for i, loan := range response.Loans {
+		ids[i] = loan.ID
+	}
+	return ids
+}
+
+func TestDeleteLoanHidesItFromListings(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	kept := env.createLoan(t, 1000)
+	deleted := env.createLoan(t, 2000)
+	approved := env.createApprovedLoan(t, 3000)
+	officer := testToken(t, "EMP-OFFICER", RoleOfficer)
+
+	req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/loans/%d", deleted.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+officer)
+	if w := env.serve(req); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: got status %d, want 204: %s", w.Code, w.Body.String())
+	}
+
+	if ids := env.listLoans(t, ""); len(ids) != 2 || ids[0] != approved.ID || ids[1] != kept.ID {
+		t.Errorf("listed loans %v, want %d and %d", ids, approved.ID, kept.ID)
+	}
+	if ids := env.listLoans(t, "include_deleted=true"); len(ids) != 3 {
+		t.Errorf("listed loans %v including deleted, want all 3", ids)
+	}
+
+	if w := env.serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/loans/%d", deleted.ID), nil)); w.Code != http.StatusNotFound {
+		t.Errorf("get deleted loan: got status %d, want 404", w.Code)
+	}
+	if w := env.serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/loans/%d?include_deleted=true", deleted.ID), nil)); w.Code != http.StatusOK {
+		t.Errorf("get deleted loan with include_deleted: got status %d, want 200", w.Code)
+	}
+
+	// Only proposed and rejected loans may be deleted
+	req = httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/loans/%d", approved.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+officer)
+	w := env.serve(req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("delete approved loan: got status %d, want 409: %s", w.Code, w.Body.String())
+	}
+	if code := decodeError(t, w).Code; code != CodeInvalidState {
+		t.Errorf("got code %q, want %q", code, CodeInvalidState)
+	}
+}
+
+// createSingleInvestmentLoan creates and approves a loan of 1000 allowing one investment per investor
+func (env *handlerEnv) createSingleInvestmentLoan(t *testing.T) *entity.Loan {
+	t.Helper()
+
+	allow := false
+	loan, _, err := env.uc.CreateLoan(context.Background(), entity.CreateLoanParams{
+		BorrowerIDNumber:                    "3171234567890123",
+		PrincipalAmount:                     entity.NewMoney(1000),
+		Rate:                                12,
+		ROI:                                 10,
+		TermWeeks:                           52,
+		AgreementLetterLink:                 "https://example.com/agreements/1.pdf",
+		AllowMultipleInvestmentsPerInvestor: &allow,
+	})
+	if err != nil {
+		t.Fatalf("failed to create loan: %v", err)
+	}
+	if _, err := env.uc.ApproveLoan(context.Background(), loan.ID, entity.ApproveLoanParams{
+		ProofPicture: "uploads/proof_pictures/proof.jpg",
+		EmployeeID:   "EMP-APPROVER",
+		ApprovalDate: time.Now(),
+	}); err != nil {
+		t.Fatalf("failed to approve loan: %v", err)
+	}
+	return loan
+}
+
+func TestInvestInLoanRejectsRepeatInvestorWithConflict(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	loan := env.createSingleInvestmentLoan(t)
+	env.invest(t, loan.ID, "alice@example.com", 100)
+
+	body := strings.NewReader(`{"investor_email": "alice@example.com", "amount": 100}`)
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/loans/%d/invest", loan.ID), body)
+	req.Header.Set("Content-Type", "application/json")
+	w := env.serve(req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("got status %d, want 409: %s", w.Code, w.Body.String())
+	}
+	if code := decodeError(t, w).Code; code != CodeAlreadyInvested {
+		t.Errorf("got code %q, want %q", code, CodeAlreadyInvested)
+	}
+}
+
+func TestInvestInLoanParallelRepeatInvestorGetsConflict(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	loan := env.createSingleInvestmentLoan(t)
+
+	// The same investor sends the request several times at once, e.g. a double-clicked button
+	const requests = 5
+	path := fmt.Sprintf("/api/loans/%d/invest", loan.ID)
+	responses := make([]*httptest.ResponseRecorder, requests)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = env.serve(jsonRequest(http.MethodPost, path, `{"investor_email": "alice@example.com", "amount": 100}`))
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for _, w := range responses {
+		switch w.Code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+			if code := decodeError(t, w).Code; code != CodeAlreadyInvested {
+				t.Errorf("got code %q, want %q", code, CodeAlreadyInvested)
+			}
+		default:
+			t.Errorf("got status %d, want 201 or 409: %s", w.Code, w.Body.String())
+		}
+	}
+	if created != 1 {
+		t.Errorf("got %d investments created, want 1", created)
+	}
+
+	summary, err := env.uc.GetLoan(context.Background(), loan.ID, false)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	if summary.InvestmentCount != 1 || summary.TotalInvested != entity.NewMoney(100) {
+		t.Errorf("got %d investments totalling %s, want one of 100", summary.InvestmentCount, summary.TotalInvested)
+	}
+}
+
+// jsonRequest returns a request to path with body as JSON
+func jsonRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestExportLoansWritesFilteredCSV(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	env.createLoan(t, 1000)
+	approved := env.createApprovedLoan(t, 2500)
+
+	w := env.serve(httptest.NewRequest(http.MethodGet, "/api/loans/export?state=approved", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/csv" {
+		t.Errorf("got Content-Type %q, want text/csv", contentType)
+	}
+	if disposition := w.Header().Get("Content-Disposition"); disposition != `attachment; filename="loans.csv"` {
+		t.Errorf("got Content-Disposition %q, want an attachment", disposition)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d rows, want the header and the approved loan", len(records))
+	}
+	if header := strings.Join(records[0], ","); header != strings.Join(loanCSVHeader, ",") {
+		t.Errorf("got header %q", header)
+	}
+
+	row := make(map[string]string, len(records[0]))
+	for i, column := range records[0] {
+		row[column] = records[1][i]
+	}
+	want := map[string]string{
+		"id":                   strconv.FormatInt(approved.ID, 10),
+		"borrower_id_number":   "3171234567890123",
+		"principal_amount":     "2500",
+		"state":                "approved",
+		"approval_employee_id": "EMP-APPROVER",
+		"rejection_reason":     "",
+	}
+	for column, value := range want {
+		if row[column] != value {
+			t.Errorf("got %s %q, want %q", column, row[column], value)
+		}
+	}
+}
+
+func TestExpiredRequestDeadlineRespondsGatewayTimeout(t *testing.T) {
+	// A deadline of a nanosecond has passed by the time the handler queries
+	env := newHandlerEnv(t, handlerOptions{requestTimeout: time.Nanosecond})
+
+	paths := []string{"/api/loans", "/api/loans/1", "/api/stats"}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			w := env.serve(httptest.NewRequest(http.MethodGet, path, nil))
+
+			if w.Code != http.StatusGatewayTimeout {
+				t.Fatalf("got status %d, want 504: %s", w.Code, w.Body.String())
+			}
+			if code := decodeError(t, w).Code; code != CodeTimeout {
+				t.Errorf("got code %q, want %q", code, CodeTimeout)
+			}
+		})
+	}
+}
+
+// storedFilePath returns where the local storage keeps the file at fileURL
+func (env *handlerEnv) storedFilePath(fileURL string) string {
+	return filepath.Join(env.uploadDir, filepath.FromSlash(strings.TrimPrefix(fileURL, DefaultBaseFileURL+"/")))
+}
+
+func TestReplaceApprovalProof(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	approver := testToken(t, "EMP-APPROVER", RoleApprover)
+	loan := env.createLoan(t, 1000)
+	path := fmt.Sprintf("/api/loans/%d/approval-proof", loan.ID)
+
+	if w := env.serve(approveRequest(t, loan.ID, approver)); w.Code != http.StatusOK {
+		t.Fatalf("failed to approve loan: %d %s", w.Code, w.Body.String())
+	}
+	summary, err := env.uc.GetLoan(context.Background(), loan.ID, false)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	original := *summary.Loan.ApprovalProofPicture
+
+	// A file that is not an image is rejected and leaves the proof in place
+	w := env.serve(multipartRequest(t, http.MethodPut, path, approver, nil, formFile{"proof_picture", "proof.jpg", testPDF}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d for a PDF proof, want 400: %s", w.Code, w.Body.String())
+	}
+
+	w = env.serve(multipartRequest(t, http.MethodPut, path, approver, nil, formFile{"proof_picture", "proof.png", testPNG}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var response LoanResponse
+	decodeJSON(t, w, &response)
+	if want := fileDownloadURL(loan.ID, FileTypeApprovalProof); response.ApprovalProofPictureURL == nil || *response.ApprovalProofPictureURL != want {
+		t.Errorf("got proof URL %v, want %q", response.ApprovalProofPictureURL, want)
+	}
+
+	summary, err = env.uc.GetLoan(context.Background(), loan.ID, false)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	replaced := *summary.Loan.ApprovalProofPicture
+	if replaced == original || !strings.HasSuffix(replaced, ".png") {
+		t.Errorf("got stored proof %q, want a new PNG replacing %q", replaced, original)
+	}
+	if _, err := os.Stat(env.storedFilePath(original)); !os.IsNotExist(err) {
+		t.Errorf("replaced proof %q is still stored (stat error %v)", original, err)
+	}
+
+	// The download endpoint serves the new picture
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/loans/%d/files/%s", loan.ID, FileTypeApprovalProof), nil)
+	req.Header.Set("Authorization", "Bearer "+approver)
+	w = env.serve(req)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), testPNG) {
+		t.Errorf("got status %d and %d bytes, want the new PNG", w.Code, w.Body.Len())
+	}
+
+	// Once disbursed, the proof can no longer be replaced
+	env.invest(t, loan.ID, "alice@example.com", 1000)
+	if w := env.serve(disburseRequest(t, loan.ID, testToken(t, "EMP-DISBURSER", RoleDisburser))); w.Code != http.StatusOK {
+		t.Fatalf("failed to disburse loan: %d %s", w.Code, w.Body.String())
+	}
+	w = env.serve(multipartRequest(t, http.MethodPut, path, approver, nil, formFile{"proof_picture", "proof.png", testPNG}))
+	if w.Code != http.StatusConflict {
+		t.Errorf("got status %d after disbursement, want 409: %s", w.Code, w.Body.String())
+	}
+}
+
+// padded returns content followed by zeros up to size bytes
+func padded(content []byte, size int) []byte {
+	return append(append([]byte{}, content...), make([]byte, size-len(content))...)
+}
+
+func TestUploadsHonorConfiguredSizeLimits(t *testing.T) {
+	limits := UploadLimits{MaxImageSize: 2 * 1024, MaxDocumentSize: 3 * 1024}
+	env := newHandlerEnv(t, handlerOptions{uploadLimits: limits})
+	approver := testToken(t, "EMP-APPROVER", RoleApprover)
+	disburser := testToken(t, "EMP-DISBURSER", RoleDisburser)
+
+	approve := func(proof []byte) *httptest.ResponseRecorder {
+		loan := env.createLoan(t, 1000)
+		return env.serve(multipartRequest(t, http.MethodPost, fmt.Sprintf("/api/loans/%d/approve", loan.ID), approver,
+			map[string]string{"approval_date": formNow()},
+			formFile{"proof_picture", "proof.jpg", proof}))
+	}
+	disburse := func(agreement []byte) *httptest.ResponseRecorder {
+		loan := env.createApprovedLoan(t, 1000)
+		env.invest(t, loan.ID, "alice@example.com", 1000)
+		return env.serve(multipartRequest(t, http.MethodPost, fmt.Sprintf("/api/loans/%d/disburse", loan.ID), disburser,
+			map[string]string{"disbursement_date": formNow()},
+			formFile{"signed_agreement_doc", "agreement.pdf", agreement}))
+	}
+
+	tests := []struct {
+		name       string
+		upload     func([]byte) *httptest.ResponseRecorder
+		content    []byte
+		size       int
+		wantStatus int
+		wantError  string
+	}{
+		{"image at the limit", approve, testJPEG, 2 * 1024, http.StatusOK, ""},
+		{"image over the limit", approve, testJPEG, 2*1024 + 1, http.StatusBadRequest, "proof picture file size must not exceed 2KB"},
+		{"document at the limit", disburse, testPDF, 3 * 1024, http.StatusOK, ""},
+		{"document over the limit", disburse, testPDF, 3*1024 + 1, http.StatusBadRequest, "signed agreement file size must not exceed 3KB"},
+		// The image limit does not apply to documents
+		{"document over the image limit", disburse, testPDF, 2*1024 + 1, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.upload(padded(tt.content, tt.size))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantError != "" {
+				if message := decodeError(t, w).Message; message != tt.wantError {
+					t.Errorf("got message %q, want %q", message, tt.wantError)
+				}
+			}
+		})
+	}
+}
+
+func TestListInvestmentsWalksPagesByCursor(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+
+	var want []int64
+	for i := 0; i < 7; i++ {
+		want = append(want, env.invest(t, loan.ID, fmt.Sprintf("investor%d@example.com", i), 100).ID)
+	}
+
+	type investmentPage struct {
+		Investments []*InvestmentResponse `json:"investments"`
+		Total       int                   `json:"total"`
+		NextCursor  *string               `json:"next_cursor"`
+	}
+	list := func(query string) investmentPage {
+		t.Helper()
+
+		w := env.serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/loans/%d/investments?%s", loan.ID, query), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+		}
+		var response investmentPage
+		decodeJSON(t, w, &response)
+		return response
+	}
+
+	var got []int64
+	pages := 0
+	query := "limit=3"
+	for {
+		page := list(query)
+		pages++
+		if page.Total != len(want) {
+			t.Errorf("page %d: got total %d, want %d", pages, page.Total, len(want))
+		}
+		for _, investment := range page.Investments {
+			got = append(got, investment.ID)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		if pages > len(want) {
+			t.Fatal("cursor pagination does not end")
+		}
+		query = "limit=3&cursor=" + *page.NextCursor
+	}
+
+	if pages != 3 {
+		t.Errorf("walked %d pages, want 3", pages)
+	}
+	// Every investment appears once, in ID order
+	if !equalIDs(got, want) {
+		t.Errorf("got investments %v, want %v", got, want)
+	}
+
+	// Offset pagination is still available
+	page := list("limit=3&offset=3")
+	if len(page.Investments) != 3 || page.Investments[0].ID != want[3] || page.NextCursor != nil {
+		t.Errorf("got offset page starting at %v with cursor %v, want investments from %d and no cursor", page.Investments, page.NextCursor, want[3])
+	}
+
+	w := env.serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/loans/%d/investments?cursor=not-a-cursor", loan.ID), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got status %d for an invalid cursor, want 400", w.Code)
+	}
+}
+
+func TestListInvestmentsFiltersByAmountAndDate(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+	other := env.createApprovedLoan(t, 1000)
+
+	// One investment a day, starting an hour from now
+	start := time.Now().UTC().Truncate(time.Second).Add(time.Hour)
+	day := func(n int) time.Time { return start.Add(time.Duration(n) * 24 * time.Hour) }
+	investOn := func(n int, amount float64) int64 {
+		restore := entity.SetClock(func() time.Time { return day(n) })
+		defer restore()
+		return env.invest(t, loan.ID, fmt.Sprintf("investor%d@example.com", n), amount).ID
+	}
+	first := investOn(0, 50)
+	second := investOn(1, 100)
+	third := investOn(2, 250)
+	fourth := investOn(3, 400)
+	env.invest(t, other.ID, "alice@example.com", 100)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int64
+	}{
+		{"no filter", "", []int64{first, second, third, fourth}},
+		{"min amount, inclusive", "min_amount=100", []int64{second, third, fourth}},
+		{"max amount, inclusive", "max_amount=250", []int64{first, second, third}},
+		{"amount range", "min_amount=100&max_amount=250", []int64{second, third}},
+		{"created after, inclusive", "created_after=" + day(2).Format(time.RFC3339), []int64{third, fourth}},
+		{"created before, inclusive", "created_before=" + day(1).Format(time.RFC3339), []int64{first, second}},
+		{"date range", "created_after=" + day(1).Format(time.RFC3339) + "&created_before=" + day(2).Format(time.RFC3339), []int64{second, third}},
+		{"amount and date", "min_amount=200&created_before=" + day(2).Format(time.RFC3339), []int64{third}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := env.serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/loans/%d/investments?%s", loan.ID, tt.query), nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+			}
+			var response InvestmentListResponse
+			decodeJSON(t, w, &response)
+
+			got := make([]int64, len(response.Investments))
+			for i, investment := range response.Investments {
+				got[i] = investment.ID
+			}
+			if !equalIDs(got, tt.want) {
+				t.Errorf("got investments %v, want %v", got, tt.want)
+			}
+			if response.Total != len(tt.want) {
+				t.Errorf("got total %d, want it to count the %d matches", response.Total, len(tt.want))
+			}
+		})
+	}
+
+	for _, query := range []string{"min_amount=300&max_amount=200", "min_amount=lots", "created_after=yesterday"} {
+		if w := env.serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/loans/%d/investments?%s", loan.ID, query), nil)); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want 400", query, w.Code)
+		}
+	}
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListLoansFiltersByPrincipalRange(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	small := env.createLoan(t, 500)
+	medium := env.createLoan(t, 1000)
+	large := env.createLoan(t, 2000)
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"min_principal=1000", []int64{medium.ID, large.ID}},
+		{"max_principal=1000", []int64{small.ID, medium.ID}},
+		{"min_principal=600&max_principal=1999.99", []int64{medium.ID}},
+		{"min_principal=1000&max_principal=1000", []int64{medium.ID}},
+		{"min_principal=2000.01", []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := env.listLoans(t, tt.query+"&sort=principal_amount"); !equalIDs(got, tt.want) {
+				t.Errorf("got loans %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, query := range []string{"min_principal=-1", "max_principal=-0.01", "min_principal=abc", "min_principal=2000&max_principal=1000"} {
+		t.Run(query, func(t *testing.T) {
+			w := env.serve(httptest.NewRequest(http.MethodGet, "/api/loans?"+query, nil))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("got status %d, want 400: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetInvestorPortfolioSpansLoans(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	first := env.createApprovedLoan(t, 1000)
+	second := env.createApprovedLoan(t, 500)
+	third := env.createApprovedLoan(t, 2000)
+
+	env.invest(t, first.ID, "alice@example.com", 100)
+	env.invest(t, first.ID, "bob@example.com", 300)
+	env.invest(t, first.ID, "alice@example.com", 200)
+	env.invest(t, second.ID, "alice@example.com", 500)
+	env.invest(t, third.ID, "alice@example.com", 250)
+
+	portfolio := func(query string) InvestorPortfolioResponse {
+		t.Helper()
+
+		w := env.serve(httptest.NewRequest(http.MethodGet, "/api/investors/alice@example.com/portfolio?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+		}
+		var response InvestorPortfolioResponse
+		decodeJSON(t, w, &response)
+		return response
+	}
+
+	response := portfolio("")
+	if response.Count != 4 || response.Total != 4 {
+		t.Errorf("got %d of %d investments, want 4 of 4", response.Count, response.Total)
+	}
+	for _, investment := range response.Investments {
+		if investment.InvestorEmail != "alice@example.com" {
+			t.Errorf("got investment %d of %s", investment.ID, investment.InvestorEmail)
+		}
+	}
+	if response.LoanCount != 3 || response.TotalInvested != 1050 || response.ExpectedReturns != 105 {
+		t.Errorf("got %d loans, %v invested, %v expected returns; want 3, 1050, 105",
+			response.LoanCount, response.TotalInvested, response.ExpectedReturns)
+	}
+
+	// Each loan is listed once with its current state
+	wantStates := map[int64]string{
+		first.ID:  string(entity.StateApproved),
+		second.ID: string(entity.StateInvested),
+		third.ID:  string(entity.StateApproved),
+	}
+	if len(response.Loans) != len(wantStates) {
+		t.Fatalf("got %d loans, want %d", len(response.Loans), len(wantStates))
+	}
+	for _, loan := range response.Loans {
+		if loan.State != wantStates[loan.ID] {
+			t.Errorf("loan %d has state %q, want %q", loan.ID, loan.State, wantStates[loan.ID])
+		}
+	}
+
+	// Paging keeps the totals of the whole portfolio
+	page := portfolio("limit=3")
+	if page.Count != 3 || page.NextCursor == nil || page.TotalInvested != 1050 {
+		t.Fatalf("got %d investments with cursor %v and %v invested, want 3, a cursor and 1050", page.Count, page.NextCursor, page.TotalInvested)
+	}
+	last := portfolio("limit=3&cursor=" + *page.NextCursor)
+	if last.Count != 1 || last.NextCursor != nil || len(last.Loans) != 1 || last.Loans[0].ID != third.ID {
+		t.Errorf("got last page of %d investments in loans %v, want the investment in loan %d", last.Count, last.Loans, third.ID)
+	}
+}
+
+func TestMetricsEndpointExposesLoanAndRequestMetrics(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+	env.invest(t, loan.ID, "alice@example.com", 1000)
+
+	if w := env.serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/loans/%d", loan.ID), nil)); w.Code != http.StatusOK {
+		t.Fatalf("failed to get loan: %d %s", w.Code, w.Body.String())
+	}
+	env.serve(httptest.NewRequest(http.MethodGet, "/no/such/route", nil))
+
+	w := env.serve(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", w.Code)
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"loan_engine_loans_created_total 1",
+		`loan_engine_loan_state_transitions_total{from_state="proposed",to_state="approved"} 1`,
+		`loan_engine_loan_state_transitions_total{from_state="approved",to_state="invested"} 1`,
+		// Requests are labelled by route template, not by path
+		`loan_engine_http_request_duration_seconds_count{method="GET",route="/api/loans/:id",status="200"} 1`,
+		`loan_engine_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`,
+		"loan_engine_outstanding_principal",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q", want)
+		}
+	}
+}
+
+func TestActionDatesCannotBeInTheFuture(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	future := entity.Now().Add(time.Hour).Format("2006-01-02 15:04:05")
+
+	loan := env.createLoan(t, 1000)
+	w := env.serve(multipartRequest(t, http.MethodPost, fmt.Sprintf("/api/loans/%d/approve", loan.ID), testToken(t, "EMP-APPROVER", RoleApprover),
+		map[string]string{"approval_date": future},
+		formFile{"proof_picture", "proof.jpg", testJPEG}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("approval: got status %d, want 400: %s", w.Code, w.Body.String())
+	}
+	if message := decodeError(t, w).Message; message != "approval_date cannot be in the future" {
+		t.Errorf("approval: got message %q", message)
+	}
+
+	approved := env.createApprovedLoan(t, 1000)
+	env.invest(t, approved.ID, "alice@example.com", 1000)
+	w = env.serve(multipartRequest(t, http.MethodPost, fmt.Sprintf("/api/loans/%d/disburse", approved.ID), testToken(t, "EMP-DISBURSER", RoleDisburser),
+		map[string]string{"disbursement_date": future},
+		formFile{"signed_agreement_doc", "agreement.pdf", testPDF}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("disbursement: got status %d, want 400: %s", w.Code, w.Body.String())
+	}
+	if message := decodeError(t, w).Message; message != "disbursement_date cannot be in the future" {
+		t.Errorf("disbursement: got message %q", message)
+	}
+}
+
+func TestDownloadFileRequiresStaffRole(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	approved := env.createLoan(t, 1000)
+	proposed := env.createLoan(t, 1000)
+	if w := env.serve(approveRequest(t, approved.ID, testToken(t, "EMP-APPROVER", RoleApprover))); w.Code != http.StatusOK {
+		t.Fatalf("failed to approve loan: %d %s", w.Code, w.Body.String())
+	}
+
+	officer := testToken(t, "EMP-OFFICER", RoleOfficer)
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"no token", fmt.Sprintf("/api/loans/%d/files/approval_proof", approved.ID), "", http.StatusUnauthorized},
+		{"non-staff role", fmt.Sprintf("/api/loans/%d/files/approval_proof", approved.ID), testToken(t, "alice@example.com", "investor"), http.StatusForbidden},
+		{"officer", fmt.Sprintf("/api/loans/%d/files/approval_proof", approved.ID), officer, http.StatusOK},
+		{"admin", fmt.Sprintf("/api/loans/%d/files/approval_proof", approved.ID), testToken(t, "EMP-ADMIN", RoleAdmin), http.StatusOK},
+		{"loan without proof", fmt.Sprintf("/api/loans/%d/files/approval_proof", proposed.ID), officer, http.StatusNotFound},
+		{"agreement before disbursement", fmt.Sprintf("/api/loans/%d/files/signed_agreement", approved.ID), officer, http.StatusNotFound},
+		{"unknown loan", "/api/loans/9999/files/approval_proof", officer, http.StatusNotFound},
+		{"unknown file type", fmt.Sprintf("/api/loans/%d/files/passport", approved.ID), officer, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := env.serve(req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != "image/jpeg" {
+				t.Errorf("got Content-Type %q, want image/jpeg", contentType)
+			}
+			if !bytes.Equal(w.Body.Bytes(), testJPEG) {
+				t.Errorf("got %d bytes, want the uploaded proof", w.Body.Len())
+			}
+		})
+	}
+
+	// Uploads are not served publicly
+	if w := env.serve(httptest.NewRequest(http.MethodGet, "/files/proof_pictures/proof.jpg", nil)); w.Code != http.StatusNotFound {
+		t.Errorf("got status %d for the public files path, want 404", w.Code)
+	}
+}
+
+func TestCreateLoanValidateOnlyDoesNotSave(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	draft := `{"borrower_id_number": "3171234567890123", "principal_amount": 1000, "rate": 12, "roi": 10,
+		"term_weeks": 52, "agreement_letter_link": "https://example.com/agreements/1.pdf"}`
+
+	createRequest := func(query, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/loans"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	w := env.serve(createRequest("?validate_only=true", draft))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var response LoanResponse
+	decodeJSON(t, w, &response)
+	if response.ID != 0 || response.State != string(entity.StateProposed) {
+		t.Errorf("got loan %d in state %q, want an unsaved proposed loan", response.ID, response.State)
+	}
+	if response.TotalInterest != 120 || response.TotalRepayable != 1120 {
+		t.Errorf("got interest %v and total repayable %v, want 120 and 1120", response.TotalInterest, response.TotalRepayable)
+	}
+
+	// Invalid drafts fail the same way as creation
+	invalid := strings.Replace(draft, `"roi": 10`, `"roi": 15`, 1)
+	if w := env.serve(createRequest("?validate_only=true", invalid)); w.Code != http.StatusBadRequest {
+		t.Errorf("got status %d for an ROI above the rate, want 400", w.Code)
+	}
+
+	if ids := env.listLoans(t, ""); len(ids) != 0 {
+		t.Fatalf("got loans %v after validating, want none saved", ids)
+	}
+
+	// The first saved loan gets the first ID
+	w = env.serve(createRequest("", draft))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got status %d creating the loan, want 201: %s", w.Code, w.Body.String())
+	}
+	decodeJSON(t, w, &response)
+	if response.ID != 1 {
+		t.Errorf("got loan ID %d, want 1", response.ID)
+	}
+}
+
+func TestGetLoanActionsFollowsTheLoanState(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	proposed := env.createLoan(t, 1000)
+	invested := env.createApprovedLoan(t, 1000)
+	env.invest(t, invested.ID, "alice@example.com", 1000)
+
+	tests := []struct {
+		loanID int64
+		state  string
+		want   string
+	}{
+		{proposed.ID, "proposed", "approve,reject,cancel"},
+		{invested.ID, "invested", "invest,disburse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			w := env.serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/loans/%d/actions", tt.loanID), nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+			}
+			var response LoanActionsResponse
+			decodeJSON(t, w, &response)
+			if response.State != tt.state || strings.Join(response.Actions, ",") != tt.want {
+				t.Errorf("got %s loan with actions %v, want %s with %s", response.State, response.Actions, tt.state, tt.want)
+			}
+		})
+	}
+
+	if w := env.serve(httptest.NewRequest(http.MethodGet, "/api/loans/9999/actions", nil)); w.Code != http.StatusNotFound {
+		t.Errorf("got status %d for an unknown loan, want 404", w.Code)
+	}
+}
+
+func TestGetInvestmentReceiptReturnsPDF(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+	investment := env.invest(t, loan.ID, "alice@example.com", 250)
+
+	w := env.serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/investments/%d/receipt", investment.ID), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/pdf" {
+		t.Errorf("got Content-Type %q, want application/pdf", got)
+	}
+	wantDisposition := fmt.Sprintf(`inline; filename="investment-%d-receipt.pdf"`, investment.ID)
+	if got := w.Header().Get("Content-Disposition"); got != wantDisposition {
+		t.Errorf("got Content-Disposition %q, want %q", got, wantDisposition)
+	}
+	body := w.Body.String()
+	if !strings.HasPrefix(body, "%PDF-") || !strings.Contains(body, "(alice@example.com)") {
+		t.Errorf("got a %d byte body, want a PDF naming the investor", len(body))
+	}
+
+	if w := env.serve(httptest.NewRequest(http.MethodGet, "/api/investments/9999/receipt", nil)); w.Code != http.StatusNotFound {
+		t.Errorf("got status %d for an unknown investment, want 404", w.Code)
+	}
+}
+
+func TestDisburseLoanWithSeveralSignedDocuments(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+	env.invest(t, loan.ID, "alice@example.com", 1000)
+
+	w := env.serve(multipartRequest(t, http.MethodPost, fmt.Sprintf("/api/loans/%d/disburse", loan.ID), testToken(t, "EMP-DISBURSER", RoleDisburser),
+		map[string]string{"disbursement_date": formNow()},
+		formFile{"signed_agreement_docs[]", "agreement.pdf", testPDF},
+		formFile{"signed_agreement_docs[]", "guarantee.jpg", testJPEG}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	w = env.serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/loans/%d", loan.ID), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var summary LoanSummaryResponse
+	decodeJSON(t, w, &summary)
+
+	if len(summary.Documents) != 2 {
+		t.Fatalf("got documents %v, want both uploaded documents", summary.Documents)
+	}
+	officer := testToken(t, "EMP-OFFICER", RoleOfficer)
+	for i, want := range [][]byte{testPDF, testJPEG} {
+		req := httptest.NewRequest(http.MethodGet, summary.Documents[i], nil)
+		req.Header.Set("Authorization", "Bearer "+officer)
+		w := env.serve(req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("downloading %s: got status %d, want 200: %s", summary.Documents[i], w.Code, w.Body.String())
+		}
+		if !bytes.Equal(w.Body.Bytes(), want) {
+			t.Errorf("document %d has different content than uploaded", i)
+		}
+	}
+}
+
+func TestBatchGetLoansKeepsRequestOrder(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	first := env.createLoan(t, 1000)
+	second := env.createLoan(t, 2000)
+
+	w := env.serve(jsonRequest(http.MethodPost, "/api/loans/batch-get", fmt.Sprintf(`{"ids": [%d, 9999, %d, %d]}`, second.ID, first.ID, second.ID)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var response LoanBatchResponse
+	decodeJSON(t, w, &response)
+
+	// Missing IDs keep their place as null
+	want := []int64{second.ID, 0, first.ID, second.ID}
+	if len(response.Loans) != len(want) {
+		t.Fatalf("got %d loans, want %d", len(response.Loans), len(want))
+	}
+	for i, loan := range response.Loans {
+		var got int64
+		if loan != nil {
+			got = loan.ID
+		}
+		if got != want[i] {
+			t.Errorf("loan %d: got ID %d, want %d", i, got, want[i])
+		}
+	}
+	if !equalIDs(response.MissingIDs, []int64{9999}) {
+		t.Errorf("got missing IDs %v, want [9999]", response.MissingIDs)
+	}
+
+	tooMany := strings.TrimSuffix(strings.Repeat("1,", 101), ",")
+	for _, body := range []string{`{"ids": []}`, `{}`, `{"ids": [0]}`, `{"ids": [` + tooMany + `]}`} {
+		if w := env.serve(jsonRequest(http.MethodPost, "/api/loans/batch-get", body)); w.Code != http.StatusBadRequest {
+			t.Errorf("got status %d for %.30s, want 400", w.Code, body)
+		}
+	}
+}
+
+func TestInvestInLoanAllowPartialCapsTheFinalInvestment(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+	env.invest(t, loan.ID, "alice@example.com", 900)
+	path := fmt.Sprintf("/api/loans/%d/invest", loan.ID)
+
+	// Without the flag an amount above the remaining 100 is rejected
+	w := env.serve(jsonRequest(http.MethodPost, path, `{"investor_email": "budi@example.com", "amount": 250}`))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d, want 400: %s", w.Code, w.Body.String())
+	}
+	if response := decodeError(t, w); response.Code != CodeInvestmentExceeds {
+		t.Errorf("got error code %s, want %s", response.Code, CodeInvestmentExceeds)
+	}
+
+	w = env.serve(jsonRequest(http.MethodPost, path, `{"investor_email": "budi@example.com", "amount": 250, "allow_partial": true}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got status %d, want 201: %s", w.Code, w.Body.String())
+	}
+	var investment InvestmentResponse
+	decodeJSON(t, w, &investment)
+	if investment.Amount != 100 || investment.OriginalAmount != 100 {
+		t.Errorf("got %v invested (%v as submitted), want the remaining 100", investment.Amount, investment.OriginalAmount)
+	}
+	if investment.RequestedAmount == nil || *investment.RequestedAmount != 250 {
+		t.Errorf("got requested amount %v, want 250", investment.RequestedAmount)
+	}
+
+	summary, err := env.uc.GetLoan(context.Background(), loan.ID, false)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	if summary.Loan.State != entity.StateInvested || summary.TotalInvested != entity.NewMoney(1000) {
+		t.Errorf("got %s loan with %s invested, want invested with 1000.00", summary.Loan.State, summary.TotalInvested)
+	}
+}
+
+func TestGetLoanConditionalRequests(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+	path := fmt.Sprintf("/api/loans/%d", loan.ID)
+
+	// getLoan requests the loan in the accept format, revalidating ifNoneMatch when set
+	getLoan := func(accept, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		return env.serve(req)
+	}
+
+	w := getLoan("application/json", "")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("got status %d with ETag %q, want 200 with an ETag", w.Code, etag)
+	}
+
+	w = getLoan("application/json", etag)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("got status %d revalidating an unchanged loan, want 304", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("got a %d byte body on 304, want none", w.Body.Len())
+	}
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("got ETag %q on 304, want %q", got, etag)
+	}
+	if got := w.Header().Get("Vary"); got != "Accept" {
+		t.Errorf("got Vary %q on 304, want Accept", got)
+	}
+
+	// The XML representation must not revalidate against the JSON ETag
+	w = getLoan("application/xml", etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d for XML with the JSON ETag, want 200", w.Code)
+	}
+	if xmlETag := w.Header().Get("ETag"); xmlETag == etag {
+		t.Errorf("got the JSON ETag %q for XML, want a different one", xmlETag)
+	} else if w := getLoan("application/xml", xmlETag); w.Code != http.StatusNotModified {
+		t.Errorf("got status %d revalidating XML with its own ETag, want 304", w.Code)
+	}
+
+	env.invest(t, loan.ID, "alice@example.com", 250)
+	w = getLoan("application/json", etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d after an investment, want 200", w.Code)
+	}
+	if got := w.Header().Get("ETag"); got == etag {
+		t.Errorf("got the same ETag %q after an investment, want a new one", got)
+	}
+}
+
+func TestGetLoanFundingReportsProgress(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	unfunded := env.createApprovedLoan(t, 1000)
+	partial := env.createApprovedLoan(t, 1000)
+	env.invest(t, partial.ID, "alice@example.com", 250)
+	env.invest(t, partial.ID, "budi@example.com", 125)
+	env.invest(t, partial.ID, "alice@example.com", 100)
+	funded := env.createApprovedLoan(t, 1000)
+	env.invest(t, funded.ID, "alice@example.com", 600)
+	env.invest(t, funded.ID, "budi@example.com", 300)
+	env.invest(t, funded.ID, "citra@example.com", 100)
+
+	tests := []struct {
+		name string
+		want LoanFundingResponse
+	}{
+		{"unfunded", LoanFundingResponse{LoanID: unfunded.ID, Principal: 1000, TotalInvested: 0, Remaining: 1000, PercentFunded: 0, InvestorCount: 0}},
+		{"partially funded, counting investors once", LoanFundingResponse{LoanID: partial.ID, Principal: 1000, TotalInvested: 475, Remaining: 525, PercentFunded: 47.5, InvestorCount: 2}},
+		{"fully funded", LoanFundingResponse{LoanID: funded.ID, Principal: 1000, TotalInvested: 1000, Remaining: 0, PercentFunded: 100, InvestorCount: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := env.serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/loans/%d/funding", tt.want.LoanID), nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+			}
+			var got LoanFundingResponse
+			decodeJSON(t, w, &got)
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if w := env.serve(httptest.NewRequest(http.MethodGet, "/api/loans/9999/funding", nil)); w.Code != http.StatusNotFound {
+		t.Errorf("got status %d for an unknown loan, want 404", w.Code)
+	}
+}
+
+func TestGetLoanAndListLoansNegotiateXML(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+	env.invest(t, loan.ID, "alice@example.com", 250)
+
+	// get requests path with the Accept header and checks the response is well-formed XML
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		w := env.serve(req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/xml") {
+			t.Fatalf("got Content-Type %q, want application/xml", got)
+		}
+		decoder := xml.NewDecoder(bytes.NewReader(w.Body.Bytes()))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("got malformed XML: %v\n%s", err, w.Body.String())
+			}
+		}
+		return w
+	}
+
+	for _, accept := range []string{"application/xml", "text/xml", "text/html, application/xml;q=0.9"} {
+		w := get(fmt.Sprintf("/api/loans/%d", loan.ID), accept)
+		var summary LoanSummaryResponse
+		if err := xml.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+			t.Fatalf("%s: failed to decode loan summary: %v", accept, err)
+		}
+		if summary.XMLName.Local != "loan_summary" || summary.Loan == nil || summary.Loan.ID != loan.ID {
+			t.Errorf("%s: got <%s> for loan %v, want <loan_summary> for loan %d", accept, summary.XMLName.Local, summary.Loan, loan.ID)
+		}
+		if summary.TotalInvested != 250 || len(summary.Investments) != 1 || summary.Investments[0].InvestorEmail != "alice@example.com" {
+			t.Errorf("%s: got %v invested over investments %v, want alice's 250", accept, summary.TotalInvested, summary.Investments)
+		}
+	}
+
+	w := get("/api/loans", "application/xml")
+	var list LoanListResponse
+	if err := xml.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode loan list: %v", err)
+	}
+	if list.XMLName.Local != "loan_list" || list.Count != 1 || len(list.Loans) != 1 || list.Loans[0].ID != loan.ID {
+		t.Errorf("got <%s> with count %d and loans %v, want <loan_list> with loan %d", list.XMLName.Local, list.Count, list.Loans, loan.ID)
+	}
+
+	// Clients without an XML Accept header keep getting JSON
+	for _, accept := range []string{"", "*/*", "application/json"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/loans", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := env.serve(req)
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/json") {
+			t.Errorf("Accept %q: got Content-Type %q, want application/json", accept, got)
+		}
+	}
+}
+
+func TestGetLoanHistoryPagesThroughManyTransitions(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+
+	// Every ROI update is logged as an approved to approved transition
+	const roiUpdates = 7
+	for i := 0; i < roiUpdates; i++ {
+		if _, err := env.uc.UpdateROI(context.Background(), loan.ID, 9+float64(i)/10, "EMP-OPS"); err != nil {
+			t.Fatalf("failed to update ROI: %v", err)
+		}
+	}
+	env.invest(t, loan.ID, "alice@example.com", 1000)
+
+	history := func(query string) StateTransitionListResponse {
+		t.Helper()
+
+		w := env.serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/loans/%d/history?%s", loan.ID, query), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, want 200: %s", query, w.Code, w.Body.String())
+		}
+		var response StateTransitionListResponse
+		decodeJSON(t, w, &response)
+		return response
+	}
+	ids := func(response StateTransitionListResponse) []int64 {
+		ids := make([]int64, len(response.Transitions))
+		for i, transition := range response.Transitions {
+			ids[i] = transition.ID
+		}
+		return ids
+	}
+
+	oldestFirst := ids(history("sort=asc&limit=100"))
+	if len(oldestFirst) < roiUpdates+2 {
+		t.Fatalf("got %d transitions, want at least the approval, %d ROI updates and the investment", len(oldestFirst), roiUpdates)
+	}
+	newestFirst := make([]int64, len(oldestFirst))
+	for i, id := range oldestFirst {
+		newestFirst[len(oldestFirst)-1-i] = id
+	}
+
+	// Walk the newest-first pages three at a time
+	var walked []int64
+	for offset := 0; offset < len(newestFirst); offset += 3 {
+		page := history(fmt.Sprintf("limit=3&offset=%d", offset))
+		if page.Total != len(newestFirst) {
+			t.Errorf("offset %d: got total %d, want %d", offset, page.Total, len(newestFirst))
+		}
+		if want := min(3, len(newestFirst)-offset); page.Count != want {
+			t.Errorf("offset %d: got count %d, want %d", offset, page.Count, want)
+		}
+		walked = append(walked, ids(page)...)
+	}
+	if !equalIDs(walked, newestFirst) {
+		t.Errorf("got transitions %v across pages, want %v", walked, newestFirst)
+	}
+
+	tests := []struct {
+		name      string
+		fromState string
+		toState   string
+		page      string
+		wantCount int
+		wantTotal int
+	}{
+		{"ROI updates", "approved", "approved", "", roiUpdates, roiUpdates},
+		{"last page of the ROI updates", "approved", "approved", "&limit=2&offset=6", 1, roiUpdates},
+		{"into invested", "", "invested", "", 1, 1},
+		{"out of disbursed", "disbursed", "", "", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := history(fmt.Sprintf("from_state=%s&to_state=%s%s", tt.fromState, tt.toState, tt.page))
+			if page.Count != tt.wantCount || page.Total != tt.wantTotal {
+				t.Errorf("got count %d of total %d, want %d of %d", page.Count, page.Total, tt.wantCount, tt.wantTotal)
+			}
+			for _, transition := range page.Transitions {
+				if (tt.fromState != "" && transition.FromState != tt.fromState) || (tt.toState != "" && transition.ToState != tt.toState) {
+					t.Errorf("got a transition from %s to %s", transition.FromState, transition.ToState)
+				}
+			}
+		})
+	}
+
+	w := env.serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/loans/%d/history?sort=sideways", loan.ID), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got status %d for an invalid sort, want 400", w.Code)
+	}
+}
+
+func TestListingsApplyPageLimits(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{pageLimits: PageLimits{DefaultSize: 3, MaxSize: 5}})
+	var loan *entity.Loan
+	for i := 0; i < 7; i++ {
+		loan = env.createApprovedLoan(t, 1000)
+	}
+	for i := 0; i < 7; i++ {
+		env.invest(t, loan.ID, fmt.Sprintf("investor%d@example.com", i), 100)
+	}
+
+	tests := []struct {
+		name  string
+		limit string
+		want  int
+	}{
+		{"absent limit uses the default size", "", 3},
+		{"limit within the maximum", "limit=4", 4},
+		{"limit at the maximum", "limit=5", 5},
+		{"limit over the maximum is clamped", "limit=1000", 5},
+		{"invalid limit uses the default size", "limit=-1", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := env.listLoans(t, tt.limit); len(got) != tt.want {
+				t.Errorf("got %d loans, want %d", len(got), tt.want)
+			}
+
+			w := env.serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/loans/%d/investments?%s", loan.ID, tt.limit), nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200: %s", w.Code, w.Body.String())
+			}
+			var investments InvestmentListResponse
+			decodeJSON(t, w, &investments)
+			if investments.Count != tt.want || investments.Total != 7 {
+				t.Errorf("got %d investments of %d, want %d of 7", investments.Count, investments.Total, tt.want)
+			}
+		})
+	}
+}
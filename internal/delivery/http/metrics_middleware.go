@@ -0,0 +1,31 @@
+package http
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests that didn't match a registered route, so
+// arbitrary paths can't blow up the metric's cardinality
+const unmatchedRoute = "unmatched"
+
+// RequestObserver records the latency of handled requests
+type RequestObserver interface {
+	ObserveRequest(method, route string, status int, duration time.Duration)
+}
+
+// NewMetricsMiddleware creates a middleware that reports the latency of every
+// request to observer, labelled by its route template (e.g. /api/loans/:id)
+func NewMetricsMiddleware(observer RequestObserver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		observer.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
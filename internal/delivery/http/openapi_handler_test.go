@@ -0,0 +1,143 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// openAPIDocumentFrom registers the OpenAPI handler after the loan routes and fetches the document
+func openAPIDocumentFrom(t *testing.T, env *handlerEnv) map[string]interface{} {
+	t.Helper()
+
+	if err := NewOpenAPIHandler(OpenAPIInfo{Title: "Loan Engine API", Version: "1.0.0"}).RegisterRoutes(env.router); err != nil {
+		t.Fatalf("failed to build the OpenAPI document: %v", err)
+	}
+	w := env.serve(httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", w.Code)
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &document); err != nil {
+		t.Fatalf("failed to decode the OpenAPI document: %v", err)
+	}
+	return document
+}
+
+// collectRefs appends every $ref found in value
+func collectRefs(value interface{}, refs *[]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				*refs = append(*refs, ref)
+			}
+			collectRefs(child, refs)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectRefs(child, refs)
+		}
+	}
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+func TestOpenAPIDocumentIsValid(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	document := openAPIDocumentFrom(t, env)
+
+	if document["openapi"] != openAPIVersion {
+		t.Errorf("got openapi %v, want %s", document["openapi"], openAPIVersion)
+	}
+	info, _ := document["info"].(map[string]interface{})
+	if title, _ := info["title"].(string); title != "Loan Engine API" {
+		t.Errorf("got title %q, want Loan Engine API", title)
+	}
+	if version, _ := info["version"].(string); version != "1.0.0" {
+		t.Errorf("got version %q, want 1.0.0", version)
+	}
+
+	// Every reference resolves to a component schema
+	schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	var refs []string
+	collectRefs(document, &refs)
+	if len(refs) == 0 {
+		t.Fatal("got no schema references, want the DTOs referenced")
+	}
+	for _, ref := range refs {
+		name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+		if _, exists := schemas[name]; !ok || !exists {
+			t.Errorf("reference %s does not resolve", ref)
+		}
+	}
+
+	paths := document["paths"].(map[string]interface{})
+	operationIDs := make(map[string]string)
+	for path, item := range paths {
+		for method, value := range item.(map[string]interface{}) {
+			operation := value.(map[string]interface{})
+			name := strings.ToUpper(method) + " " + path
+
+			// Operation IDs are unique
+			id, _ := operation["operationId"].(string)
+			if other, ok := operationIDs[id]; ok || id == "" {
+				t.Errorf("%s has operationId %q, already used by %s", name, id, other)
+			}
+			operationIDs[id] = name
+
+			if responses, _ := operation["responses"].(map[string]interface{}); len(responses) == 0 {
+				t.Errorf("%s has no responses", name)
+			}
+
+			// Every path template parameter is declared
+			declared := make(map[string]bool)
+			parameters, _ := operation["parameters"].([]interface{})
+			for _, p := range parameters {
+				parameter := p.(map[string]interface{})
+				if parameter["in"] == "path" {
+					declared[parameter["name"].(string)] = true
+				}
+			}
+			for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+				if !declared[match[1]] {
+					t.Errorf("%s does not declare path parameter %s", name, match[1])
+				}
+			}
+		}
+	}
+}
+
+func TestOpenAPIDocumentListsTheRoutes(t *testing.T) {
+	env := newHandlerEnv(t, handlerOptions{})
+	// Every route registered before the document is built must be documented
+	routes := env.router.Routes()
+	document := openAPIDocumentFrom(t, env)
+	paths := document["paths"].(map[string]interface{})
+
+	for _, path := range []string{"/api/loans", "/api/loans/{id}", "/api/loans/{id}/approve", "/api/loans/{id}/invest", "/api/loans/{id}/disburse", "/api/search", "/metrics"} {
+		if _, ok := paths[path]; !ok {
+			t.Errorf("document does not list %s", path)
+		}
+	}
+
+	for _, route := range routes {
+		item, _ := paths[openAPIPath(route.Path)].(map[string]interface{})
+		if _, ok := item[strings.ToLower(route.Method)]; !ok {
+			t.Errorf("document does not describe %s %s", route.Method, route.Path)
+		}
+	}
+
+	// Documented routes the router doesn't serve, such as the health checks, are left out
+	if _, ok := paths["/healthz"]; ok {
+		t.Error("document lists /healthz, which this router does not serve")
+	}
+
+	w := env.serve(httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `url: "/openapi.json"`) {
+		t.Errorf("got status %d, want Swagger UI loading /openapi.json", w.Code)
+	}
+}
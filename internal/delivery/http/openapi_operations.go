@@ -0,0 +1,380 @@
+package http
+
+import "net/http"
+
+// openAPIOperation documents one route of the API
+type openAPIOperation struct {
+	Method    string
+	Path      string // Gin path, such as /api/loans/:id
+	ID        string
+	Summary   string
+	Tag       string
+	Roles     []string // Roles allowed by RequireRole, empty for public routes
+	Query     []openAPIParam
+	Headers   []openAPIParam
+	JSONBody  interface{} // Request DTO bound from a JSON body
+	Form      []openAPIFormField
+	Responses map[int]openAPIResponse
+}
+
+// openAPIParam documents a query or header parameter
+type openAPIParam struct {
+	Name        string
+	Description string
+	Type        string // OpenAPI type, defaults to string
+	Format      string
+	Required    bool
+}
+
+func (p openAPIParam) parameter(in string) map[string]interface{} {
+	schema := map[string]interface{}{"type": "string"}
+	if p.Type != "" {
+		schema["type"] = p.Type
+	}
+	if p.Format != "" {
+		schema["format"] = p.Format
+	}
+	return map[string]interface{}{
+		"name":        p.Name,
+		"in":          in,
+		"description": p.Description,
+		"required":    p.Required,
+		"schema":      schema,
+	}
+}
+
+// openAPIFormField documents a field of a multipart form body
+type openAPIFormField struct {
+	Name        string
+	Description string
+	Required    bool
+	File        bool
+}
+
+// openAPIResponse documents a response; Body is a response DTO, or nil with
+// ContentType set for non-JSON bodies. XML marks a Body that is also served as
+// application/xml through content negotiation.
+type openAPIResponse struct {
+	Description string
+	Body        interface{}
+	ContentType string
+	XML         bool
+}
+
+// Parameters and form fields shared by several operations
+var (
+	employeeIDField = openAPIFormField{Name: "employee_id", Description: "Defaults to the token's employee_id claim; must match it when given"}
+
+	loanFilterParams = []openAPIParam{
+		{Name: "state", Description: "Only loans in this state"},
+		{Name: "borrower_id", Description: "Only loans of this borrower ID number"},
+		{Name: "funding_status", Description: "open, almost_funded or funded"},
+		{Name: "created_after", Description: "RFC 3339 time", Format: "date-time"},
+		{Name: "created_before", Description: "RFC 3339 time", Format: "date-time"},
+		{Name: "min_principal", Type: "number"},
+		{Name: "max_principal", Type: "number"},
+		{Name: "include_deleted", Type: "boolean"},
+		{Name: "sort", Description: "Field to sort by, prefixed with - for descending order"},
+		{Name: "limit", Type: "integer"},
+		{Name: "offset", Type: "integer"},
+	}
+
+	investmentRangeParams = []openAPIParam{
+		{Name: "created_after", Description: "RFC 3339 time", Format: "date-time"},
+		{Name: "created_before", Description: "RFC 3339 time", Format: "date-time"},
+		{Name: "min_amount", Type: "number", Description: "In the loan currency"},
+		{Name: "max_amount", Type: "number", Description: "In the loan currency"},
+	}
+
+	investmentPageParams = []openAPIParam{
+		{Name: "limit", Type: "integer", Description: "Page size, lowered to MAX_PAGE_SIZE"},
+		{Name: "offset", Type: "integer", Description: "Cannot be combined with cursor"},
+		{Name: "cursor", Description: "next_cursor of the previous page"},
+	}
+)
+
+// apiOperations documents the routes registered by LoanHandler, HealthHandler, InfoHandler and main
+var apiOperations = []openAPIOperation{
+	{
+		Method: http.MethodGet, Path: "/healthz", ID: "liveness", Tag: "health",
+		Summary:   "Liveness probe",
+		Responses: map[int]openAPIResponse{http.StatusOK: {Description: "Process is up"}},
+	},
+	{
+		Method: http.MethodGet, Path: "/readyz", ID: "readiness", Tag: "health",
+		Summary: "Readiness probe, pings the database",
+		Responses: map[int]openAPIResponse{
+			http.StatusOK:                 {Description: "Database is reachable"},
+			http.StatusServiceUnavailable: {Description: "Database is down"},
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/metrics", ID: "metrics", Tag: "health",
+		Summary:   "Prometheus metrics",
+		Responses: map[int]openAPIResponse{http.StatusOK: {ContentType: "text/plain"}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/info", ID: "getServerInfo", Tag: "health",
+		Summary:   "Running version and backends, without credentials",
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: ServerInfo{}}},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/loans", ID: "createLoan", Tag: "loans",
+		Summary:  "Create a loan",
+		Query:    []openAPIParam{{Name: "validate_only", Type: "boolean", Description: "Validate without saving"}},
+		JSONBody: CreateLoanRequest{},
+		Responses: map[int]openAPIResponse{
+			http.StatusCreated: {Body: LoanResponse{}},
+			http.StatusOK:      {Description: "Valid loan, not saved (validate_only)", Body: LoanResponse{}},
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/loans", ID: "listLoans", Tag: "loans",
+		Summary:   "List loans",
+		Query:     loanFilterParams,
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanListResponse{}, XML: true}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/loans/export", ID: "exportLoans", Tag: "loans",
+		Summary:   "Export loans as CSV",
+		Query:     loanFilterParams,
+		Responses: map[int]openAPIResponse{http.StatusOK: {ContentType: "text/csv"}},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/loans/batch-get", ID: "batchGetLoans", Tag: "loans",
+		Summary:   "Get several loans by ID, in request order",
+		JSONBody:  BatchGetLoansRequest{},
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanBatchResponse{}}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/loans/:id", ID: "getLoan", Tag: "loans",
+		Summary: "Get a loan with its investments",
+		Query:   []openAPIParam{{Name: "include_deleted", Type: "boolean"}},
+		Headers: []openAPIParam{{Name: "If-None-Match", Description: "ETag of a previous response"}},
+		Responses: map[int]openAPIResponse{
+			http.StatusOK:          {Body: LoanSummaryResponse{}, XML: true},
+			http.StatusNotModified: {Description: "Loan unchanged since the given ETag"},
+		},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/loans/:id", ID: "updateLoan", Tag: "loans",
+		Summary:   "Edit a proposed loan",
+		JSONBody:  UpdateLoanRequest{},
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanResponse{}}},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/loans/:id", ID: "deleteLoan", Tag: "loans",
+		Summary:   "Soft-delete a proposed or rejected loan",
+		Roles:     []string{RoleOfficer},
+		Responses: map[int]openAPIResponse{http.StatusNoContent: {}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/loans/:id/returns", ID: "getInvestorReturns", Tag: "loans",
+		Summary:   "Expected returns per investor",
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanReturnsResponse{}}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/loans/:id/funding", ID: "getLoanFunding", Tag: "loans",
+		Summary:   "Funding progress without the investments",
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanFundingResponse{}}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/loans/:id/history", ID: "getLoanHistory", Tag: "loans",
+		Summary: "State transition audit log",
+		Query: []openAPIParam{
+			{Name: "from_state"},
+			{Name: "to_state"},
+			{Name: "sort", Description: "desc (default, newest first) or asc"},
+			{Name: "limit", Type: "integer"},
+			{Name: "offset", Type: "integer"},
+		},
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: StateTransitionListResponse{}}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/loans/:id/actions", ID: "getLoanActions", Tag: "loans",
+		Summary:   "Actions the loan's state allows",
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanActionsResponse{}}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/loans/:id/investments", ID: "listInvestments", Tag: "investments",
+		Summary: "List investments in a loan",
+		Query: append([]openAPIParam{
+			{Name: "investor_email", Format: "email"},
+		}, append(investmentRangeParams, investmentPageParams...)...),
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: InvestmentListResponse{}}},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/loans/:id/approve", ID: "approveLoan", Tag: "loans",
+		Summary: "Approve a loan",
+		Roles:   []string{RoleApprover},
+		Form: []openAPIFormField{
+			employeeIDField,
+			{Name: "approval_date", Description: "YYYY-MM-DD HH:MM:SS", Required: true},
+			{Name: "effective_at", Description: "YYYY-MM-DD HH:MM:SS, a future time to schedule the approval for; final approval only"},
+			{Name: "proof_picture", Description: "JPEG or PNG", Required: true, File: true},
+		},
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanResponse{}}},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/loans/:id/approval-proof", ID: "replaceApprovalProof", Tag: "loans",
+		Summary: "Replace the approval proof picture",
+		Roles:   []string{RoleApprover},
+		Form: []openAPIFormField{
+			{Name: "proof_picture", Description: "JPEG or PNG", Required: true, File: true},
+		},
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanResponse{}}},
+	},
+	{
+		Method: http.MethodPatch, Path: "/api/loans/:id/roi", ID: "updateROI", Tag: "loans",
+		Summary:   "Change the ROI of an approved loan before investment",
+		Roles:     []string{RoleApprover},
+		JSONBody:  UpdateROIRequest{},
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanResponse{}}},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/loans/:id/reject", ID: "rejectLoan", Tag: "loans",
+		Summary: "Reject a proposed or pending_approval loan",
+		Roles:   []string{RoleOfficer},
+		Form: []openAPIFormField{
+			employeeIDField,
+			{Name: "reason", Required: true},
+			{Name: "rejection_date", Description: "YYYY-MM-DD HH:MM:SS", Required: true},
+		},
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanResponse{}}},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/loans/:id/cancel", ID: "cancelLoan", Tag: "loans",
+		Summary: "Cancel a loan",
+		Roles:   []string{RoleOfficer},
+		Form: []openAPIFormField{
+			employeeIDField,
+			{Name: "reason", Required: true},
+			{Name: "force", Description: "true to cancel a loan that has investments"},
+		},
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanResponse{}}},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/loans/:id/expire", ID: "expireLoan", Tag: "loans",
+		Summary:   "Expire an approved loan now",
+		Roles:     []string{RoleAdmin},
+		Form:      []openAPIFormField{employeeIDField},
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanResponse{}}},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/loans/:id/force-invested", ID: "forceInvested", Tag: "loans",
+		Summary:   "Mark a fully funded loan invested (reconciliation)",
+		Roles:     []string{RoleAdmin},
+		Form:      []openAPIFormField{employeeIDField},
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanResponse{}}},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/loans/:id/notify", ID: "resendInvestedNotification", Tag: "loans",
+		Summary:   "Resend the fully invested notification to the investors",
+		Roles:     []string{RoleAdmin},
+		Responses: map[int]openAPIResponse{http.StatusAccepted: {Body: NotificationResponse{}}},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/loans/:id/invest", ID: "investInLoan", Tag: "investments",
+		Summary:  "Invest in a loan",
+		Headers:  []openAPIParam{{Name: "Idempotency-Key", Description: "Retries with the same key return the original investment"}},
+		JSONBody: InvestLoanRequest{},
+		Responses: map[int]openAPIResponse{
+			http.StatusCreated: {Body: InvestmentResponse{}},
+			http.StatusOK:      {Description: "Investment already made with this Idempotency-Key", Body: InvestmentResponse{}},
+		},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/loans/:id/investments/:investment_id", ID: "withdrawInvestment", Tag: "investments",
+		Summary:   "Withdraw an investment",
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanSummaryResponse{}}},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/loans/:id/disburse", ID: "disburseLoan", Tag: "loans",
+		Summary: "Disburse a loan, in full or in tranches",
+		Roles:   []string{RoleDisburser},
+		Form: []openAPIFormField{
+			employeeIDField,
+			{Name: "disbursement_date", Description: "YYYY-MM-DD HH:MM:SS", Required: true},
+			{Name: "amount", Description: "Tranche amount, defaults to the remaining principal"},
+			{Name: "signed_agreement_docs[]", Description: "PDF, JPEG or PNG, repeat the field for several documents", File: true},
+			{Name: "signed_agreement_doc", Description: "PDF, JPEG or PNG, for a single document", File: true},
+		},
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanResponse{}}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/loans/:id/disbursements", ID: "listDisbursements", Tag: "loans",
+		Summary:   "List disbursement tranches of a loan",
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: DisbursementListResponse{}}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/loans/:id/files/:type", ID: "downloadFile", Tag: "loans",
+		Summary: "Download an uploaded document (approval_proof, signed_agreement)",
+		Roles:   []string{RoleOfficer, RoleApprover, RoleDisburser, RoleAdmin},
+		Query: []openAPIParam{
+			{Name: "disbursement_id", Type: "integer", Description: "Signed agreement of this tranche"},
+			{Name: "document_id", Type: "integer", Description: "Signed document listed in the loan's documents"},
+		},
+		Responses: map[int]openAPIResponse{http.StatusOK: {ContentType: "application/octet-stream"}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/stats", ID: "getStats", Tag: "loans",
+		Summary: "Loan portfolio statistics",
+		Query: []openAPIParam{
+			{Name: "created_after", Description: "RFC 3339 time", Format: "date-time"},
+			{Name: "created_before", Description: "RFC 3339 time", Format: "date-time"},
+		},
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanStatsResponse{}}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/search", ID: "searchLoans", Tag: "loans",
+		Summary: "Search loans by borrower ID or investor email fragment",
+		Query: []openAPIParam{
+			{Name: "q", Required: true},
+			{Name: "limit", Type: "integer"},
+			{Name: "offset", Type: "integer"},
+		},
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: LoanListResponse{}}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/borrowers/:id/exposure", ID: "getBorrowerExposure", Tag: "loans",
+		Summary:   "Disbursed principal and loans of one borrower across all their loans",
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: BorrowerExposureResponse{}}},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/investments/bulk", ID: "bulkInvest", Tag: "investments",
+		Summary:  "Invest in several loans at once",
+		JSONBody: BulkInvestRequest{},
+		Responses: map[int]openAPIResponse{
+			http.StatusCreated:             {Description: "Every investment succeeded", Body: BulkInvestmentResponse{}},
+			http.StatusOK:                  {Description: "Some investments failed (allow_partial)", Body: BulkInvestmentResponse{}},
+			http.StatusUnprocessableEntity: {Description: "No investment was made", Body: BulkInvestmentResponse{}},
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/investments/:id/receipt", ID: "getInvestmentReceipt", Tag: "investments",
+		Summary:   "Download the PDF receipt of an investment",
+		Responses: map[int]openAPIResponse{http.StatusOK: {ContentType: "application/pdf"}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/investors/:email/portfolio", ID: "getInvestorPortfolio", Tag: "investments",
+		Summary:   "One investor's investments across all loans",
+		Query:     investmentPageParams,
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: InvestorPortfolioResponse{}}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/investments", ID: "listAllInvestments", Tag: "investments",
+		Summary: "Investment ledger across all loans",
+		Roles:   []string{RoleAdmin},
+		Query: append([]openAPIParam{
+			{Name: "investor_email", Format: "email"},
+			{Name: "loan_id", Type: "integer"},
+		}, append(investmentRangeParams, investmentPageParams...)...),
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: InvestmentListResponse{}}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/roi-summary", ID: "getROISummary", Tag: "loans",
+		Summary:   "Blended ROI of the invested and disbursed loans",
+		Roles:     []string{RoleAdmin},
+		Responses: map[int]openAPIResponse{http.StatusOK: {Body: ROISummaryResponse{}}},
+	},
+}
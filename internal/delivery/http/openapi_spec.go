@@ -0,0 +1,340 @@
+package http
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openAPIVersion is the OpenAPI specification version the document follows
+const openAPIVersion = "3.0.3"
+
+// OpenAPIInfo describes the API in the OpenAPI document
+type OpenAPIInfo struct {
+	Title   string
+	Version string
+}
+
+// openAPIDocument builds an OpenAPI 3 document. Request and response schemas
+// are derived from the DTO structs: field names from their json tags and
+// constraints from their binding tags, so the document follows the handlers.
+type openAPIDocument struct {
+	schemas map[string]interface{}
+}
+
+// Build returns the document for the given operations
+func (d *openAPIDocument) Build(info OpenAPIInfo, operations []openAPIOperation) map[string]interface{} {
+	d.schemas = map[string]interface{}{
+		"ErrorResponse": d.structSchema(reflect.TypeOf(ErrorResponse{}), false),
+	}
+
+	paths := make(map[string]interface{})
+	for _, op := range operations {
+		path := openAPIPath(op.Path)
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			item = make(map[string]interface{})
+			paths[path] = item
+		}
+		item[strings.ToLower(op.Method)] = d.operation(op)
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   info.Title,
+			"version": info.Version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": d.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+			},
+		},
+	}
+}
+
+// operation describes a single route
+func (d *openAPIDocument) operation(op openAPIOperation) map[string]interface{} {
+	operation := map[string]interface{}{
+		"summary":     op.Summary,
+		"operationId": op.ID,
+		"tags":        []string{op.Tag},
+	}
+
+	var parameters []interface{}
+	for _, segment := range strings.Split(op.Path, "/") {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+		name := strings.TrimPrefix(segment, ":")
+		schema := map[string]interface{}{"type": "string"}
+		if name == "id" || strings.HasSuffix(name, "_id") {
+			schema = map[string]interface{}{"type": "integer", "format": "int64"}
+		}
+		parameters = append(parameters, map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   schema,
+		})
+	}
+	for _, param := range op.Query {
+		parameters = append(parameters, param.parameter("query"))
+	}
+	for _, param := range op.Headers {
+		parameters = append(parameters, param.parameter("header"))
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	if body := d.requestBody(op); body != nil {
+		operation["requestBody"] = body
+	}
+
+	responses := make(map[string]interface{})
+	for status, response := range op.Responses {
+		responses[strconv.Itoa(status)] = d.response(status, response)
+	}
+	if len(op.Roles) > 0 {
+		operation["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+		operation["description"] = "Requires the " + strings.Join(op.Roles, ", ") + " role."
+		responses["401"] = d.errorResponse(http.StatusUnauthorized)
+		responses["403"] = d.errorResponse(http.StatusForbidden)
+	}
+	responses["default"] = map[string]interface{}{
+		"description": "Error",
+		"content":     jsonContent(schemaRef("ErrorResponse")),
+	}
+	operation["responses"] = responses
+
+	return operation
+}
+
+// requestBody describes the JSON or multipart form body of an operation
+func (d *openAPIDocument) requestBody(op openAPIOperation) map[string]interface{} {
+	if op.JSONBody != nil {
+		return map[string]interface{}{
+			"required": true,
+			"content":  jsonContent(d.schema(reflect.TypeOf(op.JSONBody), true)),
+		}
+	}
+	if len(op.Form) == 0 {
+		return nil
+	}
+
+	properties := make(map[string]interface{})
+	var required []string
+	for _, field := range op.Form {
+		schema := map[string]interface{}{"type": "string", "description": field.Description}
+		if field.File {
+			schema["format"] = "binary"
+		}
+		properties[field.Name] = schema
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return map[string]interface{}{
+		"required": true,
+		"content": map[string]interface{}{
+			"multipart/form-data": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+// response describes one response of an operation
+func (d *openAPIDocument) response(status int, response openAPIResponse) map[string]interface{} {
+	description := response.Description
+	if description == "" {
+		description = http.StatusText(status)
+	}
+	result := map[string]interface{}{"description": description}
+
+	switch {
+	case response.Body != nil:
+		schema := d.schema(reflect.TypeOf(response.Body), false)
+		content := jsonContent(schema)
+		if response.XML {
+			content["application/xml"] = map[string]interface{}{"schema": schema}
+		}
+		result["content"] = content
+	case response.ContentType != "":
+		result["content"] = map[string]interface{}{
+			response.ContentType: map[string]interface{}{
+				"schema": map[string]interface{}{"type": "string", "format": "binary"},
+			},
+		}
+	}
+	return result
+}
+
+// errorResponse describes an error response
+func (d *openAPIDocument) errorResponse(status int) map[string]interface{} {
+	return map[string]interface{}{
+		"description": http.StatusText(status),
+		"content":     jsonContent(schemaRef("ErrorResponse")),
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema describes a Go type. Named structs are added to the components and
+// referenced; request schemas take required fields from binding tags.
+func (d *openAPIDocument) schema(t reflect.Type, request bool) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		if _, ok := d.schemas[t.Name()]; !ok {
+			d.schemas[t.Name()] = nil // Reserve the name for recursive types
+			d.schemas[t.Name()] = d.structSchema(t, request)
+		}
+		return schemaRef(t.Name())
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": d.schema(t.Elem(), request)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": d.schema(t.Elem(), request)}
+	case reflect.Struct:
+		return d.structSchema(t, request)
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+// structSchema describes a struct by its JSON fields
+func (d *openAPIDocument) structSchema(t reflect.Type, request bool) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := d.schema(field.Type, request)
+		if field.Type.Kind() == reflect.Ptr && schema["$ref"] == nil {
+			schema["nullable"] = true
+		}
+
+		isRequired := false
+		if request {
+			isRequired = applyBindingTag(schema, field.Tag.Get("binding"), field.Type)
+		} else {
+			isRequired = !strings.Contains(options, "omitempty")
+		}
+		if isRequired {
+			required = append(required, name)
+		}
+		properties[name] = schema
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// applyBindingTag adds the validator constraints of a binding tag to a
+// schema and reports whether the field is required
+func applyBindingTag(schema map[string]interface{}, tag string, t reflect.Type) bool {
+	required := false
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	isCollection := t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+	isString := t.Kind() == reflect.String
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, value, _ := strings.Cut(rule, "=")
+		if name == "dive" {
+			// Later rules apply to the elements, described by their own struct
+			break
+		}
+		number, numberErr := strconv.ParseFloat(value, 64)
+		switch {
+		case name == "required":
+			required = true
+		case name == "email":
+			schema["format"] = "email"
+		case name == "oneof":
+			schema["enum"] = strings.Fields(value)
+		case numberErr != nil:
+			continue
+		case (name == "min" || name == "max") && isCollection:
+			schema[name+"Items"] = int(number)
+		case (name == "min" || name == "max") && isString:
+			schema[name+"Length"] = int(number)
+		case name == "gt":
+			schema["minimum"] = number
+			schema["exclusiveMinimum"] = true
+		case name == "gte", name == "min":
+			schema["minimum"] = number
+		case name == "lt":
+			schema["maximum"] = number
+			schema["exclusiveMaximum"] = true
+		case name == "lte", name == "max":
+			schema["maximum"] = number
+		}
+	}
+	return required
+}
+
+// openAPIPath turns a gin path such as /loans/:id into /loans/{id}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
@@ -0,0 +1,36 @@
+package http
+
+// Default page sizes of the loan and investment listings, used when not configured
+const (
+	DefaultPageSize    = 20
+	DefaultMaxPageSize = 100
+)
+
+// PageLimits bounds the pages of the loan and investment listings, so a client
+// cannot read a whole table in one request
+type PageLimits struct {
+	DefaultSize int // Page size when the request gives no limit
+	MaxSize     int // Larger limits are lowered to this
+}
+
+// withDefaults fills unset limits with the defaults and keeps the default size within the maximum
+func (l PageLimits) withDefaults() PageLimits {
+	if l.MaxSize <= 0 {
+		l.MaxSize = DefaultMaxPageSize
+	}
+	if l.DefaultSize <= 0 {
+		l.DefaultSize = DefaultPageSize
+	}
+	l.DefaultSize = min(l.DefaultSize, l.MaxSize)
+	return l
+}
+
+// clamp returns the page size for a requested limit: DefaultSize when none was
+// given, otherwise the limit lowered to MaxSize
+func (l PageLimits) clamp(limit *int) *int {
+	size := l.DefaultSize
+	if limit != nil {
+		size = min(*limit, l.MaxSize)
+	}
+	return &size
+}
@@ -0,0 +1,51 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default rate limit per client IP, used when none is configured
+const (
+	DefaultRateLimitPerSecond = 10
+	DefaultRateLimitBurst     = 20
+)
+
+// RateLimitStore keeps the request budget of every client. Allow takes one
+// request from the budget of key, or reports how long until one is available.
+type RateLimitStore interface {
+	Allow(ctx context.Context, key string) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// NewRateLimitMiddleware creates a middleware that limits every client IP to the
+// budget kept by store, answering 429 with a Retry-After header once it is spent.
+// Requests are let through when the store fails, so an outage of a shared store
+// doesn't take the API down with it.
+func NewRateLimitMiddleware(store RateLimitStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, retryAfter, err := store.Allow(c.Request.Context(), c.ClientIP())
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "rate limit store failed, letting the request through", "error", err)
+			c.Next()
+			return
+		}
+		if allowed {
+			c.Next()
+			return
+		}
+
+		// Retry-After is in whole seconds, round up so the client doesn't retry too early
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, ErrorResponse{Code: CodeRateLimited, Message: "too many requests, retry later"})
+	}
+}
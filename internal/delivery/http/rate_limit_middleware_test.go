@@ -0,0 +1,73 @@
+package http
+
+import (
+	"amartha-andreas/internal/infrastructure/ratelimit"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// failingRateLimitStore implements RateLimitStore as a store that is down
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	return false, 0, errors.New("store unavailable")
+}
+
+func newRateLimitedRouter(store RateLimitStore) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(NewRateLimitMiddleware(store))
+	router.GET("/api/loans", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+// requestFrom sends a request to router from the client at ip
+func requestFrom(router *gin.Engine, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/loans", nil)
+	req.RemoteAddr = ip + ":12345"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitMiddlewareLimitsEachClientIP(t *testing.T) {
+	// A burst of 3, refilled at one request every 2 seconds
+	router := newRateLimitedRouter(ratelimit.NewMemoryStore(0.5, 3))
+
+	for i := 1; i <= 3; i++ {
+		if w := requestFrom(router, "203.0.113.1"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: got status %d, want 200 within the burst", i, w.Code)
+		}
+	}
+
+	w := requestFrom(router, "203.0.113.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d, want 429 once the burst is spent", w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("got Retry-After %q, want 2 seconds until the next token", retryAfter)
+	}
+	if code := decodeError(t, w).Code; code != CodeRateLimited {
+		t.Errorf("got code %q, want %q", code, CodeRateLimited)
+	}
+
+	// Other clients have their own budget
+	if w := requestFrom(router, "203.0.113.2"); w.Code != http.StatusOK {
+		t.Errorf("got status %d for another client, want 200", w.Code)
+	}
+}
+
+func TestRateLimitMiddlewareLetsRequestsThroughWhenStoreFails(t *testing.T) {
+	router := newRateLimitedRouter(failingRateLimitStore{})
+
+	if w := requestFrom(router, "203.0.113.1"); w.Code != http.StatusOK {
+		t.Errorf("got status %d, want 200 while the store is down", w.Code)
+	}
+}
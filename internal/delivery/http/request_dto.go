@@ -2,14 +2,57 @@ package http
 
 // Request structs for HTTP layer - these handle JSON binding and validation
 type CreateLoanRequest struct {
+	BorrowerIDNumber    string   `json:"borrower_id_number" binding:"required"`
+	BorrowerEmail       string   `json:"borrower_email" binding:"omitempty,email"`
+	PrincipalAmount     float64  `json:"principal_amount" binding:"required,gt=0"`
+	Currency            string   `json:"currency" binding:"omitempty,len=3,alpha"`
+	Rate                float64  `json:"rate" binding:"required,gt=0,lte=100"`
+	ROI                 float64  `json:"roi" binding:"required,gt=0,lte=100"`
+	TermWeeks           int      `json:"term_weeks" binding:"required,gt=0"`
+	MinInvestment       *float64 `json:"min_investment" binding:"omitempty,gt=0"`
+	MaxInvestment       *float64 `json:"max_investment" binding:"omitempty,gt=0"`
+	MaxPerInvestor      *float64 `json:"max_per_investor" binding:"omitempty,gt=0"`
+	InvestmentIncrement *float64 `json:"investment_increment" binding:"omitempty,gt=0"`
+	AgreementLetterLink string   `json:"agreement_letter_link" binding:"required"`
+
+	// Optional, defaults to true
+	AllowMultipleInvestmentsPerInvestor *bool `json:"allow_multiple_investments_per_investor"`
+}
+
+type UpdateLoanRequest struct {
 	BorrowerIDNumber    string  `json:"borrower_id_number" binding:"required"`
 	PrincipalAmount     float64 `json:"principal_amount" binding:"required,gt=0"`
 	Rate                float64 `json:"rate" binding:"required,gt=0,lte=100"`
 	ROI                 float64 `json:"roi" binding:"required,gt=0,lte=100"`
+	TermWeeks           int     `json:"term_weeks" binding:"required,gt=0"`
 	AgreementLetterLink string  `json:"agreement_letter_link" binding:"required"`
 }
 
+type UpdateROIRequest struct {
+	ROI float64 `json:"roi" binding:"required,gt=0,lte=100"`
+}
+
 type InvestLoanRequest struct {
 	InvestorEmail string  `json:"investor_email" binding:"required,email"`
 	Amount        float64 `json:"amount" binding:"required,gt=0"`
+	Currency      string  `json:"currency" binding:"omitempty,len=3,alpha"`
+	Language      string  `json:"language" binding:"omitempty,oneof=en id"`
+	AllowPartial  bool    `json:"allow_partial"` // Invest the remaining amount when amount exceeds it
+}
+
+type BatchGetLoansRequest struct {
+	IDs []int64 `json:"ids" binding:"required,min=1,max=100,dive,gt=0"` // At most repository.MaxLoanIDs
+}
+
+type BulkInvestRequest struct {
+	Items        []BulkInvestItemRequest `json:"items" binding:"required,min=1,max=100,dive"`
+	AllowPartial bool                    `json:"allow_partial"`
+}
+
+type BulkInvestItemRequest struct {
+	LoanID        int64   `json:"loan_id" binding:"required,gt=0"`
+	InvestorEmail string  `json:"investor_email" binding:"required,email"`
+	Amount        float64 `json:"amount" binding:"required,gt=0"`
+	Currency      string  `json:"currency" binding:"omitempty,len=3,alpha"`
+	Language      string  `json:"language" binding:"omitempty,oneof=en id"`
 }
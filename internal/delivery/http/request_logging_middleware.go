@@ -0,0 +1,69 @@
+package http
+
+import (
+	"log/slog"
+	"time"
+
+	"amartha-andreas/internal/infrastructure/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request correlation ID, in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds a caller supplied request ID so it can't flood the logs
+const maxRequestIDLength = 128
+
+// NewRequestLoggingMiddleware creates a middleware that tags every request with
+// an ID and logs it to logger once handled. The caller's X-Request-ID is kept
+// when it is well formed, otherwise a new one is generated. The ID is echoed in
+// the response header and carried by the request context, so records logged
+// with that context further down share it.
+func NewRequestLoggingMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := c.Writer.Status()
+
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request handled",
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
+
+// isValidRequestID accepts non-empty IDs of printable ASCII without spaces
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] <= ' ' || requestID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
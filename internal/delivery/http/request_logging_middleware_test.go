@@ -0,0 +1,83 @@
+package http
+
+import (
+	"amartha-andreas/internal/infrastructure/logging"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestRequestLoggingMiddlewareTagsRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var logs bytes.Buffer
+	logger := logging.NewJSONLogger(&logs, slog.LevelInfo)
+	router := gin.New()
+	router.Use(NewRequestLoggingMiddleware(logger))
+	router.GET("/api/loans/:id", func(c *gin.Context) {
+		// Domain code logging with the request context shares the request ID
+		logger.InfoContext(c.Request.Context(), "loading loan")
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name     string
+		sent     string
+		wantSame bool
+	}{
+		{"generated when missing", "", false},
+		{"propagated from the caller", "req-123", true},
+		{"replaced when malformed", "has spaces in it", false},
+		{"replaced when too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/api/loans/7", nil)
+			if tt.sent != "" {
+				req.Header.Set(RequestIDHeader, tt.sent)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			requestID := w.Header().Get(RequestIDHeader)
+			if tt.wantSame {
+				if requestID != tt.sent {
+					t.Errorf("got request ID %q, want the caller's %q", requestID, tt.sent)
+				}
+			} else if _, err := uuid.Parse(requestID); err != nil {
+				t.Errorf("got request ID %q, want a generated UUID", requestID)
+			}
+
+			// One JSON record from the handler and one for the request, both with the ID
+			lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("got %d log records, want 2: %s", len(lines), logs.String())
+			}
+			var records []map[string]interface{}
+			for _, line := range lines {
+				var record map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					t.Fatalf("log record %q is not JSON: %v", line, err)
+				}
+				if record["request_id"] != requestID {
+					t.Errorf("record %q has request_id %v, want %q", record["msg"], record["request_id"], requestID)
+				}
+				records = append(records, record)
+			}
+
+			request := records[1]
+			if request["route"] != "/api/loans/:id" || request["status"] != float64(http.StatusNoContent) || request["latency_ms"] == nil {
+				t.Errorf("got request record %v, want its route, status and latency", request)
+			}
+		})
+	}
+}
@@ -3,27 +3,58 @@ package http
 import (
 	"amartha-andreas/internal/domain/entity"
 	"amartha-andreas/internal/usecase"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
-// Response DTOs that convert filenames to full URLs
+// Response DTOs that convert filenames to full URLs. The loan details and list
+// are also served as XML, with elements named after their JSON fields.
 type LoanResponse struct {
 	ID                      int64      `json:"ID"`
 	BorrowerIDNumber        string     `json:"BorrowerIDNumber"`
+	BorrowerEmail           *string    `json:"BorrowerEmail"`
 	PrincipalAmount         float64    `json:"PrincipalAmount"`
+	Currency                string     `json:"Currency"`
 	Rate                    float64    `json:"Rate"`
 	ROI                     float64    `json:"ROI"`
+	TermWeeks               int        `json:"TermWeeks"`
+	TotalInterest           float64    `json:"TotalInterest"`
+	TotalRepayable          float64    `json:"TotalRepayable"`
+	FundedPercentage        float64    `json:"FundedPercentage"`
+	MinInvestment           *float64   `json:"MinInvestment"`
+	MaxInvestment           *float64   `json:"MaxInvestment"`
+	MaxPerInvestor          *float64   `json:"MaxPerInvestor"`
+	InvestmentIncrement     *float64   `json:"InvestmentIncrement"`
 	State                   string     `json:"State"`
 	AgreementLetterLink     string     `json:"AgreementLetterLink"`
 	CreatedAt               time.Time  `json:"CreatedAt"`
 	UpdatedAt               time.Time  `json:"UpdatedAt"`
-	ApprovalProofPictureURL *string    `json:"ApprovalProofPicture"`
+	ApprovalProofPictureURL *string    `json:"ApprovalProofPicture" xml:"ApprovalProofPicture"`
 	ApprovalEmployeeID      *string    `json:"ApprovalEmployeeID"`
 	ApprovalDate            *time.Time `json:"ApprovalDate"`
-	SignedAgreementDocURL   *string    `json:"SignedAgreementDoc"`
+	FundingDeadline         *time.Time `json:"FundingDeadline"`
+	ApprovalEffectiveAt     *time.Time `json:"ApprovalEffectiveAt"`
+	SignedAgreementDocURL   *string    `json:"SignedAgreementDoc" xml:"SignedAgreementDoc"`
 	DisbursementEmployeeID  *string    `json:"DisbursementEmployeeID"`
 	DisbursementDate        *time.Time `json:"DisbursementDate"`
+	MaturityDate            *time.Time `json:"MaturityDate"`
+	RejectionReason         *string    `json:"RejectionReason"`
+	RejectionEmployeeID     *string    `json:"RejectionEmployeeID"`
+	RejectionDate           *time.Time `json:"RejectionDate"`
+	CancellationReason      *string    `json:"CancellationReason"`
+	CancellationEmployeeID  *string    `json:"CancellationEmployeeID"`
+	CancellationDate        *time.Time `json:"CancellationDate"`
+	DeletedAt               *time.Time `json:"DeletedAt"`
+	Version                 int        `json:"Version"`
+	RequiredApprovals       int        `json:"RequiredApprovals"`
+
+	AllowMultipleInvestmentsPerInvestor bool `json:"AllowMultipleInvestmentsPerInvestor"`
+
+	// Soft limits the loan exceeds, only when it is created or validated
+	Warnings []string `json:"Warnings,omitempty"`
 }
 
 type InvestmentResponse struct {
@@ -31,20 +62,187 @@ type InvestmentResponse struct {
 	LoanID        int64     `json:"LoanID"`
 	InvestorEmail string    `json:"InvestorEmail"`
 	Amount        float64   `json:"Amount"`
+	Language      string    `json:"Language"`
 	CreatedAt     time.Time `json:"CreatedAt"`
+
+	// The investment as made by the investor, before conversion to the loan currency
+	Currency       string  `json:"Currency"`
+	OriginalAmount float64 `json:"OriginalAmount"`
+
+	// Amount asked for with allow_partial, in Currency; OriginalAmount is lower when it was capped
+	RequestedAmount *float64 `json:"RequestedAmount,omitempty" xml:",omitempty"`
+
+	// State of the investment's loan, only in the investment ledger
+	LoanState string `json:"LoanState,omitempty" xml:",omitempty"`
 }
 
 type LoanSummaryResponse struct {
-	Loan            *LoanResponse         `json:"loan"`
-	TotalInvested   float64               `json:"total_invested"`
-	RemainingAmount float64               `json:"remaining_amount"`
-	InvestmentCount int                   `json:"investment_count"`
+	XMLName         xml.Name              `json:"-" xml:"loan_summary"`
+	Loan            *LoanResponse         `json:"loan" xml:"loan"`
+	TotalInvested   float64               `json:"total_invested" xml:"total_invested"`
+	RemainingAmount float64               `json:"remaining_amount" xml:"remaining_amount"`
+	InvestmentCount int                   `json:"investment_count" xml:"investment_count"`
+	Investments     []*InvestmentResponse `json:"investments" xml:"investments>investment"`
+	Documents       []string              `json:"documents" xml:"documents>document"` // Download URLs of the signed documents of every tranche
+	Approvals       []*ApprovalResponse   `json:"approvals" xml:"approvals>approval"`
+}
+
+type ApprovalResponse struct {
+	EmployeeID   string    `json:"employee_id" xml:"employee_id"`
+	ApprovalDate time.Time `json:"approval_date" xml:"approval_date"`
+	CreatedAt    time.Time `json:"created_at" xml:"created_at"`
+}
+
+type StateTransitionResponse struct {
+	ID        int64     `json:"id"`
+	LoanID    int64     `json:"loan_id"`
+	FromState string    `json:"from_state"`
+	ToState   string    `json:"to_state"`
+	Actor     string    `json:"actor"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LoanListResponse struct {
+	XMLName xml.Name        `json:"-" xml:"loan_list"`
+	Loans   []*LoanResponse `json:"loans" xml:"loans>loan"`
+	Count   int             `json:"count" xml:"count"`
+}
+
+// LoanBatchResponse holds the loans in the order they were asked for, with
+// null for IDs that have no loan, which are also listed in missing_ids
+type LoanBatchResponse struct {
+	Loans      []*LoanResponse `json:"loans"`
+	MissingIDs []int64         `json:"missing_ids"`
+}
+
+type StateTransitionListResponse struct {
+	Transitions []*StateTransitionResponse `json:"transitions"`
+	Count       int                        `json:"count"`
+	Total       int                        `json:"total"` // Transitions matching the filters across all pages
+}
+
+type InvestmentListResponse struct {
+	Investments []*InvestmentResponse `json:"investments"`
+	Count       int                   `json:"count"`
+	Total       int                   `json:"total"`
+	NextCursor  *string               `json:"next_cursor"`
+}
+
+type DisbursementListResponse struct {
+	Disbursements  []*DisbursementResponse `json:"disbursements"`
+	Count          int                     `json:"count"`
+	TotalDisbursed float64                 `json:"total_disbursed"`
+}
+
+type NotificationResponse struct {
+	LoanID     int64 `json:"loan_id"`
+	Recipients int   `json:"recipients"`
+}
+
+type LoanActionsResponse struct {
+	LoanID  int64    `json:"loan_id"`
+	State   string   `json:"state"`
+	Actions []string `json:"actions"`
+}
+
+type DisbursementResponse struct {
+	ID                    int64     `json:"id"`
+	LoanID                int64     `json:"loan_id"`
+	Amount                float64   `json:"amount"`
+	SignedAgreementDocURL string    `json:"signed_agreement_doc"`
+	EmployeeID            string    `json:"employee_id"`
+	DisbursementDate      time.Time `json:"disbursement_date"`
+	CreatedAt             time.Time `json:"created_at"`
+}
+
+type InvestorPortfolioResponse struct {
+	InvestorEmail   string                `json:"investor_email"`
 	Investments     []*InvestmentResponse `json:"investments"`
+	Loans           []*LoanResponse       `json:"loans"`
+	Count           int                   `json:"count"`
+	Total           int                   `json:"total"`
+	NextCursor      *string               `json:"next_cursor"`
+	LoanCount       int                   `json:"loan_count"`
+	TotalInvested   float64               `json:"total_invested"`
+	ExpectedReturns float64               `json:"expected_returns"`
+}
+
+type InvestorReturnResponse struct {
+	InvestorEmail  string  `json:"investor_email"`
+	AmountInvested float64 `json:"amount_invested"`
+	SharePercent   float64 `json:"share_percent"`
+	ExpectedReturn float64 `json:"expected_return"`
+}
+
+type LoanReturnsResponse struct {
+	LoanID    int64                     `json:"loan_id"`
+	ROI       float64                   `json:"roi"`
+	Investors []*InvestorReturnResponse `json:"investors"`
+}
+
+type LoanFundingResponse struct {
+	LoanID        int64   `json:"loan_id"`
+	Principal     float64 `json:"principal"`
+	TotalInvested float64 `json:"total_invested"`
+	Remaining     float64 `json:"remaining"`
+	PercentFunded float64 `json:"percent_funded"`
+	InvestorCount int     `json:"investor_count"`
+}
+
+type LoanStatsResponse struct {
+	LoansByState            map[string]int `json:"loans_by_state"`
+	TotalLoans              int            `json:"total_loans"`
+	TotalDisbursedPrincipal float64        `json:"total_disbursed_principal"`
+	TotalInvested           float64        `json:"total_invested"`
+	AverageROI              float64        `json:"average_roi"`
+}
+
+type ROISummaryResponse struct {
+	States             []string `json:"states"` // Loan states the summary covers
+	LoanCount          int      `json:"loan_count"`
+	TotalInvested      float64  `json:"total_invested"`
+	WeightedAverageROI float64  `json:"weighted_average_roi"`
+	ProjectedReturns   float64  `json:"projected_returns"`
 }
 
-// Base URL for file serving - in production this would come from config
+type LoanStateExposureResponse struct {
+	Count     int     `json:"count"`
+	Principal float64 `json:"principal"`
+	Disbursed float64 `json:"disbursed"`
+}
+
+type BorrowerExposureResponse struct {
+	BorrowerIDNumber   string                                `json:"borrower_id_number"`
+	ActiveLoans        int                                   `json:"active_loans"`
+	DisbursedPrincipal float64                               `json:"disbursed_principal"`
+	ByState            map[string]*LoanStateExposureResponse `json:"by_state"`
+}
+
+// Outcome of one item of a bulk investment
 const (
-	BaseFileURL = "http://localhost:8080/files"
+	BulkItemSucceeded  = "succeeded"
+	BulkItemFailed     = "failed"
+	BulkItemRolledBack = "rolled_back"
+)
+
+type BulkInvestmentItemResponse struct {
+	Index      int                 `json:"index"`
+	LoanID     int64               `json:"loan_id"`
+	Status     string              `json:"status"`
+	Investment *InvestmentResponse `json:"investment,omitempty"`
+	Error      *ErrorResponse      `json:"error,omitempty"`
+}
+
+type BulkInvestmentResponse struct {
+	Succeeded int                           `json:"succeeded"`
+	Failed    int                           `json:"failed"`
+	Results   []*BulkInvestmentItemResponse `json:"results"`
+}
+
+// Default base URL of locally stored files, used when FILE_BASE_URL is not configured
+const (
+	DefaultBaseFileURL = "http://localhost:8080/files"
 )
 
 // Convert entity to response DTO with full URLs
@@ -52,41 +250,78 @@ func (h *LoanHandler) toLoanResponse(loan *entity.Loan) *LoanResponse {
 	response := &LoanResponse{
 		ID:                     loan.ID,
 		BorrowerIDNumber:       loan.BorrowerIDNumber,
-		PrincipalAmount:        loan.PrincipalAmount,
+		BorrowerEmail:          loan.BorrowerEmail,
+		PrincipalAmount:        loan.PrincipalAmount.Float64(),
+		Currency:               loan.Currency,
 		Rate:                   loan.Rate,
 		ROI:                    loan.ROI,
+		TermWeeks:              loan.TermWeeks,
+		TotalInterest:          loan.TotalInterest().Float64(),
+		TotalRepayable:         loan.TotalRepayable().Float64(),
+		FundedPercentage:       loan.FundedPercentage(),
+		MinInvestment:          loan.MinInvestment.Float64Ptr(),
+		MaxInvestment:          loan.MaxInvestment.Float64Ptr(),
+		MaxPerInvestor:         loan.MaxPerInvestor.Float64Ptr(),
+		InvestmentIncrement:    loan.InvestmentIncrement.Float64Ptr(),
 		State:                  string(loan.State),
 		AgreementLetterLink:    loan.AgreementLetterLink,
 		CreatedAt:              loan.CreatedAt,
 		UpdatedAt:              loan.UpdatedAt,
 		ApprovalEmployeeID:     loan.ApprovalEmployeeID,
 		ApprovalDate:           loan.ApprovalDate,
+		FundingDeadline:        loan.FundingDeadline,
+		ApprovalEffectiveAt:    loan.ApprovalEffectiveAt,
 		DisbursementEmployeeID: loan.DisbursementEmployeeID,
 		DisbursementDate:       loan.DisbursementDate,
+		MaturityDate:           loan.MaturityDate,
+		RejectionReason:        loan.RejectionReason,
+		RejectionEmployeeID:    loan.RejectionEmployeeID,
+		RejectionDate:          loan.RejectionDate,
+		CancellationReason:     loan.CancellationReason,
+		CancellationEmployeeID: loan.CancellationEmployeeID,
+		CancellationDate:       loan.CancellationDate,
+		DeletedAt:              loan.DeletedAt,
+		Version:                loan.Version,
+		RequiredApprovals:      loan.RequiredApprovals,
+
+		AllowMultipleInvestmentsPerInvestor: loan.AllowMultipleInvestmentsPerInvestor,
 	}
 
-	// Convert filename to full URL for approval proof picture
+	// Files are only served through the authenticated download endpoint
 	if loan.ApprovalProofPicture != nil && *loan.ApprovalProofPicture != "" {
-		fullURL := fmt.Sprintf("%s/proof_pictures/%s", BaseFileURL, *loan.ApprovalProofPicture)
-		response.ApprovalProofPictureURL = &fullURL
+		downloadURL := fileDownloadURL(loan.ID, FileTypeApprovalProof)
+		response.ApprovalProofPictureURL = &downloadURL
 	}
 
-	// Convert filename to full URL for signed agreement document
 	if loan.SignedAgreementDoc != nil && *loan.SignedAgreementDoc != "" {
-		fullURL := fmt.Sprintf("%s/signed_agreements/%s", BaseFileURL, *loan.SignedAgreementDoc)
-		response.SignedAgreementDocURL = &fullURL
+		downloadURL := fileDownloadURL(loan.ID, FileTypeSignedAgreement)
+		response.SignedAgreementDocURL = &downloadURL
 	}
 
 	return response
 }
 
+// fileURL returns the storage URL of a stored file. Files saved through the file
+// storage are stored as full URLs; older loans only stored the bare filename
+// under the base file URL.
+func (h *LoanHandler) fileURL(subdirectory, location string) string {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return location
+	}
+	return fmt.Sprintf("%s/%s/%s", h.baseFileURL, subdirectory, location)
+}
+
 func (h *LoanHandler) toInvestmentResponse(investment *entity.Investment) *InvestmentResponse {
 	return &InvestmentResponse{
 		ID:            investment.ID,
 		LoanID:        investment.LoanID,
 		InvestorEmail: investment.InvestorEmail,
-		Amount:        investment.Amount,
+		Amount:        investment.Amount.Float64(),
+		Language:      investment.Language,
 		CreatedAt:     investment.CreatedAt,
+
+		Currency:       investment.Currency,
+		OriginalAmount: investment.OriginalAmount.Float64(),
 	}
 }
 
@@ -98,11 +333,176 @@ func (h *LoanHandler) toLoanSummaryResponse(summary *usecase.LoanSummary) *LoanS
 		investmentResponses = append(investmentResponses, h.toInvestmentResponse(investment))
 	}
 
+	documentURLs := make([]string, 0, len(summary.Documents))
+	for _, document := range summary.Documents {
+		documentURLs = append(documentURLs, fmt.Sprintf("%s?document_id=%d", fileDownloadURL(document.LoanID, FileTypeSignedAgreement), document.ID))
+	}
+
+	approvalResponses := make([]*ApprovalResponse, 0, len(summary.Approvals))
+	for _, approval := range summary.Approvals {
+		approvalResponses = append(approvalResponses, &ApprovalResponse{
+			EmployeeID:   approval.EmployeeID,
+			ApprovalDate: approval.ApprovalDate,
+			CreatedAt:    approval.CreatedAt,
+		})
+	}
+
 	return &LoanSummaryResponse{
 		Loan:            loanResponse,
-		TotalInvested:   summary.TotalInvested,
-		RemainingAmount: summary.RemainingAmount,
+		TotalInvested:   summary.TotalInvested.Float64(),
+		RemainingAmount: summary.RemainingAmount.Float64(),
 		InvestmentCount: summary.InvestmentCount,
 		Investments:     investmentResponses,
+		Documents:       documentURLs,
+		Approvals:       approvalResponses,
+	}
+}
+
+func (h *LoanHandler) toLoanFundingResponse(funding *entity.LoanFunding) *LoanFundingResponse {
+	return &LoanFundingResponse{
+		LoanID:        funding.LoanID,
+		Principal:     funding.Principal.Float64(),
+		TotalInvested: funding.TotalInvested.Float64(),
+		Remaining:     funding.Remaining.Float64(),
+		PercentFunded: funding.PercentFunded,
+		InvestorCount: funding.InvestorCount,
+	}
+}
+
+func (h *LoanHandler) toLoanReturnsResponse(returns *usecase.LoanReturns) *LoanReturnsResponse {
+	investorResponses := make([]*InvestorReturnResponse, 0, len(returns.Investors))
+	for _, investor := range returns.Investors {
+		investorResponses = append(investorResponses, &InvestorReturnResponse{
+			InvestorEmail:  investor.InvestorEmail,
+			AmountInvested: investor.AmountInvested.Float64(),
+			SharePercent:   investor.SharePercent,
+			ExpectedReturn: investor.ExpectedReturn.Float64(),
+		})
 	}
+
+	return &LoanReturnsResponse{
+		LoanID:    returns.LoanID,
+		ROI:       returns.ROI,
+		Investors: investorResponses,
+	}
+}
+
+func (h *LoanHandler) toStateTransitionResponse(transition *entity.LoanStateTransition) *StateTransitionResponse {
+	return &StateTransitionResponse{
+		ID:        transition.ID,
+		LoanID:    transition.LoanID,
+		FromState: string(transition.FromState),
+		ToState:   string(transition.ToState),
+		Actor:     transition.Actor,
+		Note:      transition.Note,
+		CreatedAt: transition.CreatedAt,
+	}
+}
+
+func (h *LoanHandler) toDisbursementResponse(disbursement *entity.Disbursement) *DisbursementResponse {
+	return &DisbursementResponse{
+		ID:                    disbursement.ID,
+		LoanID:                disbursement.LoanID,
+		Amount:                disbursement.Amount.Float64(),
+		SignedAgreementDocURL: fmt.Sprintf("%s?disbursement_id=%d", fileDownloadURL(disbursement.LoanID, FileTypeSignedAgreement), disbursement.ID),
+		EmployeeID:            disbursement.EmployeeID,
+		DisbursementDate:      disbursement.DisbursementDate,
+		CreatedAt:             disbursement.CreatedAt,
+	}
+}
+
+func (h *LoanHandler) toInvestorPortfolioResponse(portfolio *usecase.InvestorPortfolio) *InvestorPortfolioResponse {
+	investmentResponses := make([]*InvestmentResponse, 0, len(portfolio.Page.Investments))
+	for _, investment := range portfolio.Page.Investments {
+		investmentResponses = append(investmentResponses, h.toInvestmentResponse(investment))
+	}
+
+	loanResponses := make([]*LoanResponse, 0, len(portfolio.Loans))
+	for _, loan := range portfolio.Loans {
+		loanResponses = append(loanResponses, h.toLoanResponse(loan))
+	}
+
+	return &InvestorPortfolioResponse{
+		InvestorEmail:   portfolio.InvestorEmail,
+		Investments:     investmentResponses,
+		Loans:           loanResponses,
+		Count:           len(investmentResponses),
+		Total:           portfolio.Page.Total,
+		NextCursor:      nextCursor(portfolio.Page),
+		LoanCount:       portfolio.Totals.LoanCount,
+		TotalInvested:   portfolio.Totals.TotalInvested.Float64(),
+		ExpectedReturns: portfolio.Totals.ExpectedReturns.Float64(),
+	}
+}
+
+func (h *LoanHandler) toLoanStatsResponse(stats *entity.LoanStats) *LoanStatsResponse {
+	loansByState := make(map[string]int, len(stats.CountByState))
+	for state, count := range stats.CountByState {
+		loansByState[string(state)] = count
+	}
+
+	return &LoanStatsResponse{
+		LoansByState:            loansByState,
+		TotalLoans:              stats.TotalLoans,
+		TotalDisbursedPrincipal: stats.TotalDisbursedPrincipal.Float64(),
+		TotalInvested:           stats.TotalInvested.Float64(),
+		AverageROI:              stats.AverageROI,
+	}
+}
+
+func (h *LoanHandler) toROISummaryResponse(summary *entity.ROISummary) *ROISummaryResponse {
+	states := make([]string, len(entity.ROISummaryStates))
+	for i, state := range entity.ROISummaryStates {
+		states[i] = string(state)
+	}
+
+	return &ROISummaryResponse{
+		States:             states,
+		LoanCount:          summary.LoanCount,
+		TotalInvested:      summary.TotalInvested.Float64(),
+		WeightedAverageROI: summary.WeightedAverageROI,
+		ProjectedReturns:   summary.ProjectedReturns.Float64(),
+	}
+}
+
+func (h *LoanHandler) toBorrowerExposureResponse(exposure *entity.BorrowerExposure) *BorrowerExposureResponse {
+	byState := make(map[string]*LoanStateExposureResponse, len(exposure.ByState))
+	for state, stateExposure := range exposure.ByState {
+		byState[string(state)] = &LoanStateExposureResponse{
+			Count:     stateExposure.Count,
+			Principal: stateExposure.Principal.Float64(),
+			Disbursed: stateExposure.Disbursed.Float64(),
+		}
+	}
+
+	return &BorrowerExposureResponse{
+		BorrowerIDNumber:   exposure.BorrowerIDNumber,
+		ActiveLoans:        exposure.ActiveLoans,
+		DisbursedPrincipal: exposure.DisbursedPrincipal.Float64(),
+		ByState:            byState,
+	}
+}
+
+func (h *LoanHandler) toBulkInvestmentResponse(results []*usecase.BulkInvestmentResult) *BulkInvestmentResponse {
+	response := &BulkInvestmentResponse{Results: make([]*BulkInvestmentItemResponse, 0, len(results))}
+
+	for i, result := range results {
+		item := &BulkInvestmentItemResponse{Index: i, LoanID: result.LoanID}
+		switch {
+		case result.Err == nil:
+			item.Status = BulkItemSucceeded
+			item.Investment = h.toInvestmentResponse(result.Investment)
+			response.Succeeded++
+		case errors.Is(result.Err, usecase.ErrBatchRolledBack):
+			item.Status = BulkItemRolledBack
+		default:
+			_, errorResponse := describeError(result.Err)
+			item.Status = BulkItemFailed
+			item.Error = &errorResponse
+			response.Failed++
+		}
+		response.Results = append(response.Results, item)
+	}
+
+	return response
 }
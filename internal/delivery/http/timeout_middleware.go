@@ -0,0 +1,28 @@
+package http
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultRequestTimeout bounds a request when no timeout is configured
+const DefaultRequestTimeout = 10 * time.Second
+
+// NewTimeoutMiddleware creates a middleware that sets a deadline of timeout on the
+// request context. Repository queries run with that context, so a slow query is
+// cancelled instead of hanging the handler, and respondError answers 504.
+func NewTimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
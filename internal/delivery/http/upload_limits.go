@@ -0,0 +1,46 @@
+package http
+
+import "fmt"
+
+const megabyte = 1024 * 1024
+
+// Default upload size limits, used when a limit is not configured
+const (
+	DefaultMaxImageSize    = 5 * megabyte  // Proof pictures
+	DefaultMaxDocumentSize = 15 * megabyte // Signed agreements
+)
+
+// UploadLimits caps the size in bytes of uploaded files per file type
+type UploadLimits struct {
+	MaxImageSize    int64
+	MaxDocumentSize int64
+}
+
+// withDefaults fills unset limits with the defaults
+func (l UploadLimits) withDefaults() UploadLimits {
+	if l.MaxImageSize <= 0 {
+		l.MaxImageSize = DefaultMaxImageSize
+	}
+	if l.MaxDocumentSize <= 0 {
+		l.MaxDocumentSize = DefaultMaxDocumentSize
+	}
+	return l
+}
+
+// MaxMultipartMemory is the largest limit, so any accepted upload can be parsed in memory
+func (l UploadLimits) MaxMultipartMemory() int64 {
+	l = l.withDefaults()
+	return max(l.MaxImageSize, l.MaxDocumentSize)
+}
+
+// formatSize renders a byte count for error messages, e.g. 5MB or 1536KB
+func formatSize(size int64) string {
+	switch {
+	case size%megabyte == 0:
+		return fmt.Sprintf("%dMB", size/megabyte)
+	case size%1024 == 0:
+		return fmt.Sprintf("%dKB", size/1024)
+	default:
+		return fmt.Sprintf("%d bytes", size)
+	}
+}
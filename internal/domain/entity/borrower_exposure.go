@@ -0,0 +1,43 @@
+package entity
+
+// ActiveLoanStates are the states of approved loans that have not ended by
+// rejection, cancellation or expiry
+var ActiveLoanStates = []LoanState{StateApproved, StateInvested, StatePartiallyDisbursed, StateDisbursed}
+
+// IsActive reports whether loans in the state count toward a borrower's exposure
+func (s LoanState) IsActive() bool {
+	for _, active := range ActiveLoanStates {
+		if s == active {
+			return true
+		}
+	}
+	return false
+}
+
+// LoanStateExposure summarizes a borrower's loans in one state
+type LoanStateExposure struct {
+	Count     int
+	Principal Money
+	Disbursed Money // Sum of the disbursed tranches
+}
+
+// BorrowerExposure summarizes the platform's exposure to one borrower across
+// all their live loans
+type BorrowerExposure struct {
+	BorrowerIDNumber   string
+	ActiveLoans        int
+	DisbursedPrincipal Money // Principal paid out to the borrower over every tranche
+	ByState            map[LoanState]*LoanStateExposure
+}
+
+// NewBorrowerExposure creates an empty exposure with zero totals for every loan state
+func NewBorrowerExposure(borrowerIDNumber string) *BorrowerExposure {
+	exposure := &BorrowerExposure{
+		BorrowerIDNumber: borrowerIDNumber,
+		ByState:          make(map[LoanState]*LoanStateExposure),
+	}
+	for state := range NewLoanStats().CountByState {
+		exposure.ByState[state] = &LoanStateExposure{}
+	}
+	return exposure
+}
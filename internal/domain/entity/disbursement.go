@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// Disbursement records one tranche of a loan paid out to the borrower
+type Disbursement struct {
+	ID                 int64
+	LoanID             int64
+	Amount             Money
+	SignedAgreementDoc string
+	EmployeeID         string
+	DisbursementDate   time.Time
+	CreatedAt          time.Time
+}
@@ -0,0 +1,50 @@
+package entity
+
+import "errors"
+
+// Sentinel errors describing the kind of failure, checked with errors.Is
+var (
+	ErrLoanNotFound       = errors.New("loan not found")
+	ErrInvestmentNotFound = errors.New("investment not found")
+	ErrInvalidState       = errors.New("invalid loan state")
+	ErrInvestmentExceeds  = errors.New("investment amount exceeds remaining loan amount")
+	ErrValidation         = errors.New("validation failed")
+	ErrForbidden          = errors.New("action not permitted")
+
+	// ErrDuplicateInvestment is returned when an investor invests twice in a loan that allows one investment per investor
+	ErrDuplicateInvestment = errors.New("investor has already invested in this loan")
+
+	// ErrDuplicateIdempotencyKey is returned when an investment with the same idempotency key already exists
+	ErrDuplicateIdempotencyKey = errors.New("duplicate idempotency key")
+
+	// ErrConcurrentModification is returned when a loan changed between being read and written back
+	ErrConcurrentModification = errors.New("loan was modified by another request, reload it and retry")
+
+	// ErrConstraintViolation is returned when a write breaks a database constraint
+	// that the domain checks did not catch, such as a row changed concurrently
+	ErrConstraintViolation = errors.New("constraint violation")
+
+	// ErrFileNotFound is returned when a loan has no such file or the storage no longer holds it
+	ErrFileNotFound = errors.New("file not found")
+)
+
+// DomainError pairs a sentinel error with a more specific human-readable message
+type DomainError struct {
+	Kind    error
+	Message string
+}
+
+// NewDomainError creates a domain error of the given kind
+func NewDomainError(kind error, message string) error {
+	return &DomainError{Kind: kind, Message: message}
+}
+
+// Error returns the human-readable message
+func (e *DomainError) Error() string {
+	return e.Message
+}
+
+// Unwrap exposes the sentinel so errors.Is matches the error kind
+func (e *DomainError) Unwrap() error {
+	return e.Kind
+}
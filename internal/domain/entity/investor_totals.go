@@ -0,0 +1,9 @@
+package entity
+
+// InvestorTotals summarizes one investor's investments across all loans
+type InvestorTotals struct {
+	InvestmentCount int
+	LoanCount       int
+	TotalInvested   Money
+	ExpectedReturns Money // Sum of every investment's amount * ROI / 100
+}
@@ -0,0 +1,8 @@
+package entity
+
+// LedgerInvestment is an investment of the investment ledger, listed with the
+// state of its loan
+type LedgerInvestment struct {
+	Investment *Investment
+	LoanState  LoanState
+}
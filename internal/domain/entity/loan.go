@@ -1,7 +1,10 @@
 package entity
 
 import (
-	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -13,29 +16,111 @@ const (
 	StateApproved  LoanState = "approved"
 	StateInvested  LoanState = "invested"
 	StateDisbursed LoanState = "disbursed"
+	StateRejected  LoanState = "rejected"
+	StateCancelled LoanState = "cancelled"
+
+	// StatePartiallyDisbursed is an invested loan paid out in tranches that do not yet add up to the principal
+	StatePartiallyDisbursed LoanState = "partially_disbursed"
+
+	// StateExpired is an approved loan that wasn't fully funded before its funding deadline
+	StateExpired LoanState = "expired"
+
+	// StatePendingApproval is a loan requiring several approvals that has some, but not all of them
+	StatePendingApproval LoanState = "pending_approval"
+)
+
+// LoanAction is an action that moves a loan to another state
+type LoanAction string
+
+const (
+	ActionApprove  LoanAction = "approve"
+	ActionInvest   LoanAction = "invest"
+	ActionDisburse LoanAction = "disburse"
+	ActionReject   LoanAction = "reject"
+	ActionCancel   LoanAction = "cancel"
+)
+
+// FundingStatus groups loans by how much of the principal is invested
+type FundingStatus string
+
+const (
+	FundingOpen         FundingStatus = "open"          // Less than AlmostFundedRatio invested
+	FundingAlmostFunded FundingStatus = "almost_funded" // At least AlmostFundedRatio invested, but not fully
+	FundingFunded       FundingStatus = "funded"        // Fully invested
 )
 
+// AlmostFundedRatio is the invested share of the principal from which a loan is almost funded
+const AlmostFundedRatio = 0.8
+
+// clock is the time source behind Now
+var clock = time.Now
+
+// Now returns the current time in UTC. Persisted timestamps are always set by
+// the application from this clock rather than by database defaults.
+func Now() time.Time {
+	return clock().UTC()
+}
+
+// SetClock makes Now read the time from now until the returned restore
+// function is called. Tests use it to move past deadlines.
+func SetClock(now func() time.Time) (restore func()) {
+	previous := clock
+	clock = now
+	return func() { clock = previous }
+}
+
 // Loan represents the core loan entity
 type Loan struct {
 	ID                  int64
 	BorrowerIDNumber    string
-	PrincipalAmount     float64
+	BorrowerEmail       *string // Optional, used for borrower notifications
+	PrincipalAmount     Money
+	Currency            string  // ISO 4217 code the loan is denominated in, DefaultCurrency unless given
 	Rate                float64 // Interest rate for borrower
 	ROI                 float64 // Return of investment for investors
+	TermWeeks           int     // Repayment term; the loan matures this many weeks after disbursement
+	MinInvestment       *Money  // Optional, smallest amount accepted per investment
+	MaxInvestment       *Money  // Optional, largest amount accepted per investment
+	MaxPerInvestor      *Money  // Optional, largest combined amount one investor may put in
+	InvestmentIncrement *Money  // Optional, investments must be whole multiples of it, except the final top-up
 	State               LoanState
 	AgreementLetterLink string
 	CreatedAt           time.Time
 	UpdatedAt           time.Time
+	DeletedAt           *time.Time // Set when the loan is soft-deleted
+	Version             int        // Incremented on every write, a write based on an older version is rejected
+
+	// TotalInvested is the sum of the loan's investments, computed when the loan is read
+	TotalInvested Money
+
+	// AllowMultipleInvestmentsPerInvestor lets an investor invest more than once, true by default
+	AllowMultipleInvestmentsPerInvestor bool
+
+	// RequiredApprovals is how many distinct employees must approve the loan, see RequiredApprovalsFor
+	RequiredApprovals int
 
 	// Approval information
 	ApprovalProofPicture *string
 	ApprovalEmployeeID   *string
 	ApprovalDate         *time.Time
+	FundingDeadline      *time.Time // Set on approval when a funding window is configured, the loan expires unless fully funded by then
+	ApprovalEffectiveAt  *time.Time // Set when the approval was scheduled, the loan stays proposed until then
 
 	// Disbursement information
 	SignedAgreementDoc     *string
 	DisbursementEmployeeID *string
 	DisbursementDate       *time.Time
+	MaturityDate           *time.Time // Disbursement date plus the term
+
+	// Rejection information
+	RejectionReason     *string
+	RejectionEmployeeID *string
+	RejectionDate       *time.Time
+
+	// Cancellation information
+	CancellationReason     *string
+	CancellationEmployeeID *string
+	CancellationDate       *time.Time
 }
 
 // Investment represents an investment in a loan
@@ -43,111 +128,750 @@ type Investment struct {
 	ID            int64
 	LoanID        int64
 	InvestorEmail string
-	Amount        float64
+	Amount        Money // In the loan currency, counts toward funding
 	CreatedAt     time.Time
+
+	// Currency and OriginalAmount record the investment as made by the investor,
+	// before Amount was converted to the loan currency
+	Currency       string
+	OriginalAmount Money
+
+	// IdempotencyKey is the client-supplied key used to deduplicate retried requests
+	IdempotencyKey *string
+
+	// Language the investor wants notification emails in, e.g. LanguageIndonesian
+	Language string
 }
 
+// DefaultCurrency is the currency of loans created without one
+const DefaultCurrency = "IDR"
+
+// Languages of the notification emails
+const (
+	LanguageEnglish    = "en" // Default
+	LanguageIndonesian = "id"
+)
+
 // Business rules and validation methods
 
-// ValidateBorrowerIDNumber validates the borrower ID format and length
-func ValidateBorrowerIDNumber(borrowerID string) error {
+// borrowerIDLength is the length of an Indonesian KTP number (NIK)
+const borrowerIDLength = 16
+
+// ValidateBorrowerID validates the borrower ID is a KTP number of exactly 16 digits
+func ValidateBorrowerID(borrowerID string) error {
 	if len(borrowerID) == 0 {
-		return errors.New("borrower ID number cannot be empty")
+		return NewDomainError(ErrValidation, "borrower ID number cannot be empty")
+	}
+	if len(borrowerID) != borrowerIDLength {
+		return NewDomainError(ErrValidation, fmt.Sprintf("borrower ID number must be exactly %d digits", borrowerIDLength))
+	}
+	for _, r := range borrowerID {
+		if r < '0' || r > '9' {
+			return NewDomainError(ErrValidation, "borrower ID number must contain digits only")
+		}
+	}
+	return nil
+}
+
+// ValidateBorrowerEmail validates the optional borrower email address
+func ValidateBorrowerEmail(email string) error {
+	if email == "" {
+		return nil
+	}
+	if _, err := mail.ParseAddress(email); err != nil {
+		return NewDomainError(ErrValidation, "borrower email must be a valid email address")
 	}
-	if len(borrowerID) > 16 {
-		return errors.New("borrower ID number cannot exceed 16 characters")
+	return nil
+}
+
+// ValidateRates ensures the investor ROI does not exceed the borrower interest rate
+func ValidateRates(rate, roi float64) error {
+	if roi > rate {
+		return NewDomainError(ErrValidation, fmt.Sprintf("ROI (%.2f) cannot exceed borrower interest rate (%.2f)", roi, rate))
+	}
+	return nil
+}
+
+// ValidateTerm ensures the loan term is at least one week
+func ValidateTerm(termWeeks int) error {
+	if termWeeks <= 0 {
+		return NewDomainError(ErrValidation, "term_weeks must be greater than zero")
+	}
+	return nil
+}
+
+// ValidateAgreementLetterLink ensures the agreement letter link is an http or
+// https URL with a host. When allowedDomains is not empty the host must be one
+// of them or a subdomain of one.
+func ValidateAgreementLetterLink(link string, allowedDomains []string) error {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return NewDomainError(ErrValidation, "agreement letter link must be a valid http or https URL")
+	}
+	if len(allowedDomains) == 0 {
+		return nil
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range allowedDomains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return nil
+		}
+	}
+	return NewDomainError(ErrValidation, fmt.Sprintf("agreement letter link host %s is not an allowed domain", host))
+}
+
+// ValidateCurrency ensures a currency is an ISO 4217 code of three uppercase letters
+func ValidateCurrency(currency string) error {
+	if len(currency) != 3 {
+		return NewDomainError(ErrValidation, "currency must be a three-letter ISO 4217 code")
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return NewDomainError(ErrValidation, "currency must be a three-letter ISO 4217 code in uppercase")
+		}
+	}
+	return nil
+}
+
+// ValidateActionDate ensures the date of an action on a loan, named by field, is
+// neither after now nor before the loan was created. Dates are entered with second
+// precision, so createdAt is truncated to the second before comparing.
+func ValidateActionDate(field string, date, createdAt, now time.Time) error {
+	if date.After(now) {
+		return NewDomainError(ErrValidation, field+" cannot be in the future")
+	}
+	if date.Before(createdAt.Truncate(time.Second)) {
+		return NewDomainError(ErrValidation, field+" cannot be before the loan was created")
+	}
+	return nil
+}
+
+// RequiredApprovalsFor returns how many distinct employees must approve a loan
+// of principal: two above a positive dualApprovalThreshold, otherwise one
+func RequiredApprovalsFor(principal, dualApprovalThreshold Money) int {
+	if dualApprovalThreshold > 0 && principal > dualApprovalThreshold {
+		return 2
+	}
+	return 1
+}
+
+// LoanWarningThresholds are soft limits on new loans: a loan beyond them is
+// still created, with a warning for ops. A zero limit is not checked.
+type LoanWarningThresholds struct {
+	Rate      float64 // Borrower rate in percent
+	Principal Money
+}
+
+// ValidateInvestmentLimits ensures the optional per-investment limits satisfy min <= max <= principal
+// and that the per-investor cap fits between the minimum investment and the principal
+func ValidateInvestmentLimits(principalAmount Money, minInvestment, maxInvestment, maxPerInvestor, investmentIncrement *Money) error {
+	if minInvestment != nil {
+		if *minInvestment <= 0 {
+			return NewDomainError(ErrValidation, "minimum investment must be greater than zero")
+		}
+		if *minInvestment > principalAmount {
+			return NewDomainError(ErrValidation, fmt.Sprintf("minimum investment (%s) cannot exceed principal amount (%s)", *minInvestment, principalAmount))
+		}
+	}
+	if maxInvestment != nil {
+		if *maxInvestment <= 0 {
+			return NewDomainError(ErrValidation, "maximum investment must be greater than zero")
+		}
+		if *maxInvestment > principalAmount {
+			return NewDomainError(ErrValidation, fmt.Sprintf("maximum investment (%s) cannot exceed principal amount (%s)", *maxInvestment, principalAmount))
+		}
+	}
+	if minInvestment != nil && maxInvestment != nil && *minInvestment > *maxInvestment {
+		return NewDomainError(ErrValidation, fmt.Sprintf("minimum investment (%s) cannot exceed maximum investment (%s)", *minInvestment, *maxInvestment))
+	}
+	if maxPerInvestor != nil {
+		if *maxPerInvestor <= 0 {
+			return NewDomainError(ErrValidation, "maximum per investor must be greater than zero")
+		}
+		if *maxPerInvestor > principalAmount {
+			return NewDomainError(ErrValidation, fmt.Sprintf("maximum per investor (%s) cannot exceed principal amount (%s)", *maxPerInvestor, principalAmount))
+		}
+		if minInvestment != nil && *maxPerInvestor < *minInvestment {
+			return NewDomainError(ErrValidation, fmt.Sprintf("maximum per investor (%s) cannot be below minimum investment (%s)", *maxPerInvestor, *minInvestment))
+		}
+	}
+	if investmentIncrement != nil {
+		if *investmentIncrement <= 0 {
+			return NewDomainError(ErrValidation, "investment increment must be greater than zero")
+		}
+		if *investmentIncrement > principalAmount {
+			return NewDomainError(ErrValidation, fmt.Sprintf("investment increment (%s) cannot exceed principal amount (%s)", *investmentIncrement, principalAmount))
+		}
+		// Limits off the increment could not be reached exactly
+		if minInvestment != nil && *minInvestment%*investmentIncrement != 0 {
+			return NewDomainError(ErrValidation, fmt.Sprintf("minimum investment (%s) must be a multiple of the investment increment (%s)", *minInvestment, *investmentIncrement))
+		}
+		if maxInvestment != nil && *maxInvestment%*investmentIncrement != 0 {
+			return NewDomainError(ErrValidation, fmt.Sprintf("maximum investment (%s) must be a multiple of the investment increment (%s)", *maxInvestment, *investmentIncrement))
+		}
+	}
+	return nil
+}
+
+// Touch records that the loan changed now, leaving CreatedAt untouched
+func (l *Loan) Touch() {
+	l.UpdatedAt = Now()
+}
+
+// AvailableActions lists the actions the loan's state allows, in the order of
+// the loan lifecycle
+func (l *Loan) AvailableActions() []LoanAction {
+	checks := []struct {
+		action LoanAction
+		check  func() error
+	}{
+		{ActionApprove, l.CanBeApproved},
+		{ActionInvest, l.CanReceiveInvestment},
+		{ActionDisburse, l.CanBeDisbursed},
+		{ActionReject, l.CanBeRejected},
+		{ActionCancel, l.CanBeCancelled},
+	}
+
+	actions := make([]LoanAction, 0, len(checks))
+	for _, c := range checks {
+		if c.check() == nil {
+			actions = append(actions, c.action)
+		}
+	}
+	return actions
+}
+
+// CanBeUpdated checks if loan details can still be edited
+func (l *Loan) CanBeUpdated() error {
+	if l.State != StateProposed {
+		return NewDomainError(ErrInvalidState, "loan can only be edited in proposed state")
+	}
+	if l.HasScheduledApproval() {
+		return NewDomainError(ErrInvalidState, "loan with a scheduled approval cannot be edited")
+	}
+	return nil
+}
+
+// UpdateDetails replaces the editable loan details
+func (l *Loan) UpdateDetails(params UpdateLoanParams) error {
+	if err := l.CanBeUpdated(); err != nil {
+		return err
+	}
+
+	l.BorrowerIDNumber = params.BorrowerIDNumber
+	l.PrincipalAmount = params.PrincipalAmount
+	l.Rate = params.Rate
+	l.ROI = params.ROI
+	l.TermWeeks = params.TermWeeks
+	l.AgreementLetterLink = params.AgreementLetterLink
+	l.Touch()
+
+	return nil
+}
+
+// UpdateROI changes the return offered to investors of an approved loan. The
+// caller must make sure nobody has invested yet, since investors committed at
+// the old ROI.
+func (l *Loan) UpdateROI(roi float64) error {
+	if l.State != StateApproved {
+		return NewDomainError(ErrInvalidState, "ROI can only be changed on approved loans")
+	}
+	if roi <= 0 {
+		return NewDomainError(ErrValidation, "roi must be greater than zero")
+	}
+	if err := ValidateRates(l.Rate, roi); err != nil {
+		return err
+	}
+
+	l.ROI = roi
+	l.Touch()
+	return nil
+}
+
+// CanBeDeleted checks if loan can be soft-deleted
+func (l *Loan) CanBeDeleted() error {
+	if l.State != StateProposed && l.State != StateRejected {
+		return NewDomainError(ErrInvalidState, "loan can only be deleted in proposed or rejected state")
 	}
-	// Additional validation can be added here (e.g., numeric only, specific format)
 	return nil
 }
 
 // CanBeApproved checks if loan can be approved
 func (l *Loan) CanBeApproved() error {
-	if l.State != StateProposed {
-		return errors.New("loan can only be approved from proposed state")
+	if l.State != StateProposed && l.State != StatePendingApproval {
+		return NewDomainError(ErrInvalidState, "loan can only be approved from proposed or pending_approval state")
+	}
+	if l.HasScheduledApproval() {
+		return NewDomainError(ErrInvalidState, "loan already has a scheduled approval")
+	}
+	return nil
+}
+
+// HasScheduledApproval reports whether the loan waits for a scheduled approval to take effect
+func (l *Loan) HasScheduledApproval() bool {
+	return (l.State == StateProposed || l.State == StatePendingApproval) && l.ApprovalEffectiveAt != nil
+}
+
+// ValidateApprover checks the employee is not among the loan's previous approvers
+func (l *Loan) ValidateApprover(approvals []*LoanApproval, employeeID string) error {
+	for _, approval := range approvals {
+		if approval.EmployeeID == employeeID {
+			return NewDomainError(ErrInvalidState, "employee has already approved this loan, another approver is required")
+		}
+	}
+	return nil
+}
+
+// IsFinalApproval reports whether one more approval, after approvalCount
+// earlier ones, completes the approvals the loan requires
+func (l *Loan) IsFinalApproval(approvalCount int) bool {
+	return approvalCount+1 >= l.RequiredApprovals
+}
+
+// AwaitFurtherApproval records an approval that leaves the loan short of its
+// required approvals, moving it to pending_approval
+func (l *Loan) AwaitFurtherApproval(approvalDate time.Time) error {
+	if err := l.CanBeApproved(); err != nil {
+		return err
+	}
+	if err := ValidateActionDate("approval_date", approvalDate, l.CreatedAt, Now()); err != nil {
+		return err
 	}
+
+	l.State = StatePendingApproval
+	l.Touch()
+
 	return nil
 }
 
-// Approve transitions loan to approved state
-func (l *Loan) Approve(proofPicture, employeeID string, approvalDate time.Time) error {
+// Approve transitions loan to approved state. A positive fundingWindow sets
+// the funding deadline that long after the approval date.
+func (l *Loan) Approve(proofPicture, employeeID string, approvalDate time.Time, fundingWindow time.Duration) error {
 	if err := l.CanBeApproved(); err != nil {
 		return err
 	}
+	if err := ValidateActionDate("approval_date", approvalDate, l.CreatedAt, Now()); err != nil {
+		return err
+	}
 
 	l.State = StateApproved
 	l.ApprovalProofPicture = &proofPicture
 	l.ApprovalEmployeeID = &employeeID
 	l.ApprovalDate = &approvalDate
-	l.UpdatedAt = time.Now()
+	if fundingWindow > 0 {
+		fundingDeadline := approvalDate.Add(fundingWindow)
+		l.FundingDeadline = &fundingDeadline
+	}
+	l.Touch()
 
 	return nil
 }
 
-// CanReceiveInvestment checks if loan can receive investments
+// ScheduleApproval records an approval that takes effect at effectiveAt. The
+// loan stays proposed until ActivateScheduledApproval moves it to approved.
+func (l *Loan) ScheduleApproval(proofPicture, employeeID string, approvalDate, effectiveAt time.Time) error {
+	if err := l.CanBeApproved(); err != nil {
+		return err
+	}
+	now := Now()
+	if err := ValidateActionDate("approval_date", approvalDate, l.CreatedAt, now); err != nil {
+		return err
+	}
+	if !effectiveAt.After(now) {
+		return NewDomainError(ErrValidation, "effective_at must be in the future")
+	}
+
+	l.ApprovalProofPicture = &proofPicture
+	l.ApprovalEmployeeID = &employeeID
+	l.ApprovalDate = &approvalDate
+	l.ApprovalEffectiveAt = &effectiveAt
+	l.Touch()
+
+	return nil
+}
+
+// IsApprovalDue reports whether the loan's scheduled approval should take effect
+func (l *Loan) IsApprovalDue(now time.Time) bool {
+	return l.HasScheduledApproval() && !now.Before(*l.ApprovalEffectiveAt)
+}
+
+// ActivateScheduledApproval transitions a loan whose scheduled approval is due
+// to approved. The funding window runs from the time the approval took effect.
+func (l *Loan) ActivateScheduledApproval(now time.Time, fundingWindow time.Duration) error {
+	if !l.IsApprovalDue(now) {
+		return NewDomainError(ErrInvalidState, "loan has no scheduled approval due")
+	}
+
+	l.State = StateApproved
+	if fundingWindow > 0 {
+		fundingDeadline := l.ApprovalEffectiveAt.Add(fundingWindow)
+		l.FundingDeadline = &fundingDeadline
+	}
+	l.Touch()
+
+	return nil
+}
+
+// CanReplaceApprovalProof checks if the approval proof picture can still be replaced
+func (l *Loan) CanReplaceApprovalProof() error {
+	if l.State != StateApproved && l.State != StateInvested {
+		return NewDomainError(ErrInvalidState, "approval proof can only be replaced while the loan is approved or invested")
+	}
+	return nil
+}
+
+// ReplaceApprovalProof swaps the approval proof picture, returning the previous one
+func (l *Loan) ReplaceApprovalProof(proofPicture string) (string, error) {
+	if err := l.CanReplaceApprovalProof(); err != nil {
+		return "", err
+	}
+
+	var previous string
+	if l.ApprovalProofPicture != nil {
+		previous = *l.ApprovalProofPicture
+	}
+	l.ApprovalProofPicture = &proofPicture
+	l.Touch()
+
+	return previous, nil
+}
+
+// CanBeRejected checks if loan can be rejected
+func (l *Loan) CanBeRejected() error {
+	if l.State != StateProposed && l.State != StatePendingApproval {
+		return NewDomainError(ErrInvalidState, "loan can only be rejected from proposed or pending_approval state")
+	}
+	return nil
+}
+
+// Reject transitions loan to rejected state
+func (l *Loan) Reject(employeeID, reason string, rejectedAt time.Time) error {
+	if err := l.CanBeRejected(); err != nil {
+		return err
+	}
+	if err := ValidateActionDate("rejection_date", rejectedAt, l.CreatedAt, Now()); err != nil {
+		return err
+	}
+
+	l.State = StateRejected
+	l.RejectionReason = &reason
+	l.RejectionEmployeeID = &employeeID
+	l.RejectionDate = &rejectedAt
+	l.Touch()
+
+	return nil
+}
+
+// CanBeCancelled checks if loan can be cancelled
+func (l *Loan) CanBeCancelled() error {
+	if l.State != StateProposed && l.State != StatePendingApproval && l.State != StateApproved {
+		return NewDomainError(ErrInvalidState, "loan can only be cancelled from proposed, pending_approval or approved state")
+	}
+	return nil
+}
+
+// Cancel transitions loan to cancelled state
+func (l *Loan) Cancel(employeeID, reason string) error {
+	if err := l.CanBeCancelled(); err != nil {
+		return err
+	}
+
+	l.Touch()
+	cancelledAt := l.UpdatedAt
+	l.State = StateCancelled
+	l.CancellationReason = &reason
+	l.CancellationEmployeeID = &employeeID
+	l.CancellationDate = &cancelledAt
+
+	return nil
+}
+
+// CanReceiveInvestment checks if loan can receive investments. Investments are
+// timestamped when they are made, so the approval must already be in effect.
 func (l *Loan) CanReceiveInvestment() error {
+	if l.HasScheduledApproval() {
+		return NewDomainError(ErrInvalidState, "loan's approval takes effect at "+l.ApprovalEffectiveAt.UTC().Format(time.RFC3339)+", it cannot receive investments before then")
+	}
 	if l.State != StateApproved && l.State != StateInvested {
-		return errors.New("loan must be approved or already partially invested to receive investments")
+		return NewDomainError(ErrInvalidState, "loan must be approved or already partially invested to receive investments")
+	}
+	if err := l.ReadyForInvestment(); err != nil {
+		return err
+	}
+	if l.ApprovalDate.After(Now()) {
+		return NewDomainError(ErrInvalidState, "loan's approval date "+l.ApprovalDate.UTC().Format(time.RFC3339)+" is in the future, it cannot receive investments before then")
+	}
+	if l.IsFundingOverdue(Now()) {
+		return NewDomainError(ErrInvalidState, "loan's funding deadline has passed")
+	}
+	return nil
+}
+
+// ReadyForInvestment checks the loan has the data investors rely on: an
+// agreement letter to sign and a complete approval record. Loans stored before
+// these were enforced may lack them.
+func (l *Loan) ReadyForInvestment() error {
+	var missing []string
+	if strings.TrimSpace(l.AgreementLetterLink) == "" {
+		missing = append(missing, "agreement letter link")
+	}
+	if l.ApprovalProofPicture == nil || *l.ApprovalProofPicture == "" {
+		missing = append(missing, "approval proof picture")
+	}
+	if l.ApprovalEmployeeID == nil || *l.ApprovalEmployeeID == "" {
+		missing = append(missing, "approval employee ID")
+	}
+	if l.ApprovalDate == nil {
+		missing = append(missing, "approval date")
+	}
+	if len(missing) > 0 {
+		return NewDomainError(ErrInvalidState, "loan cannot receive investments, it is missing its "+strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// IsFundingOverdue reports whether the loan is still waiting for investments
+// after its funding deadline
+func (l *Loan) IsFundingOverdue(now time.Time) bool {
+	return l.State == StateApproved && l.FundingDeadline != nil && !now.Before(*l.FundingDeadline)
+}
+
+// CanBeExpired checks if loan can be expired. Only approved loans can; invested
+// loans are fully funded.
+func (l *Loan) CanBeExpired() error {
+	if l.State != StateApproved {
+		return NewDomainError(ErrInvalidState, "loan can only be expired from approved state")
+	}
+	return nil
+}
+
+// Expire transitions loan to expired state. Its investments are kept for a later refund flow.
+func (l *Loan) Expire() error {
+	if err := l.CanBeExpired(); err != nil {
+		return err
 	}
+
+	l.State = StateExpired
+	l.Touch()
+
 	return nil
 }
 
 // ValidateInvestmentAmount checks if investment amount is valid
-func (l *Loan) ValidateInvestmentAmount(amount float64, currentTotalInvestment float64) error {
+func (l *Loan) ValidateInvestmentAmount(amount Money, currentTotalInvestment Money) error {
 	if amount <= 0 {
-		return errors.New("investment amount must be greater than zero")
+		return NewDomainError(ErrValidation, "investment amount must be greater than zero")
+	}
+
+	remaining := l.PrincipalAmount - currentTotalInvestment
+	if amount > remaining {
+		return NewDomainError(ErrInvestmentExceeds, fmt.Sprintf("investment amount exceeds remaining loan amount: remaining %s", remaining))
+	}
+
+	// The final top-up that completes the loan may be smaller than the minimum
+	if l.MinInvestment != nil && amount < *l.MinInvestment && amount != remaining {
+		return NewDomainError(ErrValidation, fmt.Sprintf("investment amount is below the minimum investment of %s", *l.MinInvestment))
+	}
+
+	if l.MaxInvestment != nil && amount > *l.MaxInvestment {
+		return NewDomainError(ErrValidation, fmt.Sprintf("investment amount exceeds the maximum investment of %s", *l.MaxInvestment))
 	}
 
-	if currentTotalInvestment+amount > l.PrincipalAmount {
-		remaining := l.PrincipalAmount - currentTotalInvestment
-		return errors.New("investment amount exceeds remaining loan amount: " +
-			"remaining " + string(rune(remaining)))
+	// The final top-up is exempt too, the remaining amount need not be a multiple
+	if l.InvestmentIncrement != nil && amount%*l.InvestmentIncrement != 0 && amount != remaining {
+		message := fmt.Sprintf("investment amount must be a whole multiple of %s, or the remaining %s", *l.InvestmentIncrement, remaining)
+		if lower := amount - amount%*l.InvestmentIncrement; lower > 0 {
+			message += fmt.Sprintf("; the nearest lower amount is %s", lower)
+		}
+		return NewDomainError(ErrValidation, message)
 	}
 
 	return nil
 }
 
+// ValidateInvestorTotal checks that an investment keeps the investor within the per-investor cap
+func (l *Loan) ValidateInvestorTotal(amount Money, investorTotal Money) error {
+	if l.MaxPerInvestor != nil && investorTotal+amount > *l.MaxPerInvestor {
+		return NewDomainError(ErrValidation, fmt.Sprintf("investment exceeds the per-investor cap of %s: investor has already invested %s", *l.MaxPerInvestor, investorTotal))
+	}
+	return nil
+}
+
+// ValidateRepeatInvestment checks whether an investor who may already have invested can invest again
+func (l *Loan) ValidateRepeatInvestment(hasInvested bool) error {
+	if hasInvested && !l.AllowMultipleInvestmentsPerInvestor {
+		return NewDomainError(ErrDuplicateInvestment, "investor has already invested in this loan, which allows one investment per investor")
+	}
+	return nil
+}
+
 // MarkAsInvested transitions loan to invested state when fully funded
 func (l *Loan) MarkAsInvested() {
 	if l.State == StateApproved {
 		l.State = StateInvested
-		l.UpdatedAt = time.Now()
+		l.Touch()
+	}
+}
+
+// ForceInvested marks an approved or invested loan as invested during reconciliation,
+// e.g. after external funding left it approved. The investments must already add
+// up to the principal.
+func (l *Loan) ForceInvested(totalInvestment Money) error {
+	if l.State != StateApproved && l.State != StateInvested {
+		return NewDomainError(ErrInvalidState, "only approved or invested loans can be forced to invested")
+	}
+	if !l.IsFullyInvested(totalInvestment) {
+		return NewDomainError(ErrValidation, fmt.Sprintf("investments total %s but the principal is %s", totalInvestment, l.PrincipalAmount))
+	}
+
+	l.State = StateInvested
+	l.Touch()
+	return nil
+}
+
+// CanWithdrawInvestment checks if an investment can still be withdrawn from the loan
+func (l *Loan) CanWithdrawInvestment() error {
+	if l.State != StateApproved && l.State != StateInvested {
+		return NewDomainError(ErrInvalidState, "investments can only be withdrawn from approved or invested loans that are not yet disbursed")
+	}
+	return nil
+}
+
+// RevertToApproved moves an invested loan back to approved when it is no longer fully funded
+func (l *Loan) RevertToApproved(totalInvestment Money) {
+	if l.State == StateInvested && !l.IsFullyInvested(totalInvestment) {
+		l.State = StateApproved
+		l.Touch()
 	}
 }
 
 // CanBeDisbursed checks if loan can be disbursed
 func (l *Loan) CanBeDisbursed() error {
-	if l.State != StateInvested {
-		return errors.New("loan can only be disbursed from invested state")
+	if l.State != StateInvested && l.State != StatePartiallyDisbursed {
+		return NewDomainError(ErrInvalidState, "loan can only be disbursed from invested or partially disbursed state")
 	}
 	return nil
 }
 
-// Disburse transitions loan to disbursed state
-func (l *Loan) Disburse(signedAgreementDoc, employeeID string, disbursementDate time.Time) error {
+// CanResendInvestedNotification checks if the fully invested notification can be sent again
+func (l *Loan) CanResendInvestedNotification() error {
+	if l.State != StateInvested && l.State != StatePartiallyDisbursed && l.State != StateDisbursed {
+		return NewDomainError(ErrInvalidState, "fully invested notification can only be resent for invested or disbursed loans")
+	}
+	return nil
+}
+
+// GetRemainingDisbursement calculates the principal not yet paid out in tranches
+func (l *Loan) GetRemainingDisbursement(disbursedTotal Money) Money {
+	remaining := l.PrincipalAmount - disbursedTotal
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Disburse pays out a tranche of amount on top of the disbursedTotal already paid out.
+// The loan becomes disbursed once the tranches add up to the principal, and partially
+// disbursed until then. The disbursement fields record the latest tranche.
+// None of the employees in approvals may disburse it (four-eyes principle).
+func (l *Loan) Disburse(amount, disbursedTotal Money, approvals []*LoanApproval, signedAgreementDoc, employeeID string, disbursementDate time.Time) (*Disbursement, error) {
 	if err := l.CanBeDisbursed(); err != nil {
-		return err
+		return nil, err
+	}
+	for _, approval := range approvals {
+		if approval.EmployeeID == employeeID {
+			return nil, NewDomainError(ErrForbidden, "an employee who approved the loan cannot also disburse it")
+		}
+	}
+	if err := ValidateActionDate("disbursement_date", disbursementDate, l.CreatedAt, Now()); err != nil {
+		return nil, err
+	}
+	if amount <= 0 {
+		return nil, NewDomainError(ErrValidation, "disbursement amount must be greater than zero")
+	}
+	remaining := l.GetRemainingDisbursement(disbursedTotal)
+	if amount > remaining {
+		return nil, NewDomainError(ErrValidation, fmt.Sprintf("disbursement amount exceeds remaining principal: remaining %s", remaining))
 	}
 
-	l.State = StateDisbursed
 	l.SignedAgreementDoc = &signedAgreementDoc
 	l.DisbursementEmployeeID = &employeeID
 	l.DisbursementDate = &disbursementDate
-	l.UpdatedAt = time.Now()
+	if amount == remaining {
+		l.State = StateDisbursed
+		maturityDate := l.CalculateMaturityDate(disbursementDate)
+		l.MaturityDate = &maturityDate
+	} else {
+		l.State = StatePartiallyDisbursed
+	}
+	l.Touch()
 
-	return nil
+	return &Disbursement{
+		LoanID:             l.ID,
+		Amount:             amount,
+		SignedAgreementDoc: signedAgreementDoc,
+		EmployeeID:         employeeID,
+		DisbursementDate:   disbursementDate,
+		CreatedAt:          l.UpdatedAt,
+	}, nil
 }
 
-// IsFullyInvested checks if the loan is fully invested
-func (l *Loan) IsFullyInvested(totalInvestment float64) bool {
-	return totalInvestment == l.PrincipalAmount
+// CalculateMaturityDate returns when a loan disbursed at disbursementDate matures
+func (l *Loan) CalculateMaturityDate(disbursementDate time.Time) time.Time {
+	return disbursementDate.AddDate(0, 0, 7*l.TermWeeks)
+}
+
+// weeksPerYear converts the term in weeks to the yearly basis of the interest rate
+const weeksPerYear = 52
+
+// TermYears returns the loan term in years
+func (l *Loan) TermYears() float64 {
+	return float64(l.TermWeeks) / weeksPerYear
+}
+
+// TotalInterest returns the interest the borrower pays over the whole term.
+// Interest is simple, not compounded: Rate is a yearly percentage of the principal.
+func (l *Loan) TotalInterest() Money {
+	return l.PrincipalAmount.Percent(l.Rate * l.TermYears())
+}
+
+// TotalRepayable returns the principal plus the simple interest over the term,
+// i.e. PrincipalAmount * (1 + Rate/100 * TermYears)
+func (l *Loan) TotalRepayable() Money {
+	return l.PrincipalAmount + l.TotalInterest()
+}
+
+// FundedPercentage returns the invested share of the principal, from 0 to 100
+func (l *Loan) FundedPercentage() float64 {
+	if l.PrincipalAmount <= 0 {
+		return 0
+	}
+	return float64(l.TotalInvested) / float64(l.PrincipalAmount) * 100
+}
+
+// IsFullyInvested checks if the investments add up to the principal. Money is
+// exact, so investments that sum to the principal always match it.
+func (l *Loan) IsFullyInvested(totalInvestment Money) bool {
+	return totalInvestment >= l.PrincipalAmount
 }
 
 // GetRemainingAmount calculates remaining investment amount needed
-func (l *Loan) GetRemainingAmount(totalInvestment float64) float64 {
+func (l *Loan) GetRemainingAmount(totalInvestment Money) Money {
 	remaining := l.PrincipalAmount - totalInvestment
 	if remaining < 0 {
 		return 0
 	}
 	return remaining
 }
+
+// CapInvestmentAmount lowers an investment amount to the remaining amount
+// needed. A fully invested loan leaves the amount as is, for validation to reject.
+func (l *Loan) CapInvestmentAmount(amount, totalInvestment Money) Money {
+	remaining := l.GetRemainingAmount(totalInvestment)
+	if remaining > 0 && amount > remaining {
+		return remaining
+	}
+	return amount
+}
@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// LoanApproval records one employee's sign-off on a loan. Loans above the dual
+// approval threshold need approvals from two distinct employees.
+type LoanApproval struct {
+	ID           int64
+	LoanID       int64
+	EmployeeID   string
+	ProofPicture string // Stored URL of the proof picture uploaded with the approval
+	ApprovalDate time.Time
+	CreatedAt    time.Time
+}
@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// LoanDocument records one signed document uploaded when a tranche was disbursed
+type LoanDocument struct {
+	ID             int64
+	LoanID         int64
+	DisbursementID int64
+	Document       string // Stored URL (or legacy bare filename) of the document
+	CreatedAt      time.Time
+}
@@ -0,0 +1,24 @@
+package entity
+
+// LoanFunding summarizes how far a loan is funded, without its investments
+type LoanFunding struct {
+	LoanID        int64
+	Principal     Money
+	TotalInvested Money
+	Remaining     Money
+	PercentFunded float64
+	InvestorCount int // Distinct investor emails
+}
+
+// NewLoanFunding builds the funding progress of a loan read with its invested
+// total, given the number of distinct investors
+func NewLoanFunding(loan *Loan, investorCount int) *LoanFunding {
+	return &LoanFunding{
+		LoanID:        loan.ID,
+		Principal:     loan.PrincipalAmount,
+		TotalInvested: loan.TotalInvested,
+		Remaining:     loan.GetRemainingAmount(loan.TotalInvested),
+		PercentFunded: loan.FundedPercentage(),
+		InvestorCount: investorCount,
+	}
+}
@@ -8,9 +8,29 @@ import "time"
 // CreateLoanParams represents parameters for creating a new loan
 type CreateLoanParams struct {
 	BorrowerIDNumber    string
-	PrincipalAmount     float64
+	BorrowerEmail       string // Optional
+	PrincipalAmount     Money
+	Currency            string // Optional, defaults to DefaultCurrency
 	Rate                float64
 	ROI                 float64
+	TermWeeks           int
+	MinInvestment       *Money // Optional
+	MaxInvestment       *Money // Optional
+	MaxPerInvestor      *Money // Optional
+	InvestmentIncrement *Money // Optional
+	AgreementLetterLink string
+
+	// AllowMultipleInvestmentsPerInvestor is optional, nil keeps the default of true
+	AllowMultipleInvestmentsPerInvestor *bool
+}
+
+// UpdateLoanParams represents parameters for editing a proposed loan
+type UpdateLoanParams struct {
+	BorrowerIDNumber    string
+	PrincipalAmount     Money
+	Rate                float64
+	ROI                 float64
+	TermWeeks           int
 	AgreementLetterLink string
 }
 
@@ -19,17 +39,52 @@ type ApproveLoanParams struct {
 	ProofPicture string
 	EmployeeID   string
 	ApprovalDate time.Time
+	EffectiveAt  *time.Time // Optional, a future time the approval is scheduled to take effect at
 }
 
 // InvestLoanParams represents parameters for investing in a loan
 type InvestLoanParams struct {
+	InvestorEmail  string
+	Amount         float64 // In currency units as submitted, rounded to the hundredth when invested
+	Currency       string  // Optional, currency of Amount, defaults to the loan currency
+	IdempotencyKey string  // Optional, replays the original investment when repeated
+	Language       string  // Optional, language of the investor's emails, defaults to LanguageEnglish
+	AllowPartial   bool    // Invest only the remaining amount when Amount exceeds it, instead of rejecting
+}
+
+// BulkInvestmentItem represents one investment of a bulk investment
+type BulkInvestmentItem struct {
+	LoanID        int64
 	InvestorEmail string
-	Amount        float64
+	Amount        float64 // In currency units as submitted, like InvestLoanParams.Amount
+	Currency      string
+	Language      string
+}
+
+// BulkInvestParams represents parameters for investing in several loans at once
+type BulkInvestParams struct {
+	Items        []BulkInvestmentItem
+	AllowPartial bool // Save the valid items even when others fail, instead of none
 }
 
 // DisburseLoanParams represents parameters for disbursing a loan
 type DisburseLoanParams struct {
-	SignedAgreementDoc string
-	EmployeeID         string
-	DisbursementDate   time.Time
+	SignedAgreementDocs []string // Stored URLs of the signed documents, the first is the tranche's agreement
+	EmployeeID          string
+	DisbursementDate    time.Time
+	Amount              *Money // Optional, defaults to the remaining principal
+}
+
+// RejectLoanParams represents parameters for rejecting a loan
+type RejectLoanParams struct {
+	Reason        string
+	EmployeeID    string
+	RejectionDate time.Time
+}
+
+// CancelLoanParams represents parameters for cancelling a loan
+type CancelLoanParams struct {
+	Reason     string
+	EmployeeID string
+	Force      bool // Cancel even if the loan already has investments
 }
@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+// SystemActor is the actor of state changes made by the application itself, such as expiring loans
+const SystemActor = "system"
+
+// LoanStateTransition records a single change of a loan's state for auditing
+type LoanStateTransition struct {
+	ID        int64
+	LoanID    int64
+	FromState LoanState
+	ToState   LoanState
+	Actor     string // Employee ID, investor email or SystemActor that triggered the change
+	Note      string // Describes changes that keep the state, such as ROI updates
+	CreatedAt time.Time
+}
+
+// NewLoanStateTransition records the loan moving from the given state to its current state
+func NewLoanStateTransition(loan *Loan, fromState LoanState, actor string) *LoanStateTransition {
+	return &LoanStateTransition{
+		LoanID:    loan.ID,
+		FromState: fromState,
+		ToState:   loan.State,
+		Actor:     actor,
+		CreatedAt: loan.UpdatedAt,
+	}
+}
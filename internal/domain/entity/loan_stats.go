@@ -0,0 +1,28 @@
+package entity
+
+// LoanStats summarizes the loan portfolio
+type LoanStats struct {
+	CountByState            map[LoanState]int
+	TotalLoans              int
+	TotalDisbursedPrincipal Money // Principal of disbursed loans plus the tranches paid out on partially disbursed ones
+	TotalInvested           Money
+	AverageROI              float64
+}
+
+// NewLoanStats creates empty stats with a zero count for every loan state
+func NewLoanStats() *LoanStats {
+	return &LoanStats{
+		CountByState: map[LoanState]int{
+			StateProposed:  0,
+			StateApproved:  0,
+			StateInvested:  0,
+			StateDisbursed: 0,
+			StateRejected:  0,
+			StateCancelled: 0,
+
+			StatePartiallyDisbursed: 0,
+			StateExpired:            0,
+			StatePendingApproval:    0,
+		},
+	}
+}
@@ -0,0 +1,414 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateInvestmentAmountReportsRemaining(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal float64
+		invested  float64
+		amount    float64
+		remaining string
+	}{
+		{"nothing invested yet", 5000, 0, 6000, "remaining 5000.00"},
+		{"partially invested", 5000, 1234.5, 4000, "remaining 3765.50"},
+		{"one cent left", 5000, 4999.99, 1, "remaining 0.01"},
+		{"fully invested", 5000, 5000, 0.01, "remaining 0.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := &Loan{PrincipalAmount: NewMoney(tt.principal)}
+
+			err := loan.ValidateInvestmentAmount(NewMoney(tt.amount), NewMoney(tt.invested))
+			if !errors.Is(err, ErrInvestmentExceeds) {
+				t.Fatalf("got error %v, want ErrInvestmentExceeds", err)
+			}
+			want := "investment amount exceeds remaining loan amount: " + tt.remaining
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("got message %q, want it to contain %q", err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestValidateRates(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    float64
+		roi     float64
+		wantErr string
+	}{
+		{"ROI lower than rate", 12, 10, ""},
+		{"ROI equal to rate", 12, 12, ""},
+		{"ROI higher than rate", 12, 12.5, "ROI (12.50) cannot exceed borrower interest rate (12.00)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRates(tt.rate, tt.roi)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got error %v, want none", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrValidation) {
+				t.Fatalf("got error %v, want ErrValidation", err)
+			}
+			if err.Error() != tt.wantErr {
+				t.Errorf("got message %q, want %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func moneyPtr(amount float64) *Money {
+	m := NewMoney(amount)
+	return &m
+}
+
+func TestValidateInvestmentAmountLimits(t *testing.T) {
+	loan := &Loan{
+		PrincipalAmount: NewMoney(1000),
+		MinInvestment:   moneyPtr(100),
+		MaxInvestment:   moneyPtr(500),
+	}
+
+	tests := []struct {
+		name     string
+		invested float64
+		amount   float64
+		wantErr  error
+	}{
+		{"at the minimum", 0, 100, nil},
+		{"one cent below the minimum", 0, 99.99, ErrValidation},
+		{"at the maximum", 0, 500, nil},
+		{"one cent above the maximum", 0, 500.01, ErrValidation},
+		{"final top-up below the minimum", 950, 50, nil},
+		{"below the minimum without completing the loan", 900, 50, ErrValidation},
+		{"final top-up above the remaining amount", 950, 60, ErrInvestmentExceeds},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := loan.ValidateInvestmentAmount(NewMoney(tt.amount), NewMoney(tt.invested))
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("got error %v, want none", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateInvestmentAmountIncrement(t *testing.T) {
+	loan := &Loan{
+		PrincipalAmount:     NewMoney(2500),
+		InvestmentIncrement: moneyPtr(1000),
+	}
+
+	tests := []struct {
+		name     string
+		invested float64
+		amount   float64
+		wantErr  string
+	}{
+		{"one increment", 0, 1000, ""},
+		{"several increments", 0, 2000, ""},
+		{"not a multiple", 0, 1500, "investment amount must be a whole multiple of 1000.00, or the remaining 2500.00; the nearest lower amount is 1000.00"},
+		{"below one increment", 0, 999.99, "investment amount must be a whole multiple of 1000.00, or the remaining 2500.00"},
+		{"final top-up", 2000, 500, ""},
+		{"final top-up of the whole loan", 0, 2500, ""},
+		{"short of the final top-up", 2000, 400, "investment amount must be a whole multiple of 1000.00, or the remaining 500.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := loan.ValidateInvestmentAmount(NewMoney(tt.amount), NewMoney(tt.invested))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got error %v, want none", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrValidation) {
+				t.Fatalf("got error %v, want ErrValidation", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got message %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+			if !strings.Contains(tt.wantErr, "nearest") && strings.Contains(err.Error(), "nearest") {
+				t.Errorf("got message %q, want no lower amount suggested", err.Error())
+			}
+		})
+	}
+}
+
+func TestValidateInvestmentLimits(t *testing.T) {
+	principal := NewMoney(1000)
+
+	tests := []struct {
+		name     string
+		min, max *Money
+		wantErr  bool
+	}{
+		{"no limits", nil, nil, false},
+		{"min equal to max", moneyPtr(500), moneyPtr(500), false},
+		{"max equal to principal", moneyPtr(100), moneyPtr(1000), false},
+		{"min above max", moneyPtr(600), moneyPtr(500), true},
+		{"max above principal", nil, moneyPtr(1000.01), true},
+		{"min above principal", moneyPtr(1000.01), nil, true},
+		{"zero min", moneyPtr(0), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateInvestmentLimits(principal, tt.min, tt.max, nil, nil)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrValidation) {
+				t.Errorf("got error %v, want ErrValidation", err)
+			}
+		})
+	}
+}
+
+func TestValidateBorrowerID(t *testing.T) {
+	tests := []struct {
+		name       string
+		borrowerID string
+		wantErr    string
+	}{
+		{"16 digits", "3171234567890123", ""},
+		{"empty", "", "borrower ID number cannot be empty"},
+		{"too short", "317123456789012", "borrower ID number must be exactly 16 digits"},
+		{"too long", "31712345678901234", "borrower ID number must be exactly 16 digits"},
+		{"non-numeric", "31712345678901AB", "borrower ID number must contain digits only"},
+		{"spaces", "3171 2345 678901", "borrower ID number must contain digits only"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateBorrowerID(tt.borrowerID)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got error %v, want none", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrValidation) {
+				t.Fatalf("got error %v, want ErrValidation", err)
+			}
+			if err.Error() != tt.wantErr {
+				t.Errorf("got message %q, want %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTotalRepayable(t *testing.T) {
+	tests := []struct {
+		name          string
+		principal     float64
+		rate          float64
+		termWeeks     int
+		wantInterest  float64
+		wantRepayable float64
+	}{
+		{"one year", 1000, 12, 52, 120, 1120},
+		{"half a year", 1000, 12, 26, 60, 1060},
+		{"two years of simple interest", 5000, 10, 104, 1000, 6000},
+		{"interest-free", 1000, 0, 52, 0, 1000},
+		{"quarter rounded to the cent", 1234.56, 7.5, 13, 23.15, 1257.71},
+		{"single week", 333.33, 12, 1, 0.77, 334.10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := &Loan{PrincipalAmount: NewMoney(tt.principal), Rate: tt.rate, TermWeeks: tt.termWeeks}
+
+			if got, want := loan.TotalInterest(), NewMoney(tt.wantInterest); got != want {
+				t.Errorf("got interest %s, want %s", got, want)
+			}
+			if got, want := loan.TotalRepayable(), NewMoney(tt.wantRepayable); got != want {
+				t.Errorf("got total repayable %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateActionDate(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 9, 0, 0, 500_000_000, time.UTC)
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		date    time.Time
+		wantErr string
+	}{
+		{"in the past", now.Add(-24 * time.Hour), ""},
+		{"now", now, ""},
+		{"one second in the future", now.Add(time.Second), "approval_date cannot be in the future"},
+		{"next year", now.AddDate(1, 0, 0), "approval_date cannot be in the future"},
+		// Dates are entered with second precision, so the creation second itself is allowed
+		{"the second the loan was created", createdAt.Truncate(time.Second), ""},
+		{"before the loan was created", createdAt.Add(-time.Second), "approval_date cannot be before the loan was created"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateActionDate("approval_date", tt.date, createdAt, now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got error %v, want none", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrValidation) {
+				t.Fatalf("got error %v, want ErrValidation", err)
+			}
+			if err.Error() != tt.wantErr {
+				t.Errorf("got message %q, want %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAvailableActions(t *testing.T) {
+	past := Now().Add(-time.Hour)
+	future := Now().Add(time.Hour)
+
+	// loanIn returns a loan in state carrying the approval details investing requires
+	loanIn := func(state LoanState) *Loan {
+		proof := "/files/proof_pictures/proof.jpg"
+		employeeID := "EMP-APPROVER"
+		return &Loan{
+			State:                state,
+			AgreementLetterLink:  "https://example.com/agreements/1.pdf",
+			ApprovalProofPicture: &proof,
+			ApprovalEmployeeID:   &employeeID,
+			ApprovalDate:         &past,
+		}
+	}
+
+	tests := []struct {
+		name string
+		loan *Loan
+		want []LoanAction
+	}{
+		{"proposed", loanIn(StateProposed), []LoanAction{ActionApprove, ActionReject, ActionCancel}},
+		{"pending approval", loanIn(StatePendingApproval), []LoanAction{ActionApprove, ActionReject, ActionCancel}},
+		{"approved", loanIn(StateApproved), []LoanAction{ActionInvest, ActionCancel}},
+		{"invested", loanIn(StateInvested), []LoanAction{ActionInvest, ActionDisburse}},
+		{"partially disbursed", loanIn(StatePartiallyDisbursed), []LoanAction{ActionDisburse}},
+		{"disbursed", loanIn(StateDisbursed), []LoanAction{}},
+		{"rejected", loanIn(StateRejected), []LoanAction{}},
+		{"cancelled", loanIn(StateCancelled), []LoanAction{}},
+		{"expired", loanIn(StateExpired), []LoanAction{}},
+		{"approved past its funding deadline", func() *Loan {
+			loan := loanIn(StateApproved)
+			loan.FundingDeadline = &past
+			return loan
+		}(), []LoanAction{ActionCancel}},
+		{"proposed with a scheduled approval", func() *Loan {
+			loan := loanIn(StateProposed)
+			loan.ApprovalEffectiveAt = &future
+			return loan
+		}(), []LoanAction{ActionReject, ActionCancel}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.loan.AvailableActions()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got actions %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got actions %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsFullyInvested(t *testing.T) {
+	loan := &Loan{PrincipalAmount: NewMoney(1000)}
+
+	tests := []struct {
+		name    string
+		amounts []float64
+		want    bool
+	}{
+		{"three thirds and a cent", []float64{333.33, 333.33, 333.33, 0.01}, true},
+		{"three thirds", []float64{333.33, 333.33, 333.33}, false},
+		// In float64 these sum to 999.9999999999999
+		{"amounts whose float sum falls short", []float64{200.2, 499.9, 299.9}, true},
+		{"one cent short", []float64{999.99}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var total Money
+			for _, amount := range tt.amounts {
+				total += NewMoney(amount)
+			}
+			if got := loan.IsFullyInvested(total); got != tt.want {
+				t.Errorf("got fully invested %t at %s, want %t", got, total, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAgreementLetterLink(t *testing.T) {
+	allowed := []string{"example.com", "docs.partner.co.id"}
+
+	tests := []struct {
+		name    string
+		link    string
+		domains []string
+		wantErr string
+	}{
+		{"https", "https://example.com/agreements/1.pdf", nil, ""},
+		{"http", "http://example.com/agreements/1.pdf", nil, ""},
+		{"scheme merely starting with http", "httpfoo://example.com/1.pdf", nil, "agreement letter link must be a valid http or https URL"},
+		{"other scheme", "ftp://example.com/1.pdf", nil, "agreement letter link must be a valid http or https URL"},
+		{"no host", "https:///agreements/1.pdf", nil, "agreement letter link must be a valid http or https URL"},
+		{"not a URL", "agreement.pdf", nil, "agreement letter link must be a valid http or https URL"},
+		{"malformed", "https://exa mple.com/%zz", nil, "agreement letter link must be a valid http or https URL"},
+		{"allowed domain", "https://example.com/1.pdf", allowed, ""},
+		{"subdomain of an allowed domain", "https://files.example.com/1.pdf", allowed, ""},
+		{"allowed domain regardless of case", "https://Docs.Partner.co.id/1.pdf", allowed, ""},
+		{"disallowed domain", "https://evil.com/1.pdf", allowed, "agreement letter link host evil.com is not an allowed domain"},
+		{"allowed domain as a suffix only", "https://notexample.com/1.pdf", allowed, "agreement letter link host notexample.com is not an allowed domain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAgreementLetterLink(tt.link, tt.domains)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got error %v, want none", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrValidation) {
+				t.Fatalf("got error %v, want ErrValidation", err)
+			}
+			if err.Error() != tt.wantErr {
+				t.Errorf("got message %q, want %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
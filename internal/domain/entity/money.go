@@ -0,0 +1,127 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in hundredths of the currency unit. Amounts are kept as
+// integers so totals add up exactly and can be compared for equality; the API
+// and the database still carry them in currency units.
+type Money int64
+
+// NewMoney converts an amount in currency units, rounded half-up to the
+// hundredth. The decimal digits are rounded rather than amount*100, which is
+// off for amounts such as 1.005 that floats hold slightly below their value.
+func NewMoney(amount float64) Money {
+	whole, fraction, _ := strings.Cut(strconv.FormatFloat(math.Abs(amount), 'f', -1, 64), ".")
+	fraction += "000"
+	cents, err := strconv.ParseInt(whole+fraction[:2], 10, 64)
+	if err != nil {
+		// Out of range for exact rounding
+		return Money(math.Round(amount * 100))
+	}
+	if fraction[2] >= '5' {
+		cents++
+	}
+	if amount < 0 {
+		cents = -cents
+	}
+	return Money(cents)
+}
+
+// IsWholeCents reports whether an amount in currency units has no more than two decimals
+func IsWholeCents(amount float64) bool {
+	return NewMoney(amount).Float64() == amount
+}
+
+// NewMoneyPtr converts an optional amount in currency units
+func NewMoneyPtr(amount *float64) *Money {
+	if amount == nil {
+		return nil
+	}
+	money := NewMoney(*amount)
+	return &money
+}
+
+// Float64 returns the amount in currency units
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// Float64Ptr returns an optional amount in currency units
+func (m *Money) Float64Ptr() *float64 {
+	if m == nil {
+		return nil
+	}
+	amount := m.Float64()
+	return &amount
+}
+
+// Percent returns percent % of the amount, rounded to the hundredth
+func (m Money) Percent(percent float64) Money {
+	return Money(math.Round(float64(m) * percent / 100))
+}
+
+// Convert returns the amount in another currency at rate units of that
+// currency per unit of this one, rounded to the hundredth
+func (m Money) Convert(rate float64) Money {
+	return Money(math.Round(float64(m) * rate))
+}
+
+// String formats the amount in currency units with two decimals
+func (m Money) String() string {
+	return strconv.FormatFloat(m.Float64(), 'f', 2, 64)
+}
+
+// MarshalJSON encodes the amount in currency units
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Float64())
+}
+
+// UnmarshalJSON decodes an amount in currency units
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var amount float64
+	if err := json.Unmarshal(data, &amount); err != nil {
+		return err
+	}
+	*m = NewMoney(amount)
+	return nil
+}
+
+// Value stores the amount in currency units
+func (m Money) Value() (driver.Value, error) {
+	return m.Float64(), nil
+}
+
+// Scan reads an amount stored in currency units, rounding away the error
+// floating point sums accumulate
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case float64:
+		*m = NewMoney(v)
+	case int64:
+		*m = Money(v * 100)
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+// scanString reads an amount stored as text, as Postgres returns NUMERIC sums
+func (m *Money) scanString(s string) error {
+	amount, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("cannot scan %q into Money: %w", s, err)
+	}
+	*m = NewMoney(amount)
+	return nil
+}
@@ -0,0 +1,74 @@
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewMoneyRoundsToTheHundredth(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount float64
+		want   Money
+	}{
+		{"whole amount", 1000, 100000},
+		{"two decimals", 333.33, 33333},
+		{"one decimal", 0.1, 10},
+		{"float sum above its value", 0.30000000000000004, 30},
+		{"float sum below its value", 30.299999999999997, 3030},
+		{"half a cent rounds up", 1.005, 101},
+		{"below half a cent rounds down", 1.004, 100},
+		{"negative", -12.345, -1235},
+		{"accumulated float error", 999.9999999999, 100000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewMoney(tt.amount); got != tt.want {
+				t.Errorf("got %d hundredths, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		name string
+		src  interface{}
+		want Money
+	}{
+		{"SQLite sum with float error", 100.00000000000001, 10000},
+		{"integer", int64(25), 2500},
+		{"Postgres numeric", []byte("1234.56"), 123456},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Money
+			if err := got.Scan(tt.src); err != nil {
+				t.Fatalf("failed to scan: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d hundredths, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyJSONIsInCurrencyUnits(t *testing.T) {
+	data, err := json.Marshal(NewMoney(1234.5))
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if string(data) != "1234.5" {
+		t.Errorf("got %s, want 1234.5", data)
+	}
+
+	var decoded Money
+	if err := json.Unmarshal([]byte("0.30000000000000004"), &decoded); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if decoded != 30 {
+		t.Errorf("got %d hundredths, want 30", decoded)
+	}
+}
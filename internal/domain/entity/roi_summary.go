@@ -0,0 +1,13 @@
+package entity
+
+// ROISummary blends the ROI of the platform's funded loans, those invested or
+// (partially) disbursed
+type ROISummary struct {
+	LoanCount          int
+	TotalInvested      Money
+	WeightedAverageROI float64 // ROI of every loan weighted by its principal
+	ProjectedReturns   Money   // Sum of every investment's amount * ROI / 100
+}
+
+// ROISummaryStates are the states of the loans an ROISummary covers
+var ROISummaryStates = []LoanState{StateInvested, StatePartiallyDisbursed, StateDisbursed}
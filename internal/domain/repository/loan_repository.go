@@ -3,24 +3,57 @@ package repository
 import (
 	"amartha-andreas/internal/domain/entity"
 	"context"
+	"time"
 )
 
+// MaxLoanIDs is the number of loans GetByIDs fetches at once, keeping the
+// query within the bind parameter limits of the databases
+const MaxLoanIDs = 100
+
 // LoanRepository defines the interface for loan data access
 type LoanRepository interface {
 	// Create saves a new loan
 	Create(ctx context.Context, loan *entity.Loan) error
 
-	// GetByID retrieves a loan by its ID
+	// GetByID retrieves a loan by its ID, treating soft-deleted loans as not found
 	GetByID(ctx context.Context, id int64) (*entity.Loan, error)
 
-	// Update updates an existing loan
+	// GetByIDForUpdate retrieves a loan by its ID and, within a transaction,
+	// locks it until the transaction ends
+	GetByIDForUpdate(ctx context.Context, id int64) (*entity.Loan, error)
+
+	// GetByIDIncludingDeleted retrieves a loan by its ID even if it was soft-deleted
+	GetByIDIncludingDeleted(ctx context.Context, id int64) (*entity.Loan, error)
+
+	// GetByIDs retrieves the live loans among up to MaxLoanIDs IDs in a single
+	// query, in no particular order; IDs without a loan are left out
+	GetByIDs(ctx context.Context, ids []int64) ([]*entity.Loan, error)
+
+	// Update updates an existing loan and increments its version. It returns
+	// ErrConcurrentModification when the stored loan no longer has the loan's version.
 	Update(ctx context.Context, loan *entity.Loan) error
 
 	// List retrieves loans with optional filtering
 	List(ctx context.Context, filter LoanFilter) ([]*entity.Loan, error)
 
+	// ForEach streams loans matching the filter to fn without loading them all in memory
+	ForEach(ctx context.Context, filter LoanFilter, fn func(*entity.Loan) error) error
+
+	// SoftDelete marks a loan as deleted without removing the row. Like Update it
+	// returns ErrConcurrentModification when the loan changed since it was read.
+	SoftDelete(ctx context.Context, loan *entity.Loan) error
+
+	// GetStats aggregates portfolio statistics over live loans
+	GetStats(ctx context.Context, filter StatsFilter) (*entity.LoanStats, error)
+
+	// GetROISummary aggregates the investments in live funded loans
+	GetROISummary(ctx context.Context) (*entity.ROISummary, error)
+
+	// GetBorrowerExposure aggregates a borrower's live loans by state
+	GetBorrowerExposure(ctx context.Context, borrowerIDNumber string) (*entity.BorrowerExposure, error)
+
 	// GetTotalInvestment calculates total investment for a loan
-	GetTotalInvestment(ctx context.Context, loanID int64) (float64, error)
+	GetTotalInvestment(ctx context.Context, loanID int64) (entity.Money, error)
 }
 
 // InvestmentRepository defines the interface for investment data access
@@ -28,17 +61,153 @@ type InvestmentRepository interface {
 	// Create saves a new investment
 	Create(ctx context.Context, investment *entity.Investment) error
 
+	// CreateWithinPrincipal atomically rechecks the loan's funded total, saves the
+	// investment and marks the loan invested once fully funded. It returns the loan
+	// as it stands after the investment.
+	CreateWithinPrincipal(ctx context.Context, investment *entity.Investment) (*entity.Loan, error)
+
+	// GetByID retrieves an investment by its ID
+	GetByID(ctx context.Context, id int64) (*entity.Investment, error)
+
+	// GetByIdempotencyKey retrieves the investment created with the given idempotency key
+	GetByIdempotencyKey(ctx context.Context, key string) (*entity.Investment, error)
+
+	// Delete removes an investment
+	Delete(ctx context.Context, id int64) error
+
 	// GetByLoanID retrieves all investments for a specific loan
 	GetByLoanID(ctx context.Context, loanID int64) ([]*entity.Investment, error)
 
 	// GetTotalByLoanID calculates total investment amount for a loan
-	GetTotalByLoanID(ctx context.Context, loanID int64) (float64, error)
+	GetTotalByLoanID(ctx context.Context, loanID int64) (entity.Money, error)
+
+	// GetTotalByInvestor calculates total amount one investor has put into a loan
+	GetTotalByInvestor(ctx context.Context, loanID int64, investorEmail string) (entity.Money, error)
+
+	// CountInvestors counts the distinct investors of a loan
+	CountInvestors(ctx context.Context, loanID int64) (int, error)
+
+	// HasInvested reports whether an investor already has an investment in a loan
+	HasInvested(ctx context.Context, loanID int64, investorEmail string) (bool, error)
+
+	// List retrieves investments with optional filtering
+	List(ctx context.Context, filter InvestmentFilter) ([]*entity.Investment, error)
+
+	// Count counts investments matching the filter, ignoring pagination
+	Count(ctx context.Context, filter InvestmentFilter) (int, error)
+
+	// ListWithLoanState retrieves investments across all loans like List, each
+	// with the state of its loan, soft-deleted loans included
+	ListWithLoanState(ctx context.Context, filter InvestmentFilter) ([]*entity.LedgerInvestment, error)
+
+	// GetByInvestor retrieves one investor's investments across all loans, paginated by the filter
+	GetByInvestor(ctx context.Context, investorEmail string, filter InvestmentFilter) ([]*entity.Investment, error)
+
+	// GetInvestorTotals aggregates one investor's investments across all loans
+	GetInvestorTotals(ctx context.Context, investorEmail string) (*entity.InvestorTotals, error)
+}
+
+// LoanStateTransitionRepository defines the interface for the append-only loan state audit log
+type LoanStateTransitionRepository interface {
+	// Append records a new state transition
+	Append(ctx context.Context, transition *entity.LoanStateTransition) error
+
+	// ListByLoanID retrieves a loan's state transitions matching the filter, newest
+	// first unless the filter asks for the order they happened
+	ListByLoanID(ctx context.Context, loanID int64, filter StateTransitionFilter) ([]*entity.LoanStateTransition, error)
+
+	// CountByLoanID counts a loan's state transitions matching the filter, ignoring its pagination
+	CountByLoanID(ctx context.Context, loanID int64, filter StateTransitionFilter) (int, error)
+}
+
+// DisbursementRepository defines the interface for loan disbursement tranche data access
+type DisbursementRepository interface {
+	// Create saves a new disbursement tranche
+	Create(ctx context.Context, disbursement *entity.Disbursement) error
+
+	// ListByLoanID retrieves all disbursement tranches of a loan in the order they were paid out
+	ListByLoanID(ctx context.Context, loanID int64) ([]*entity.Disbursement, error)
+
+	// GetTotalByLoanID calculates the amount disbursed so far for a loan
+	GetTotalByLoanID(ctx context.Context, loanID int64) (entity.Money, error)
+}
+
+// LoanDocumentRepository defines the interface for data access of the signed
+// documents uploaded with disbursement tranches
+type LoanDocumentRepository interface {
+	// Create saves a new loan document
+	Create(ctx context.Context, document *entity.LoanDocument) error
+
+	// ListByLoanID retrieves all documents of a loan in the order they were uploaded
+	ListByLoanID(ctx context.Context, loanID int64) ([]*entity.LoanDocument, error)
+}
+
+// LoanApprovalRepository defines the interface for data access of the
+// approvals employees gave a loan
+type LoanApprovalRepository interface {
+	// Create saves a new loan approval, failing when the employee already approved the loan
+	Create(ctx context.Context, approval *entity.LoanApproval) error
+
+	// ListByLoanID retrieves all approvals of a loan in the order they were given
+	ListByLoanID(ctx context.Context, loanID int64) ([]*entity.LoanApproval, error)
+}
+
+// Transactor runs a unit of work atomically. Repository calls made with the
+// context passed to fn take part in the same transaction.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 // LoanFilter represents filtering options for loan queries
 type LoanFilter struct {
-	State      *entity.LoanState
-	BorrowerID *string
-	Limit      *int
-	Offset     *int
+	State          *entity.LoanState
+	BorrowerID     *string
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	MinPrincipal   *entity.Money // Inclusive lower bound of the principal amount
+	MaxPrincipal   *entity.Money // Inclusive upper bound of the principal amount
+	FundingStatus  *entity.FundingStatus
+	SortBy         string // Column to order by, defaults to created_at
+	SortDesc       bool
+	IncludeDeleted bool // Include soft-deleted loans
+	Limit          *int
+	Offset         *int
+
+	// FundingDeadlineBefore selects loans whose funding deadline is at or before this time
+	FundingDeadlineBefore *time.Time
+
+	// ApprovalEffectiveBefore selects loans whose scheduled approval takes effect at or before this time
+	ApprovalEffectiveBefore *time.Time
+
+	// Search selects loans whose borrower ID number, or the email of one of their
+	// investors, contains this text, ignoring case
+	Search *string
+}
+
+// StatsFilter restricts portfolio statistics to loans created in a date range
+type StatsFilter struct {
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// StateTransitionFilter represents filtering options for a loan's history
+type StateTransitionFilter struct {
+	FromState *entity.LoanState
+	ToState   *entity.LoanState
+	Ascending bool // Oldest first instead of newest first
+	Limit     *int
+	Offset    *int
+}
+
+// InvestmentFilter represents filtering options for investment queries
+type InvestmentFilter struct {
+	LoanID        *int64
+	InvestorEmail *string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	MinAmount     *entity.Money // Inclusive, in the loan currency
+	MaxAmount     *entity.Money // Inclusive, in the loan currency
+	AfterID       *int64        // Keyset pagination, only investments with a larger ID in ID order
+	Limit         *int
+	Offset        *int
 }
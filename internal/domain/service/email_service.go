@@ -1,17 +1,60 @@
 package service
 
-import "context"
+import (
+	"amartha-andreas/internal/domain/entity"
+	"context"
+	"time"
+)
 
 // EmailService defines the interface for sending emails
 type EmailService interface {
 	SendLoanFullyInvestedNotification(ctx context.Context, request SendLoanNotificationRequest) error
+	SendLoanApprovedNotification(ctx context.Context, request SendLoanApprovedNotificationRequest) error
+	SendLoanDisbursedNotification(ctx context.Context, request SendLoanDisbursedNotificationRequest) error
+	SendInvestmentConfirmation(ctx context.Context, request SendInvestmentConfirmationRequest) error
 }
 
 // SendLoanNotificationRequest represents the request for loan fully invested notification
 type SendLoanNotificationRequest struct {
-	LoanID              int64    `json:"loan_id"`
-	InvestorEmails      []string `json:"investor_emails"`
-	BorrowerIDNumber    string   `json:"borrower_id_number"`
-	PrincipalAmount     float64  `json:"principal_amount"`
-	AgreementLetterLink string   `json:"agreement_letter_link"`
+	LoanID              int64        `json:"loan_id"`
+	InvestorEmails      []string     `json:"investor_emails"`
+	BorrowerIDNumber    string       `json:"borrower_id_number"`
+	PrincipalAmount     entity.Money `json:"principal_amount"`
+	AgreementLetterLink string       `json:"agreement_letter_link"`
+	Language            string       `json:"language"` // Language of the email, e.g. "en" (default) or "id"
+}
+
+// SendLoanApprovedNotificationRequest represents the request for loan approved notification
+type SendLoanApprovedNotificationRequest struct {
+	LoanID           int64     `json:"loan_id"`
+	BorrowerIDNumber string    `json:"borrower_id_number"`
+	EmployeeID       string    `json:"employee_id"`
+	ApprovalDate     time.Time `json:"approval_date"`
+}
+
+// SendLoanDisbursedNotificationRequest represents the request for loan disbursed notification
+type SendLoanDisbursedNotificationRequest struct {
+	LoanID             int64        `json:"loan_id"`
+	BorrowerEmail      string       `json:"borrower_email"`
+	BorrowerIDNumber   string       `json:"borrower_id_number"`
+	PrincipalAmount    entity.Money `json:"principal_amount"`
+	SignedAgreementDoc string       `json:"signed_agreement_doc"` // Stored URL (or legacy bare filename) of the signed agreement
+	DisbursementDate   time.Time    `json:"disbursement_date"`
+}
+
+// SendInvestmentConfirmationRequest represents the request for the confirmation
+// sent to an investor once their investment is saved
+type SendInvestmentConfirmationRequest struct {
+	InvestmentID     int64        `json:"investment_id"`
+	LoanID           int64        `json:"loan_id"`
+	InvestorEmail    string       `json:"investor_email"`
+	Amount           entity.Money `json:"amount"`   // As invested, in Currency
+	Currency         string       `json:"currency"` // Currency the investor invested in
+	BorrowerIDNumber string       `json:"borrower_id_number"`
+	PrincipalAmount  entity.Money `json:"principal_amount"` // In LoanCurrency
+	LoanCurrency     string       `json:"loan_currency"`
+	ROI              float64      `json:"roi"`
+	FundedPercentage float64      `json:"funded_percentage"` // Of the principal, including this investment
+	InvestedAt       time.Time    `json:"invested_at"`
+	Language         string       `json:"language"` // Language of the email, e.g. "en" (default) or "id"
 }
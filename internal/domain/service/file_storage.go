@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"io"
+)
+
+// FileStorage defines the interface for storing uploaded files
+type FileStorage interface {
+	// Save stores the content under key and returns the public URL of the stored file
+	Save(ctx context.Context, key string, reader io.Reader, contentType string) (string, error)
+
+	// Delete removes a file by the URL Save returned. URLs the storage did not
+	// produce are left in place.
+	Delete(ctx context.Context, fileURL string) error
+
+	// Open reads a file by the URL Save returned. The caller must close the
+	// body. entity.ErrFileNotFound is returned for URLs the storage did not
+	// produce and for files that no longer exist.
+	Open(ctx context.Context, fileURL string) (*StoredFile, error)
+}
+
+// StoredFile is an open file read from the storage
+type StoredFile struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64 // -1 when unknown
+}
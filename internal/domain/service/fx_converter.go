@@ -0,0 +1,13 @@
+package service
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"context"
+)
+
+// FXConverter defines the interface for converting amounts between currencies
+type FXConverter interface {
+	// Convert converts amount from one currency to another at the current rate.
+	// A currency pair without a rate is a validation error.
+	Convert(ctx context.Context, amount entity.Money, from, to string) (entity.Money, error)
+}
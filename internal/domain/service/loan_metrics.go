@@ -0,0 +1,7 @@
+package service
+
+// LoanMetrics defines the interface for recording loan events for monitoring
+type LoanMetrics interface {
+	LoanCreated()
+	LoanStateChanged(fromState, toState string)
+}
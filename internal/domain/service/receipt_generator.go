@@ -0,0 +1,27 @@
+package service
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"context"
+	"time"
+)
+
+// ReceiptGenerator defines the interface for rendering documents handed to investors
+type ReceiptGenerator interface {
+	// GenerateInvestmentReceipt renders the receipt of one investment as a PDF
+	GenerateInvestmentReceipt(ctx context.Context, receipt InvestmentReceipt) ([]byte, error)
+}
+
+// InvestmentReceipt represents the content of an investment receipt
+type InvestmentReceipt struct {
+	InvestmentID   int64
+	LoanID         int64
+	InvestorEmail  string
+	Amount         entity.Money // In the loan currency
+	LoanCurrency   string
+	OriginalAmount entity.Money // As invested, in Currency
+	Currency       string
+	ROI            float64
+	ExpectedReturn entity.Money // In the loan currency
+	InvestedAt     time.Time
+}
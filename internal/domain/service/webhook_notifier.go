@@ -0,0 +1,19 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// WebhookNotifier defines the interface for pushing loan events to downstream systems
+type WebhookNotifier interface {
+	NotifyLoanStateChanged(ctx context.Context, event LoanStateChangedEvent) error
+}
+
+// LoanStateChangedEvent represents the payload sent after a loan changed state
+type LoanStateChangedEvent struct {
+	LoanID    int64     `json:"loan_id"`
+	FromState string    `json:"from_state"`
+	ToState   string    `json:"to_state"`
+	At        time.Time `json:"at"`
+}
@@ -0,0 +1,77 @@
+package approval
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultActivationInterval is how often due approvals are activated when no interval is configured
+const DefaultActivationInterval = time.Minute
+
+// ActivateFunc approves the loans whose scheduled approval is due, returning how many were approved
+type ActivateFunc func(ctx context.Context) (int, error)
+
+// Activator periodically approves the proposed loans whose scheduled approval
+// has taken effect
+type Activator struct {
+	activate ActivateFunc
+	interval time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewActivator creates an activator that calls activate every interval once Start is called
+func NewActivator(activate ActivateFunc, interval time.Duration) *Activator {
+	if interval <= 0 {
+		interval = DefaultActivationInterval
+	}
+
+	return &Activator{
+		activate: activate,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start activates right away, then every interval until Shutdown
+func (a *Activator) Start() {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+
+		for {
+			a.run()
+			select {
+			case <-ticker.C:
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops the activator and waits for a running activation to finish
+func (a *Activator) Shutdown() {
+	close(a.stop)
+	a.wg.Wait()
+}
+
+// run approves the loans whose scheduled approval is due, bounded by the interval
+func (a *Activator) run() {
+	ctx, cancel := context.WithTimeout(context.Background(), a.interval)
+	defer cancel()
+
+	activated, err := a.activate(ctx)
+	if activated > 0 {
+		log.Printf("Approved %d loans whose scheduled approval took effect", activated)
+	}
+	if err != nil {
+		log.Printf("Failed to activate scheduled approvals: %v", err)
+	}
+}
@@ -1,37 +1,187 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// Supported database drivers
+const (
+	DriverSQLite   = "sqlite3"
+	DriverPostgres = "postgres"
+)
+
+// DBConfig holds the configuration for the database connection
+type DBConfig struct {
+	Driver string
+	DSN    string
+
+	// Connection pool settings; zero values use the driver defaults from withDefaults
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	// Startup retries while the database isn't reachable yet, e.g. a database
+	// container still starting; zero values use the defaults from withDefaults
+	ConnectAttempts   int
+	ConnectRetryDelay time.Duration // Delay before the second attempt, doubled after every further failure
+}
+
+// Default startup retries, waiting up to 15 seconds for the database in total
+const (
+	DefaultConnectAttempts   = 5
+	DefaultConnectRetryDelay = time.Second
+)
+
+// withDefaults fills unset pool settings. SQLite allows a single writer, so it
+// gets one connection that requests queue for instead of failing with
+// "database is locked"; Postgres gets a pool sized for a single API instance.
+func (c DBConfig) withDefaults() DBConfig {
+	if c.ConnectAttempts <= 0 {
+		c.ConnectAttempts = DefaultConnectAttempts
+	}
+	if c.ConnectRetryDelay <= 0 {
+		c.ConnectRetryDelay = DefaultConnectRetryDelay
+	}
+
+	if c.Driver == DriverSQLite {
+		if c.MaxOpenConns <= 0 {
+			c.MaxOpenConns = 1
+		}
+		if c.MaxIdleConns <= 0 {
+			c.MaxIdleConns = c.MaxOpenConns
+		}
+		return c
+	}
+
+	if c.MaxOpenConns <= 0 {
+		c.MaxOpenConns = 25
+	}
+	if c.MaxIdleConns <= 0 {
+		c.MaxIdleConns = min(5, c.MaxOpenConns)
+	}
+	if c.ConnMaxLifetime <= 0 {
+		c.ConnMaxLifetime = 30 * time.Minute
+	}
+	return c
+}
+
 // Database represents the database connection
 type Database struct {
-	DB *sql.DB
+	DB     *sql.DB
+	Driver string
 }
 
-// NewDatabase creates a new database connection
+// Querier is implemented by both *sql.DB and *sql.Tx
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// NewDatabase creates a new SQLite database connection
 func NewDatabase(databasePath string) (*Database, error) {
-	db, err := sql.Open("sqlite3", databasePath)
+	return NewDatabaseWithConfig(DBConfig{Driver: DriverSQLite, DSN: databasePath})
+}
+
+// NewDatabaseWithConfig creates a new database connection for the configured driver
+func NewDatabaseWithConfig(config DBConfig) (*Database, error) {
+	if config.Driver != DriverSQLite && config.Driver != DriverPostgres {
+		return nil, fmt.Errorf("unsupported database driver: %s", config.Driver)
+	}
+
+	db, err := sql.Open(config.Driver, config.DSN)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = db.Ping(); err != nil {
+	config = config.withDefaults()
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+
+	if err = pingWithRetry(db, config.ConnectAttempts, config.ConnectRetryDelay); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	database := &Database{DB: db}
-	if err := database.createTables(); err != nil {
+	database := &Database{DB: db, Driver: config.Driver}
+	if err := database.migrate(); err != nil {
 		return nil, err
 	}
 
-	log.Println("Database initialized successfully")
+	log.Printf("Database initialized successfully (driver: %s, max open conns: %d, max idle conns: %d, conn max lifetime: %s)",
+		config.Driver, config.MaxOpenConns, config.MaxIdleConns, config.ConnMaxLifetime)
 	return database, nil
 }
 
+// pingWithRetry pings db up to attempts times, waiting delay before the second
+// attempt and twice as long before every further one
+func pingWithRetry(db *sql.DB, attempts int, delay time.Duration) error {
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = db.Ping(); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		log.Printf("Database not reachable (attempt %d of %d), retrying in %s: %v", attempt, attempts, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+	return fmt.Errorf("database not reachable after %d attempts: %w", attempts, err)
+}
+
+// Rebind converts ? placeholders to the placeholder style of the active driver
+func (d *Database) Rebind(query string) string {
+	if d.Driver != DriverPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, ch := range query {
+		if ch == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+// InsertReturningID executes an INSERT written with ? placeholders and returns the generated ID
+func (d *Database) InsertReturningID(ctx context.Context, q Querier, query string, args ...interface{}) (int64, error) {
+	// lib/pq does not support LastInsertId, so ask Postgres to return the ID instead
+	if d.Driver == DriverPostgres {
+		var id int64
+		err := q.QueryRowContext(ctx, d.Rebind(query)+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Ping verifies the database connection is still alive
+func (d *Database) Ping(ctx context.Context) error {
+	return d.DB.PingContext(ctx)
+}
+
 // Close closes the database connection
 func (d *Database) Close() error {
 	if d.DB != nil {
@@ -40,55 +190,58 @@ func (d *Database) Close() error {
 	return nil
 }
 
-// createTables creates the necessary database tables
-func (d *Database) createTables() error {
-	// Create loans table
-	loanTable := `
-	CREATE TABLE IF NOT EXISTS loans (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		borrower_id_number VARCHAR(16) NOT NULL,
-		principal_amount REAL NOT NULL,
-		rate REAL NOT NULL,
-		roi REAL NOT NULL,
-		state TEXT NOT NULL DEFAULT 'proposed',
-		agreement_letter_link TEXT,
-		approval_proof_picture TEXT,
-		approval_employee_id TEXT,
-		approval_date DATETIME,
-		signed_agreement_doc TEXT,
-		disbursement_employee_id TEXT,
-		disbursement_date DATETIME,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	// Create investments table
-	investmentTable := `
-	CREATE TABLE IF NOT EXISTS investments (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		loan_id INTEGER NOT NULL,
-		investor_email TEXT NOT NULL,
-		amount REAL NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (loan_id) REFERENCES loans(id)
-	);`
-
-	// Create indexes for better performance
-	indexes := []string{
-		`CREATE INDEX IF NOT EXISTS idx_loans_state ON loans(state);`,
-		`CREATE INDEX IF NOT EXISTS idx_loans_borrower ON loans(borrower_id_number);`,
-		`CREATE INDEX IF NOT EXISTS idx_investments_loan_id ON investments(loan_id);`,
-	}
-
-	// Execute table creation
-	tables := []string{loanTable, investmentTable}
-	allStatements := append(tables, indexes...)
-
-	for _, statement := range allStatements {
-		if _, err := d.DB.Exec(statement); err != nil {
-			return err
+// txKey is the context key under which WithinTransaction stores the active transaction
+type txKey struct{}
+
+// WithTx runs fn inside a transaction, committing on success and rolling back on error.
+// If ctx already carries a transaction from WithinTransaction, fn joins it instead.
+func (d *Database) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(tx)
+	}
+
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
 		}
+		return err
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+// WithinTransaction runs fn with a context carrying a transaction, so every
+// repository call made with that context is part of the same unit of work
+func (d *Database) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return d.WithTx(ctx, func(tx *sql.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// Conn returns the transaction carried by ctx, or the connection pool when there is none
+func (d *Database) Conn(ctx context.Context) Querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return d.DB
+}
+
+// adaptDDL rewrites the SQLite flavoured DDL for the active driver
+func (d *Database) adaptDDL(statement string) string {
+	if d.Driver != DriverPostgres {
+		return statement
+	}
+
+	replacer := strings.NewReplacer(
+		"INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY",
+		"INTEGER NOT NULL", "BIGINT NOT NULL",
+		"DATETIME", "TIMESTAMP",
+		"REAL", "DOUBLE PRECISION",
+	)
+	return replacer.Replace(statement)
 }
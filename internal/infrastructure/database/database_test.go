@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewDatabaseWithConfigAppliesPoolSettings(t *testing.T) {
+	db, err := NewDatabaseWithConfig(DBConfig{
+		Driver:          DriverSQLite,
+		DSN:             filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000",
+		MaxOpenConns:    4,
+		MaxIdleConns:    2,
+		ConnMaxLifetime: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if got := db.DB.Stats().MaxOpenConnections; got != 4 {
+		t.Errorf("got %d max open connections, want 4", got)
+	}
+
+	// Hold three connections at once, then release them: only two stay idle
+	ctx := context.Background()
+	var conns []*sql.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := db.DB.Conn(ctx)
+		if err != nil {
+			t.Fatalf("failed to get connection: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	if got := db.DB.Stats().Idle; got != 2 {
+		t.Errorf("got %d idle connections, want 2", got)
+	}
+}
+
+func TestDBConfigDefaults(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       DBConfig
+		wantOpen     int
+		wantIdle     int
+		wantLifetime time.Duration
+	}{
+		{"SQLite uses a single writer connection", DBConfig{Driver: DriverSQLite}, 1, 1, 0},
+		{"SQLite idle connections follow the open limit", DBConfig{Driver: DriverSQLite, MaxOpenConns: 3}, 3, 3, 0},
+		{"Postgres gets a pool", DBConfig{Driver: DriverPostgres}, 25, 5, 30 * time.Minute},
+		{"Postgres idle connections stay within the open limit", DBConfig{Driver: DriverPostgres, MaxOpenConns: 2}, 2, 2, 30 * time.Minute},
+		{"configured values are kept", DBConfig{Driver: DriverPostgres, MaxOpenConns: 10, MaxIdleConns: 7, ConnMaxLifetime: time.Minute}, 10, 7, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.config.withDefaults()
+			if got.MaxOpenConns != tt.wantOpen || got.MaxIdleConns != tt.wantIdle || got.ConnMaxLifetime != tt.wantLifetime {
+				t.Errorf("got open %d, idle %d, lifetime %s; want %d, %d, %s",
+					got.MaxOpenConns, got.MaxIdleConns, got.ConnMaxLifetime, tt.wantOpen, tt.wantIdle, tt.wantLifetime)
+			}
+			if got.ConnectAttempts != DefaultConnectAttempts || got.ConnectRetryDelay != DefaultConnectRetryDelay {
+				t.Errorf("got %d connect attempts every %s, want the defaults", got.ConnectAttempts, got.ConnectRetryDelay)
+			}
+		})
+	}
+}
+
+// flakyConnector refuses the first failures connections, like a database
+// container that is still starting, then connects through the SQLite driver
+type flakyConnector struct {
+	driver   driver.Driver
+	dsn      string
+	failures int
+	attempts int
+}
+
+func (c *flakyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.attempts++
+	if c.attempts <= c.failures {
+		return nil, errors.New("connection refused")
+	}
+	return c.driver.Open(c.dsn)
+}
+
+func (c *flakyConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+func newFlakyDB(t *testing.T, failures int) (*sql.DB, *flakyConnector) {
+	t.Helper()
+
+	sqlite, err := sql.Open(DriverSQLite, ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open SQLite driver: %v", err)
+	}
+	defer sqlite.Close()
+
+	connector := &flakyConnector{driver: sqlite.Driver(), dsn: filepath.Join(t.TempDir(), "test.db"), failures: failures}
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+	return db, connector
+}
+
+func TestPingWithRetryWaitsForTheDatabase(t *testing.T) {
+	db, connector := newFlakyDB(t, 3)
+
+	start := time.Now()
+	if err := pingWithRetry(db, 5, 10*time.Millisecond); err != nil {
+		t.Fatalf("got error %v, want the fourth attempt to succeed", err)
+	}
+	if connector.attempts != 4 {
+		t.Errorf("got %d attempts, want 4", connector.attempts)
+	}
+	// Waits of 10, 20 and 40ms before the second, third and fourth attempts
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("got %s until connected, want at least the 70ms of backoff", elapsed)
+	}
+}
+
+func TestPingWithRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	db, connector := newFlakyDB(t, 10)
+
+	err := pingWithRetry(db, 3, time.Millisecond)
+	if err == nil || err.Error() != "database not reachable after 3 attempts: connection refused" {
+		t.Fatalf("got error %v, want the attempts and last error reported", err)
+	}
+	if connector.attempts != 3 {
+		t.Errorf("got %d attempts, want 3", connector.attempts)
+	}
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations are SQL files named <version>_<name>.sql, applied in version order.
+// They are forward-only: an applied migration must never be edited, a schema
+// change to an existing table is a new migration altering it.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration is one numbered schema change
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// schemaMigrationsTable records the applied migrations
+const schemaMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at DATETIME NOT NULL
+);`
+
+// migrate applies the migrations that aren't recorded in schema_migrations yet,
+// each in a transaction together with its record
+func (d *Database) migrate() error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	if _, err := d.DB.Exec(d.adaptDDL(schemaMigrationsTable)); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		err := d.WithTx(context.Background(), func(tx *sql.Tx) error {
+			if _, err := tx.Exec(d.adaptDDL(m.sql)); err != nil {
+				return err
+			}
+			// The primary key makes an instance racing another one on the same migration fail instead of applying it twice
+			_, err := tx.Exec(d.Rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`), m.version, m.name, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d_%s: %w", m.version, m.name, err)
+		}
+		log.Printf("Applied database migration %d_%s", m.version, m.name)
+	}
+
+	return nil
+}
+
+// appliedMigrations returns the versions recorded in schema_migrations
+func (d *Database) appliedMigrations() (map[int]bool, error) {
+	rows, err := d.DB.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// loadMigrations reads the embedded migrations sorted by version
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		versionText, name, ok := strings.Cut(strings.TrimSuffix(fileName, ".sql"), "_")
+		version, err := strconv.Atoi(versionText)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>.sql", fileName)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, fileName, version)
+		}
+		seen[version] = fileName
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", fileName))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version: version, name: name, sql: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// columns returns the column names of table
+func columns(t *testing.T, db *Database, table string) map[string]bool {
+	t.Helper()
+	rows, err := db.DB.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		t.Fatalf("failed to read columns of %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("failed to scan column: %v", err)
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// assertLatestSchema checks that every migration is recorded and a column of each later migration exists
+func assertLatestSchema(t *testing.T, db *Database) {
+	t.Helper()
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		t.Fatalf("failed to read applied migrations: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("got %d applied migrations, want %d", len(applied), len(migrations))
+	}
+
+	want := map[string][]string{
+		"loans":                  {"rejection_reason", "cancellation_reason", "borrower_email", "min_investment", "deleted_at", "allow_multiple_investments", "term_weeks", "maturity_date", "version", "funding_deadline", "currency", "approval_effective_at", "required_approvals", "investment_increment"},
+		"investments":            {"idempotency_key", "language", "currency", "original_amount"},
+		"loan_state_transitions": {"from_state", "note"},
+		"disbursements":          {"amount", "signed_agreement_doc"},
+		"loan_documents":         {"document"},
+		"loan_approvals":         {"employee_id"},
+	}
+	for table, names := range want {
+		got := columns(t, db, table)
+		for _, name := range names {
+			if !got[name] {
+				t.Errorf("table %s has no column %s", table, name)
+			}
+		}
+	}
+}
+
+func TestMigrateTwiceIsANoOp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := NewDatabase(path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Opening the database again runs the migrations a second time
+	if err := db.migrate(); err != nil {
+		t.Fatalf("got error %v migrating a second time, want none", err)
+	}
+	reopened, err := NewDatabase(path)
+	if err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	defer reopened.Close()
+
+	assertLatestSchema(t, reopened)
+}
+
+func TestMigrateUpgradesBaselineDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// Create the schema as released before migrations existed, with a disbursed and a proposed loan
+	baseline, err := migrationFiles.ReadFile("migrations/0001_create_tables.sql")
+	if err != nil {
+		t.Fatalf("failed to read baseline migration: %v", err)
+	}
+	raw, err := sql.Open(DriverSQLite, path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	statements := []string{
+		string(baseline),
+		`INSERT INTO loans (borrower_id_number, principal_amount, rate, roi, state, agreement_letter_link,
+			approval_proof_picture, approval_employee_id, approval_date, signed_agreement_doc, disbursement_employee_id, disbursement_date)
+		VALUES ('3171234567890123', 1000, 12, 10, 'disbursed', 'https://example.com/agreements/1.pdf',
+			'proof.jpg', 'EMP-APPROVER', '2024-03-01 09:00:00', 'agreement.pdf', 'EMP-DISBURSER', '2024-03-05 09:00:00')`,
+		`INSERT INTO investments (loan_id, investor_email, amount) VALUES (1, 'alice@example.com', 1000)`,
+		`INSERT INTO loans (borrower_id_number, principal_amount, rate, roi) VALUES ('3171234567890124', 500, 12, 10)`,
+	}
+	for _, statement := range statements {
+		if _, err := raw.Exec(statement); err != nil {
+			t.Fatalf("failed to set up baseline database: %v", err)
+		}
+	}
+	raw.Close()
+
+	db, err := NewDatabase(path)
+	if err != nil {
+		t.Fatalf("failed to upgrade baseline database: %v", err)
+	}
+	defer db.Close()
+	assertLatestSchema(t, db)
+
+	var state, language string
+	var termWeeks, version int
+	var allowMultiple bool
+	err = db.DB.QueryRow(`SELECT l.state, l.term_weeks, l.version, l.allow_multiple_investments, i.language
+		FROM loans l JOIN investments i ON i.loan_id = l.id WHERE l.id = 1`).Scan(&state, &termWeeks, &version, &allowMultiple, &language)
+	if err != nil {
+		t.Fatalf("failed to read the upgraded loan: %v", err)
+	}
+	if state != "disbursed" || termWeeks != 52 || version != 1 || !allowMultiple || language != "en" {
+		t.Errorf("got state %s, term %d, version %d, multiple investments %t, language %s; want the defaults for an existing loan",
+			state, termWeeks, version, allowMultiple, language)
+	}
+
+	// The disbursed loan became a single tranche with the signed agreement as its document
+	var amount float64
+	var document string
+	err = db.DB.QueryRow(`SELECT d.amount, ld.document FROM disbursements d
+		JOIN loan_documents ld ON ld.disbursement_id = d.id WHERE d.loan_id = 1`).Scan(&amount, &document)
+	if err != nil {
+		t.Fatalf("failed to read the backfilled disbursement: %v", err)
+	}
+	if amount != 1000 || document != "agreement.pdf" {
+		t.Errorf("got tranche of %.2f with document %s, want 1000.00 with agreement.pdf", amount, document)
+	}
+
+	var disbursements int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM disbursements WHERE loan_id = 2`).Scan(&disbursements); err != nil {
+		t.Fatalf("failed to count disbursements: %v", err)
+	}
+	if disbursements != 0 {
+		t.Errorf("got %d disbursements for the proposed loan, want 0", disbursements)
+	}
+}
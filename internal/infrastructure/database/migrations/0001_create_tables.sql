@@ -0,0 +1,34 @@
+-- Tables and indexes of the loan engine as first released. They are created
+-- only if missing, so databases created before migrations existed are adopted
+-- as they are and brought up to date by the migrations that follow.
+
+CREATE TABLE IF NOT EXISTS loans (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	borrower_id_number VARCHAR(16) NOT NULL,
+	principal_amount REAL NOT NULL,
+	rate REAL NOT NULL,
+	roi REAL NOT NULL,
+	state TEXT NOT NULL DEFAULT 'proposed',
+	agreement_letter_link TEXT,
+	approval_proof_picture TEXT,
+	approval_employee_id TEXT,
+	approval_date DATETIME,
+	signed_agreement_doc TEXT,
+	disbursement_employee_id TEXT,
+	disbursement_date DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS investments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	loan_id INTEGER NOT NULL,
+	investor_email TEXT NOT NULL,
+	amount REAL NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (loan_id) REFERENCES loans(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_loans_state ON loans(state);
+CREATE INDEX IF NOT EXISTS idx_loans_borrower ON loans(borrower_id_number);
+CREATE INDEX IF NOT EXISTS idx_investments_loan_id ON investments(loan_id);
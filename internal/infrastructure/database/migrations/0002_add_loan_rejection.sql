@@ -0,0 +1,4 @@
+-- Proposed loans can be rejected with a reason
+ALTER TABLE loans ADD COLUMN rejection_reason TEXT;
+ALTER TABLE loans ADD COLUMN rejection_employee_id TEXT;
+ALTER TABLE loans ADD COLUMN rejection_date DATETIME;
@@ -0,0 +1,4 @@
+-- Loans can be cancelled before they are fully invested
+ALTER TABLE loans ADD COLUMN cancellation_reason TEXT;
+ALTER TABLE loans ADD COLUMN cancellation_employee_id TEXT;
+ALTER TABLE loans ADD COLUMN cancellation_date DATETIME;
@@ -0,0 +1,4 @@
+-- A retried investment request carrying the same Idempotency-Key is stored once
+ALTER TABLE investments ADD COLUMN idempotency_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_investments_idempotency_key ON investments(idempotency_key);
@@ -0,0 +1,2 @@
+-- Borrowers are emailed when their loan is approved or disbursed
+ALTER TABLE loans ADD COLUMN borrower_email TEXT;
@@ -0,0 +1,12 @@
+-- Audit trail of the state changes of every loan
+CREATE TABLE IF NOT EXISTS loan_state_transitions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	loan_id INTEGER NOT NULL,
+	from_state TEXT NOT NULL,
+	to_state TEXT NOT NULL,
+	actor TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY (loan_id) REFERENCES loans(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_loan_state_transitions_loan_id ON loan_state_transitions(loan_id);
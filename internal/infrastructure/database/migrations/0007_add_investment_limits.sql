@@ -0,0 +1,4 @@
+-- Optional per-investment and per-investor limits, unlimited when NULL
+ALTER TABLE loans ADD COLUMN min_investment REAL;
+ALTER TABLE loans ADD COLUMN max_investment REAL;
+ALTER TABLE loans ADD COLUMN max_per_investor REAL;
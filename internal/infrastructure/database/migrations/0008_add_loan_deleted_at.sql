@@ -0,0 +1,2 @@
+-- Soft-deleted loans keep their row and are hidden from every query
+ALTER TABLE loans ADD COLUMN deleted_at DATETIME;
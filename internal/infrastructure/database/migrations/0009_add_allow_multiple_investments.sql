@@ -0,0 +1,2 @@
+-- Loans may restrict every investor to a single investment
+ALTER TABLE loans ADD COLUMN allow_multiple_investments BOOLEAN NOT NULL DEFAULT TRUE;
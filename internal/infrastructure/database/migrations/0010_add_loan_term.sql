@@ -0,0 +1,4 @@
+-- Loans mature term_weeks after disbursement; loans created before terms
+-- existed are given a one year term
+ALTER TABLE loans ADD COLUMN term_weeks INTEGER NOT NULL DEFAULT 52;
+ALTER TABLE loans ADD COLUMN maturity_date DATETIME;
@@ -0,0 +1,19 @@
+-- Loans may be disbursed in tranches, each recorded on its own; every loan
+-- already disbursed becomes a single tranche of its whole principal
+CREATE TABLE IF NOT EXISTS disbursements (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	loan_id INTEGER NOT NULL,
+	amount REAL NOT NULL,
+	signed_agreement_doc TEXT NOT NULL,
+	employee_id TEXT NOT NULL,
+	disbursement_date DATETIME NOT NULL,
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY (loan_id) REFERENCES loans(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_disbursements_loan_id ON disbursements(loan_id);
+
+INSERT INTO disbursements (loan_id, amount, signed_agreement_doc, employee_id, disbursement_date, created_at)
+SELECT id, principal_amount, signed_agreement_doc, disbursement_employee_id, disbursement_date, disbursement_date FROM loans
+WHERE state = 'disbursed' AND signed_agreement_doc IS NOT NULL AND disbursement_employee_id IS NOT NULL AND disbursement_date IS NOT NULL
+ORDER BY id;
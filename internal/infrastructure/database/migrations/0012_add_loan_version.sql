@@ -0,0 +1,2 @@
+-- Every loan update bumps the version so concurrent writers detect each other
+ALTER TABLE loans ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
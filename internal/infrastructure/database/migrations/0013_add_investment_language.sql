@@ -0,0 +1,2 @@
+-- Investors receive their notifications in the language they invested in
+ALTER TABLE investments ADD COLUMN language TEXT NOT NULL DEFAULT 'en';
@@ -0,0 +1,7 @@
+-- Strip the upload directory from file columns written before only bare
+-- filenames were stored
+UPDATE loans SET approval_proof_picture = REPLACE(approval_proof_picture, 'uploads/proof_pictures/', '')
+WHERE approval_proof_picture LIKE 'uploads/proof_pictures/%';
+
+UPDATE loans SET signed_agreement_doc = REPLACE(signed_agreement_doc, 'uploads/signed_agreements/', '')
+WHERE signed_agreement_doc LIKE 'uploads/signed_agreements/%';
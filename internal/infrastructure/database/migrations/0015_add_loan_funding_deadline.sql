@@ -0,0 +1,2 @@
+-- Approved loans expire unless fully funded by their funding deadline
+ALTER TABLE loans ADD COLUMN funding_deadline DATETIME;
@@ -0,0 +1,2 @@
+-- Audit entries that do not change the state, such as ROI updates, describe the change in a note
+ALTER TABLE loan_state_transitions ADD COLUMN note TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- Loans are denominated in a currency; investments keep the amount as invested,
+-- in the investor's currency, next to the amount converted to the loan currency
+ALTER TABLE loans ADD COLUMN currency TEXT NOT NULL DEFAULT 'IDR';
+ALTER TABLE investments ADD COLUMN currency TEXT NOT NULL DEFAULT 'IDR';
+ALTER TABLE investments ADD COLUMN original_amount REAL NOT NULL DEFAULT 0;
+UPDATE investments SET original_amount = amount;
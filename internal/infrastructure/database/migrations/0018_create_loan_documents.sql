@@ -0,0 +1,16 @@
+-- Disbursements may come with several signed documents; the signed agreement
+-- of each existing tranche becomes its first document
+CREATE TABLE IF NOT EXISTS loan_documents (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	loan_id INTEGER NOT NULL,
+	disbursement_id INTEGER NOT NULL,
+	document TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY (loan_id) REFERENCES loans(id),
+	FOREIGN KEY (disbursement_id) REFERENCES disbursements(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_loan_documents_loan_id ON loan_documents(loan_id);
+
+INSERT INTO loan_documents (loan_id, disbursement_id, document, created_at)
+SELECT loan_id, id, signed_agreement_doc, created_at FROM disbursements ORDER BY id;
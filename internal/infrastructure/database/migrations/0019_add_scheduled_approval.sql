@@ -0,0 +1,2 @@
+-- Approvals can be scheduled to take effect later, the loan stays proposed until then
+ALTER TABLE loans ADD COLUMN approval_effective_at DATETIME;
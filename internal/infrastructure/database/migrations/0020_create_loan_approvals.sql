@@ -0,0 +1,21 @@
+-- Loans above the dual approval threshold need two distinct approvers. Every
+-- approval is recorded, the approval of each already approved loan becomes its first.
+ALTER TABLE loans ADD COLUMN required_approvals INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS loan_approvals (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	loan_id INTEGER NOT NULL,
+	employee_id TEXT NOT NULL,
+	proof_picture TEXT NOT NULL,
+	approval_date DATETIME NOT NULL,
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY (loan_id) REFERENCES loans(id),
+	UNIQUE (loan_id, employee_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_loan_approvals_loan_id ON loan_approvals(loan_id);
+
+INSERT INTO loan_approvals (loan_id, employee_id, proof_picture, approval_date, created_at)
+SELECT id, approval_employee_id, approval_proof_picture, approval_date, approval_date FROM loans
+WHERE approval_employee_id IS NOT NULL AND approval_proof_picture IS NOT NULL AND approval_date IS NOT NULL
+ORDER BY id;
@@ -0,0 +1,2 @@
+-- Loans may only accept investments in whole multiples of an increment
+ALTER TABLE loans ADD COLUMN investment_increment REAL;
@@ -0,0 +1,139 @@
+package email
+
+import (
+	"amartha-andreas/internal/domain/service"
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+// ErrQueueFull is returned when a notification cannot be queued without blocking
+var ErrQueueFull = errors.New("email queue is full")
+
+// ErrQueueClosed is returned when a notification is queued after shutdown started
+var ErrQueueClosed = errors.New("email queue is closed")
+
+// emailJob is a queued notification, run against the wrapped email service
+type emailJob struct {
+	name string
+	send func() error
+}
+
+// AsyncEmailService implements service.EmailService by queueing notifications
+// for a pool of workers, so callers return without waiting on the email provider
+type AsyncEmailService struct {
+	next    service.EmailService
+	workers int
+	jobs    chan emailJob
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewAsyncEmailService wraps next with a queue of queueSize notifications
+// processed by workers goroutines once Start is called
+func NewAsyncEmailService(next service.EmailService, workers, queueSize int) *AsyncEmailService {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &AsyncEmailService{
+		next:    next,
+		workers: workers,
+		jobs:    make(chan emailJob, queueSize),
+	}
+}
+
+// Start launches the worker pool
+func (s *AsyncEmailService) Start() {
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for job := range s.jobs {
+				if err := job.send(); err != nil {
+					log.Printf("Failed to send %s: %v", job.name, err)
+				}
+			}
+		}()
+	}
+}
+
+// Shutdown stops accepting notifications and waits until the queued ones are
+// sent or ctx expires
+func (s *AsyncEmailService) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.jobs)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue queues a job without blocking the caller
+func (s *AsyncEmailService) enqueue(job emailJob) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case s.jobs <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// SendLoanFullyInvestedNotification queues the fully invested notification
+func (s *AsyncEmailService) SendLoanFullyInvestedNotification(ctx context.Context, request service.SendLoanNotificationRequest) error {
+	// Detach from the request context, which is cancelled once the response is written
+	ctx = context.WithoutCancel(ctx)
+	return s.enqueue(emailJob{
+		name: "loan fully invested notification",
+		send: func() error { return s.next.SendLoanFullyInvestedNotification(ctx, request) },
+	})
+}
+
+// SendLoanApprovedNotification queues the loan approved notification
+func (s *AsyncEmailService) SendLoanApprovedNotification(ctx context.Context, request service.SendLoanApprovedNotificationRequest) error {
+	ctx = context.WithoutCancel(ctx)
+	return s.enqueue(emailJob{
+		name: "loan approved notification",
+		send: func() error { return s.next.SendLoanApprovedNotification(ctx, request) },
+	})
+}
+
+// SendLoanDisbursedNotification queues the loan disbursed notification
+func (s *AsyncEmailService) SendLoanDisbursedNotification(ctx context.Context, request service.SendLoanDisbursedNotificationRequest) error {
+	ctx = context.WithoutCancel(ctx)
+	return s.enqueue(emailJob{
+		name: "loan disbursed notification",
+		send: func() error { return s.next.SendLoanDisbursedNotification(ctx, request) },
+	})
+}
+
+// SendInvestmentConfirmation queues the investment confirmation
+func (s *AsyncEmailService) SendInvestmentConfirmation(ctx context.Context, request service.SendInvestmentConfirmationRequest) error {
+	ctx = context.WithoutCancel(ctx)
+	return s.enqueue(emailJob{
+		name: "investment confirmation",
+		send: func() error { return s.next.SendInvestmentConfirmation(ctx, request) },
+	})
+}
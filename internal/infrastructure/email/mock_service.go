@@ -19,9 +19,47 @@ func (m *mockEmailService) SendLoanFullyInvestedNotification(ctx context.Context
 	log.Printf("MOCK EMAIL: Loan Fully Invested Notification")
 	log.Printf("  Loan ID: %d", request.LoanID)
 	log.Printf("  Borrower ID: %s", request.BorrowerIDNumber)
-	log.Printf("  Principal Amount: $%.2f", request.PrincipalAmount)
+	log.Printf("  Principal Amount: $%s", request.PrincipalAmount)
 	log.Printf("  Agreement Letter: %s", request.AgreementLetterLink)
 	log.Printf("  Investor Emails: %v", request.InvestorEmails)
+	log.Printf("  Language: %s", request.Language)
 	log.Printf("  Email Content: Loan is fully funded, agreement letter available")
 	return nil
 }
+
+// SendLoanApprovedNotification logs the notification instead of sending email
+func (m *mockEmailService) SendLoanApprovedNotification(ctx context.Context, request service.SendLoanApprovedNotificationRequest) error {
+	log.Printf("MOCK EMAIL: Loan Approved Notification")
+	log.Printf("  Loan ID: %d", request.LoanID)
+	log.Printf("  Borrower ID: %s", request.BorrowerIDNumber)
+	log.Printf("  Approved By: %s", request.EmployeeID)
+	log.Printf("  Approval Date: %s", request.ApprovalDate.Format("2006-01-02 15:04:05"))
+	log.Printf("  Email Content: Loan has been approved and is open for investment")
+	return nil
+}
+
+// SendLoanDisbursedNotification logs the notification instead of sending email
+func (m *mockEmailService) SendLoanDisbursedNotification(ctx context.Context, request service.SendLoanDisbursedNotificationRequest) error {
+	log.Printf("MOCK EMAIL: Loan Disbursed Notification")
+	log.Printf("  Loan ID: %d", request.LoanID)
+	log.Printf("  Borrower Email: %s", request.BorrowerEmail)
+	log.Printf("  Borrower ID: %s", request.BorrowerIDNumber)
+	log.Printf("  Disbursed Amount: $%s", request.PrincipalAmount)
+	log.Printf("  Signed Agreement: %s", request.SignedAgreementDoc)
+	log.Printf("  Disbursement Date: %s", request.DisbursementDate.Format("2006-01-02 15:04:05"))
+	log.Printf("  Email Content: Loan has been disbursed, signed agreement attached as link")
+	return nil
+}
+
+// SendInvestmentConfirmation logs the confirmation instead of sending email
+func (m *mockEmailService) SendInvestmentConfirmation(ctx context.Context, request service.SendInvestmentConfirmationRequest) error {
+	log.Printf("MOCK EMAIL: Investment Confirmation")
+	log.Printf("  Investment ID: %d", request.InvestmentID)
+	log.Printf("  Loan ID: %d", request.LoanID)
+	log.Printf("  Investor Email: %s", request.InvestorEmail)
+	log.Printf("  Amount: %s %s", request.Amount, request.Currency)
+	log.Printf("  Funded: %.2f%%", request.FundedPercentage)
+	log.Printf("  Language: %s", request.Language)
+	log.Printf("  Email Content: Investment received, loan details included")
+	return nil
+}
@@ -0,0 +1,51 @@
+package email
+
+import (
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+)
+
+// Email types, named after their templates, that may each be sent from their own sender
+const (
+	EmailTypeLoanFullyInvested      = "loan_fully_invested"
+	EmailTypeLoanApproved           = "loan_approved"
+	EmailTypeLoanDisbursed          = "loan_disbursed"
+	EmailTypeInvestmentConfirmation = "investment_confirmation"
+)
+
+// emailTypes lists the email types accepted by ParseSenders
+var emailTypes = []string{EmailTypeLoanFullyInvested, EmailTypeLoanApproved, EmailTypeLoanDisbursed, EmailTypeInvestmentConfirmation}
+
+// Sender is the identity an email is sent from
+type Sender struct {
+	Email string
+	Name  string
+}
+
+// ParseSenders parses comma-separated senders by email type such as
+// "investment_confirmation=noreply@amartha.com,loan_disbursed=Amartha Loans <loans@amartha.com>"
+func ParseSenders(value string) (map[string]Sender, error) {
+	senders := make(map[string]Sender)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		emailType, address, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("sender %q must look like loan_disbursed=loans@amartha.com", entry)
+		}
+		emailType = strings.TrimSpace(emailType)
+		if !slices.Contains(emailTypes, emailType) {
+			return nil, fmt.Errorf("sender %q: unknown email type %q, expected one of %s", entry, emailType, strings.Join(emailTypes, ", "))
+		}
+		parsed, err := mail.ParseAddress(strings.TrimSpace(address))
+		if err != nil {
+			return nil, fmt.Errorf("sender %q: %w", entry, err)
+		}
+		senders[emailType] = Sender{Email: parsed.Address, Name: parsed.Name}
+	}
+	return senders, nil
+}
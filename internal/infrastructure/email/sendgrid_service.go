@@ -2,30 +2,71 @@ package email
 
 import (
 	"amartha-andreas/internal/domain/service"
+	"amartha-andreas/internal/infrastructure/email/templates"
+	"cmp"
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/sendgrid/rest"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
 )
 
+// maxPersonalizations is the SendGrid limit of personalizations per request
+const maxPersonalizations = 1000
+
+// Retry defaults used when SendGridConfig leaves them unset
+const (
+	defaultMaxAttempts    = 3
+	defaultRetryBaseDelay = 500 * time.Millisecond
+)
+
 // SendGridConfig holds the configuration for SendGrid
 type SendGridConfig struct {
 	APIKey    string
 	FromEmail string
 	FromName  string
+	OpsEmail  string // Recipient for operational notifications such as loan approvals
+
+	// Senders overrides the sender by email type, such as EmailTypeLoanDisbursed;
+	// types without one are sent from FromEmail and FromName
+	Senders map[string]Sender
+
+	// FileBaseURL is the base URL of stored files, used to link documents stored as bare filenames
+	FileBaseURL string
+
+	// MaxAttempts bounds how many times a send is tried on 429/5xx responses and network errors
+	MaxAttempts int
+	// RetryBaseDelay is the wait before the first retry, doubled for every further attempt
+	RetryBaseDelay time.Duration
+}
+
+// sendGridClient is the subset of the SendGrid client used by the service
+type sendGridClient interface {
+	SendWithContext(ctx context.Context, email *mail.SGMailV3) (*rest.Response, error)
 }
 
 // sendGridService implements service.EmailService using SendGrid
 type sendGridService struct {
-	client *sendgrid.Client
+	client sendGridClient
 	config SendGridConfig
 }
 
 // NewSendGridService creates a new SendGrid email service
 func NewSendGridService(config SendGridConfig) service.EmailService {
 	client := sendgrid.NewSendClient(config.APIKey)
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.RetryBaseDelay <= 0 {
+		config.RetryBaseDelay = defaultRetryBaseDelay
+	}
 	return &sendGridService{
 		client: client,
 		config: config,
@@ -34,64 +75,217 @@ func NewSendGridService(config SendGridConfig) service.EmailService {
 
 // SendLoanFullyInvestedNotification sends notification when loan is fully invested
 func (s *sendGridService) SendLoanFullyInvestedNotification(ctx context.Context, request service.SendLoanNotificationRequest) error {
-	from := mail.NewEmail(s.config.FromName, s.config.FromEmail)
-	subject := fmt.Sprintf("Loan #%d is Fully Invested - Agreement Letter Available", request.LoanID)
-
-	// Create HTML content
-	htmlContent := fmt.Sprintf(`
-		<h2>Loan Fully Invested Notification</h2>
-		<p>Dear Investor,</p>
-		<p>Great news! The loan you invested in has been fully funded and is ready for disbursement.</p>
-		<h3>Loan Details:</h3>
-		<ul>
-			<li><strong>Loan ID:</strong> %d</li>
-			<li><strong>Borrower ID:</strong> %s</li>
-			<li><strong>Principal Amount:</strong> $%.2f</li>
-		</ul>
-		<p><strong>Agreement Letter:</strong> <a href="%s">Download Agreement</a></p>
-		<p>Thank you for your investment!</p>
-		<p>Best regards,<br/>Amartha Loan Engine Team</p>
-	`, request.LoanID, request.BorrowerIDNumber, request.PrincipalAmount, request.AgreementLetterLink)
-
-	// Create plain text content
-	plainTextContent := fmt.Sprintf(`
-Loan Fully Invested Notification
-
-Dear Investor,
-
-Great news! The loan you invested in has been fully funded and is ready for disbursement.
-
-Loan Details:
-- Loan ID: %d
-- Borrower ID: %s
-- Principal Amount: $%.2f
-
-Agreement Letter: %s
-
-Thank you for your investment!
-
-Best regards,
-Amartha Loan Engine Team
-	`, request.LoanID, request.BorrowerIDNumber, request.PrincipalAmount, request.AgreementLetterLink)
-
-	// Send to all investors
-	for _, email := range request.InvestorEmails {
-		to := mail.NewEmail("", email)
-		message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
-
-		response, err := s.client.Send(message)
+	from := s.from(EmailTypeLoanFullyInvested)
+	content, err := templates.LoanFullyInvested(request)
+	if err != nil {
+		return err
+	}
+
+	// Send to all investors in as few requests as possible, one personalization per
+	// recipient so investors don't see each other's addresses
+	var rejected []string
+	for start := 0; start < len(request.InvestorEmails); start += maxPersonalizations {
+		end := start + maxPersonalizations
+		if end > len(request.InvestorEmails) {
+			end = len(request.InvestorEmails)
+		}
+		recipients := request.InvestorEmails[start:end]
+
+		message := mail.NewV3Mail()
+		message.SetFrom(from)
+		message.Subject = content.Subject
+		message.AddContent(
+			mail.NewContent("text/plain", content.PlainText),
+			mail.NewContent("text/html", content.HTML),
+		)
+		for _, email := range recipients {
+			personalization := mail.NewPersonalization()
+			personalization.AddTos(mail.NewEmail("", email))
+			message.AddPersonalizations(personalization)
+		}
+
+		response, err := s.send(ctx, message)
 		if err != nil {
-			log.Printf("Failed to send email to %s: %v", email, err)
-			return fmt.Errorf("failed to send email to %s: %w", email, err)
+			log.Printf("Failed to send email to %d investors: %v", len(recipients), err)
+			return fmt.Errorf("failed to send email to %d investors: %w", len(recipients), err)
 		}
 
 		if response.StatusCode >= 400 {
-			log.Printf("SendGrid error for %s: Status %d, Body: %s", email, response.StatusCode, response.Body)
-			return fmt.Errorf("sendgrid error for %s: status %d", email, response.StatusCode)
+			log.Printf("SendGrid error: Status %d, Body: %s", response.StatusCode, response.Body)
+			failed := rejectedRecipients(response.Body, recipients)
+			if len(failed) == 0 {
+				return fmt.Errorf("sendgrid error: status %d", response.StatusCode)
+			}
+			rejected = append(rejected, failed...)
+			continue
+		}
+
+		log.Printf("Successfully sent loan fully invested notification to %d investors", len(recipients))
+	}
+
+	if len(rejected) > 0 {
+		return fmt.Errorf("sendgrid rejected %d recipients: %s", len(rejected), strings.Join(rejected, "; "))
+	}
+
+	return nil
+}
+
+// from returns the sender of emailType, the configured default unless Senders
+// overrides it. An override without a name keeps the default name.
+func (s *sendGridService) from(emailType string) *mail.Email {
+	sender, ok := s.config.Senders[emailType]
+	if !ok {
+		return mail.NewEmail(s.config.FromName, s.config.FromEmail)
+	}
+	return mail.NewEmail(cmp.Or(sender.Name, s.config.FromName), sender.Email)
+}
+
+// send sends the message, retrying with exponential backoff while SendGrid
+// answers 429/5xx or the request fails at the network level
+func (s *sendGridService) send(ctx context.Context, message *mail.SGMailV3) (*rest.Response, error) {
+	delay := s.config.RetryBaseDelay
+	for attempt := 1; ; attempt++ {
+		response, err := s.client.SendWithContext(ctx, message)
+		if !isRetryable(ctx, response, err) || attempt >= s.config.MaxAttempts {
+			return response, err
 		}
 
-		log.Printf("Successfully sent loan fully invested notification to %s", email)
+		if err != nil {
+			log.Printf("SendGrid attempt %d/%d failed: %v, retrying in %s", attempt, s.config.MaxAttempts, err, delay)
+		} else {
+			log.Printf("SendGrid attempt %d/%d returned status %d, retrying in %s", attempt, s.config.MaxAttempts, response.StatusCode, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// isRetryable reports whether a send failed transiently: a network error (not a
+// cancelled context), a 429 rate limit or a 5xx server error
+func isRetryable(ctx context.Context, response *rest.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil
+	}
+	return response.StatusCode == 429 || response.StatusCode >= 500
+}
+
+// personalizationField matches SendGrid error fields such as "personalizations.2.to.0.email"
+var personalizationField = regexp.MustCompile(`^personalizations\.(\d+)\.`)
+
+// rejectedRecipients maps SendGrid's per-personalization errors back to recipient emails
+func rejectedRecipients(body string, recipients []string) []string {
+	var errorResponse struct {
+		Errors []struct {
+			Message string `json:"message"`
+			Field   string `json:"field"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(body), &errorResponse); err != nil {
+		return nil
+	}
+
+	var rejected []string
+	for _, e := range errorResponse.Errors {
+		match := personalizationField.FindStringSubmatch(e.Field)
+		if match == nil {
+			continue
+		}
+		index, err := strconv.Atoi(match[1])
+		if err != nil || index >= len(recipients) {
+			continue
+		}
+		rejected = append(rejected, fmt.Sprintf("%s (%s)", recipients[index], e.Message))
+	}
+
+	return rejected
+}
+
+// SendLoanApprovedNotification notifies the operations team that a loan has been approved
+func (s *sendGridService) SendLoanApprovedNotification(ctx context.Context, request service.SendLoanApprovedNotificationRequest) error {
+	if s.config.OpsEmail == "" {
+		log.Printf("No ops email configured, skipping loan approved notification for loan %d", request.LoanID)
+		return nil
+	}
+
+	from := s.from(EmailTypeLoanApproved)
+	content, err := templates.LoanApproved(request)
+	if err != nil {
+		return err
+	}
+
+	to := mail.NewEmail("", s.config.OpsEmail)
+	message := mail.NewSingleEmail(from, content.Subject, to, content.PlainText, content.HTML)
+
+	response, err := s.send(ctx, message)
+	if err != nil {
+		log.Printf("Failed to send email to %s: %v", s.config.OpsEmail, err)
+		return fmt.Errorf("failed to send email to %s: %w", s.config.OpsEmail, err)
+	}
+
+	if response.StatusCode >= 400 {
+		log.Printf("SendGrid error for %s: Status %d, Body: %s", s.config.OpsEmail, response.StatusCode, response.Body)
+		return fmt.Errorf("sendgrid error for %s: status %d", s.config.OpsEmail, response.StatusCode)
+	}
+
+	log.Printf("Successfully sent loan approved notification to %s", s.config.OpsEmail)
+	return nil
+}
+
+// SendLoanDisbursedNotification notifies the borrower that their loan has been disbursed
+func (s *sendGridService) SendLoanDisbursedNotification(ctx context.Context, request service.SendLoanDisbursedNotificationRequest) error {
+	from := s.from(EmailTypeLoanDisbursed)
+	content, err := templates.LoanDisbursed(request, s.config.FileBaseURL)
+	if err != nil {
+		return err
+	}
+
+	to := mail.NewEmail("", request.BorrowerEmail)
+	message := mail.NewSingleEmail(from, content.Subject, to, content.PlainText, content.HTML)
+
+	response, err := s.send(ctx, message)
+	if err != nil {
+		log.Printf("Failed to send email to %s: %v", request.BorrowerEmail, err)
+		return fmt.Errorf("failed to send email to %s: %w", request.BorrowerEmail, err)
+	}
+
+	if response.StatusCode >= 400 {
+		log.Printf("SendGrid error for %s: Status %d, Body: %s", request.BorrowerEmail, response.StatusCode, response.Body)
+		return fmt.Errorf("sendgrid error for %s: status %d", request.BorrowerEmail, response.StatusCode)
+	}
+
+	log.Printf("Successfully sent loan disbursed notification to %s", request.BorrowerEmail)
+	return nil
+}
+
+// SendInvestmentConfirmation confirms to an investor that their investment was received
+func (s *sendGridService) SendInvestmentConfirmation(ctx context.Context, request service.SendInvestmentConfirmationRequest) error {
+	from := s.from(EmailTypeInvestmentConfirmation)
+	content, err := templates.InvestmentConfirmation(request)
+	if err != nil {
+		return err
+	}
+
+	to := mail.NewEmail("", request.InvestorEmail)
+	message := mail.NewSingleEmail(from, content.Subject, to, content.PlainText, content.HTML)
+
+	response, err := s.send(ctx, message)
+	if err != nil {
+		log.Printf("Failed to send email to %s: %v", request.InvestorEmail, err)
+		return fmt.Errorf("failed to send email to %s: %w", request.InvestorEmail, err)
+	}
+
+	if response.StatusCode >= 400 {
+		log.Printf("SendGrid error for %s: Status %d, Body: %s", request.InvestorEmail, response.StatusCode, response.Body)
+		return fmt.Errorf("sendgrid error for %s: status %d", request.InvestorEmail, response.StatusCode)
 	}
 
+	log.Printf("Successfully sent investment confirmation to %s", request.InvestorEmail)
 	return nil
 }
@@ -0,0 +1,223 @@
+package email
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"amartha-andreas/internal/domain/service"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sendgrid/rest"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// stubSendGridClient records the messages instead of calling SendGrid,
+// answering with the queued responses and then 202 Accepted
+type stubSendGridClient struct {
+	sent      []*mail.SGMailV3
+	responses []*rest.Response
+}
+
+func (c *stubSendGridClient) SendWithContext(ctx context.Context, email *mail.SGMailV3) (*rest.Response, error) {
+	c.sent = append(c.sent, email)
+	if len(c.responses) > 0 {
+		response := c.responses[0]
+		c.responses = c.responses[1:]
+		return response, nil
+	}
+	return &rest.Response{StatusCode: http.StatusAccepted}, nil
+}
+
+func newStubSendGridService(client sendGridClient) *sendGridService {
+	return &sendGridService{
+		client: client,
+		config: SendGridConfig{
+			FromEmail:      "noreply@example.com",
+			FromName:       "Loan Engine",
+			MaxAttempts:    3,
+			RetryBaseDelay: time.Millisecond,
+		},
+	}
+}
+
+func fullyInvestedRequest(investors int) service.SendLoanNotificationRequest {
+	emails := make([]string, investors)
+	for i := range emails {
+		emails[i] = fmt.Sprintf("investor%d@example.com", i)
+	}
+	return service.SendLoanNotificationRequest{
+		LoanID:              1,
+		InvestorEmails:      emails,
+		BorrowerIDNumber:    "3171234567890123",
+		PrincipalAmount:     entity.NewMoney(1000),
+		AgreementLetterLink: "https://example.com/agreements/1.pdf",
+	}
+}
+
+func TestSendLoanFullyInvestedNotificationSendsOneRequestForAllInvestors(t *testing.T) {
+	client := &stubSendGridClient{}
+	s := newStubSendGridService(client)
+
+	const investors = 25
+	if err := s.SendLoanFullyInvestedNotification(context.Background(), fullyInvestedRequest(investors)); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	if len(client.sent) != 1 {
+		t.Fatalf("got %d sends, want 1", len(client.sent))
+	}
+	personalizations := client.sent[0].Personalizations
+	if len(personalizations) != investors {
+		t.Fatalf("got %d personalizations, want one per investor (%d)", len(personalizations), investors)
+	}
+	for i, p := range personalizations {
+		// Every investor gets their own personalization so they don't see each other
+		if len(p.To) != 1 || p.To[0].Address != fmt.Sprintf("investor%d@example.com", i) {
+			t.Errorf("personalization %d has recipients %v", i, p.To)
+		}
+	}
+}
+
+func TestSendLoanFullyInvestedNotificationSplitsAtPersonalizationLimit(t *testing.T) {
+	client := &stubSendGridClient{}
+	s := newStubSendGridService(client)
+
+	if err := s.SendLoanFullyInvestedNotification(context.Background(), fullyInvestedRequest(maxPersonalizations+1)); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	if len(client.sent) != 2 {
+		t.Fatalf("got %d sends, want 2", len(client.sent))
+	}
+	if got := len(client.sent[0].Personalizations); got != maxPersonalizations {
+		t.Errorf("first send has %d personalizations, want %d", got, maxPersonalizations)
+	}
+	if got := len(client.sent[1].Personalizations); got != 1 {
+		t.Errorf("second send has %d personalizations, want 1", got)
+	}
+}
+
+func TestSendLoanFullyInvestedNotificationListsRejectedRecipients(t *testing.T) {
+	client := &stubSendGridClient{responses: []*rest.Response{{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"errors":[{"message":"Does not contain a valid address.","field":"personalizations.1.to.0.email"}]}`,
+	}}}
+	s := newStubSendGridService(client)
+
+	err := s.SendLoanFullyInvestedNotification(context.Background(), fullyInvestedRequest(3))
+	if err == nil {
+		t.Fatal("got no error, want the rejected recipient reported")
+	}
+	want := "sendgrid rejected 1 recipients: investor1@example.com (Does not contain a valid address.)"
+	if err.Error() != want {
+		t.Errorf("got error %q, want %q", err.Error(), want)
+	}
+	if len(client.sent) != 1 {
+		t.Errorf("got %d sends, want 1", len(client.sent))
+	}
+}
+
+func TestSendRetriesTransientFailures(t *testing.T) {
+	client := &stubSendGridClient{responses: []*rest.Response{
+		{StatusCode: http.StatusServiceUnavailable},
+		{StatusCode: http.StatusTooManyRequests},
+	}}
+	s := newStubSendGridService(client)
+
+	if err := s.SendLoanFullyInvestedNotification(context.Background(), fullyInvestedRequest(3)); err != nil {
+		t.Fatalf("got error %v, want the third attempt to succeed", err)
+	}
+	if len(client.sent) != 3 {
+		t.Errorf("got %d attempts, want 3", len(client.sent))
+	}
+}
+
+func TestSendGivesUpAfterMaxAttempts(t *testing.T) {
+	client := &stubSendGridClient{responses: []*rest.Response{
+		{StatusCode: http.StatusBadGateway},
+		{StatusCode: http.StatusBadGateway},
+		{StatusCode: http.StatusBadGateway},
+		{StatusCode: http.StatusBadGateway},
+	}}
+	s := newStubSendGridService(client)
+
+	err := s.SendLoanFullyInvestedNotification(context.Background(), fullyInvestedRequest(3))
+	if err == nil || err.Error() != "sendgrid error: status 502" {
+		t.Fatalf("got error %v, want the last status reported", err)
+	}
+	if len(client.sent) != 3 {
+		t.Errorf("got %d attempts, want MaxAttempts (3)", len(client.sent))
+	}
+}
+
+func TestSendStopsRetryingWhenContextIsCancelled(t *testing.T) {
+	client := &stubSendGridClient{responses: []*rest.Response{{StatusCode: http.StatusServiceUnavailable}}}
+	s := newStubSendGridService(client)
+	s.config.RetryBaseDelay = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s.SendLoanFullyInvestedNotification(ctx, fullyInvestedRequest(3))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want context.Canceled", err)
+	}
+	if len(client.sent) != 1 {
+		t.Errorf("got %d attempts, want 1", len(client.sent))
+	}
+}
+
+func TestSendUsesTheSenderOfEachEmailType(t *testing.T) {
+	client := &stubSendGridClient{}
+	s := newStubSendGridService(client)
+	s.config.OpsEmail = "ops@example.com"
+	s.config.Senders = map[string]Sender{
+		EmailTypeInvestmentConfirmation: {Email: "confirmations@example.com"},
+		EmailTypeLoanDisbursed:          {Email: "loans@example.com", Name: "Loan Desk"},
+	}
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		send     func() error
+		wantFrom mail.Email
+	}{
+		{"investment confirmation, default name", func() error {
+			return s.SendInvestmentConfirmation(ctx, service.SendInvestmentConfirmationRequest{
+				InvestmentID: 1, LoanID: 1, InvestorEmail: "investor0@example.com", Amount: entity.NewMoney(100),
+				Currency: entity.DefaultCurrency, PrincipalAmount: entity.NewMoney(1000), LoanCurrency: entity.DefaultCurrency, InvestedAt: now,
+			})
+		}, mail.Email{Name: "Loan Engine", Address: "confirmations@example.com"}},
+		{"loan disbursed", func() error {
+			return s.SendLoanDisbursedNotification(ctx, service.SendLoanDisbursedNotificationRequest{
+				LoanID: 1, BorrowerEmail: "borrower@example.com", PrincipalAmount: entity.NewMoney(1000),
+				SignedAgreementDoc: "https://example.com/files/signed.pdf", DisbursementDate: now,
+			})
+		}, mail.Email{Name: "Loan Desk", Address: "loans@example.com"}},
+		{"loan approved falls back to the default", func() error {
+			return s.SendLoanApprovedNotification(ctx, service.SendLoanApprovedNotificationRequest{LoanID: 1, EmployeeID: "EMP-APPROVER", ApprovalDate: now})
+		}, mail.Email{Name: "Loan Engine", Address: "noreply@example.com"}},
+		{"loan fully invested falls back to the default", func() error {
+			return s.SendLoanFullyInvestedNotification(ctx, fullyInvestedRequest(1))
+		}, mail.Email{Name: "Loan Engine", Address: "noreply@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.sent = nil
+			if err := tt.send(); err != nil {
+				t.Fatalf("failed to send: %v", err)
+			}
+			if len(client.sent) != 1 {
+				t.Fatalf("got %d sends, want 1", len(client.sent))
+			}
+			if from := client.sent[0].From; from == nil || *from != tt.wantFrom {
+				t.Errorf("got from %v, want %v", from, tt.wantFrom)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package email
+
+import (
+	"amartha-andreas/internal/domain/service"
+	"amartha-andreas/internal/infrastructure/email/templates"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRecipientsPerMessage keeps each SMTP transaction within the recipient
+// limit most servers enforce (RFC 5321 requires accepting at least 100)
+const maxRecipientsPerMessage = 100
+
+// TLS modes of the SMTP connection
+const (
+	SMTPTLSStartTLS = "starttls" // Upgrade a plain connection with STARTTLS, usually on port 587
+	SMTPTLSImplicit = "tls"      // Connect over TLS from the start, usually on port 465
+	SMTPTLSNone     = "none"     // No encryption, only for local relays
+)
+
+// SMTPConfig holds the configuration of an SMTP server
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // Authenticates with PLAIN when set
+	Password string
+	TLSMode  string // One of SMTPTLSStartTLS (default), SMTPTLSImplicit, SMTPTLSNone
+
+	FromEmail string
+	FromName  string
+	OpsEmail  string // Recipient for operational notifications such as loan approvals
+
+	// FileBaseURL is the base URL of stored files, used to link documents stored as bare filenames
+	FileBaseURL string
+}
+
+// smtpService implements service.EmailService using an SMTP server
+type smtpService struct {
+	config SMTPConfig
+}
+
+// NewSMTPService creates a new SMTP email service
+func NewSMTPService(config SMTPConfig) service.EmailService {
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	if config.TLSMode == "" {
+		config.TLSMode = SMTPTLSStartTLS
+	}
+	return &smtpService{
+		config: config,
+	}
+}
+
+// SendLoanFullyInvestedNotification sends notification when loan is fully invested
+func (s *smtpService) SendLoanFullyInvestedNotification(ctx context.Context, request service.SendLoanNotificationRequest) error {
+	content, err := templates.LoanFullyInvested(request)
+	if err != nil {
+		return err
+	}
+
+	// Investors are only addressed in the envelope so they don't see each other's addresses
+	var rejected []string
+	for start := 0; start < len(request.InvestorEmails); start += maxRecipientsPerMessage {
+		end := min(start+maxRecipientsPerMessage, len(request.InvestorEmails))
+		recipients := request.InvestorEmails[start:end]
+
+		failed, err := s.send(ctx, recipients, "undisclosed-recipients:;", content)
+		if err != nil {
+			log.Printf("Failed to send email to %d investors: %v", len(recipients), err)
+			return fmt.Errorf("failed to send email to %d investors: %w", len(recipients), err)
+		}
+		rejected = append(rejected, failed...)
+
+		log.Printf("Successfully sent loan fully invested notification to %d investors", len(recipients)-len(failed))
+	}
+
+	if len(rejected) > 0 {
+		return fmt.Errorf("smtp server rejected %d recipients: %s", len(rejected), strings.Join(rejected, "; "))
+	}
+
+	return nil
+}
+
+// SendLoanApprovedNotification notifies the operations team that a loan has been approved
+func (s *smtpService) SendLoanApprovedNotification(ctx context.Context, request service.SendLoanApprovedNotificationRequest) error {
+	if s.config.OpsEmail == "" {
+		log.Printf("No ops email configured, skipping loan approved notification for loan %d", request.LoanID)
+		return nil
+	}
+
+	content, err := templates.LoanApproved(request)
+	if err != nil {
+		return err
+	}
+
+	return s.sendSingle(ctx, s.config.OpsEmail, content, "loan approved")
+}
+
+// SendLoanDisbursedNotification notifies the borrower that their loan has been disbursed
+func (s *smtpService) SendLoanDisbursedNotification(ctx context.Context, request service.SendLoanDisbursedNotificationRequest) error {
+	content, err := templates.LoanDisbursed(request, s.config.FileBaseURL)
+	if err != nil {
+		return err
+	}
+
+	return s.sendSingle(ctx, request.BorrowerEmail, content, "loan disbursed")
+}
+
+// SendInvestmentConfirmation confirms to an investor that their investment was received
+func (s *smtpService) SendInvestmentConfirmation(ctx context.Context, request service.SendInvestmentConfirmationRequest) error {
+	content, err := templates.InvestmentConfirmation(request)
+	if err != nil {
+		return err
+	}
+
+	return s.sendSingle(ctx, request.InvestorEmail, content, "investment confirmation")
+}
+
+// sendSingle sends content to one recipient, named in the To header
+func (s *smtpService) sendSingle(ctx context.Context, to string, content templates.Message, notification string) error {
+	rejected, err := s.send(ctx, []string{to}, to, content)
+	if err == nil && len(rejected) > 0 {
+		err = fmt.Errorf("recipient rejected: %s", rejected[0])
+	}
+	if err != nil {
+		log.Printf("Failed to send email to %s: %v", to, err)
+		return fmt.Errorf("failed to send email to %s: %w", to, err)
+	}
+
+	log.Printf("Successfully sent %s notification to %s", notification, to)
+	return nil
+}
+
+// send delivers content to recipients in one SMTP transaction, returning the
+// recipients the server refused. The message is only sent when at least one
+// recipient was accepted.
+func (s *smtpService) send(ctx context.Context, recipients []string, toHeader string, content templates.Message) ([]string, error) {
+	message, err := s.buildMessage(toHeader, content)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	if err := client.Mail(s.config.FromEmail); err != nil {
+		return nil, err
+	}
+	var rejected []string
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			rejected = append(rejected, fmt.Sprintf("%s (%v)", recipient, err))
+		}
+	}
+	if len(rejected) == len(recipients) {
+		return rejected, nil
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(message); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return rejected, client.Quit()
+}
+
+// dial connects and authenticates to the server, bounded by the deadline of ctx
+func (s *smtpService) dial(ctx context.Context) (*smtp.Client, error) {
+	address := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	tlsConfig := &tls.Config{ServerName: s.config.Host}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if s.config.TLSMode == SMTPTLSImplicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if s.config.TLSMode == SMTPTLSStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	if s.config.Username != "" {
+		auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+// buildMessage renders content as a multipart/alternative MIME message with
+// quoted-printable plain text and HTML parts
+func (s *smtpService) buildMessage(toHeader string, content templates.Message) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		text        string
+	}{
+		{"text/plain; charset=UTF-8", content.PlainText},
+		{"text/html; charset=UTF-8", content.HTML},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoder := quotedprintable.NewWriter(writer)
+		if _, err := encoder.Write([]byte(part.text)); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	from := mail.Address{Name: s.config.FromName, Address: s.config.FromEmail}
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from.String())
+	fmt.Fprintf(&message, "To: %s\r\n", toHeader)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", content.Subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%q\r\n", parts.Boundary())
+	fmt.Fprintf(&message, "\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
@@ -0,0 +1,238 @@
+package email
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"amartha-andreas/internal/domain/service"
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpMessage is a message received by the mock SMTP server
+type smtpMessage struct {
+	from       string
+	recipients []string
+	data       string
+}
+
+// mockSMTPServer is a minimal plain-text SMTP server accepting every message.
+// Recipients at reject.example.com are refused with 550.
+type mockSMTPServer struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+func newMockSMTPServer(t *testing.T) *mockSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &mockSMTPServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+// serve runs one SMTP session
+func (s *mockSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	var message smtpMessage
+	reply("220 localhost ESMTP mock")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0])
+
+		switch {
+		case verb == "EHLO" || verb == "HELO":
+			reply("250 localhost")
+		case strings.HasPrefix(strings.ToUpper(command), "MAIL FROM:"):
+			message = smtpMessage{from: strings.Trim(command[len("MAIL FROM:"):], "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(strings.ToUpper(command), "RCPT TO:"):
+			recipient := strings.Trim(command[len("RCPT TO:"):], "<>")
+			if strings.HasSuffix(recipient, "@reject.example.com") {
+				reply("550 mailbox unavailable")
+				continue
+			}
+			message.recipients = append(message.recipients, recipient)
+			reply("250 OK")
+		case verb == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			message.data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, message)
+			s.mu.Unlock()
+			reply("250 OK queued")
+		case verb == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *mockSMTPServer) received() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+// newMockSMTPService creates an SMTP service sending to server without TLS
+func newMockSMTPService(t *testing.T, server *mockSMTPServer) service.EmailService {
+	host, port, err := net.SplitHostPort(server.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to parse server address: %v", err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	return NewSMTPService(SMTPConfig{
+		Host:      host,
+		Port:      portNumber,
+		TLSMode:   SMTPTLSNone,
+		FromEmail: "noreply@example.com",
+		FromName:  "Loan Engine",
+	})
+}
+
+// readMessageParts parses a received message, returning its headers and its
+// decoded parts by content type
+func readMessageParts(t *testing.T, data string) (mail.Header, map[string]string) {
+	t.Helper()
+
+	message, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("got content type %q, want multipart/alternative", message.Header.Get("Content-Type"))
+	}
+
+	parts := make(map[string]string)
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(body)
+	}
+	return message.Header, parts
+}
+
+func TestSMTPSendInvestmentConfirmation(t *testing.T) {
+	server := newMockSMTPServer(t)
+	s := newMockSMTPService(t, server)
+
+	err := s.SendInvestmentConfirmation(context.Background(), service.SendInvestmentConfirmationRequest{
+		InvestmentID:     7,
+		LoanID:           3,
+		InvestorEmail:    "alice@example.com",
+		Amount:           entity.NewMoney(250),
+		Currency:         entity.DefaultCurrency,
+		BorrowerIDNumber: "3171234567890123",
+		PrincipalAmount:  entity.NewMoney(1000),
+		LoanCurrency:     entity.DefaultCurrency,
+		ROI:              10,
+		FundedPercentage: 25,
+		InvestedAt:       time.Date(2024, 6, 1, 9, 30, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	message := messages[0]
+	if message.from != "noreply@example.com" || len(message.recipients) != 1 || message.recipients[0] != "alice@example.com" {
+		t.Errorf("got envelope from %q to %v", message.from, message.recipients)
+	}
+
+	header, parts := readMessageParts(t, message.data)
+	if header.Get("To") != "alice@example.com" || header.Get("From") != `"Loan Engine" <noreply@example.com>` {
+		t.Errorf("got From %q and To %q", header.Get("From"), header.Get("To"))
+	}
+	if header.Get("Subject") == "" {
+		t.Error("message has no subject")
+	}
+	// Both parts come from the shared templates
+	for _, contentType := range []string{"text/plain", "text/html"} {
+		if !strings.Contains(parts[contentType], "250") {
+			t.Errorf("%s part does not mention the amount: %q", contentType, parts[contentType])
+		}
+	}
+}
+
+func TestSMTPSendLoanFullyInvestedNotificationHidesInvestors(t *testing.T) {
+	server := newMockSMTPServer(t)
+	s := newMockSMTPService(t, server)
+
+	err := s.SendLoanFullyInvestedNotification(context.Background(), service.SendLoanNotificationRequest{
+		LoanID:              1,
+		InvestorEmails:      []string{"alice@example.com", "bob@example.com", "mallory@reject.example.com"},
+		BorrowerIDNumber:    "3171234567890123",
+		PrincipalAmount:     entity.NewMoney(1000),
+		AgreementLetterLink: "https://example.com/agreements/1.pdf",
+	})
+	if err == nil || !strings.Contains(err.Error(), "smtp server rejected 1 recipients: mallory@reject.example.com") {
+		t.Errorf("got error %v, want the rejected recipient reported", err)
+	}
+
+	// The accepted investors still get the message, addressed in the envelope only
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	if got := strings.Join(messages[0].recipients, ","); got != "alice@example.com,bob@example.com" {
+		t.Errorf("got recipients %q, want alice and bob", got)
+	}
+	header, _ := readMessageParts(t, messages[0].data)
+	if to := header.Get("To"); to != "undisclosed-recipients:;" {
+		t.Errorf("got To %q, want undisclosed recipients", to)
+	}
+}
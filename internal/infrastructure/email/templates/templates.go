@@ -0,0 +1,169 @@
+package templates
+
+import (
+	"amartha-andreas/internal/domain/service"
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// dateLayout formats the dates shown in emails
+const dateLayout = "2006-01-02 15:04:05"
+
+// DefaultLanguage is used when a request has no language, or the email has no
+// translation in it
+const DefaultLanguage = "en"
+
+// Every email has a subject template <name>.subject.txt, an HTML template
+// <name>.html, which escapes the loan data it renders, and a plain text template
+// <name>.txt, in a directory per language
+//
+//go:embed en id
+var files embed.FS
+
+// templateSet holds the emails translated into one language
+type templateSet struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// templateSets holds the translations by language
+var templateSets = map[string]templateSet{
+	"en": mustParseTemplateSet("en"),
+	"id": mustParseTemplateSet("id"),
+}
+
+// mustParseTemplateSet parses the templates in the directory of language
+func mustParseTemplateSet(language string) templateSet {
+	return templateSet{
+		html: htmltemplate.Must(htmltemplate.ParseFS(files, language+"/*.html")),
+		text: texttemplate.Must(texttemplate.ParseFS(files, language+"/*.txt")),
+	}
+}
+
+// Message is the rendered content of a notification email
+type Message struct {
+	Subject   string
+	PlainText string
+	HTML      string
+}
+
+// loanFullyInvestedData is the data of the loan_fully_invested templates
+type loanFullyInvestedData struct {
+	LoanID              int64
+	BorrowerIDNumber    string
+	PrincipalAmount     float64
+	AgreementLetterLink string
+}
+
+// loanApprovedData is the data of the loan_approved templates
+type loanApprovedData struct {
+	LoanID           int64
+	BorrowerIDNumber string
+	EmployeeID       string
+	ApprovalDate     string
+}
+
+// loanDisbursedData is the data of the loan_disbursed templates
+type loanDisbursedData struct {
+	LoanID           int64
+	BorrowerIDNumber string
+	PrincipalAmount  float64
+	DisbursementDate string
+	AgreementLink    string
+}
+
+// investmentConfirmationData is the data of the investment_confirmation templates
+type investmentConfirmationData struct {
+	InvestmentID     int64
+	LoanID           int64
+	Amount           float64
+	Currency         string
+	BorrowerIDNumber string
+	PrincipalAmount  float64
+	LoanCurrency     string
+	ROI              float64
+	FundedPercentage float64
+	InvestedAt       string
+}
+
+// LoanFullyInvested renders the notification sent to investors when a loan is
+// fully invested, in the language of the request
+func LoanFullyInvested(request service.SendLoanNotificationRequest) (Message, error) {
+	return render(request.Language, "loan_fully_invested", loanFullyInvestedData{
+		LoanID:              request.LoanID,
+		BorrowerIDNumber:    request.BorrowerIDNumber,
+		PrincipalAmount:     request.PrincipalAmount.Float64(),
+		AgreementLetterLink: request.AgreementLetterLink,
+	})
+}
+
+// LoanApproved renders the notification sent to the operations team when a loan is approved
+func LoanApproved(request service.SendLoanApprovedNotificationRequest) (Message, error) {
+	return render(DefaultLanguage, "loan_approved", loanApprovedData{
+		LoanID:           request.LoanID,
+		BorrowerIDNumber: request.BorrowerIDNumber,
+		EmployeeID:       request.EmployeeID,
+		ApprovalDate:     request.ApprovalDate.Format(dateLayout),
+	})
+}
+
+// LoanDisbursed renders the notification sent to the borrower when their loan is disbursed.
+// fileBaseURL is the base URL of stored files, used to link agreements stored as bare filenames.
+func LoanDisbursed(request service.SendLoanDisbursedNotificationRequest, fileBaseURL string) (Message, error) {
+	// Older loans only stored the bare filename under the base file URL
+	agreementLink := request.SignedAgreementDoc
+	if !strings.HasPrefix(agreementLink, "http://") && !strings.HasPrefix(agreementLink, "https://") {
+		agreementLink = fmt.Sprintf("%s/signed_agreements/%s", strings.TrimSuffix(fileBaseURL, "/"), agreementLink)
+	}
+
+	return render(DefaultLanguage, "loan_disbursed", loanDisbursedData{
+		LoanID:           request.LoanID,
+		BorrowerIDNumber: request.BorrowerIDNumber,
+		PrincipalAmount:  request.PrincipalAmount.Float64(),
+		DisbursementDate: request.DisbursementDate.Format(dateLayout),
+		AgreementLink:    agreementLink,
+	})
+}
+
+// InvestmentConfirmation renders the confirmation sent to an investor once their
+// investment is saved, in the language of the request
+func InvestmentConfirmation(request service.SendInvestmentConfirmationRequest) (Message, error) {
+	return render(request.Language, "investment_confirmation", investmentConfirmationData{
+		InvestmentID:     request.InvestmentID,
+		LoanID:           request.LoanID,
+		Amount:           request.Amount.Float64(),
+		Currency:         request.Currency,
+		BorrowerIDNumber: request.BorrowerIDNumber,
+		PrincipalAmount:  request.PrincipalAmount.Float64(),
+		LoanCurrency:     request.LoanCurrency,
+		ROI:              request.ROI,
+		FundedPercentage: request.FundedPercentage,
+		InvestedAt:       request.InvestedAt.Format(dateLayout),
+	})
+}
+
+// render executes the templates of the email called name in language, falling
+// back to DefaultLanguage when the email isn't translated into it
+func render(language, name string, data any) (Message, error) {
+	set, ok := templateSets[language]
+	if !ok || set.html.Lookup(name+".html") == nil {
+		set = templateSets[DefaultLanguage]
+	}
+
+	var subject, html, plainText bytes.Buffer
+	if err := set.text.ExecuteTemplate(&subject, name+".subject.txt", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s email: %w", name, err)
+	}
+	if err := set.html.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s email: %w", name, err)
+	}
+	if err := set.text.ExecuteTemplate(&plainText, name+".txt", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s email: %w", name, err)
+	}
+
+	return Message{Subject: strings.TrimSpace(subject.String()), PlainText: plainText.String(), HTML: html.String()}, nil
+}
@@ -0,0 +1,107 @@
+package templates
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"amartha-andreas/internal/domain/service"
+	"strings"
+	"testing"
+	"time"
+)
+
+// injected is loan data a user could have entered, which must not become markup
+const injected = `<script>alert("x")</script>`
+
+func TestHTMLTemplatesEscapeUserFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		render func() (Message, error)
+	}{
+		{"fully invested borrower ID", func() (Message, error) {
+			return LoanFullyInvested(service.SendLoanNotificationRequest{
+				LoanID:              1,
+				BorrowerIDNumber:    injected,
+				PrincipalAmount:     entity.NewMoney(1000),
+				AgreementLetterLink: "https://example.com/agreements/1.pdf",
+			})
+		}},
+		{"approval employee ID", func() (Message, error) {
+			return LoanApproved(service.SendLoanApprovedNotificationRequest{
+				LoanID:           1,
+				BorrowerIDNumber: "3171234567890123",
+				EmployeeID:       injected,
+				ApprovalDate:     time.Date(2024, 6, 1, 9, 30, 0, 0, time.UTC),
+			})
+		}},
+		{"disbursed borrower ID", func() (Message, error) {
+			return LoanDisbursed(service.SendLoanDisbursedNotificationRequest{
+				LoanID:             1,
+				BorrowerIDNumber:   injected,
+				PrincipalAmount:    entity.NewMoney(1000),
+				SignedAgreementDoc: "agreement.pdf",
+				DisbursementDate:   time.Date(2024, 6, 1, 9, 30, 0, 0, time.UTC),
+			}, "https://files.example.com")
+		}},
+		{"confirmation in Indonesian", func() (Message, error) {
+			return InvestmentConfirmation(service.SendInvestmentConfirmationRequest{
+				InvestmentID:     1,
+				LoanID:           1,
+				InvestorEmail:    "alice@example.com",
+				Amount:           entity.NewMoney(100),
+				BorrowerIDNumber: injected,
+				PrincipalAmount:  entity.NewMoney(1000),
+				Language:         "id",
+			})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := tt.render()
+			if err != nil {
+				t.Fatalf("failed to render: %v", err)
+			}
+
+			if strings.Contains(message.HTML, "<script>") {
+				t.Errorf("HTML contains the unescaped field: %s", message.HTML)
+			}
+			if !strings.Contains(message.HTML, "&lt;script&gt;") {
+				t.Errorf("HTML does not contain the escaped field: %s", message.HTML)
+			}
+			// The plain text part is not markup, so the field is shown as entered
+			if !strings.Contains(message.PlainText, injected) {
+				t.Errorf("plain text does not contain the field as entered: %s", message.PlainText)
+			}
+		})
+	}
+}
+
+func TestLoanFullyInvestedSubjectPerLanguage(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"en", "Loan #42 is Fully Invested - Agreement Letter Available"},
+		{"id", "Pinjaman #42 Telah Terdanai Penuh - Surat Perjanjian Tersedia"},
+		{"", "Loan #42 is Fully Invested - Agreement Letter Available"},
+		// Languages without a translation fall back to English
+		{"fr", "Loan #42 is Fully Invested - Agreement Letter Available"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			message, err := LoanFullyInvested(service.SendLoanNotificationRequest{
+				LoanID:              42,
+				BorrowerIDNumber:    "3171234567890123",
+				PrincipalAmount:     entity.NewMoney(1000),
+				AgreementLetterLink: "https://example.com/agreements/1.pdf",
+				Language:            tt.language,
+			})
+			if err != nil {
+				t.Fatalf("failed to render: %v", err)
+			}
+			if message.Subject != tt.want {
+				t.Errorf("got subject %q, want %q", message.Subject, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package expiry
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultSweepInterval is how often overdue loans are expired when no interval is configured
+const DefaultSweepInterval = time.Minute
+
+// ExpireFunc expires the loans past their funding deadline, returning how many were expired
+type ExpireFunc func(ctx context.Context) (int, error)
+
+// Sweeper periodically expires the approved loans that weren't fully funded
+// before their funding deadline
+type Sweeper struct {
+	expire   ExpireFunc
+	interval time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewSweeper creates a sweeper that calls expire every interval once Start is called
+func NewSweeper(expire ExpireFunc, interval time.Duration) *Sweeper {
+	if interval <= 0 {
+		interval = DefaultSweepInterval
+	}
+
+	return &Sweeper{
+		expire:   expire,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start sweeps right away, then every interval until Shutdown
+func (s *Sweeper) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.sweep()
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops the sweeper and waits for a running sweep to finish
+func (s *Sweeper) Shutdown() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// sweep expires the overdue loans, bounded by the sweep interval
+func (s *Sweeper) sweep() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.interval)
+	defer cancel()
+
+	expired, err := s.expire(ctx)
+	if expired > 0 {
+		log.Printf("Expired %d loans past their funding deadline", expired)
+	}
+	if err != nil {
+		log.Printf("Failed to expire loans past their funding deadline: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package fx
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"amartha-andreas/internal/domain/service"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Pair is a currency pair, such as USD to IDR
+type Pair struct {
+	From string
+	To   string
+}
+
+// staticConverter implements service.FXConverter with fixed rates
+type staticConverter struct {
+	rates map[Pair]float64
+}
+
+// NewStaticConverter creates a converter using fixed rates, given in units of
+// the To currency per unit of the From currency. A pair is also converted in
+// the opposite direction at the inverse rate unless that direction has its own.
+func NewStaticConverter(rates map[Pair]float64) service.FXConverter {
+	return &staticConverter{rates: rates}
+}
+
+// Convert converts amount at the configured rate of the pair
+func (c *staticConverter) Convert(ctx context.Context, amount entity.Money, from, to string) (entity.Money, error) {
+	if from == to {
+		return amount, nil
+	}
+	if rate, ok := c.rates[Pair{From: from, To: to}]; ok {
+		return amount.Convert(rate), nil
+	}
+	if rate, ok := c.rates[Pair{From: to, To: from}]; ok {
+		return amount.Convert(1 / rate), nil
+	}
+	return 0, entity.NewDomainError(entity.ErrValidation, fmt.Sprintf("no exchange rate from %s to %s", from, to))
+}
+
+// ParseRates parses comma-separated rates such as "USD:IDR=16250,SGD:IDR=12100"
+func ParseRates(value string) (map[Pair]float64, error) {
+	rates := make(map[Pair]float64)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pair, rateStr, ok := strings.Cut(entry, "=")
+		from, to, hasTo := strings.Cut(pair, ":")
+		if !ok || !hasTo {
+			return nil, fmt.Errorf("rate %q must look like USD:IDR=16250", entry)
+		}
+		from, to = strings.ToUpper(strings.TrimSpace(from)), strings.ToUpper(strings.TrimSpace(to))
+		if err := entity.ValidateCurrency(from); err != nil {
+			return nil, fmt.Errorf("rate %q: %w", entry, err)
+		}
+		if err := entity.ValidateCurrency(to); err != nil {
+			return nil, fmt.Errorf("rate %q: %w", entry, err)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateStr), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("rate %q must be a positive number", entry)
+		}
+		rates[Pair{From: from, To: to}] = rate
+	}
+	return rates, nil
+}
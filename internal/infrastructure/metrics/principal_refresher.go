@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultRefreshInterval is how often the outstanding principal is recalculated when no interval is configured
+const DefaultRefreshInterval = time.Minute
+
+// OutstandingPrincipalFunc calculates the current outstanding principal
+type OutstandingPrincipalFunc func(ctx context.Context) (float64, error)
+
+// PrincipalRefresher periodically recalculates the outstanding principal and
+// publishes it on the gauge, so scrapes don't query the database
+type PrincipalRefresher struct {
+	metrics  *Prometheus
+	source   OutstandingPrincipalFunc
+	interval time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewPrincipalRefresher creates a refresher that calls source every interval once Start is called
+func NewPrincipalRefresher(metrics *Prometheus, source OutstandingPrincipalFunc, interval time.Duration) *PrincipalRefresher {
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+
+	return &PrincipalRefresher{
+		metrics:  metrics,
+		source:   source,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start refreshes the gauge right away, then every interval until Shutdown
+func (r *PrincipalRefresher) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			r.refresh()
+			select {
+			case <-ticker.C:
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops the refresher and waits for a running refresh to finish
+func (r *PrincipalRefresher) Shutdown() {
+	close(r.stop)
+	r.wg.Wait()
+}
+
+// refresh recalculates the outstanding principal, bounded by the refresh interval
+func (r *PrincipalRefresher) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+	defer cancel()
+
+	principal, err := r.source(ctx)
+	if err != nil {
+		log.Printf("Failed to refresh outstanding principal metric: %v", err)
+		return
+	}
+	r.metrics.SetOutstandingPrincipal(principal)
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPrincipalRefresherPublishesOutstandingPrincipal(t *testing.T) {
+	metrics := NewPrometheus(prometheus.NewRegistry())
+	refreshed := make(chan struct{}, 1)
+	refresher := NewPrincipalRefresher(metrics, func(ctx context.Context) (float64, error) {
+		select {
+		case refreshed <- struct{}{}:
+		default:
+		}
+		return 12345.67, nil
+	}, time.Hour)
+
+	// The first refresh runs right away rather than after the interval
+	refresher.Start()
+	select {
+	case <-refreshed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the outstanding principal was not refreshed on start")
+	}
+	refresher.Shutdown()
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	if want := "loan_engine_outstanding_principal 12345.67"; !strings.Contains(string(body), want) {
+		t.Errorf("metrics do not contain %q", want)
+	}
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric exported by the loan engine
+const namespace = "loan_engine"
+
+// Prometheus implements service.LoanMetrics and records HTTP request latency,
+// exposing everything on the registry it was created with
+type Prometheus struct {
+	registry *prometheus.Registry
+
+	loansCreated         prometheus.Counter
+	stateTransitions     *prometheus.CounterVec
+	requestDuration      *prometheus.HistogramVec
+	outstandingPrincipal prometheus.Gauge
+}
+
+// NewPrometheus creates the loan engine metrics and registers them, along with
+// the Go runtime and process collectors, on registry
+func NewPrometheus(registry *prometheus.Registry) *Prometheus {
+	p := &Prometheus{
+		registry: registry,
+		loansCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "loans_created_total",
+			Help:      "Number of loans created.",
+		}),
+		stateTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "loan_state_transitions_total",
+			Help:      "Number of loan state changes, e.g. to_state=\"approved\", \"invested\" or \"disbursed\".",
+		}, []string{"from_state", "to_state"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests per route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		outstandingPrincipal: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "outstanding_principal",
+			Help:      "Total principal of disbursed loans, refreshed periodically.",
+		}),
+	}
+
+	registry.MustRegister(
+		p.loansCreated,
+		p.stateTransitions,
+		p.requestDuration,
+		p.outstandingPrincipal,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return p
+}
+
+// LoanCreated counts a newly created loan
+func (p *Prometheus) LoanCreated() {
+	p.loansCreated.Inc()
+}
+
+// LoanStateChanged counts a loan moving from fromState to toState
+func (p *Prometheus) LoanStateChanged(fromState, toState string) {
+	p.stateTransitions.WithLabelValues(fromState, toState).Inc()
+}
+
+// ObserveRequest records how long a request to route took
+func (p *Prometheus) ObserveRequest(method, route string, status int, duration time.Duration) {
+	p.requestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// SetOutstandingPrincipal updates the outstanding principal gauge
+func (p *Prometheus) SetOutstandingPrincipal(principal float64) {
+	p.outstandingPrincipal.Set(principal)
+}
+
+// Handler serves the registered metrics in the Prometheus exposition format
+func (p *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{})
+}
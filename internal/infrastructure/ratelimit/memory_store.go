@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that refilled completely are dropped
+const sweepInterval = time.Minute
+
+// bucket is the token bucket of one key
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryStore is an in-process token bucket store: every key may make burst
+// requests at once, refilled at ratePerSecond. Budgets are not shared between
+// instances of the API.
+type MemoryStore struct {
+	ratePerSecond float64
+	burst         float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates a store granting ratePerSecond requests per key, with bursts of up to burst
+func NewMemoryStore(ratePerSecond float64, burst int) *MemoryStore {
+	return &MemoryStore{
+		ratePerSecond: ratePerSecond,
+		burst:         float64(burst),
+		buckets:       make(map[string]*bucket),
+		lastSweep:     time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket of key, or reports how long until the next one
+func (s *MemoryStore) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: s.burst, updated: now}
+		s.buckets[key] = b
+	}
+
+	// Refill for the time since the last request, up to the burst
+	b.tokens = min(s.burst, b.tokens+now.Sub(b.updated).Seconds()*s.ratePerSecond)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+
+	wait := (1 - b.tokens) / s.ratePerSecond
+	return false, time.Duration(wait * float64(time.Second)), nil
+}
+
+// sweep drops the buckets that have refilled completely, which behave like new
+// ones, so clients that went away don't accumulate
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*s.ratePerSecond >= s.burst {
+			delete(s.buckets, key)
+		}
+	}
+}
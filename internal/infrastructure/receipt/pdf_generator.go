@@ -0,0 +1,116 @@
+package receipt
+
+import (
+	"amartha-andreas/internal/domain/service"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Page layout in PDF points, on an A4 page
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	marginLeft   = 72
+	valueColumn  = 230
+	titleTop     = 770
+	firstRowTop  = 720
+	rowSpacing   = 24
+	titleSize    = 20
+	bodySize     = 12
+	regularFont  = "F1"
+	boldFont     = "F2"
+	receiptTitle = "Investment Receipt"
+)
+
+// pdfGenerator implements service.ReceiptGenerator, writing single-page PDFs
+// with the standard Helvetica fonts so no font files need to be embedded
+type pdfGenerator struct{}
+
+// NewPDFGenerator creates a receipt generator producing PDF documents
+func NewPDFGenerator() service.ReceiptGenerator {
+	return &pdfGenerator{}
+}
+
+// GenerateInvestmentReceipt renders the receipt of one investment as a PDF
+func (g *pdfGenerator) GenerateInvestmentReceipt(ctx context.Context, receipt service.InvestmentReceipt) ([]byte, error) {
+	rows := [][2]string{
+		{"Receipt number", fmt.Sprintf("%d", receipt.InvestmentID)},
+		{"Loan ID", fmt.Sprintf("%d", receipt.LoanID)},
+		{"Investor", receipt.InvestorEmail},
+		{"Amount invested", fmt.Sprintf("%s %s", receipt.OriginalAmount, receipt.Currency)},
+	}
+	if receipt.Currency != receipt.LoanCurrency {
+		rows = append(rows, [2]string{"Amount credited", fmt.Sprintf("%s %s", receipt.Amount, receipt.LoanCurrency)})
+	}
+	rows = append(rows,
+		[2]string{"ROI", fmt.Sprintf("%.2f%%", receipt.ROI)},
+		[2]string{"Expected return", fmt.Sprintf("%s %s", receipt.ExpectedReturn, receipt.LoanCurrency)},
+		[2]string{"Investment date", receipt.InvestedAt.UTC().Format("2006-01-02 15:04:05 UTC")},
+	)
+
+	var content strings.Builder
+	writeText(&content, boldFont, titleSize, marginLeft, titleTop, receiptTitle)
+	for i, row := range rows {
+		y := firstRowTop - i*rowSpacing
+		writeText(&content, boldFont, bodySize, marginLeft, y, row[0])
+		writeText(&content, regularFont, bodySize, valueColumn, y, row[1])
+	}
+
+	return buildPDF(content.String()), nil
+}
+
+// writeText appends a content stream operation drawing text at x, y
+func writeText(content *strings.Builder, font string, size, x, y int, text string) {
+	fmt.Fprintf(content, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", font, size, x, y, escapeText(text))
+}
+
+// escapeText escapes a PDF string literal; characters outside printable ASCII,
+// which the standard fonts cannot show without an encoding, become '?'
+func escapeText(text string) string {
+	var escaped strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			escaped.WriteByte('\\')
+			escaped.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			escaped.WriteByte('?')
+		default:
+			escaped.WriteRune(r)
+		}
+	}
+	return escaped.String()
+}
+
+// buildPDF wraps a page content stream in a complete PDF document
+func buildPDF(content string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Contents 4 0 R /Resources << /Font << /%s 5 0 R /%s 6 0 R >> >> >>",
+			pageWidth, pageHeight, regularFont, boldFont),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	// Cross-reference table, each entry exactly 20 bytes
+	xrefOffset := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xrefOffset)
+
+	return buf.Bytes()
+}
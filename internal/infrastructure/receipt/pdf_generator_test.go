@@ -0,0 +1,96 @@
+package receipt
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"amartha-andreas/internal/domain/service"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testReceipt() service.InvestmentReceipt {
+	return service.InvestmentReceipt{
+		InvestmentID:   42,
+		LoanID:         7,
+		InvestorEmail:  "alice@example.com",
+		Amount:         entity.NewMoney(4_000_000),
+		LoanCurrency:   "IDR",
+		OriginalAmount: entity.NewMoney(250),
+		Currency:       "USD",
+		ROI:            10,
+		ExpectedReturn: entity.NewMoney(400_000),
+		InvestedAt:     time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestGenerateInvestmentReceiptShowsTheInvestment(t *testing.T) {
+	pdf, err := NewPDFGenerator().GenerateInvestmentReceipt(context.Background(), testReceipt())
+	if err != nil {
+		t.Fatalf("failed to generate receipt: %v", err)
+	}
+
+	for _, want := range []string{
+		"(Investment Receipt)",
+		"(42)",
+		"(alice@example.com)",
+		"(250.00 USD)",
+		"(4000000.00 IDR)", // Credited in the loan currency
+		"(10.00%)",
+		"(400000.00 IDR)",
+		"(2024-03-01 09:30:00 UTC)",
+	} {
+		if !bytes.Contains(pdf, []byte(want)) {
+			t.Errorf("receipt does not show %s", want)
+		}
+	}
+}
+
+func TestGenerateInvestmentReceiptWritesAValidCrossReference(t *testing.T) {
+	pdf, err := NewPDFGenerator().GenerateInvestmentReceipt(context.Background(), testReceipt())
+	if err != nil {
+		t.Fatalf("failed to generate receipt: %v", err)
+	}
+
+	// startxref points at the xref table, whose entries point at the objects
+	match := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(pdf)
+	if match == nil {
+		t.Fatal("receipt does not end with the startxref trailer")
+	}
+	xrefOffset, _ := strconv.Atoi(string(match[1]))
+	if !bytes.HasPrefix(pdf[xrefOffset:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xrefOffset)
+	}
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(pdf[xrefOffset:], -1)
+	if len(entries) != 6 {
+		t.Fatalf("got %d objects in the xref table, want 6", len(entries))
+	}
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !bytes.HasPrefix(pdf[offset:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q, want object %d", i+1, pdf[offset:offset+10], i+1)
+		}
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain", "plain"},
+		{`a(b)c\d`, `a\(b\)c\\d`},
+		{"Rp 1.000 – ok", "Rp 1.000 ? ok"},
+		{"line\nbreak", "line?break"},
+	}
+
+	for _, tt := range tests {
+		if got := escapeText(tt.text); got != tt.want {
+			t.Errorf("escapeText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
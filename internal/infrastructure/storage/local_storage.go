@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"amartha-andreas/internal/domain/service"
+	"context"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage implements service.FileStorage on the local disk
+type LocalStorage struct {
+	baseDir string
+	baseURL string
+}
+
+// NewLocalStorage creates a storage writing under baseDir, whose files are served at baseURL
+func NewLocalStorage(baseDir, baseURL string) service.FileStorage {
+	return &LocalStorage{
+		baseDir: baseDir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// Save writes the content to baseDir/key and returns its URL under baseURL
+func (s *LocalStorage) Save(ctx context.Context, key string, reader io.Reader, contentType string) (string, error) {
+	filePath := filepath.Join(s.baseDir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return "", err
+	}
+
+	// Create the file
+	dst, err := os.Create(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+
+	// Copy file content
+	if _, err := io.Copy(dst, reader); err != nil {
+		return "", err
+	}
+
+	return s.baseURL + "/" + key, nil
+}
+
+// Delete removes the file behind a URL under baseURL, ignoring files that are already gone
+func (s *LocalStorage) Delete(ctx context.Context, fileURL string) error {
+	filePath, ok := s.filePath(fileURL)
+	if !ok {
+		return nil
+	}
+
+	err := os.Remove(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Open opens the file behind a URL under baseURL, typed by its extension
+func (s *LocalStorage) Open(ctx context.Context, fileURL string) (*service.StoredFile, error) {
+	filePath, ok := s.filePath(fileURL)
+	if !ok {
+		return nil, entity.ErrFileNotFound
+	}
+
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, entity.ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(filePath))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return &service.StoredFile{Body: file, ContentType: contentType, Size: info.Size()}, nil
+}
+
+// filePath maps a URL under baseURL to its path on disk, refusing keys that
+// would escape baseDir
+func (s *LocalStorage) filePath(fileURL string) (string, bool) {
+	key, ok := strings.CutPrefix(fileURL, s.baseURL+"/")
+	if !ok || !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", false
+	}
+	return filepath.Join(s.baseDir, filepath.FromSlash(key)), true
+}
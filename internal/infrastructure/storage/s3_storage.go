@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"amartha-andreas/internal/domain/service"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config holds configuration for S3-compatible object storage (AWS S3, MinIO, ...)
+type S3Config struct {
+	Endpoint  string // Host and optional port, e.g. s3.amazonaws.com or minio:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+
+	// PublicURL is the base URL objects are served from; defaults to <endpoint>/<bucket>
+	PublicURL string
+}
+
+// S3Storage implements service.FileStorage on an S3-compatible bucket
+type S3Storage struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+}
+
+// NewS3Storage creates a storage uploading to the configured bucket
+func NewS3Storage(config S3Config) (service.FileStorage, error) {
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.UseSSL,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	publicURL := config.PublicURL
+	if publicURL == "" {
+		scheme := "http"
+		if config.UseSSL {
+			scheme = "https"
+		}
+		publicURL = fmt.Sprintf("%s://%s/%s", scheme, config.Endpoint, config.Bucket)
+	}
+
+	return &S3Storage{
+		client:    client,
+		bucket:    config.Bucket,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}, nil
+}
+
+// Save uploads the content as object key and returns its public URL
+func (s *S3Storage) Save(ctx context.Context, key string, reader io.Reader, contentType string) (string, error) {
+	_, err := s.client.PutObject(ctx, s.bucket, key, reader, objectSize(reader), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s to bucket %s: %w", key, s.bucket, err)
+	}
+
+	return s.publicURL + "/" + key, nil
+}
+
+// Delete removes the object behind a URL under the public URL
+func (s *S3Storage) Delete(ctx context.Context, fileURL string) error {
+	key, ok := strings.CutPrefix(fileURL, s.publicURL+"/")
+	if !ok || key == "" {
+		return nil
+	}
+
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete %s from bucket %s: %w", key, s.bucket, err)
+	}
+
+	return nil
+}
+
+// Open streams the object behind a URL under the public URL
+func (s *S3Storage) Open(ctx context.Context, fileURL string) (*service.StoredFile, error) {
+	key, ok := strings.CutPrefix(fileURL, s.publicURL+"/")
+	if !ok || key == "" {
+		return nil, entity.ErrFileNotFound
+	}
+
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s from bucket %s: %w", key, s.bucket, err)
+	}
+	// GetObject is lazy, Stat makes the request and reports a missing object
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, entity.ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to get %s from bucket %s: %w", key, s.bucket, err)
+	}
+
+	return &service.StoredFile{Body: object, ContentType: info.ContentType, Size: info.Size}, nil
+}
+
+// objectSize returns the bytes left in a seekable reader, or -1 so the client
+// falls back to a streaming multipart upload when the size is unknown
+func objectSize(reader io.Reader) int64 {
+	seeker, ok := reader.(io.Seeker)
+	if !ok {
+		return -1
+	}
+
+	current, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return -1
+	}
+	if _, err := seeker.Seek(current, io.SeekStart); err != nil {
+		return -1
+	}
+
+	return end - current
+}
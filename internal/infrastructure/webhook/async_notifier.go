@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"amartha-andreas/internal/domain/service"
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+// ErrQueueFull is returned when an event cannot be queued without blocking
+var ErrQueueFull = errors.New("webhook queue is full")
+
+// ErrQueueClosed is returned when an event is queued after shutdown started
+var ErrQueueClosed = errors.New("webhook queue is closed")
+
+// webhookJob is a queued event, delivered through the wrapped notifier
+type webhookJob struct {
+	ctx   context.Context
+	event service.LoanStateChangedEvent
+}
+
+// AsyncNotifier implements service.WebhookNotifier by queueing events for a
+// single worker, so callers return without waiting on the receiver and events
+// are delivered in the order the loans changed state
+type AsyncNotifier struct {
+	next service.WebhookNotifier
+	jobs chan webhookJob
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewAsyncNotifier wraps next with a queue of queueSize events, delivered once Start is called
+func NewAsyncNotifier(next service.WebhookNotifier, queueSize int) *AsyncNotifier {
+	return &AsyncNotifier{
+		next: next,
+		jobs: make(chan webhookJob, queueSize),
+	}
+}
+
+// Start launches the delivery worker
+func (n *AsyncNotifier) Start() {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		for job := range n.jobs {
+			if err := n.next.NotifyLoanStateChanged(job.ctx, job.event); err != nil {
+				log.Printf("Failed to deliver webhook for loan %d (%s -> %s): %v", job.event.LoanID, job.event.FromState, job.event.ToState, err)
+			}
+		}
+	}()
+}
+
+// Shutdown stops accepting events and waits until the queued ones are
+// delivered or ctx expires
+func (n *AsyncNotifier) Shutdown(ctx context.Context) error {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.jobs)
+	}
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NotifyLoanStateChanged queues the event without blocking the caller
+func (n *AsyncNotifier) NotifyLoanStateChanged(ctx context.Context, event service.LoanStateChangedEvent) error {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if n.closed {
+		return ErrQueueClosed
+	}
+
+	// Detach from the request context, which is cancelled once the response is written
+	job := webhookJob{ctx: context.WithoutCancel(ctx), event: event}
+	select {
+	case n.jobs <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
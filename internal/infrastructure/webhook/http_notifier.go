@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"amartha-andreas/internal/domain/service"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body, prefixed with "sha256="
+const SignatureHeader = "X-Webhook-Signature"
+
+// Defaults used when HTTPConfig leaves them unset
+const (
+	defaultMaxAttempts    = 3
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultTimeout        = 10 * time.Second
+)
+
+// HTTPConfig holds the configuration for the HTTP webhook notifier
+type HTTPConfig struct {
+	URL    string
+	Secret string // Key of the HMAC signature, shared with the receiver
+
+	// MaxAttempts bounds how many times a delivery is tried on 429/5xx responses and network errors
+	MaxAttempts int
+	// RetryBaseDelay is the wait before the first retry, doubled for every further attempt
+	RetryBaseDelay time.Duration
+	// Timeout bounds a single delivery attempt
+	Timeout time.Duration
+}
+
+// httpNotifier implements service.WebhookNotifier by POSTing signed JSON payloads
+type httpNotifier struct {
+	client *http.Client
+	config HTTPConfig
+}
+
+// NewHTTPNotifier creates a webhook notifier posting to the configured URL
+func NewHTTPNotifier(config HTTPConfig) service.WebhookNotifier {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.RetryBaseDelay <= 0 {
+		config.RetryBaseDelay = defaultRetryBaseDelay
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &httpNotifier{
+		client: &http.Client{Timeout: config.Timeout},
+		config: config,
+	}
+}
+
+// NotifyLoanStateChanged posts the event, retrying with exponential backoff
+// while the receiver answers 429/5xx or the request fails at the network level
+func (n *httpNotifier) NotifyLoanStateChanged(ctx context.Context, event service.LoanStateChangedEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	signature := Sign(n.config.Secret, body)
+
+	delay := n.config.RetryBaseDelay
+	for attempt := 1; ; attempt++ {
+		status, err := n.post(ctx, body, signature)
+		if err == nil && status >= 200 && status < 300 {
+			return nil
+		}
+
+		retryable := status == http.StatusTooManyRequests || status >= 500
+		if err != nil {
+			retryable = ctx.Err() == nil
+			err = fmt.Errorf("webhook delivery failed: %w", err)
+		} else {
+			err = fmt.Errorf("webhook receiver returned status %d", status)
+		}
+		if !retryable || attempt >= n.config.MaxAttempts {
+			return err
+		}
+
+		log.Printf("Webhook attempt %d/%d for loan %d: %v, retrying in %s", attempt, n.config.MaxAttempts, event.LoanID, err, delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// post sends one delivery attempt and returns the response status
+func (n *httpNotifier) post(ctx context.Context, body []byte, signature string) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(SignatureHeader, signature)
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	// Drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, response.Body)
+	return response.StatusCode, nil
+}
+
+// Sign returns the signature header value of body: "sha256=" followed by the
+// hex HMAC-SHA256 of body keyed with secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"amartha-andreas/internal/domain/service"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver is a test server recording the deliveries it gets, answering
+// with the queued statuses and then 204 No Content
+type webhookReceiver struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	bodies     [][]byte
+	signatures []string
+	statuses   []int
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	receiver := &webhookReceiver{statuses: statuses}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		receiver.bodies = append(receiver.bodies, body)
+		receiver.signatures = append(receiver.signatures, r.Header.Get(SignatureHeader))
+		status := http.StatusNoContent
+		if len(receiver.statuses) > 0 {
+			status = receiver.statuses[0]
+			receiver.statuses = receiver.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) deliveries() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.bodies)
+}
+
+func testEvent() service.LoanStateChangedEvent {
+	return service.LoanStateChangedEvent{
+		LoanID:    42,
+		FromState: "approved",
+		ToState:   "invested",
+		At:        time.Date(2024, 6, 1, 9, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestNotifyLoanStateChangedPostsSignedPayload(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	notifier := NewHTTPNotifier(HTTPConfig{URL: receiver.URL, Secret: "webhook-secret"})
+
+	if err := notifier.NotifyLoanStateChanged(context.Background(), testEvent()); err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+
+	if receiver.deliveries() != 1 {
+		t.Fatalf("got %d deliveries, want 1", receiver.deliveries())
+	}
+	body := receiver.bodies[0]
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("failed to decode payload %q: %v", body, err)
+	}
+	want := map[string]interface{}{
+		"loan_id":    float64(42),
+		"from_state": "approved",
+		"to_state":   "invested",
+		"at":         "2024-06-01T09:30:00Z",
+	}
+	if len(payload) != len(want) {
+		t.Errorf("got payload %v, want %v", payload, want)
+	}
+	for key, value := range want {
+		if payload[key] != value {
+			t.Errorf("got %s %v, want %v", key, payload[key], value)
+		}
+	}
+
+	// The receiver verifies the body with the shared secret
+	mac := hmac.New(sha256.New, []byte("webhook-secret"))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); receiver.signatures[0] != want {
+		t.Errorf("got signature %q, want %q", receiver.signatures[0], want)
+	}
+}
+
+func TestNotifyLoanStateChangedRetries(t *testing.T) {
+	tests := []struct {
+		name           string
+		statuses       []int
+		wantErr        bool
+		wantDeliveries int
+	}{
+		{"server errors are retried", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, false, 3},
+		{"gives up after max attempts", []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, true, 3},
+		{"client errors are not retried", []int{http.StatusBadRequest}, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t, tt.statuses...)
+			notifier := NewHTTPNotifier(HTTPConfig{URL: receiver.URL, Secret: "webhook-secret", MaxAttempts: 3, RetryBaseDelay: time.Millisecond})
+
+			err := notifier.NotifyLoanStateChanged(context.Background(), testEvent())
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+			if receiver.deliveries() != tt.wantDeliveries {
+				t.Errorf("got %d deliveries, want %d", receiver.deliveries(), tt.wantDeliveries)
+			}
+		})
+	}
+}
+
+func TestAsyncNotifierDeliversQueuedEventsOnShutdown(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	notifier := NewAsyncNotifier(NewHTTPNotifier(HTTPConfig{URL: receiver.URL, Secret: "webhook-secret"}), 10)
+	notifier.Start()
+
+	// The request context is cancelled once the response is written, which must not stop the delivery
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < 3; i++ {
+		if err := notifier.NotifyLoanStateChanged(ctx, testEvent()); err != nil {
+			t.Fatalf("failed to queue event: %v", err)
+		}
+	}
+	cancel()
+
+	if err := notifier.Shutdown(context.Background()); err != nil {
+		t.Fatalf("failed to shut down: %v", err)
+	}
+	if receiver.deliveries() != 3 {
+		t.Errorf("got %d deliveries, want the 3 queued events", receiver.deliveries())
+	}
+	if err := notifier.NotifyLoanStateChanged(context.Background(), testEvent()); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("got error %v after shutdown, want ErrQueueClosed", err)
+	}
+}
@@ -0,0 +1,19 @@
+package webhook
+
+import (
+	"amartha-andreas/internal/domain/service"
+	"context"
+)
+
+// noopNotifier implements service.WebhookNotifier when no webhook URL is configured
+type noopNotifier struct{}
+
+// NewNoopNotifier creates a notifier that drops every event
+func NewNoopNotifier() service.WebhookNotifier {
+	return &noopNotifier{}
+}
+
+// NotifyLoanStateChanged does nothing
+func (n *noopNotifier) NotifyLoanStateChanged(ctx context.Context, event service.LoanStateChangedEvent) error {
+	return nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"amartha-andreas/internal/domain/repository"
+	"amartha-andreas/internal/infrastructure/database"
+	"context"
+)
+
+// disbursementRepository implements repository.DisbursementRepository
+type disbursementRepository struct {
+	db *database.Database
+}
+
+// NewDisbursementRepository creates a new disbursement repository
+func NewDisbursementRepository(db *database.Database) repository.DisbursementRepository {
+	return &disbursementRepository{db: db}
+}
+
+// Create saves a new disbursement tranche
+func (r *disbursementRepository) Create(ctx context.Context, disbursement *entity.Disbursement) error {
+	query := `
+		INSERT INTO disbursements (loan_id, amount, signed_agreement_doc, employee_id, disbursement_date, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	// Get the auto-generated ID
+	id, err := r.db.InsertReturningID(ctx, r.db.Conn(ctx), query,
+		disbursement.LoanID, disbursement.Amount, disbursement.SignedAgreementDoc,
+		disbursement.EmployeeID, disbursement.DisbursementDate, disbursement.CreatedAt.UTC())
+	if err != nil {
+		return err
+	}
+	disbursement.ID = id
+
+	return nil
+}
+
+// ListByLoanID retrieves all disbursement tranches of a loan in the order they were paid out
+func (r *disbursementRepository) ListByLoanID(ctx context.Context, loanID int64) ([]*entity.Disbursement, error) {
+	query := `
+		SELECT id, loan_id, amount, signed_agreement_doc, employee_id, disbursement_date, created_at
+		FROM disbursements WHERE loan_id = ? ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, r.db.Rebind(query), loanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var disbursements []*entity.Disbursement
+	for rows.Next() {
+		disbursement := &entity.Disbursement{}
+		err := rows.Scan(&disbursement.ID, &disbursement.LoanID, &disbursement.Amount,
+			&disbursement.SignedAgreementDoc, &disbursement.EmployeeID,
+			&disbursement.DisbursementDate, &disbursement.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		disbursement.CreatedAt = disbursement.CreatedAt.UTC()
+		disbursements = append(disbursements, disbursement)
+	}
+
+	return disbursements, rows.Err()
+}
+
+// GetTotalByLoanID calculates the amount disbursed so far for a loan
+func (r *disbursementRepository) GetTotalByLoanID(ctx context.Context, loanID int64) (entity.Money, error) {
+	query := "SELECT COALESCE(SUM(amount), 0) FROM disbursements WHERE loan_id = ?"
+
+	var total entity.Money
+	err := r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(query), loanID).Scan(&total)
+	return total, err
+}
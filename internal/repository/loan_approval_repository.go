@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"amartha-andreas/internal/domain/repository"
+	"amartha-andreas/internal/infrastructure/database"
+	"context"
+)
+
+// loanApprovalRepository implements repository.LoanApprovalRepository
+type loanApprovalRepository struct {
+	db *database.Database
+}
+
+// NewLoanApprovalRepository creates a new loan approval repository
+func NewLoanApprovalRepository(db *database.Database) repository.LoanApprovalRepository {
+	return &loanApprovalRepository{db: db}
+}
+
+// Create saves a new loan approval
+func (r *loanApprovalRepository) Create(ctx context.Context, approval *entity.LoanApproval) error {
+	query := `
+		INSERT INTO loan_approvals (loan_id, employee_id, proof_picture, approval_date, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	// Get the auto-generated ID
+	id, err := r.db.InsertReturningID(ctx, r.db.Conn(ctx), query,
+		approval.LoanID, approval.EmployeeID, approval.ProofPicture, approval.ApprovalDate.UTC(), approval.CreatedAt.UTC())
+	if err != nil {
+		// The same employee approved the loan concurrently
+		if isUniqueViolation(err) {
+			return entity.NewDomainError(entity.ErrInvalidState, "employee has already approved this loan, another approver is required")
+		}
+		return err
+	}
+	approval.ID = id
+
+	return nil
+}
+
+// ListByLoanID retrieves all approvals of a loan in the order they were given
+func (r *loanApprovalRepository) ListByLoanID(ctx context.Context, loanID int64) ([]*entity.LoanApproval, error) {
+	query := `
+		SELECT id, loan_id, employee_id, proof_picture, approval_date, created_at
+		FROM loan_approvals WHERE loan_id = ? ORDER BY id
+	`
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, r.db.Rebind(query), loanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var approvals []*entity.LoanApproval
+	for rows.Next() {
+		approval := &entity.LoanApproval{}
+		err := rows.Scan(&approval.ID, &approval.LoanID, &approval.EmployeeID,
+			&approval.ProofPicture, &approval.ApprovalDate, &approval.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		approval.ApprovalDate = approval.ApprovalDate.UTC()
+		approval.CreatedAt = approval.CreatedAt.UTC()
+		approvals = append(approvals, approval)
+	}
+
+	return approvals, rows.Err()
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"amartha-andreas/internal/domain/repository"
+	"amartha-andreas/internal/infrastructure/database"
+	"context"
+)
+
+// loanDocumentRepository implements repository.LoanDocumentRepository
+type loanDocumentRepository struct {
+	db *database.Database
+}
+
+// NewLoanDocumentRepository creates a new loan document repository
+func NewLoanDocumentRepository(db *database.Database) repository.LoanDocumentRepository {
+	return &loanDocumentRepository{db: db}
+}
+
+// Create saves a new loan document
+func (r *loanDocumentRepository) Create(ctx context.Context, document *entity.LoanDocument) error {
+	query := `
+		INSERT INTO loan_documents (loan_id, disbursement_id, document, created_at)
+		VALUES (?, ?, ?, ?)
+	`
+
+	// Get the auto-generated ID
+	id, err := r.db.InsertReturningID(ctx, r.db.Conn(ctx), query,
+		document.LoanID, document.DisbursementID, document.Document, document.CreatedAt.UTC())
+	if err != nil {
+		return err
+	}
+	document.ID = id
+
+	return nil
+}
+
+// ListByLoanID retrieves all documents of a loan in the order they were uploaded
+func (r *loanDocumentRepository) ListByLoanID(ctx context.Context, loanID int64) ([]*entity.LoanDocument, error) {
+	query := `
+		SELECT id, loan_id, disbursement_id, document, created_at
+		FROM loan_documents WHERE loan_id = ? ORDER BY id
+	`
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, r.db.Rebind(query), loanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []*entity.LoanDocument
+	for rows.Next() {
+		document := &entity.LoanDocument{}
+		err := rows.Scan(&document.ID, &document.LoanID, &document.DisbursementID,
+			&document.Document, &document.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		document.CreatedAt = document.CreatedAt.UTC()
+		documents = append(documents, document)
+	}
+
+	return documents, rows.Err()
+}
@@ -7,7 +7,11 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
 )
 
 // loanRepository implements repository.LoanRepository
@@ -15,6 +19,50 @@ type loanRepository struct {
 	db *database.Database
 }
 
+// loanColumns lists the loan columns in the order expected by scanLoan. The
+// agreement letter link column is nullable, a missing link reads as empty.
+const loanColumns = `id, borrower_id_number, borrower_email, principal_amount, currency, rate, roi, term_weeks,
+	min_investment, max_investment, max_per_investor, investment_increment, allow_multiple_investments, required_approvals, state, COALESCE(agreement_letter_link, ''),
+	approval_proof_picture, approval_employee_id, approval_date, funding_deadline, approval_effective_at,
+	signed_agreement_doc, disbursement_employee_id, disbursement_date, maturity_date,
+	rejection_reason, rejection_employee_id, rejection_date,
+	cancellation_reason, cancellation_employee_id, cancellation_date,
+	created_at, updated_at, deleted_at, version, ` + loanTotalInvestedColumn
+
+// loanTotalInvestedColumn computes the sum of a loan's investments in a loans query
+const loanTotalInvestedColumn = "(SELECT COALESCE(SUM(amount), 0) FROM investments WHERE investments.loan_id = loans.id)"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanLoan scans a single loan row selected with loanColumns
+func scanLoan(row rowScanner) (*entity.Loan, error) {
+	loan := &entity.Loan{}
+	err := row.Scan(
+		&loan.ID, &loan.BorrowerIDNumber, &loan.BorrowerEmail, &loan.PrincipalAmount, &loan.Currency,
+		&loan.Rate, &loan.ROI, &loan.TermWeeks, &loan.MinInvestment, &loan.MaxInvestment, &loan.MaxPerInvestor, &loan.InvestmentIncrement,
+		&loan.AllowMultipleInvestmentsPerInvestor, &loan.RequiredApprovals, &loan.State, &loan.AgreementLetterLink,
+		&loan.ApprovalProofPicture, &loan.ApprovalEmployeeID, &loan.ApprovalDate, &loan.FundingDeadline, &loan.ApprovalEffectiveAt,
+		&loan.SignedAgreementDoc, &loan.DisbursementEmployeeID, &loan.DisbursementDate, &loan.MaturityDate,
+		&loan.RejectionReason, &loan.RejectionEmployeeID, &loan.RejectionDate,
+		&loan.CancellationReason, &loan.CancellationEmployeeID, &loan.CancellationDate,
+		&loan.CreatedAt, &loan.UpdatedAt, &loan.DeletedAt, &loan.Version, &loan.TotalInvested)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drivers may return timestamps in the local zone, they are always stored as UTC
+	loan.CreatedAt = loan.CreatedAt.UTC()
+	loan.UpdatedAt = loan.UpdatedAt.UTC()
+	if loan.DeletedAt != nil {
+		deletedAt := loan.DeletedAt.UTC()
+		loan.DeletedAt = &deletedAt
+	}
+	return loan, nil
+}
+
 // NewLoanRepository creates a new loan repository
 func NewLoanRepository(db *database.Database) repository.LoanRepository {
 	return &loanRepository{db: db}
@@ -23,49 +71,86 @@ func NewLoanRepository(db *database.Database) repository.LoanRepository {
 // Create saves a new loan
 func (r *loanRepository) Create(ctx context.Context, loan *entity.Loan) error {
 	query := `
-		INSERT INTO loans (borrower_id_number, principal_amount, rate, roi, state, agreement_letter_link, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO loans (borrower_id_number, borrower_email, principal_amount, currency, rate, roi, term_weeks,
+			min_investment, max_investment, max_per_investor, investment_increment, allow_multiple_investments, required_approvals,
+			state, agreement_letter_link, created_at, updated_at, version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	result, err := r.db.DB.ExecContext(ctx, query,
-		loan.BorrowerIDNumber, loan.PrincipalAmount,
-		loan.Rate, loan.ROI, loan.State, loan.AgreementLetterLink,
-		loan.CreatedAt, loan.UpdatedAt)
-
-	if err != nil {
-		return err
-	}
-
 	// Get the auto-generated ID
-	id, err := result.LastInsertId()
+	id, err := r.db.InsertReturningID(ctx, r.db.Conn(ctx), query,
+		loan.BorrowerIDNumber, loan.BorrowerEmail, loan.PrincipalAmount, loan.Currency,
+		loan.Rate, loan.ROI, loan.TermWeeks, loan.MinInvestment, loan.MaxInvestment, loan.MaxPerInvestor,
+		loan.InvestmentIncrement, loan.AllowMultipleInvestmentsPerInvestor, loan.RequiredApprovals, loan.State, loan.AgreementLetterLink,
+		loan.CreatedAt.UTC(), loan.UpdatedAt.UTC(), 1)
 	if err != nil {
 		return err
 	}
 	loan.ID = id
+	loan.Version = 1
 
 	return nil
 }
 
-// GetByID retrieves a loan by its ID
+// GetByID retrieves a loan by its ID, treating soft-deleted loans as not found
 func (r *loanRepository) GetByID(ctx context.Context, id int64) (*entity.Loan, error) {
-	query := `
-		SELECT id, borrower_id_number, principal_amount, rate, roi, state, agreement_letter_link,
-			   approval_proof_picture, approval_employee_id, approval_date,
-			   signed_agreement_doc, disbursement_employee_id, disbursement_date,
-			   created_at, updated_at
-		FROM loans WHERE id = ?
-	`
+	return r.getByID(ctx, "SELECT "+loanColumns+" FROM loans WHERE id = ? AND deleted_at IS NULL", id)
+}
 
-	loan := &entity.Loan{}
-	err := r.db.DB.QueryRowContext(ctx, query, id).Scan(
-		&loan.ID, &loan.BorrowerIDNumber, &loan.PrincipalAmount,
-		&loan.Rate, &loan.ROI, &loan.State, &loan.AgreementLetterLink,
-		&loan.ApprovalProofPicture, &loan.ApprovalEmployeeID, &loan.ApprovalDate,
-		&loan.SignedAgreementDoc, &loan.DisbursementEmployeeID, &loan.DisbursementDate,
-		&loan.CreatedAt, &loan.UpdatedAt)
+// GetByIDForUpdate retrieves a loan by its ID, locking the row on Postgres.
+// SQLite locks the whole database when the transaction begins.
+func (r *loanRepository) GetByIDForUpdate(ctx context.Context, id int64) (*entity.Loan, error) {
+	query := "SELECT " + loanColumns + " FROM loans WHERE id = ? AND deleted_at IS NULL"
+	if r.db.Driver == database.DriverPostgres {
+		query += " FOR UPDATE"
+	}
+	return r.getByID(ctx, query, id)
+}
+
+// GetByIDIncludingDeleted retrieves a loan by its ID even if it was soft-deleted
+func (r *loanRepository) GetByIDIncludingDeleted(ctx context.Context, id int64) (*entity.Loan, error) {
+	return r.getByID(ctx, "SELECT "+loanColumns+" FROM loans WHERE id = ?", id)
+}
+
+// GetByIDs retrieves the live loans with the given IDs in a single query
+func (r *loanRepository) GetByIDs(ctx context.Context, ids []int64) ([]*entity.Loan, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if len(ids) > repository.MaxLoanIDs {
+		return nil, entity.NewDomainError(entity.ErrValidation, fmt.Sprintf("at most %d loan IDs can be fetched at once", repository.MaxLoanIDs))
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	query := "SELECT " + loanColumns + " FROM loans WHERE id IN (" + placeholders + ") AND deleted_at IS NULL"
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var loans []*entity.Loan
+	for rows.Next() {
+		loan, err := scanLoan(rows)
+		if err != nil {
+			return nil, err
+		}
+		loans = append(loans, loan)
+	}
+
+	return loans, rows.Err()
+}
 
+// getByID runs a single-loan query, mapping no rows to ErrLoanNotFound
+func (r *loanRepository) getByID(ctx context.Context, query string, id int64) (*entity.Loan, error) {
+	loan, err := scanLoan(r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(query), id))
 	if err == sql.ErrNoRows {
-		return nil, errors.New("loan not found")
+		return nil, entity.ErrLoanNotFound
 	}
 	if err != nil {
 		return nil, err
@@ -74,50 +159,94 @@ func (r *loanRepository) GetByID(ctx context.Context, id int64) (*entity.Loan, e
 	return loan, nil
 }
 
-// Update updates an existing loan
+// Update updates an existing loan, as long as it still has the version it was read with
 func (r *loanRepository) Update(ctx context.Context, loan *entity.Loan) error {
 	query := `
 		UPDATE loans 
-		SET borrower_id_number = ?, principal_amount = ?, rate = ?, roi = ?, state = ?,
-			agreement_letter_link = ?, approval_proof_picture = ?, approval_employee_id = ?,
-			approval_date = ?, signed_agreement_doc = ?, disbursement_employee_id = ?,
-			disbursement_date = ?, updated_at = ?
-		WHERE id = ?
+		SET borrower_id_number = ?, borrower_email = ?, principal_amount = ?, rate = ?, roi = ?, term_weeks = ?,
+			min_investment = ?, max_investment = ?, max_per_investor = ?, allow_multiple_investments = ?,
+			required_approvals = ?, state = ?, agreement_letter_link = ?, approval_proof_picture = ?, approval_employee_id = ?,
+			approval_date = ?, funding_deadline = ?, approval_effective_at = ?, signed_agreement_doc = ?, disbursement_employee_id = ?,
+			disbursement_date = ?, maturity_date = ?, rejection_reason = ?, rejection_employee_id = ?,
+			rejection_date = ?, cancellation_reason = ?, cancellation_employee_id = ?,
+			cancellation_date = ?, updated_at = ?, version = version + 1
+		WHERE id = ? AND version = ?
 	`
 
-	result, err := r.db.DB.ExecContext(ctx, query,
-		loan.BorrowerIDNumber, loan.PrincipalAmount, loan.Rate, loan.ROI, loan.State,
-		loan.AgreementLetterLink, loan.ApprovalProofPicture, loan.ApprovalEmployeeID,
-		loan.ApprovalDate, loan.SignedAgreementDoc, loan.DisbursementEmployeeID,
-		loan.DisbursementDate, loan.UpdatedAt, loan.ID)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, r.db.Rebind(query),
+		loan.BorrowerIDNumber, loan.BorrowerEmail, loan.PrincipalAmount, loan.Rate, loan.ROI, loan.TermWeeks,
+		loan.MinInvestment, loan.MaxInvestment, loan.MaxPerInvestor, loan.AllowMultipleInvestmentsPerInvestor,
+		loan.RequiredApprovals, loan.State, loan.AgreementLetterLink, loan.ApprovalProofPicture, loan.ApprovalEmployeeID,
+		loan.ApprovalDate, loan.FundingDeadline, loan.ApprovalEffectiveAt, loan.SignedAgreementDoc, loan.DisbursementEmployeeID,
+		loan.DisbursementDate, loan.MaturityDate, loan.RejectionReason, loan.RejectionEmployeeID,
+		loan.RejectionDate, loan.CancellationReason, loan.CancellationEmployeeID,
+		loan.CancellationDate, loan.UpdatedAt.UTC(), loan.ID, loan.Version)
 
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+	if err := r.checkVersionedWrite(ctx, result, loan.ID); err != nil {
 		return err
 	}
-
-	if rowsAffected == 0 {
-		return errors.New("loan not found")
-	}
+	loan.Version++
 
 	return nil
 }
 
 // List retrieves loans with optional filtering
 func (r *loanRepository) List(ctx context.Context, filter repository.LoanFilter) ([]*entity.Loan, error) {
-	query := `SELECT id, borrower_id_number, principal_amount, rate, roi, state, 
-			  agreement_letter_link, approval_proof_picture, approval_employee_id, approval_date,
-			  signed_agreement_doc, disbursement_employee_id, disbursement_date,
-			  created_at, updated_at FROM loans`
+	var loans []*entity.Loan
+	err := r.ForEach(ctx, filter, func(loan *entity.Loan) error {
+		loans = append(loans, loan)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return loans, nil
+}
+
+// ForEach streams the loans matching the filter to fn one row at a time,
+// stopping at the first error returned by fn
+func (r *loanRepository) ForEach(ctx context.Context, filter repository.LoanFilter, fn func(*entity.Loan) error) error {
+	query, args, err := loanListQuery(filter)
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		loan, err := scanLoan(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(loan); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// loanListQuery builds the loan query and arguments for the filter
+func loanListQuery(filter repository.LoanFilter) (string, []interface{}, error) {
+	query := "SELECT " + loanColumns + " FROM loans"
 
 	var conditions []string
 	var args []interface{}
 
 	// Build WHERE clause
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
 	if filter.State != nil {
 		conditions = append(conditions, "state = ?")
 		args = append(args, *filter.State)
@@ -128,11 +257,61 @@ func (r *loanRepository) List(ctx context.Context, filter repository.LoanFilter)
 		args = append(args, *filter.BorrowerID)
 	}
 
+	if filter.CreatedAfter != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *filter.CreatedAfter)
+	}
+
+	if filter.CreatedBefore != nil {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, *filter.CreatedBefore)
+	}
+
+	if filter.FundingDeadlineBefore != nil {
+		conditions = append(conditions, "funding_deadline <= ?")
+		args = append(args, *filter.FundingDeadlineBefore)
+	}
+
+	if filter.ApprovalEffectiveBefore != nil {
+		conditions = append(conditions, "approval_effective_at <= ?")
+		args = append(args, *filter.ApprovalEffectiveBefore)
+	}
+
+	if filter.Search != nil {
+		pattern := "%" + escapeLike(strings.ToLower(*filter.Search)) + "%"
+		conditions = append(conditions, `(LOWER(borrower_id_number) LIKE ? ESCAPE '\' OR EXISTS (
+			SELECT 1 FROM investments WHERE investments.loan_id = loans.id AND LOWER(investments.investor_email) LIKE ? ESCAPE '\'))`)
+		args = append(args, pattern, pattern)
+	}
+
+	if filter.MinPrincipal != nil {
+		conditions = append(conditions, "principal_amount >= ?")
+		args = append(args, *filter.MinPrincipal)
+	}
+
+	if filter.MaxPrincipal != nil {
+		conditions = append(conditions, "principal_amount <= ?")
+		args = append(args, *filter.MaxPrincipal)
+	}
+
+	if filter.FundingStatus != nil {
+		condition, conditionArgs, err := loanFundingCondition(*filter.FundingStatus)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	query += " ORDER BY created_at DESC"
+	orderBy, err := loanOrderBy(filter)
+	if err != nil {
+		return "", nil, err
+	}
+	query += orderBy
 
 	// Add pagination
 	if filter.Limit != nil {
@@ -145,36 +324,255 @@ func (r *loanRepository) List(ctx context.Context, filter repository.LoanFilter)
 		args = append(args, *filter.Offset)
 	}
 
-	rows, err := r.db.DB.QueryContext(ctx, query, args...)
+	return query, args, nil
+}
+
+// likeEscaper escapes the LIKE wildcards so they match literally, using \ as the escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes user input match literally inside a LIKE pattern
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// SoftDelete marks a loan as deleted without removing the row, as long as it
+// still has the version it was read with
+func (r *loanRepository) SoftDelete(ctx context.Context, loan *entity.Loan) error {
+	query := "UPDATE loans SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL"
+
+	now := entity.Now()
+	result, err := r.db.Conn(ctx).ExecContext(ctx, r.db.Rebind(query), now, now, loan.ID, loan.Version)
+	if err != nil {
+		return err
+	}
+
+	if err := r.checkVersionedWrite(ctx, result, loan.ID); err != nil {
+		return err
+	}
+	loan.DeletedAt = &now
+	loan.UpdatedAt = now
+	loan.Version++
+
+	return nil
+}
+
+// checkVersionedWrite tells apart why a write guarded by the loan version
+// affected no rows: the loan is gone, or another request changed it first
+func (r *loanRepository) checkVersionedWrite(ctx context.Context, result sql.Result, id int64) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM loans WHERE id = ? AND deleted_at IS NULL)"
+	if err := r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(query), id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return entity.ErrLoanNotFound
+	}
+	return entity.ErrConcurrentModification
+}
+
+// GetStats aggregates portfolio statistics over live loans
+func (r *loanRepository) GetStats(ctx context.Context, filter repository.StatsFilter) (*entity.LoanStats, error) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+
+	if filter.CreatedAfter != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *filter.CreatedAfter)
+	}
+
+	if filter.CreatedBefore != nil {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, *filter.CreatedBefore)
+	}
+
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	// Count, principal and ROI totals per state in a single pass
+	stateQuery := "SELECT state, COUNT(*), COALESCE(SUM(principal_amount), 0), COALESCE(SUM(roi), 0) FROM loans" +
+		where + " GROUP BY state"
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, r.db.Rebind(stateQuery), args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var loans []*entity.Loan
+	stats := entity.NewLoanStats()
+	var totalROI float64
 	for rows.Next() {
-		loan := &entity.Loan{}
-		err := rows.Scan(
-			&loan.ID, &loan.BorrowerIDNumber, &loan.PrincipalAmount,
-			&loan.Rate, &loan.ROI, &loan.State, &loan.AgreementLetterLink,
-			&loan.ApprovalProofPicture, &loan.ApprovalEmployeeID, &loan.ApprovalDate,
-			&loan.SignedAgreementDoc, &loan.DisbursementEmployeeID, &loan.DisbursementDate,
-			&loan.CreatedAt, &loan.UpdatedAt)
-		if err != nil {
+		var state entity.LoanState
+		var count int
+		var principal entity.Money
+		var roi float64
+		if err := rows.Scan(&state, &count, &principal, &roi); err != nil {
 			return nil, err
 		}
-		loans = append(loans, loan)
+
+		stats.CountByState[state] = count
+		stats.TotalLoans += count
+		totalROI += roi
+		if state == entity.StateDisbursed {
+			stats.TotalDisbursedPrincipal = principal
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return loans, rows.Err()
+	if stats.TotalLoans > 0 {
+		stats.AverageROI = totalROI / float64(stats.TotalLoans)
+	}
+
+	// Partially disbursed loans count with the tranches paid out so far
+	trancheQuery := "SELECT COALESCE(SUM(amount), 0) FROM disbursements WHERE loan_id IN (SELECT id FROM loans" + where + " AND state = ?)"
+	var tranches entity.Money
+	trancheArgs := append(append([]interface{}{}, args...), entity.StatePartiallyDisbursed)
+	if err := r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(trancheQuery), trancheArgs...).Scan(&tranches); err != nil {
+		return nil, err
+	}
+	stats.TotalDisbursedPrincipal += tranches
+
+	// Total invested across the same loans
+	investedQuery := "SELECT COALESCE(SUM(amount), 0) FROM investments WHERE loan_id IN (SELECT id FROM loans" + where + ")"
+	if err := r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(investedQuery), args...).Scan(&stats.TotalInvested); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GetROISummary aggregates the live funded loans, weighting each loan's ROI by
+// its principal. Investments are summed per loan first, so a loan with many
+// investments still counts once in the average.
+func (r *loanRepository) GetROISummary(ctx context.Context) (*entity.ROISummary, error) {
+	placeholders := make([]string, len(entity.ROISummaryStates))
+	args := make([]interface{}, len(entity.ROISummaryStates))
+	for i, state := range entity.ROISummaryStates {
+		placeholders[i] = "?"
+		args[i] = state
+	}
+
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(invested.amount), 0),
+			COALESCE(SUM(l.principal_amount * l.roi), 0), COALESCE(SUM(l.principal_amount), 0),
+			COALESCE(SUM(invested.amount * l.roi / 100), 0)
+		FROM loans l
+		LEFT JOIN (SELECT loan_id, SUM(amount) AS amount FROM investments GROUP BY loan_id) invested
+			ON invested.loan_id = l.id
+		WHERE l.deleted_at IS NULL AND l.state IN (` + strings.Join(placeholders, ", ") + `)
+	`
+
+	summary := &entity.ROISummary{}
+	var weightedROI, totalPrincipal float64
+	err := r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(query), args...).Scan(
+		&summary.LoanCount, &summary.TotalInvested, &weightedROI, &totalPrincipal, &summary.ProjectedReturns)
+	if err != nil {
+		return nil, err
+	}
+
+	if totalPrincipal > 0 {
+		summary.WeightedAverageROI = weightedROI / totalPrincipal
+	}
+
+	return summary, nil
+}
+
+// GetBorrowerExposure aggregates a borrower's live loans by state, with the
+// principal and the disbursed tranches of each state
+func (r *loanRepository) GetBorrowerExposure(ctx context.Context, borrowerIDNumber string) (*entity.BorrowerExposure, error) {
+	query := `SELECT loans.state, COUNT(*), COALESCE(SUM(loans.principal_amount), 0), COALESCE(SUM(disbursed.amount), 0)
+		FROM loans
+		LEFT JOIN (SELECT loan_id, SUM(amount) AS amount FROM disbursements GROUP BY loan_id) disbursed
+			ON disbursed.loan_id = loans.id
+		WHERE loans.borrower_id_number = ? AND loans.deleted_at IS NULL
+		GROUP BY loans.state`
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, r.db.Rebind(query), borrowerIDNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exposure := entity.NewBorrowerExposure(borrowerIDNumber)
+	for rows.Next() {
+		var state entity.LoanState
+		stateExposure := &entity.LoanStateExposure{}
+		if err := rows.Scan(&state, &stateExposure.Count, &stateExposure.Principal, &stateExposure.Disbursed); err != nil {
+			return nil, err
+		}
+
+		exposure.ByState[state] = stateExposure
+		exposure.DisbursedPrincipal += stateExposure.Disbursed
+		if state.IsActive() {
+			exposure.ActiveLoans += stateExposure.Count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return exposure, nil
+}
+
+// loanSortColumns allowlists the columns loans can be ordered by
+var loanSortColumns = map[string]string{
+	"created_at":       "created_at",
+	"principal_amount": "principal_amount",
+}
+
+// loanOrderBy builds the ORDER BY clause from the allowlisted sort columns
+func loanOrderBy(filter repository.LoanFilter) (string, error) {
+	if filter.SortBy == "" {
+		return " ORDER BY created_at DESC, id DESC", nil
+	}
+
+	column, ok := loanSortColumns[filter.SortBy]
+	if !ok {
+		return "", entity.NewDomainError(entity.ErrValidation, fmt.Sprintf("unsupported sort field: %s", filter.SortBy))
+	}
+
+	direction := "ASC"
+	if filter.SortDesc {
+		direction = "DESC"
+	}
+
+	return fmt.Sprintf(" ORDER BY %s %s, id %s", column, direction, direction), nil
+}
+
+// loanFundingCondition returns the WHERE condition and its arguments selecting loans with the funding status
+func loanFundingCondition(status entity.FundingStatus) (string, []interface{}, error) {
+	// Compare in hundredths like entity.Money, so floating point sums of investments
+	// that add up to the principal count as funded
+	invested := "ROUND(" + loanTotalInvestedColumn + " * 100)"
+	principal := "ROUND(principal_amount * 100)"
+
+	switch status {
+	case entity.FundingOpen:
+		return invested + " < " + principal + " * ?", []interface{}{entity.AlmostFundedRatio}, nil
+	case entity.FundingAlmostFunded:
+		return invested + " >= " + principal + " * ? AND " + invested + " < " + principal,
+			[]interface{}{entity.AlmostFundedRatio}, nil
+	case entity.FundingFunded:
+		return invested + " >= " + principal, nil, nil
+	default:
+		return "", nil, entity.NewDomainError(entity.ErrValidation, fmt.Sprintf("unsupported funding status: %s", status))
+	}
 }
 
 // GetTotalInvestment calculates total investment for a loan
-func (r *loanRepository) GetTotalInvestment(ctx context.Context, loanID int64) (float64, error) {
+func (r *loanRepository) GetTotalInvestment(ctx context.Context, loanID int64) (entity.Money, error) {
 	query := "SELECT COALESCE(SUM(amount), 0) FROM investments WHERE loan_id = ?"
 
-	var total float64
-	err := r.db.DB.QueryRowContext(ctx, query, loanID).Scan(&total)
+	var total entity.Money
+	err := r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(query), loanID).Scan(&total)
 	return total, err
 }
 
@@ -183,6 +581,25 @@ type investmentRepository struct {
 	db *database.Database
 }
 
+// investmentColumns lists the investment columns in the order expected by scanInvestment
+const investmentColumns = "id, loan_id, investor_email, amount, currency, original_amount, idempotency_key, created_at, language"
+
+// qualifiedInvestmentColumns lists investmentColumns for queries joining other tables
+const qualifiedInvestmentColumns = "investments.id, investments.loan_id, investments.investor_email, investments.amount, " +
+	"investments.currency, investments.original_amount, investments.idempotency_key, investments.created_at, investments.language"
+
+// scanInvestment scans a single investment row selected with investmentColumns
+func scanInvestment(row rowScanner) (*entity.Investment, error) {
+	investment := &entity.Investment{}
+	err := row.Scan(&investment.ID, &investment.LoanID, &investment.InvestorEmail,
+		&investment.Amount, &investment.Currency, &investment.OriginalAmount, &investment.IdempotencyKey, &investment.CreatedAt, &investment.Language)
+	if err != nil {
+		return nil, err
+	}
+	investment.CreatedAt = investment.CreatedAt.UTC()
+	return investment, nil
+}
+
 // NewInvestmentRepository creates a new investment repository
 func NewInvestmentRepository(db *database.Database) repository.InvestmentRepository {
 	return &investmentRepository{db: db}
@@ -191,33 +608,143 @@ func NewInvestmentRepository(db *database.Database) repository.InvestmentReposit
 // Create saves a new investment
 func (r *investmentRepository) Create(ctx context.Context, investment *entity.Investment) error {
 	query := `
-		INSERT INTO investments (loan_id, investor_email, amount, created_at)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO investments (loan_id, investor_email, amount, currency, original_amount, idempotency_key, created_at, language)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	result, err := r.db.DB.ExecContext(ctx, query,
+	// Get the auto-generated ID
+	id, err := r.db.InsertReturningID(ctx, r.db.Conn(ctx), query,
 		investment.LoanID, investment.InvestorEmail,
-		investment.Amount, investment.CreatedAt)
+		investment.Amount, investment.Currency, investment.OriginalAmount, investment.IdempotencyKey, investment.CreatedAt.UTC(), investment.Language)
+	if err != nil {
+		return investmentInsertError(investment, err)
+	}
+	investment.ID = id
+
+	return nil
+}
+
+// CreateWithinPrincipal saves a new investment and updates the loan state in a single transaction
+func (r *investmentRepository) CreateWithinPrincipal(ctx context.Context, investment *entity.Investment) (*entity.Loan, error) {
+	var loan *entity.Loan
+
+	err := r.db.WithTx(ctx, func(tx *sql.Tx) error {
+		// Reload the loan and its funded total inside the transaction so that
+		// the checks see every investment committed before ours
+		loanQuery := "SELECT " + loanColumns + " FROM loans WHERE id = ?"
+		if r.db.Driver == database.DriverPostgres {
+			// SQLite locks the whole database when the transaction begins; Postgres needs a row lock
+			loanQuery += " FOR UPDATE"
+		}
+
+		var err error
+		loan, err = scanLoan(tx.QueryRowContext(ctx, r.db.Rebind(loanQuery), investment.LoanID))
+		if err == sql.ErrNoRows {
+			return entity.ErrLoanNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var total entity.Money
+		err = tx.QueryRowContext(ctx,
+			r.db.Rebind("SELECT COALESCE(SUM(amount), 0) FROM investments WHERE loan_id = ?"),
+			investment.LoanID).Scan(&total)
+		if err != nil {
+			return err
+		}
+
+		if err := loan.CanReceiveInvestment(); err != nil {
+			return err
+		}
+		if err := loan.ValidateInvestmentAmount(investment.Amount, total); err != nil {
+			return err
+		}
+
+		id, err := r.db.InsertReturningID(ctx, tx,
+			"INSERT INTO investments (loan_id, investor_email, amount, currency, original_amount, idempotency_key, created_at, language) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			investment.LoanID, investment.InvestorEmail, investment.Amount, investment.Currency, investment.OriginalAmount,
+			investment.IdempotencyKey, investment.CreatedAt.UTC(), investment.Language)
+		if err != nil {
+			return investmentInsertError(investment, err)
+		}
+		investment.ID = id
+		loan.TotalInvested = total + investment.Amount
+
+		if loan.IsFullyInvested(loan.TotalInvested) {
+			loan.MarkAsInvested()
+			_, err = tx.ExecContext(ctx,
+				r.db.Rebind("UPDATE loans SET state = ?, updated_at = ?, version = version + 1 WHERE id = ?"),
+				loan.State, loan.UpdatedAt.UTC(), loan.ID)
+			if err != nil {
+				return err
+			}
+			loan.Version++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return loan, nil
+}
 
+// GetByID retrieves an investment by its ID
+func (r *investmentRepository) GetByID(ctx context.Context, id int64) (*entity.Investment, error) {
+	query := "SELECT " + investmentColumns + " FROM investments WHERE id = ?"
+
+	investment, err := scanInvestment(r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(query), id))
+	if err == sql.ErrNoRows {
+		return nil, entity.ErrInvestmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return investment, nil
+}
+
+// GetByIdempotencyKey retrieves the investment created with the given idempotency key
+func (r *investmentRepository) GetByIdempotencyKey(ctx context.Context, key string) (*entity.Investment, error) {
+	query := "SELECT " + investmentColumns + " FROM investments WHERE idempotency_key = ?"
+
+	investment, err := scanInvestment(r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(query), key))
+	if err == sql.ErrNoRows {
+		return nil, entity.ErrInvestmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return investment, nil
+}
+
+// Delete removes an investment
+func (r *investmentRepository) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.Conn(ctx).ExecContext(ctx, r.db.Rebind("DELETE FROM investments WHERE id = ?"), id)
 	if err != nil {
 		return err
 	}
 
-	// Get the auto-generated ID
-	id, err := result.LastInsertId()
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
-	investment.ID = id
+
+	if rowsAffected == 0 {
+		return entity.ErrInvestmentNotFound
+	}
 
 	return nil
 }
 
 // GetByLoanID retrieves all investments for a specific loan
 func (r *investmentRepository) GetByLoanID(ctx context.Context, loanID int64) ([]*entity.Investment, error) {
-	query := "SELECT id, loan_id, investor_email, amount, created_at FROM investments WHERE loan_id = ? ORDER BY created_at"
+	query := "SELECT " + investmentColumns + " FROM investments WHERE loan_id = ? ORDER BY created_at"
 
-	rows, err := r.db.DB.QueryContext(ctx, query, loanID)
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, r.db.Rebind(query), loanID)
 	if err != nil {
 		return nil, err
 	}
@@ -225,9 +752,7 @@ func (r *investmentRepository) GetByLoanID(ctx context.Context, loanID int64) ([
 
 	var investments []*entity.Investment
 	for rows.Next() {
-		investment := &entity.Investment{}
-		err := rows.Scan(&investment.ID, &investment.LoanID, &investment.InvestorEmail,
-			&investment.Amount, &investment.CreatedAt)
+		investment, err := scanInvestment(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -238,10 +763,257 @@ func (r *investmentRepository) GetByLoanID(ctx context.Context, loanID int64) ([
 }
 
 // GetTotalByLoanID calculates total investment amount for a loan
-func (r *investmentRepository) GetTotalByLoanID(ctx context.Context, loanID int64) (float64, error) {
+func (r *investmentRepository) GetTotalByLoanID(ctx context.Context, loanID int64) (entity.Money, error) {
 	query := "SELECT COALESCE(SUM(amount), 0) FROM investments WHERE loan_id = ?"
 
-	var total float64
-	err := r.db.DB.QueryRowContext(ctx, query, loanID).Scan(&total)
+	var total entity.Money
+	err := r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(query), loanID).Scan(&total)
+	return total, err
+}
+
+// CountInvestors counts the distinct investors of a loan
+func (r *investmentRepository) CountInvestors(ctx context.Context, loanID int64) (int, error) {
+	query := "SELECT COUNT(DISTINCT investor_email) FROM investments WHERE loan_id = ?"
+
+	var count int
+	err := r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(query), loanID).Scan(&count)
+	return count, err
+}
+
+// GetTotalByInvestor calculates total amount one investor has put into a loan
+func (r *investmentRepository) GetTotalByInvestor(ctx context.Context, loanID int64, investorEmail string) (entity.Money, error) {
+	query := "SELECT COALESCE(SUM(amount), 0) FROM investments WHERE loan_id = ? AND investor_email = ?"
+
+	var total entity.Money
+	err := r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(query), loanID, investorEmail).Scan(&total)
 	return total, err
 }
+
+// HasInvested reports whether an investor already has an investment in a loan
+func (r *investmentRepository) HasInvested(ctx context.Context, loanID int64, investorEmail string) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM investments WHERE loan_id = ? AND investor_email = ?)"
+
+	var exists bool
+	err := r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(query), loanID, investorEmail).Scan(&exists)
+	return exists, err
+}
+
+// List retrieves investments with optional filtering
+func (r *investmentRepository) List(ctx context.Context, filter repository.InvestmentFilter) ([]*entity.Investment, error) {
+	query, args := investmentListQuery("SELECT "+investmentColumns+" FROM investments", filter)
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var investments []*entity.Investment
+	for rows.Next() {
+		investment, err := scanInvestment(rows)
+		if err != nil {
+			return nil, err
+		}
+		investments = append(investments, investment)
+	}
+
+	return investments, rows.Err()
+}
+
+// Count counts investments matching the filter, ignoring pagination
+func (r *investmentRepository) Count(ctx context.Context, filter repository.InvestmentFilter) (int, error) {
+	where, args := investmentFilterConditions(filter)
+	query := "SELECT COUNT(*) FROM investments" + where
+
+	var count int
+	err := r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(query), args...).Scan(&count)
+	return count, err
+}
+
+// ListWithLoanState retrieves investments with optional filtering, each with the state of its loan
+func (r *investmentRepository) ListWithLoanState(ctx context.Context, filter repository.InvestmentFilter) ([]*entity.LedgerInvestment, error) {
+	query, args := investmentListQuery(
+		"SELECT "+qualifiedInvestmentColumns+", loans.state FROM investments JOIN loans ON loans.id = investments.loan_id", filter)
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*entity.LedgerInvestment
+	for rows.Next() {
+		investment := &entity.Investment{}
+		entry := &entity.LedgerInvestment{Investment: investment}
+		err := rows.Scan(&investment.ID, &investment.LoanID, &investment.InvestorEmail, &investment.Amount,
+			&investment.Currency, &investment.OriginalAmount, &investment.IdempotencyKey, &investment.CreatedAt,
+			&investment.Language, &entry.LoanState)
+		if err != nil {
+			return nil, err
+		}
+		investment.CreatedAt = investment.CreatedAt.UTC()
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// investmentListQuery completes a select from investments with the filter's
+// conditions, order and pagination
+func investmentListQuery(selectFrom string, filter repository.InvestmentFilter) (string, []interface{}) {
+	where, args := investmentFilterConditions(filter)
+	orderBy := " ORDER BY investments.created_at, investments.id"
+
+	// Keyset pagination continues after the last seen ID instead of skipping rows
+	if filter.AfterID != nil {
+		if where == "" {
+			where = " WHERE investments.id > ?"
+		} else {
+			where += " AND investments.id > ?"
+		}
+		args = append(args, *filter.AfterID)
+		orderBy = " ORDER BY investments.id"
+	}
+	query := selectFrom + where + orderBy
+
+	// Add pagination
+	if filter.Limit != nil {
+		query += " LIMIT ?"
+		args = append(args, *filter.Limit)
+	}
+
+	if filter.Offset != nil {
+		query += " OFFSET ?"
+		args = append(args, *filter.Offset)
+	}
+
+	return query, args
+}
+
+// GetByInvestor retrieves one investor's investments across all loans, paginated by the filter
+func (r *investmentRepository) GetByInvestor(ctx context.Context, investorEmail string, filter repository.InvestmentFilter) ([]*entity.Investment, error) {
+	filter.InvestorEmail = &investorEmail
+	return r.List(ctx, filter)
+}
+
+// GetInvestorTotals aggregates one investor's investments across all loans
+func (r *investmentRepository) GetInvestorTotals(ctx context.Context, investorEmail string) (*entity.InvestorTotals, error) {
+	query := `
+		SELECT COUNT(*), COUNT(DISTINCT i.loan_id), COALESCE(SUM(i.amount), 0), COALESCE(SUM(i.amount * l.roi / 100), 0)
+		FROM investments i JOIN loans l ON l.id = i.loan_id
+		WHERE i.investor_email = ?
+	`
+
+	totals := &entity.InvestorTotals{}
+	err := r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(query), investorEmail).Scan(
+		&totals.InvestmentCount, &totals.LoanCount, &totals.TotalInvested, &totals.ExpectedReturns)
+	if err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+// investmentFilterConditions builds the WHERE clause shared by List and Count
+func investmentFilterConditions(filter repository.InvestmentFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	// Columns are qualified for queries joining the loans
+	if filter.LoanID != nil {
+		conditions = append(conditions, "investments.loan_id = ?")
+		args = append(args, *filter.LoanID)
+	}
+
+	if filter.InvestorEmail != nil {
+		conditions = append(conditions, "investments.investor_email = ?")
+		args = append(args, *filter.InvestorEmail)
+	}
+
+	if filter.CreatedAfter != nil {
+		conditions = append(conditions, "investments.created_at >= ?")
+		args = append(args, *filter.CreatedAfter)
+	}
+
+	if filter.CreatedBefore != nil {
+		conditions = append(conditions, "investments.created_at <= ?")
+		args = append(args, *filter.CreatedBefore)
+	}
+
+	if filter.MinAmount != nil {
+		conditions = append(conditions, "investments.amount >= ?")
+		args = append(args, *filter.MinAmount)
+	}
+
+	if filter.MaxAmount != nil {
+		conditions = append(conditions, "investments.amount <= ?")
+		args = append(args, *filter.MaxAmount)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// investmentInsertError translates a failed investment insert into a domain
+// error, so constraint violations do not reach clients as raw driver errors
+func investmentInsertError(investment *entity.Investment, err error) error {
+	switch {
+	case investment.IdempotencyKey != nil && isUniqueViolation(err):
+		return entity.ErrDuplicateIdempotencyKey
+	case isForeignKeyViolation(err):
+		// The loan was removed after it was read
+		return entity.ErrLoanNotFound
+	case isConstraintViolation(err):
+		return entity.NewDomainError(entity.ErrConstraintViolation, "investment conflicts with the stored data, reload the loan and retry")
+	}
+	return err
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation on either driver
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+
+	return false
+}
+
+// isForeignKeyViolation reports whether err is a foreign key violation on either driver
+func isForeignKeyViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23503"
+	}
+
+	return false
+}
+
+// isConstraintViolation reports whether err is any constraint violation on either driver
+func isConstraintViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrConstraint
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Class 23 holds the integrity constraint violations
+		return pqErr.Code.Class() == "23"
+	}
+
+	return false
+}
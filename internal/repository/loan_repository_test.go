@@ -0,0 +1,707 @@
+package repository
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"amartha-andreas/internal/domain/repository"
+	"amartha-andreas/internal/infrastructure/database"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// newTestDB opens a fresh SQLite database with the options the server runs with
+func newTestDB(t *testing.T) *database.Database {
+	t.Helper()
+
+	db, err := database.NewDatabase(filepath.Join(t.TempDir(), "test.db") + "?_txlock=immediate&_busy_timeout=5000&_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// seedLoan saves a loan of principal in state, created at createdAt, after
+// applying the options to it
+func seedLoan(t *testing.T, loans repository.LoanRepository, principal float64, state entity.LoanState, createdAt time.Time, options ...func(*entity.Loan)) *entity.Loan {
+	t.Helper()
+
+	loan := &entity.Loan{
+		BorrowerIDNumber:    "3171234567890123",
+		PrincipalAmount:     entity.NewMoney(principal),
+		Rate:                12,
+		ROI:                 10,
+		TermWeeks:           52,
+		State:               state,
+		AgreementLetterLink: "https://example.com/agreements/1.pdf",
+		CreatedAt:           createdAt,
+		UpdatedAt:           createdAt,
+	}
+	for _, option := range options {
+		option(loan)
+	}
+	if err := loans.Create(context.Background(), loan); err != nil {
+		t.Fatalf("failed to create loan: %v", err)
+	}
+	return loan
+}
+
+// withROI sets the ROI of a seeded loan
+func withROI(roi float64) func(*entity.Loan) {
+	return func(loan *entity.Loan) { loan.ROI = roi }
+}
+
+// seedInvestment saves an investment of amount in loanID
+func seedInvestment(t *testing.T, investments repository.InvestmentRepository, loanID int64, investorEmail string, amount float64) {
+	t.Helper()
+
+	investment := &entity.Investment{
+		LoanID:        loanID,
+		InvestorEmail: investorEmail,
+		Amount:        entity.NewMoney(amount),
+		CreatedAt:     time.Now(),
+	}
+	if err := investments.Create(context.Background(), investment); err != nil {
+		t.Fatalf("failed to create investment: %v", err)
+	}
+}
+
+// seedDisbursement saves a disbursement tranche of amount for loanID
+func seedDisbursement(t *testing.T, disbursements repository.DisbursementRepository, loanID int64, amount float64) {
+	t.Helper()
+
+	disbursement := &entity.Disbursement{
+		LoanID:             loanID,
+		Amount:             entity.NewMoney(amount),
+		SignedAgreementDoc: "/files/signed_agreements/agreement.pdf",
+		EmployeeID:         "EMP-DISBURSER",
+		DisbursementDate:   time.Now(),
+		CreatedAt:          time.Now(),
+	}
+	if err := disbursements.Create(context.Background(), disbursement); err != nil {
+		t.Fatalf("failed to create disbursement: %v", err)
+	}
+}
+
+// loanIDs returns the IDs of loans in order
+func loanIDs(loans []*entity.Loan) []int64 {
+	ids := make([]int64, len(loans))
+	for i, loan := range loans {
+		ids[i] = loan.ID
+	}
+	return ids
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLoanListFiltersByCreationDate(t *testing.T) {
+	loans := NewLoanRepository(newTestDB(t))
+	january := seedLoan(t, loans, 1000, entity.StateProposed, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC))
+	february := seedLoan(t, loans, 1000, entity.StateProposed, time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC))
+	march := seedLoan(t, loans, 1000, entity.StateProposed, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+
+	feb1 := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	mar1 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	febLoan := february.CreatedAt
+
+	tests := []struct {
+		name   string
+		filter repository.LoanFilter
+		want   []int64
+	}{
+		{"only lower bound", repository.LoanFilter{CreatedAfter: &feb1}, []int64{march.ID, february.ID}},
+		{"only upper bound", repository.LoanFilter{CreatedBefore: &mar1}, []int64{february.ID, january.ID}},
+		{"both bounds", repository.LoanFilter{CreatedAfter: &feb1, CreatedBefore: &mar1}, []int64{february.ID}},
+		{"bounds are inclusive", repository.LoanFilter{CreatedAfter: &febLoan, CreatedBefore: &febLoan}, []int64{february.ID}},
+		{"no bounds", repository.LoanFilter{}, []int64{march.ID, february.ID, january.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loans.List(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("failed to list loans: %v", err)
+			}
+			if ids := loanIDs(got); !equalIDs(ids, tt.want) {
+				t.Errorf("got loans %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoanGetStats(t *testing.T) {
+	db := newTestDB(t)
+	loans := NewLoanRepository(db)
+	investments := NewInvestmentRepository(db)
+	disbursements := NewDisbursementRepository(db)
+
+	old := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	seedLoan(t, loans, 1000, entity.StateProposed, old, withROI(4))
+	seedLoan(t, loans, 2000, entity.StateApproved, recent, withROI(6))
+
+	disbursed := seedLoan(t, loans, 3000, entity.StateDisbursed, recent, withROI(8))
+	seedInvestment(t, investments, disbursed.ID, "alice@example.com", 3000)
+	seedDisbursement(t, disbursements, disbursed.ID, 3000)
+
+	partial := seedLoan(t, loans, 4000, entity.StatePartiallyDisbursed, recent, withROI(10))
+	seedInvestment(t, investments, partial.ID, "alice@example.com", 1500)
+	seedInvestment(t, investments, partial.ID, "bob@example.com", 2500)
+	seedDisbursement(t, disbursements, partial.ID, 1000)
+	seedDisbursement(t, disbursements, partial.ID, 500)
+
+	t.Run("all loans", func(t *testing.T) {
+		stats, err := loans.GetStats(context.Background(), repository.StatsFilter{})
+		if err != nil {
+			t.Fatalf("failed to get stats: %v", err)
+		}
+
+		wantCounts := map[entity.LoanState]int{
+			entity.StateProposed:           1,
+			entity.StateApproved:           1,
+			entity.StateDisbursed:          1,
+			entity.StatePartiallyDisbursed: 1,
+		}
+		for state, count := range stats.CountByState {
+			if count != wantCounts[state] {
+				t.Errorf("got %d %s loans, want %d", count, state, wantCounts[state])
+			}
+		}
+		if stats.TotalLoans != 4 {
+			t.Errorf("got %d loans, want 4", stats.TotalLoans)
+		}
+		// The disbursed loan's principal plus the two tranches paid out on the partial one
+		if want := entity.NewMoney(4500); stats.TotalDisbursedPrincipal != want {
+			t.Errorf("got disbursed principal %s, want %s", stats.TotalDisbursedPrincipal, want)
+		}
+		if want := entity.NewMoney(7000); stats.TotalInvested != want {
+			t.Errorf("got total invested %s, want %s", stats.TotalInvested, want)
+		}
+		if stats.AverageROI != 7 {
+			t.Errorf("got average ROI %v, want 7", stats.AverageROI)
+		}
+	})
+
+	t.Run("date range", func(t *testing.T) {
+		after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		stats, err := loans.GetStats(context.Background(), repository.StatsFilter{CreatedAfter: &after})
+		if err != nil {
+			t.Fatalf("failed to get stats: %v", err)
+		}
+
+		if stats.TotalLoans != 3 || stats.CountByState[entity.StateProposed] != 0 {
+			t.Errorf("got %d loans (%v), want the 3 created in 2024", stats.TotalLoans, stats.CountByState)
+		}
+		if stats.AverageROI != 8 {
+			t.Errorf("got average ROI %v, want 8", stats.AverageROI)
+		}
+	})
+}
+
+func TestQueriesHonorCancelledContext(t *testing.T) {
+	db := newTestDB(t)
+	loans := NewLoanRepository(db)
+	loan := seedLoan(t, loans, 1000, entity.StateProposed, entity.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := loans.GetByID(ctx, loan.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetByID: got error %v, want context.Canceled", err)
+	}
+	if _, err := loans.List(ctx, repository.LoanFilter{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("List: got error %v, want context.Canceled", err)
+	}
+	if _, err := loans.GetStats(ctx, repository.StatsFilter{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetStats: got error %v, want context.Canceled", err)
+	}
+	if err := loans.Update(ctx, loan); !errors.Is(err, context.Canceled) {
+		t.Errorf("Update: got error %v, want context.Canceled", err)
+	}
+}
+
+func TestLoanListFiltersByFundingStatus(t *testing.T) {
+	db := newTestDB(t)
+	loans := NewLoanRepository(db)
+	investments := NewInvestmentRepository(db)
+
+	// seedFunded seeds an approved loan of 1000 with the investments
+	seedFunded := func(amounts ...float64) *entity.Loan {
+		loan := seedLoan(t, loans, 1000, entity.StateApproved, entity.Now())
+		for i, amount := range amounts {
+			seedInvestment(t, investments, loan.ID, fmt.Sprintf("investor%d@example.com", i), amount)
+		}
+		return loan
+	}
+	empty := seedFunded()
+	half := seedFunded(500)
+	justUnder := seedFunded(400, 399.99)
+	threshold := seedFunded(800)
+	oneCentShort := seedFunded(333.33, 333.33, 333.33)
+	funded := seedFunded(333.33, 333.33, 333.34)
+
+	tests := []struct {
+		status entity.FundingStatus
+		want   []int64
+	}{
+		{entity.FundingOpen, []int64{empty.ID, half.ID, justUnder.ID}},
+		{entity.FundingAlmostFunded, []int64{threshold.ID, oneCentShort.ID}},
+		{entity.FundingFunded, []int64{funded.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			status := tt.status
+			got, err := loans.List(context.Background(), repository.LoanFilter{FundingStatus: &status})
+			if err != nil {
+				t.Fatalf("failed to list loans: %v", err)
+			}
+			ids := loanIDs(got)
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			if !equalIDs(ids, tt.want) {
+				t.Errorf("got loans %v, want %v", ids, tt.want)
+			}
+		})
+	}
+
+	// Listed loans carry their invested total for the funded percentage
+	got, err := loans.GetByID(context.Background(), oneCentShort.ID)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	if got.TotalInvested != entity.NewMoney(999.99) || math.Abs(got.FundedPercentage()-99.999) > 1e-9 {
+		t.Errorf("got %s invested (%v%%), want 999.99 (99.999%%)", got.TotalInvested, got.FundedPercentage())
+	}
+}
+
+func TestLoanUpdateRejectsStaleVersion(t *testing.T) {
+	loans := NewLoanRepository(newTestDB(t))
+	seeded := seedLoan(t, loans, 1000, entity.StateApproved, entity.Now())
+	ctx := context.Background()
+
+	// Two requests read the loan before either writes it back
+	const requests = 2
+	copies := make([]*entity.Loan, requests)
+	for i := range copies {
+		loan, err := loans.GetByID(ctx, seeded.ID)
+		if err != nil {
+			t.Fatalf("failed to get loan: %v", err)
+		}
+		copies[i] = loan
+	}
+	copies[0].State = entity.StateCancelled
+	copies[1].ROI = 11
+
+	errs := make([]error, requests)
+	var wg sync.WaitGroup
+	for i, loan := range copies {
+		wg.Add(1)
+		go func(i int, loan *entity.Loan) {
+			defer wg.Done()
+			errs[i] = loans.Update(ctx, loan)
+		}(i, loan)
+	}
+	wg.Wait()
+
+	var winner *entity.Loan
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			if winner != nil {
+				t.Fatal("both updates succeeded, want the second to conflict")
+			}
+			winner = copies[i]
+		case !errors.Is(err, entity.ErrConcurrentModification):
+			t.Fatalf("got error %v, want ErrConcurrentModification", err)
+		}
+	}
+	if winner == nil {
+		t.Fatal("no update succeeded")
+	}
+
+	// The stored loan is the winner's, one version on
+	stored, err := loans.GetByID(ctx, seeded.ID)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	if stored.Version != seeded.Version+1 || winner.Version != stored.Version {
+		t.Errorf("got stored version %d and winner version %d, want %d", stored.Version, winner.Version, seeded.Version+1)
+	}
+	if stored.State != winner.State || stored.ROI != winner.ROI {
+		t.Errorf("got stored state %s and ROI %v, want the winning update's %s and %v", stored.State, stored.ROI, winner.State, winner.ROI)
+	}
+
+	// Reloading and retrying succeeds
+	stored.ROI = 9
+	if err := loans.Update(ctx, stored); err != nil {
+		t.Errorf("got error %v retrying with the reloaded loan", err)
+	}
+}
+
+// withBorrower sets the borrower ID number of a seeded loan
+func withBorrower(borrowerIDNumber string) func(*entity.Loan) {
+	return func(loan *entity.Loan) { loan.BorrowerIDNumber = borrowerIDNumber }
+}
+
+func TestLoanListSearchesBorrowerAndInvestors(t *testing.T) {
+	db := newTestDB(t)
+	loans := NewLoanRepository(db)
+	investments := NewInvestmentRepository(db)
+
+	jakarta := seedLoan(t, loans, 1000, entity.StateApproved, entity.Now(), withBorrower("3171234567890123"))
+	bandung := seedLoan(t, loans, 1000, entity.StateApproved, entity.Now(), withBorrower("3273000011112222"))
+	surabaya := seedLoan(t, loans, 1000, entity.StateApproved, entity.Now(), withBorrower("3578999988887777"))
+
+	// Alice invested twice in the same loan, which is still found once
+	seedInvestment(t, investments, bandung.ID, "alice_w@example.com", 100)
+	seedInvestment(t, investments, bandung.ID, "alice_w@example.com", 200)
+	seedInvestment(t, investments, surabaya.ID, "aliceXw@example.com", 100)
+	seedInvestment(t, investments, surabaya.ID, "budi@Partner.example.com", 100)
+
+	tests := []struct {
+		name   string
+		search string
+		want   []int64
+	}{
+		{"borrower ID fragment", "1234567", []int64{jakarta.ID}},
+		{"investor email fragment", "alice", []int64{bandung.ID, surabaya.ID}},
+		{"investor email regardless of case", "partner.EXAMPLE", []int64{surabaya.ID}},
+		{"borrower ID prefix", "3273", []int64{bandung.ID}},
+		{"underscore matches literally", "alice_w", []int64{bandung.ID}},
+		{"percent matches literally", "%", nil},
+		{"no match", "nobody", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := tt.search
+			got, err := loans.List(context.Background(), repository.LoanFilter{Search: &search})
+			if err != nil {
+				t.Fatalf("failed to search loans: %v", err)
+			}
+			ids := loanIDs(got)
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			if !equalIDs(ids, tt.want) {
+				t.Errorf("got loans %v, want %v", ids, tt.want)
+			}
+		})
+	}
+
+	// Results are paginated, newest first
+	search := "example.com"
+	limit, offset := 1, 1
+	got, err := loans.List(context.Background(), repository.LoanFilter{Search: &search, Limit: &limit, Offset: &offset})
+	if err != nil {
+		t.Fatalf("failed to search loans: %v", err)
+	}
+	if ids := loanIDs(got); !equalIDs(ids, []int64{bandung.ID}) {
+		t.Errorf("got second page %v, want [%d]", ids, bandung.ID)
+	}
+}
+
+func TestInvestmentListWithLoanStateFilters(t *testing.T) {
+	db := newTestDB(t)
+	loans := NewLoanRepository(db)
+	investments := NewInvestmentRepository(db)
+	ctx := context.Background()
+
+	approved := seedLoan(t, loans, 1000, entity.StateApproved, entity.Now())
+	invested := seedLoan(t, loans, 1000, entity.StateInvested, entity.Now())
+
+	// seedOn saves an investment made on the given day of March 2024
+	seedOn := func(loanID int64, investorEmail string, amount float64, day int) int64 {
+		investment := &entity.Investment{
+			LoanID:         loanID,
+			InvestorEmail:  investorEmail,
+			Amount:         entity.NewMoney(amount),
+			Currency:       entity.DefaultCurrency,
+			OriginalAmount: entity.NewMoney(amount),
+			CreatedAt:      time.Date(2024, 3, day, 12, 0, 0, 0, time.UTC),
+			Language:       entity.LanguageEnglish,
+		}
+		if err := investments.Create(ctx, investment); err != nil {
+			t.Fatalf("failed to create investment: %v", err)
+		}
+		return investment.ID
+	}
+	aliceApproved := seedOn(approved.ID, "alice@example.com", 100, 1)
+	budiApproved := seedOn(approved.ID, "budi@example.com", 250, 5)
+	aliceInvested := seedOn(invested.ID, "alice@example.com", 500, 10)
+	budiInvested := seedOn(invested.ID, "budi@example.com", 500, 20)
+
+	alice := "alice@example.com"
+	march5 := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	march10 := time.Date(2024, 3, 10, 23, 59, 59, 0, time.UTC)
+	min250, max500 := entity.NewMoney(250), entity.NewMoney(500)
+	limit, offset := 2, 1
+
+	tests := []struct {
+		name      string
+		filter    repository.InvestmentFilter
+		want      []int64
+		wantCount int
+	}{
+		{"no filter, oldest first", repository.InvestmentFilter{}, []int64{aliceApproved, budiApproved, aliceInvested, budiInvested}, 4},
+		{"investor", repository.InvestmentFilter{InvestorEmail: &alice}, []int64{aliceApproved, aliceInvested}, 2},
+		{"loan", repository.InvestmentFilter{LoanID: &invested.ID}, []int64{aliceInvested, budiInvested}, 2},
+		{"date range, inclusive", repository.InvestmentFilter{CreatedAfter: &march5, CreatedBefore: &march10}, []int64{budiApproved, aliceInvested}, 2},
+		{"amount range, inclusive", repository.InvestmentFilter{MinAmount: &min250, MaxAmount: &max500}, []int64{budiApproved, aliceInvested, budiInvested}, 3},
+		{"combined", repository.InvestmentFilter{InvestorEmail: &alice, MinAmount: &min250}, []int64{aliceInvested}, 1},
+		{"paginated, counting every match", repository.InvestmentFilter{Limit: &limit, Offset: &offset}, []int64{budiApproved, aliceInvested}, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := investments.ListWithLoanState(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("failed to list investments: %v", err)
+			}
+			ids := make([]int64, len(entries))
+			for i, entry := range entries {
+				ids[i] = entry.Investment.ID
+			}
+			if !equalIDs(ids, tt.want) {
+				t.Errorf("got investments %v, want %v", ids, tt.want)
+			}
+
+			count, err := investments.Count(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("failed to count investments: %v", err)
+			}
+			if count != tt.wantCount {
+				t.Errorf("got count %d, want %d", count, tt.wantCount)
+			}
+		})
+	}
+
+	// Each investment carries the state of its loan
+	entries, err := investments.ListWithLoanState(ctx, repository.InvestmentFilter{InvestorEmail: &alice})
+	if err != nil {
+		t.Fatalf("failed to list investments: %v", err)
+	}
+	if entries[0].LoanState != entity.StateApproved || entries[1].LoanState != entity.StateInvested {
+		t.Errorf("got loan states %s and %s, want approved and invested", entries[0].LoanState, entries[1].LoanState)
+	}
+}
+
+func TestGetBorrowerExposureAggregatesByState(t *testing.T) {
+	db := newTestDB(t)
+	loans := NewLoanRepository(db)
+	disbursements := NewDisbursementRepository(db)
+	ctx := context.Background()
+
+	const borrower = "3171234567890123"
+	disburse := func(loanID int64, amount float64) {
+		err := disbursements.Create(ctx, &entity.Disbursement{
+			LoanID:             loanID,
+			Amount:             entity.NewMoney(amount),
+			SignedAgreementDoc: "/files/signed_agreements/signed.pdf",
+			EmployeeID:         "EMP-DISBURSER",
+			DisbursementDate:   entity.Now(),
+			CreatedAt:          entity.Now(),
+		})
+		if err != nil {
+			t.Fatalf("failed to create disbursement: %v", err)
+		}
+	}
+
+	seedLoan(t, loans, 500, entity.StateProposed, entity.Now(), withBorrower(borrower))
+	seedLoan(t, loans, 1000, entity.StateApproved, entity.Now(), withBorrower(borrower))
+	seedLoan(t, loans, 2000, entity.StateApproved, entity.Now(), withBorrower(borrower))
+	partial := seedLoan(t, loans, 3000, entity.StatePartiallyDisbursed, entity.Now(), withBorrower(borrower))
+	disburse(partial.ID, 1000)
+	disbursed := seedLoan(t, loans, 4000, entity.StateDisbursed, entity.Now(), withBorrower(borrower))
+	disburse(disbursed.ID, 2500)
+	disburse(disbursed.ID, 1500)
+	seedLoan(t, loans, 800, entity.StateRejected, entity.Now(), withBorrower(borrower))
+
+	// Deleted loans and other borrowers' loans are not counted
+	deleted := seedLoan(t, loans, 9000, entity.StateApproved, entity.Now(), withBorrower(borrower))
+	if err := loans.SoftDelete(ctx, deleted); err != nil {
+		t.Fatalf("failed to delete loan: %v", err)
+	}
+	seedLoan(t, loans, 7000, entity.StateDisbursed, entity.Now(), withBorrower("3273000011112222"))
+
+	exposure, err := loans.GetBorrowerExposure(ctx, borrower)
+	if err != nil {
+		t.Fatalf("failed to get exposure: %v", err)
+	}
+
+	if exposure.ActiveLoans != 4 {
+		t.Errorf("got %d active loans, want 4 (approved, partially disbursed and disbursed)", exposure.ActiveLoans)
+	}
+	if want := entity.NewMoney(5000); exposure.DisbursedPrincipal != want {
+		t.Errorf("got disbursed principal %s, want %s", exposure.DisbursedPrincipal, want)
+	}
+
+	tests := []struct {
+		state     entity.LoanState
+		count     int
+		principal float64
+		disbursed float64
+	}{
+		{entity.StateProposed, 1, 500, 0},
+		{entity.StateApproved, 2, 3000, 0},
+		{entity.StateInvested, 0, 0, 0},
+		{entity.StatePartiallyDisbursed, 1, 3000, 1000},
+		{entity.StateDisbursed, 1, 4000, 4000},
+		{entity.StateRejected, 1, 800, 0},
+	}
+	for _, tt := range tests {
+		got := exposure.ByState[tt.state]
+		if got == nil {
+			t.Errorf("%s: got no entry, want one for every state", tt.state)
+			continue
+		}
+		if got.Count != tt.count || got.Principal != entity.NewMoney(tt.principal) || got.Disbursed != entity.NewMoney(tt.disbursed) {
+			t.Errorf("%s: got %d loans, principal %s, disbursed %s, want %d, %.2f, %.2f",
+				tt.state, got.Count, got.Principal, got.Disbursed, tt.count, tt.principal, tt.disbursed)
+		}
+	}
+}
+
+func TestInvestmentCreateOnARemovedLoan(t *testing.T) {
+	db := newTestDB(t)
+	loans := NewLoanRepository(db)
+	investments := NewInvestmentRepository(db)
+	ctx := context.Background()
+
+	// The loan goes away between being read and being invested in
+	loan := seedLoan(t, loans, 1000, entity.StateApproved, entity.Now())
+	if _, err := db.DB.ExecContext(ctx, "DELETE FROM loans WHERE id = ?", loan.ID); err != nil {
+		t.Fatalf("failed to delete loan: %v", err)
+	}
+
+	err := investments.Create(ctx, &entity.Investment{
+		LoanID:         loan.ID,
+		InvestorEmail:  "alice@example.com",
+		Amount:         entity.NewMoney(100),
+		Currency:       entity.DefaultCurrency,
+		OriginalAmount: entity.NewMoney(100),
+		CreatedAt:      entity.Now(),
+		Language:       entity.LanguageEnglish,
+	})
+	if !errors.Is(err, entity.ErrLoanNotFound) {
+		t.Fatalf("got error %v, want ErrLoanNotFound", err)
+	}
+}
+
+func TestInvestmentInsertError(t *testing.T) {
+	key := "retry-1"
+	driverErr := errors.New("disk I/O error")
+
+	tests := []struct {
+		name    string
+		withKey bool
+		err     error
+		want    error
+	}{
+		{"sqlite duplicate idempotency key", true, sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}, entity.ErrDuplicateIdempotencyKey},
+		{"sqlite unique violation without a key", false, sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}, entity.ErrConstraintViolation},
+		{"sqlite foreign key", false, sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintForeignKey}, entity.ErrLoanNotFound},
+		{"sqlite not null", false, sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintNotNull}, entity.ErrConstraintViolation},
+		{"postgres duplicate idempotency key", true, &pq.Error{Code: "23505"}, entity.ErrDuplicateIdempotencyKey},
+		{"postgres foreign key", false, &pq.Error{Code: "23503"}, entity.ErrLoanNotFound},
+		{"postgres check", false, &pq.Error{Code: "23514"}, entity.ErrConstraintViolation},
+		{"other errors pass through", false, driverErr, driverErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			investment := &entity.Investment{}
+			if tt.withKey {
+				investment.IdempotencyKey = &key
+			}
+
+			err := investmentInsertError(investment, tt.err)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+			if tt.want != driverErr && strings.Contains(err.Error(), "constraint failed") {
+				t.Errorf("got message %q leaking the driver error", err.Error())
+			}
+		})
+	}
+}
+
+func TestGetROISummaryWeightsEachLoanByPrincipal(t *testing.T) {
+	db := newTestDB(t)
+	loans := NewLoanRepository(db)
+	investments := NewInvestmentRepository(db)
+	ctx := context.Background()
+
+	// Many small investments must not give a loan more weight than one large one
+	small := seedLoan(t, loans, 1000, entity.StateInvested, entity.Now(), withROI(10))
+	for i := 0; i < 4; i++ {
+		seedInvestment(t, investments, small.ID, fmt.Sprintf("investor%d@example.com", i), 250)
+	}
+	large := seedLoan(t, loans, 3000, entity.StateDisbursed, entity.Now(), withROI(12))
+	seedInvestment(t, investments, large.ID, "alice@example.com", 3000)
+	// Force-invested short of its principal, it still weighs by its 2000 principal
+	forced := seedLoan(t, loans, 2000, entity.StatePartiallyDisbursed, entity.Now(), withROI(15))
+	seedInvestment(t, investments, forced.ID, "budi@example.com", 500)
+
+	// Loans still raising funds and deleted loans are left out
+	approved := seedLoan(t, loans, 5000, entity.StateApproved, entity.Now(), withROI(5))
+	seedInvestment(t, investments, approved.ID, "alice@example.com", 1000)
+	deleted := seedLoan(t, loans, 5000, entity.StateDisbursed, entity.Now(), withROI(5))
+	seedInvestment(t, investments, deleted.ID, "alice@example.com", 5000)
+	if err := loans.SoftDelete(ctx, deleted); err != nil {
+		t.Fatalf("failed to delete loan: %v", err)
+	}
+
+	summary, err := loans.GetROISummary(ctx)
+	if err != nil {
+		t.Fatalf("failed to get ROI summary: %v", err)
+	}
+
+	if summary.LoanCount != 3 {
+		t.Errorf("got %d loans, want 3", summary.LoanCount)
+	}
+	if want := entity.NewMoney(4500); summary.TotalInvested != want {
+		t.Errorf("got total invested %s, want %s", summary.TotalInvested, want)
+	}
+	// (1000*10 + 3000*12 + 2000*15) / (1000 + 3000 + 2000)
+	if want := 76000.0 / 6000; math.Abs(summary.WeightedAverageROI-want) > 1e-9 {
+		t.Errorf("got weighted average ROI %v, want %v", summary.WeightedAverageROI, want)
+	}
+	// 1000*10% + 3000*12% + 500*15%
+	if want := entity.NewMoney(535); summary.ProjectedReturns != want {
+		t.Errorf("got projected returns %s, want %s", summary.ProjectedReturns, want)
+	}
+}
+
+func TestGetROISummaryWithoutFundedLoans(t *testing.T) {
+	db := newTestDB(t)
+	loans := NewLoanRepository(db)
+	seedLoan(t, loans, 1000, entity.StateApproved, entity.Now())
+
+	summary, err := loans.GetROISummary(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get ROI summary: %v", err)
+	}
+	if *summary != (entity.ROISummary{}) {
+		t.Errorf("got %+v, want every figure 0", *summary)
+	}
+}
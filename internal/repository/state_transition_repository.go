@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	"amartha-andreas/internal/domain/repository"
+	"amartha-andreas/internal/infrastructure/database"
+	"context"
+	"strings"
+)
+
+// stateTransitionRepository implements repository.LoanStateTransitionRepository
+type stateTransitionRepository struct {
+	db *database.Database
+}
+
+// NewStateTransitionRepository creates a new loan state transition repository
+func NewStateTransitionRepository(db *database.Database) repository.LoanStateTransitionRepository {
+	return &stateTransitionRepository{db: db}
+}
+
+// Append records a new state transition
+func (r *stateTransitionRepository) Append(ctx context.Context, transition *entity.LoanStateTransition) error {
+	query := `
+		INSERT INTO loan_state_transitions (loan_id, from_state, to_state, actor, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	// Get the auto-generated ID
+	id, err := r.db.InsertReturningID(ctx, r.db.Conn(ctx), query,
+		transition.LoanID, transition.FromState, transition.ToState,
+		transition.Actor, transition.Note, transition.CreatedAt.UTC())
+	if err != nil {
+		return err
+	}
+	transition.ID = id
+
+	return nil
+}
+
+// ListByLoanID retrieves a loan's state transitions matching the filter, newest
+// first unless the filter asks for the order they happened
+func (r *stateTransitionRepository) ListByLoanID(ctx context.Context, loanID int64, filter repository.StateTransitionFilter) ([]*entity.LoanStateTransition, error) {
+	where, args := stateTransitionFilterConditions(loanID, filter)
+	query := "SELECT id, loan_id, from_state, to_state, actor, note, created_at FROM loan_state_transitions" + where
+
+	if filter.Ascending {
+		query += " ORDER BY created_at, id"
+	} else {
+		query += " ORDER BY created_at DESC, id DESC"
+	}
+
+	// Add pagination
+	if filter.Limit != nil {
+		query += " LIMIT ?"
+		args = append(args, *filter.Limit)
+	} else if filter.Offset != nil && r.db.Driver == database.DriverSQLite {
+		// SQLite only takes an OFFSET after a LIMIT, where -1 means no limit
+		query += " LIMIT -1"
+	}
+
+	if filter.Offset != nil {
+		query += " OFFSET ?"
+		args = append(args, *filter.Offset)
+	}
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []*entity.LoanStateTransition
+	for rows.Next() {
+		transition := &entity.LoanStateTransition{}
+		err := rows.Scan(&transition.ID, &transition.LoanID, &transition.FromState,
+			&transition.ToState, &transition.Actor, &transition.Note, &transition.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		transition.CreatedAt = transition.CreatedAt.UTC()
+		transitions = append(transitions, transition)
+	}
+
+	return transitions, rows.Err()
+}
+
+// CountByLoanID counts a loan's state transitions matching the filter, ignoring its pagination
+func (r *stateTransitionRepository) CountByLoanID(ctx context.Context, loanID int64, filter repository.StateTransitionFilter) (int, error) {
+	where, args := stateTransitionFilterConditions(loanID, filter)
+	query := "SELECT COUNT(*) FROM loan_state_transitions" + where
+
+	var count int
+	err := r.db.Conn(ctx).QueryRowContext(ctx, r.db.Rebind(query), args...).Scan(&count)
+	return count, err
+}
+
+// stateTransitionFilterConditions builds the WHERE clause shared by ListByLoanID and CountByLoanID
+func stateTransitionFilterConditions(loanID int64, filter repository.StateTransitionFilter) (string, []interface{}) {
+	conditions := []string{"loan_id = ?"}
+	args := []interface{}{loanID}
+
+	if filter.FromState != nil {
+		conditions = append(conditions, "from_state = ?")
+		args = append(args, *filter.FromState)
+	}
+
+	if filter.ToState != nil {
+		conditions = append(conditions, "to_state = ?")
+		args = append(args, *filter.ToState)
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
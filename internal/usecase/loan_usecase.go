@@ -5,85 +5,311 @@ import (
 	"amartha-andreas/internal/domain/repository"
 	"amartha-andreas/internal/domain/service"
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 )
 
 // LoanUsecase defines the interface for loan business logic
 type LoanUsecase interface {
-	CreateLoan(ctx context.Context, params entity.CreateLoanParams) (*entity.Loan, error)
+	CreateLoan(ctx context.Context, params entity.CreateLoanParams) (*entity.Loan, []string, error)
+	ValidateLoan(ctx context.Context, params entity.CreateLoanParams) (*entity.Loan, []string, error)
+	UpdateLoan(ctx context.Context, loanID int64, params entity.UpdateLoanParams) (*entity.Loan, error)
 	ApproveLoan(ctx context.Context, loanID int64, params entity.ApproveLoanParams) (*entity.Loan, error)
-	InvestInLoan(ctx context.Context, loanID int64, params entity.InvestLoanParams) (*entity.Investment, error)
+	UpdateROI(ctx context.Context, loanID int64, roi float64, employeeID string) (*entity.Loan, error)
+	ReplaceApprovalProof(ctx context.Context, loanID int64, proofPicture string) (*entity.Loan, string, error)
+	RejectLoan(ctx context.Context, loanID int64, params entity.RejectLoanParams) (*entity.Loan, error)
+	CancelLoan(ctx context.Context, loanID int64, params entity.CancelLoanParams) (*entity.Loan, error)
+	ExpireLoan(ctx context.Context, loanID int64, employeeID string) (*entity.Loan, error)
+	ExpireOverdueLoans(ctx context.Context) (int, error)
+	ActivateScheduledApprovals(ctx context.Context) (int, error)
+	InvestInLoan(ctx context.Context, loanID int64, params entity.InvestLoanParams) (*entity.Investment, bool, error)
+	BulkInvest(ctx context.Context, params entity.BulkInvestParams) ([]*BulkInvestmentResult, error)
+	WithdrawInvestment(ctx context.Context, loanID, investmentID int64) (*LoanSummary, error)
+	ForceInvested(ctx context.Context, loanID int64, employeeID string) (*entity.Loan, error)
+	ResendInvestedNotification(ctx context.Context, loanID int64) (int, error)
 	DisburseLoan(ctx context.Context, loanID int64, params entity.DisburseLoanParams) (*entity.Loan, error)
-	GetLoan(ctx context.Context, loanID int64) (*LoanSummary, error)
+	DeleteLoan(ctx context.Context, loanID int64) error
+	GetLoan(ctx context.Context, loanID int64, includeDeleted bool) (*LoanSummary, error)
+	GetInvestorReturns(ctx context.Context, loanID int64) (*LoanReturns, error)
+	GetLoanFunding(ctx context.Context, loanID int64) (*entity.LoanFunding, error)
+	GetInvestmentReceipt(ctx context.Context, investmentID int64) ([]byte, error)
+	ListAllInvestments(ctx context.Context, filter repository.InvestmentFilter) (*InvestmentLedger, error)
+	GetLoanHistory(ctx context.Context, loanID int64, filter repository.StateTransitionFilter) (*LoanHistoryPage, error)
+	GetLoanActions(ctx context.Context, loanID int64) (*entity.Loan, []entity.LoanAction, error)
+	ListDisbursements(ctx context.Context, loanID int64) ([]*entity.Disbursement, error)
+	ListInvestments(ctx context.Context, loanID int64, filter repository.InvestmentFilter) (*InvestmentPage, error)
+	GetInvestorPortfolio(ctx context.Context, investorEmail string, filter repository.InvestmentFilter) (*InvestorPortfolio, error)
 	ListLoans(ctx context.Context, filter repository.LoanFilter) ([]*entity.Loan, error)
+	GetLoansByIDs(ctx context.Context, ids []int64) ([]*entity.Loan, error)
+	ExportLoans(ctx context.Context, filter repository.LoanFilter, fn func(*entity.Loan) error) error
+	GetStats(ctx context.Context, filter repository.StatsFilter) (*entity.LoanStats, error)
+	GetBorrowerExposure(ctx context.Context, borrowerIDNumber string) (*entity.BorrowerExposure, error)
+	GetROISummary(ctx context.Context) (*entity.ROISummary, error)
 }
 
 // loanUsecase implements LoanUsecase interface
 type loanUsecase struct {
-	loanRepo       repository.LoanRepository
-	investmentRepo repository.InvestmentRepository
-	emailService   service.EmailService
+	loanRepo            repository.LoanRepository
+	investmentRepo      repository.InvestmentRepository
+	stateTransitionRepo repository.LoanStateTransitionRepository
+	disbursementRepo    repository.DisbursementRepository
+	loanDocumentRepo    repository.LoanDocumentRepository
+	loanApprovalRepo    repository.LoanApprovalRepository
+	transactor          repository.Transactor
+	emailService        service.EmailService
+	webhookNotifier     service.WebhookNotifier
+	fxConverter         service.FXConverter
+	receiptGenerator    service.ReceiptGenerator
+	loanMetrics         service.LoanMetrics
+	logger              *slog.Logger
+	config              LoanUsecaseConfig
+}
+
+// LoanUsecaseConfig holds the business settings of the loan usecase; the zero
+// value of each setting turns it off
+type LoanUsecaseConfig struct {
+	FundingWindow         time.Duration // How long approved loans may take to be fully funded, no deadline when zero
+	StrictAmounts         bool          // Reject investment amounts with more than two decimals instead of rounding them
+	AgreementDomains      []string      // Domains agreement letter links must point to, any domain when empty
+	DualApprovalThreshold entity.Money  // Principal above which loans need two approvers, disabled when zero
+	WarningThresholds     entity.LoanWarningThresholds
 }
 
 // NewLoanUsecase creates a new loan usecase
-func NewLoanUsecase(loanRepo repository.LoanRepository, investmentRepo repository.InvestmentRepository, emailService service.EmailService) LoanUsecase {
+func NewLoanUsecase(loanRepo repository.LoanRepository, investmentRepo repository.InvestmentRepository, stateTransitionRepo repository.LoanStateTransitionRepository, disbursementRepo repository.DisbursementRepository, loanDocumentRepo repository.LoanDocumentRepository, loanApprovalRepo repository.LoanApprovalRepository, transactor repository.Transactor, emailService service.EmailService, webhookNotifier service.WebhookNotifier, fxConverter service.FXConverter, receiptGenerator service.ReceiptGenerator, loanMetrics service.LoanMetrics, logger *slog.Logger, config LoanUsecaseConfig) LoanUsecase {
 	return &loanUsecase{
-		loanRepo:       loanRepo,
-		investmentRepo: investmentRepo,
-		emailService:   emailService,
+		loanRepo:            loanRepo,
+		investmentRepo:      investmentRepo,
+		stateTransitionRepo: stateTransitionRepo,
+		disbursementRepo:    disbursementRepo,
+		loanDocumentRepo:    loanDocumentRepo,
+		loanApprovalRepo:    loanApprovalRepo,
+		transactor:          transactor,
+		emailService:        emailService,
+		webhookNotifier:     webhookNotifier,
+		fxConverter:         fxConverter,
+		receiptGenerator:    receiptGenerator,
+		loanMetrics:         loanMetrics,
+		logger:              logger,
+		config:              config,
 	}
 }
 
 // LoanSummary represents a complete loan summary with investments
 type LoanSummary struct {
-	Loan            *entity.Loan         `json:"loan"`
-	TotalInvested   float64              `json:"total_invested"`
-	RemainingAmount float64              `json:"remaining_amount"`
-	InvestmentCount int                  `json:"investment_count"`
-	Investments     []*entity.Investment `json:"investments"`
+	Loan            *entity.Loan           `json:"loan"`
+	TotalInvested   entity.Money           `json:"total_invested"`
+	RemainingAmount entity.Money           `json:"remaining_amount"`
+	InvestmentCount int                    `json:"investment_count"`
+	Investments     []*entity.Investment   `json:"investments"`
+	Documents       []*entity.LoanDocument `json:"documents"` // Signed documents of every disbursement tranche
+	Approvals       []*entity.LoanApproval `json:"approvals"` // Approvals given so far, in the order they were given
+}
+
+// InvestmentPage represents one page of a loan's investments
+type InvestmentPage struct {
+	Investments []*entity.Investment `json:"investments"`
+	Total       int                  `json:"total"`
+	NextAfterID *int64               `json:"next_after_id,omitempty"` // Set when keyset pagination has a next page
+}
+
+// LoanHistoryPage represents one page of a loan's state transitions
+type LoanHistoryPage struct {
+	Transitions []*entity.LoanStateTransition `json:"transitions"`
+	Total       int                           `json:"total"` // Transitions matching the filter across all pages
+}
+
+// InvestorPortfolio represents one page of an investor's investments across all
+// loans, the loans of that page and the investor's totals over every investment
+type InvestorPortfolio struct {
+	InvestorEmail string
+	Page          *InvestmentPage
+	Loans         []*entity.Loan // Loans of the investments on the page, in order of first appearance
+	Totals        *entity.InvestorTotals
+}
+
+// InvestmentLedger represents one page of the investments across all loans,
+// with the state of each investment's loan
+type InvestmentLedger struct {
+	Page       *InvestmentPage
+	LoanStates map[int64]entity.LoanState // By investment ID
+}
+
+// ErrBatchRolledBack marks a valid bulk investment item that was not saved
+// because another item of the batch failed
+var ErrBatchRolledBack = errors.New("not saved because another investment in the batch failed")
+
+// BulkInvestmentResult represents the outcome of one item of a bulk investment
+type BulkInvestmentResult struct {
+	LoanID     int64
+	Investment *entity.Investment // Set when the item was saved
+	Err        error              // Why the item was not saved
+}
+
+// InvestorReturn represents one investor's combined position in a loan
+type InvestorReturn struct {
+	InvestorEmail  string       `json:"investor_email"`
+	AmountInvested entity.Money `json:"amount_invested"`
+	SharePercent   float64      `json:"share_percent"`
+	ExpectedReturn entity.Money `json:"expected_return"`
+}
+
+// LoanReturns represents the expected returns of every investor in a loan
+type LoanReturns struct {
+	LoanID    int64             `json:"loan_id"`
+	ROI       float64           `json:"roi"`
+	Investors []*InvestorReturn `json:"investors"`
+}
+
+// CreateLoan creates a new loan with proposed state. The warnings list the
+// soft limits the loan exceeds; they don't stop it from being created.
+func (uc *loanUsecase) CreateLoan(ctx context.Context, params entity.CreateLoanParams) (*entity.Loan, []string, error) {
+	loan, err := uc.newProposedLoan(params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := uc.loanRepo.Create(ctx, loan); err != nil {
+		return nil, nil, fmt.Errorf("failed to create loan: %w", err)
+	}
+	uc.loanMetrics.LoanCreated()
+
+	return loan, uc.collectWarnings(loan), nil
+}
+
+// ValidateLoan runs the checks of CreateLoan and returns the loan it would
+// create with its warnings, without saving it
+func (uc *loanUsecase) ValidateLoan(ctx context.Context, params entity.CreateLoanParams) (*entity.Loan, []string, error) {
+	loan, err := uc.newProposedLoan(params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return loan, uc.collectWarnings(loan), nil
+}
+
+// collectWarnings lists the soft limits of the warning thresholds a new loan exceeds
+func (uc *loanUsecase) collectWarnings(loan *entity.Loan) []string {
+	var warnings []string
+	if limit := uc.config.WarningThresholds.Rate; limit > 0 && loan.Rate > limit {
+		warnings = append(warnings, fmt.Sprintf("rate %g%% is above the usual %g%%", loan.Rate, limit))
+	}
+	if limit := uc.config.WarningThresholds.Principal; limit > 0 && loan.PrincipalAmount > limit {
+		warnings = append(warnings, fmt.Sprintf("principal %s %s is above the usual %s", loan.PrincipalAmount, loan.Currency, limit))
+	}
+	return warnings
 }
 
-// CreateLoan creates a new loan with proposed state
-func (uc *loanUsecase) CreateLoan(ctx context.Context, params entity.CreateLoanParams) (*entity.Loan, error) {
+// newProposedLoan validates the params and builds the proposed loan they describe
+func (uc *loanUsecase) newProposedLoan(params entity.CreateLoanParams) (*entity.Loan, error) {
 	// Validate borrower ID number
-	if err := entity.ValidateBorrowerIDNumber(params.BorrowerIDNumber); err != nil {
+	if err := entity.ValidateBorrowerID(params.BorrowerIDNumber); err != nil {
+		return nil, err
+	}
+
+	// Validate investor ROI against borrower rate
+	if err := entity.ValidateRates(params.Rate, params.ROI); err != nil {
+		return nil, err
+	}
+
+	// Validate loan term
+	if err := entity.ValidateTerm(params.TermWeeks); err != nil {
+		return nil, err
+	}
+
+	// Validate optional borrower email
+	if err := entity.ValidateBorrowerEmail(params.BorrowerEmail); err != nil {
+		return nil, err
+	}
+
+	// Validate agreement letter link
+	if err := entity.ValidateAgreementLetterLink(params.AgreementLetterLink, uc.config.AgreementDomains); err != nil {
+		return nil, err
+	}
+
+	// Validate optional currency
+	currency := params.Currency
+	if currency == "" {
+		currency = entity.DefaultCurrency
+	}
+	if err := entity.ValidateCurrency(currency); err != nil {
+		return nil, err
+	}
+
+	// Validate optional per-investment limits
+	if err := entity.ValidateInvestmentLimits(params.PrincipalAmount, params.MinInvestment, params.MaxInvestment, params.MaxPerInvestor, params.InvestmentIncrement); err != nil {
 		return nil, err
 	}
 
+	now := entity.Now()
 	loan := &entity.Loan{
 		// ID will be auto-generated by database
 		BorrowerIDNumber:    params.BorrowerIDNumber,
 		PrincipalAmount:     params.PrincipalAmount,
+		Currency:            currency,
 		Rate:                params.Rate,
 		ROI:                 params.ROI,
+		TermWeeks:           params.TermWeeks,
+		MinInvestment:       params.MinInvestment,
+		MaxInvestment:       params.MaxInvestment,
+		MaxPerInvestor:      params.MaxPerInvestor,
+		InvestmentIncrement: params.InvestmentIncrement,
 		State:               entity.StateProposed,
 		AgreementLetterLink: params.AgreementLetterLink,
-		CreatedAt:           time.Now(),
-		UpdatedAt:           time.Now(),
+		CreatedAt:           now,
+		UpdatedAt:           now,
 	}
-
-	if err := uc.loanRepo.Create(ctx, loan); err != nil {
-		return nil, fmt.Errorf("failed to create loan: %w", err)
+	if params.BorrowerEmail != "" {
+		loan.BorrowerEmail = &params.BorrowerEmail
+	}
+	loan.AllowMultipleInvestmentsPerInvestor = true
+	if params.AllowMultipleInvestmentsPerInvestor != nil {
+		loan.AllowMultipleInvestmentsPerInvestor = *params.AllowMultipleInvestmentsPerInvestor
 	}
+	loan.RequiredApprovals = entity.RequiredApprovalsFor(loan.PrincipalAmount, uc.config.DualApprovalThreshold)
 
 	return loan, nil
 }
 
-// ApproveLoan approves a loan and moves it to approved state
-func (uc *loanUsecase) ApproveLoan(ctx context.Context, loanID int64, params entity.ApproveLoanParams) (*entity.Loan, error) {
+// UpdateLoan edits the details of a loan that is still proposed
+func (uc *loanUsecase) UpdateLoan(ctx context.Context, loanID int64, params entity.UpdateLoanParams) (*entity.Loan, error) {
 	// Get existing loan
 	loan, err := uc.loanRepo.GetByID(ctx, loanID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get loan: %w", err)
 	}
 
+	// Check state before validating so edits to approved loans report the state error
+	if err := loan.CanBeUpdated(); err != nil {
+		return nil, err
+	}
+
+	// Re-run the creation-time validations against the new values
+	if err := entity.ValidateBorrowerID(params.BorrowerIDNumber); err != nil {
+		return nil, err
+	}
+	if err := entity.ValidateRates(params.Rate, params.ROI); err != nil {
+		return nil, err
+	}
+	if err := entity.ValidateTerm(params.TermWeeks); err != nil {
+		return nil, err
+	}
+	if err := entity.ValidateAgreementLetterLink(params.AgreementLetterLink, uc.config.AgreementDomains); err != nil {
+		return nil, err
+	}
+	if err := entity.ValidateInvestmentLimits(params.PrincipalAmount, loan.MinInvestment, loan.MaxInvestment, loan.MaxPerInvestor, loan.InvestmentIncrement); err != nil {
+		return nil, err
+	}
+
 	// Apply business rules
-	if err := loan.Approve(params.ProofPicture, params.EmployeeID, params.ApprovalDate); err != nil {
+	if err := loan.UpdateDetails(params); err != nil {
 		return nil, err
 	}
+	loan.RequiredApprovals = entity.RequiredApprovalsFor(loan.PrincipalAmount, uc.config.DualApprovalThreshold)
 
-	// Update loan
 	if err := uc.loanRepo.Update(ctx, loan); err != nil {
 		return nil, fmt.Errorf("failed to update loan: %w", err)
 	}
@@ -91,63 +317,165 @@ func (uc *loanUsecase) ApproveLoan(ctx context.Context, loanID int64, params ent
 	return loan, nil
 }
 
-// InvestInLoan allows investors to invest in an approved loan
-func (uc *loanUsecase) InvestInLoan(ctx context.Context, loanID int64, params entity.InvestLoanParams) (*entity.Investment, error) {
+// ApproveLoan records an employee's approval of a loan. The loan moves to
+// approved state once it has the approvals it requires, until then it waits
+// in pending_approval for another employee.
+func (uc *loanUsecase) ApproveLoan(ctx context.Context, loanID int64, params entity.ApproveLoanParams) (*entity.Loan, error) {
 	// Get existing loan
 	loan, err := uc.loanRepo.GetByID(ctx, loanID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get loan: %w", err)
 	}
 
-	// Check if loan can receive investment
-	if err := loan.CanReceiveInvestment(); err != nil {
+	// Every approval must come from a different employee
+	approvals, err := uc.loanApprovalRepo.ListByLoanID(ctx, loanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan approvals: %w", err)
+	}
+	if err := loan.ValidateApprover(approvals, params.EmployeeID); err != nil {
 		return nil, err
 	}
+	approval := &entity.LoanApproval{
+		LoanID:       loanID,
+		EmployeeID:   params.EmployeeID,
+		ProofPicture: params.ProofPicture,
+		ApprovalDate: params.ApprovalDate,
+		CreatedAt:    entity.Now(),
+	}
 
-	// Get current total investment
-	totalInvestment, err := uc.investmentRepo.GetTotalByLoanID(ctx, loanID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get total investment: %w", err)
+	fromState := loan.State
+	if !loan.IsFinalApproval(len(approvals)) {
+		if params.EffectiveAt != nil {
+			return nil, entity.NewDomainError(entity.ErrValidation, "effective_at can only be set on the final approval of a loan")
+		}
+		if err := loan.AwaitFurtherApproval(params.ApprovalDate); err != nil {
+			return nil, err
+		}
+		if err := uc.saveApproval(ctx, loan, fromState, approval); err != nil {
+			return nil, fmt.Errorf("failed to update loan: %w", err)
+		}
+		if loan.State != fromState {
+			uc.notifyStateChange(ctx, loan, fromState)
+		}
+		return loan, nil
 	}
 
-	// Validate investment amount
-	if err := loan.ValidateInvestmentAmount(params.Amount, totalInvestment); err != nil {
-		return nil, err
+	// A future effective time only stages the approval, the loan keeps its state
+	// until ActivateScheduledApprovals picks it up
+	if params.EffectiveAt != nil && params.EffectiveAt.After(entity.Now()) {
+		if err := loan.ScheduleApproval(params.ProofPicture, params.EmployeeID, params.ApprovalDate, *params.EffectiveAt); err != nil {
+			return nil, err
+		}
+		if err := uc.saveApproval(ctx, loan, fromState, approval); err != nil {
+			return nil, fmt.Errorf("failed to update loan: %w", err)
+		}
+		return loan, nil
 	}
 
-	// Create investment
-	investment := &entity.Investment{
-		// ID will be auto-generated by database
-		LoanID:        loanID,
-		InvestorEmail: params.InvestorEmail,
-		Amount:        params.Amount,
-		CreatedAt:     time.Now(),
+	// Apply business rules
+	if err := loan.Approve(params.ProofPicture, params.EmployeeID, params.ApprovalDate, uc.config.FundingWindow); err != nil {
+		return nil, err
 	}
 
-	if err := uc.investmentRepo.Create(ctx, investment); err != nil {
-		return nil, fmt.Errorf("failed to create investment: %w", err)
+	// Update loan, record the approval and the state transition
+	if err := uc.saveApproval(ctx, loan, fromState, approval); err != nil {
+		return nil, fmt.Errorf("failed to update loan: %w", err)
 	}
+	uc.notifyStateChange(ctx, loan, fromState)
+	uc.sendLoanApprovedNotification(ctx, loan)
 
-	// Check if loan is now fully invested
-	newTotalInvestment := totalInvestment + params.Amount
-	if loan.IsFullyInvested(newTotalInvestment) {
-		loan.MarkAsInvested()
+	return loan, nil
+}
+
+// saveApproval atomically records an approval with the loan update it caused,
+// appending a state transition when the loan changed state
+func (uc *loanUsecase) saveApproval(ctx context.Context, loan *entity.Loan, fromState entity.LoanState, approval *entity.LoanApproval) error {
+	return uc.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := uc.loanApprovalRepo.Create(ctx, approval); err != nil {
+			return err
+		}
 		if err := uc.loanRepo.Update(ctx, loan); err != nil {
-			return nil, fmt.Errorf("failed to update loan state to invested: %w", err)
+			return err
+		}
+		if loan.State == fromState {
+			return nil
 		}
+		return uc.stateTransitionRepo.Append(ctx, entity.NewLoanStateTransition(loan, fromState, approval.EmployeeID))
+	})
+}
 
-		// Send email to all investors with agreement letter
-		if err := uc.sendLoanFullyInvestedNotification(ctx, loanID, loan); err != nil {
-			// Log error but don't fail the transaction
-			fmt.Printf("Failed to send loan fully invested notification: %v\n", err)
+// ActivateScheduledApprovals approves the proposed and pending_approval loans
+// whose scheduled approval is due, returning how many were approved. A loan
+// that fails to be approved is left for the next run.
+func (uc *loanUsecase) ActivateScheduledApprovals(ctx context.Context) (int, error) {
+	now := entity.Now()
+	var loans []*entity.Loan
+	for _, state := range []entity.LoanState{entity.StateProposed, entity.StatePendingApproval} {
+		due, err := uc.loanRepo.List(ctx, repository.LoanFilter{State: &state, ApprovalEffectiveBefore: &now})
+		if err != nil {
+			return 0, fmt.Errorf("failed to list scheduled approvals: %w", err)
 		}
+		loans = append(loans, due...)
 	}
 
-	return investment, nil
+	activated := 0
+	var errs []error
+	for _, loan := range loans {
+		fromState := loan.State
+		if err := loan.ActivateScheduledApproval(now, uc.config.FundingWindow); err != nil {
+			errs = append(errs, fmt.Errorf("loan %d: %w", loan.ID, err))
+			continue
+		}
+		if err := uc.updateLoanState(ctx, loan, fromState, entity.SystemActor); err != nil {
+			errs = append(errs, fmt.Errorf("loan %d: %w", loan.ID, err))
+			continue
+		}
+		uc.notifyStateChange(ctx, loan, fromState)
+		uc.sendLoanApprovedNotification(ctx, loan)
+		activated++
+	}
+
+	return activated, errors.Join(errs...)
 }
 
-// DisburseLoan disburses a fully invested loan
-func (uc *loanUsecase) DisburseLoan(ctx context.Context, loanID int64, params entity.DisburseLoanParams) (*entity.Loan, error) {
+// sendLoanApprovedNotification notifies about an approval that took effect.
+// Failures are only logged, the approval is not rolled back.
+func (uc *loanUsecase) sendLoanApprovedNotification(ctx context.Context, loan *entity.Loan) {
+	emailRequest := service.SendLoanApprovedNotificationRequest{
+		LoanID:           loan.ID,
+		BorrowerIDNumber: loan.BorrowerIDNumber,
+		EmployeeID:       *loan.ApprovalEmployeeID,
+		ApprovalDate:     *loan.ApprovalDate,
+	}
+	if err := uc.emailService.SendLoanApprovedNotification(ctx, emailRequest); err != nil {
+		uc.logger.ErrorContext(ctx, "failed to send loan approved notification", "loan_id", loan.ID, "error", err)
+	}
+}
+
+// ReplaceApprovalProof replaces the approval proof picture of an approved loan.
+// The previous proof picture is returned so the caller can remove the old file.
+func (uc *loanUsecase) ReplaceApprovalProof(ctx context.Context, loanID int64, proofPicture string) (*entity.Loan, string, error) {
+	// Get existing loan
+	loan, err := uc.loanRepo.GetByID(ctx, loanID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	// Apply business rules
+	previous, err := loan.ReplaceApprovalProof(proofPicture)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := uc.loanRepo.Update(ctx, loan); err != nil {
+		return nil, "", fmt.Errorf("failed to update loan: %w", err)
+	}
+
+	return loan, previous, nil
+}
+
+// RejectLoan rejects a proposed loan and moves it to rejected state
+func (uc *loanUsecase) RejectLoan(ctx context.Context, loanID int64, params entity.RejectLoanParams) (*entity.Loan, error) {
 	// Get existing loan
 	loan, err := uc.loanRepo.GetByID(ctx, loanID)
 	if err != nil {
@@ -155,87 +483,1174 @@ func (uc *loanUsecase) DisburseLoan(ctx context.Context, loanID int64, params en
 	}
 
 	// Apply business rules
-	if err := loan.Disburse(params.SignedAgreementDoc, params.EmployeeID, params.DisbursementDate); err != nil {
+	fromState := loan.State
+	if err := loan.Reject(params.EmployeeID, params.Reason, params.RejectionDate); err != nil {
 		return nil, err
 	}
 
-	// Update loan
-	if err := uc.loanRepo.Update(ctx, loan); err != nil {
+	// Update loan and record the state transition
+	if err := uc.updateLoanState(ctx, loan, fromState, params.EmployeeID); err != nil {
 		return nil, fmt.Errorf("failed to update loan: %w", err)
 	}
+	uc.notifyStateChange(ctx, loan, fromState)
 
 	return loan, nil
 }
 
-// GetLoan retrieves a loan with its investment summary
-func (uc *loanUsecase) GetLoan(ctx context.Context, loanID int64) (*LoanSummary, error) {
-	// Get loan
+// CancelLoan cancels a loan that has not yet been fully invested
+func (uc *loanUsecase) CancelLoan(ctx context.Context, loanID int64, params entity.CancelLoanParams) (*entity.Loan, error) {
+	var loan *entity.Loan
+	var fromState entity.LoanState
+
+	// Lock the loan so no investment can slip in between the check and the cancellation
+	err := uc.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		loan, err = uc.loanRepo.GetByIDForUpdate(ctx, loanID)
+		if err != nil {
+			return fmt.Errorf("failed to get loan: %w", err)
+		}
+
+		// Refuse to cancel a loan with investments unless explicitly forced.
+		// Forced cancellations keep the investments for a later refund flow.
+		totalInvestment, err := uc.investmentRepo.GetTotalByLoanID(ctx, loanID)
+		if err != nil {
+			return fmt.Errorf("failed to get total investment: %w", err)
+		}
+		if totalInvestment > 0 && !params.Force {
+			return entity.NewDomainError(entity.ErrInvalidState, "loan already has investments, set force=true to cancel anyway")
+		}
+
+		// Apply business rules
+		fromState = loan.State
+		if err := loan.Cancel(params.EmployeeID, params.Reason); err != nil {
+			return err
+		}
+
+		// Update loan and record the state transition
+		if err := uc.updateLoanState(ctx, loan, fromState, params.EmployeeID); err != nil {
+			return fmt.Errorf("failed to update loan: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	uc.notifyStateChange(ctx, loan, fromState)
+
+	return loan, nil
+}
+
+// UpdateROI changes the ROI of an approved loan nobody has invested in yet and
+// records the change, with the old and new ROI, in the audit log
+func (uc *loanUsecase) UpdateROI(ctx context.Context, loanID int64, roi float64, employeeID string) (*entity.Loan, error) {
+	var loan *entity.Loan
+
+	// Lock the loan so no investment can slip in between the check and the write
+	err := uc.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		loan, err = uc.loanRepo.GetByIDForUpdate(ctx, loanID)
+		if err != nil {
+			return fmt.Errorf("failed to get loan: %w", err)
+		}
+
+		totalInvestment, err := uc.investmentRepo.GetTotalByLoanID(ctx, loanID)
+		if err != nil {
+			return fmt.Errorf("failed to get total investment: %w", err)
+		}
+		if totalInvestment > 0 {
+			return entity.NewDomainError(entity.ErrInvalidState, "ROI cannot be changed once the loan has investments")
+		}
+
+		// Apply business rules
+		fromROI := loan.ROI
+		if err := loan.UpdateROI(roi); err != nil {
+			return err
+		}
+
+		if err := uc.loanRepo.Update(ctx, loan); err != nil {
+			return fmt.Errorf("failed to update loan: %w", err)
+		}
+		transition := entity.NewLoanStateTransition(loan, loan.State, employeeID)
+		transition.Note = fmt.Sprintf("roi changed from %.2f to %.2f", fromROI, roi)
+		if err := uc.stateTransitionRepo.Append(ctx, transition); err != nil {
+			return fmt.Errorf("failed to record ROI change: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return loan, nil
+}
+
+// ExpireLoan expires an approved loan right away, regardless of its funding deadline
+func (uc *loanUsecase) ExpireLoan(ctx context.Context, loanID int64, employeeID string) (*entity.Loan, error) {
+	// Get existing loan
 	loan, err := uc.loanRepo.GetByID(ctx, loanID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get loan: %w", err)
 	}
 
-	// Get investments
-	investments, err := uc.investmentRepo.GetByLoanID(ctx, loanID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get investments: %w", err)
+	// Apply business rules
+	fromState := loan.State
+	if err := loan.Expire(); err != nil {
+		return nil, err
 	}
 
-	// Calculate totals
-	var totalInvested float64
-	for _, inv := range investments {
-		totalInvested += inv.Amount
+	// Update loan and record the state transition
+	if err := uc.updateLoanState(ctx, loan, fromState, employeeID); err != nil {
+		return nil, fmt.Errorf("failed to update loan: %w", err)
 	}
+	uc.notifyStateChange(ctx, loan, fromState)
 
-	summary := &LoanSummary{
-		Loan:            loan,
-		TotalInvested:   totalInvested,
-		RemainingAmount: loan.GetRemainingAmount(totalInvested),
-		InvestmentCount: len(investments),
-		Investments:     investments,
+	return loan, nil
+}
+
+// ExpireOverdueLoans expires the approved loans past their funding deadline,
+// returning how many were expired. A loan that fails to expire is left for the
+// next run.
+func (uc *loanUsecase) ExpireOverdueLoans(ctx context.Context) (int, error) {
+	state := entity.StateApproved
+	now := entity.Now()
+	loans, err := uc.loanRepo.List(ctx, repository.LoanFilter{State: &state, FundingDeadlineBefore: &now})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list overdue loans: %w", err)
 	}
 
-	return summary, nil
+	expired := 0
+	var errs []error
+	for _, loan := range loans {
+		fromState := loan.State
+		if err := loan.Expire(); err != nil {
+			errs = append(errs, fmt.Errorf("loan %d: %w", loan.ID, err))
+			continue
+		}
+		if err := uc.updateLoanState(ctx, loan, fromState, entity.SystemActor); err != nil {
+			errs = append(errs, fmt.Errorf("loan %d: %w", loan.ID, err))
+			continue
+		}
+		uc.notifyStateChange(ctx, loan, fromState)
+		expired++
+	}
+
+	return expired, errors.Join(errs...)
 }
 
-// ListLoans retrieves loans with optional filtering
-func (uc *loanUsecase) ListLoans(ctx context.Context, filter repository.LoanFilter) ([]*entity.Loan, error) {
-	loans, err := uc.loanRepo.List(ctx, filter)
+// InvestInLoan allows investors to invest in an approved loan.
+// The returned flag is true when an earlier investment was replayed for a repeated idempotency key.
+func (uc *loanUsecase) InvestInLoan(ctx context.Context, loanID int64, params entity.InvestLoanParams) (*entity.Investment, bool, error) {
+	// Replay the original investment for a repeated idempotency key
+	if params.IdempotencyKey != "" {
+		investment, err := uc.findIdempotentInvestment(ctx, loanID, params.IdempotencyKey)
+		if err != nil {
+			return nil, false, err
+		}
+		if investment != nil {
+			return investment, true, nil
+		}
+	}
+
+	investment, loan, err := uc.createInvestment(ctx, loanID, params)
+	if errors.Is(err, entity.ErrDuplicateIdempotencyKey) {
+		// A concurrent request with the same key won the race, replay its result
+		existing, err := uc.findIdempotentInvestment(ctx, loanID, params.IdempotencyKey)
+		if err != nil {
+			return nil, false, err
+		}
+		if existing == nil {
+			return nil, false, fmt.Errorf("failed to find investment for idempotency key %q", params.IdempotencyKey)
+		}
+		return existing, true, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to list loans: %w", err)
+		return nil, false, err
 	}
+	uc.sendInvestmentConfirmation(ctx, loan, investment)
 
-	return loans, nil
+	// Check if loan is now fully invested
+	if loan.State == entity.StateInvested {
+		uc.notifyStateChange(ctx, loan, entity.StateApproved)
+
+		// Send email to all investors with agreement letter
+		if _, err := uc.sendLoanFullyInvestedNotification(ctx, loanID, loan); err != nil {
+			// Log error but don't fail the transaction
+			uc.logger.ErrorContext(ctx, "failed to send loan fully invested notification", "loan_id", loan.ID, "error", err)
+		}
+	}
+
+	return investment, false, nil
 }
 
-// sendLoanFullyInvestedNotification sends notification when loan is fully invested
-func (uc *loanUsecase) sendLoanFullyInvestedNotification(ctx context.Context, loanID int64, loan *entity.Loan) error {
-	// Get all investors for this loan
-	investments, err := uc.investmentRepo.GetByLoanID(ctx, loanID)
+// sendInvestmentConfirmation emails the investor that their investment was
+// received. The email is queued, failures are only logged.
+func (uc *loanUsecase) sendInvestmentConfirmation(ctx context.Context, loan *entity.Loan, investment *entity.Investment) {
+	emailRequest := service.SendInvestmentConfirmationRequest{
+		InvestmentID:     investment.ID,
+		LoanID:           loan.ID,
+		InvestorEmail:    investment.InvestorEmail,
+		Amount:           investment.OriginalAmount,
+		Currency:         investment.Currency,
+		BorrowerIDNumber: loan.BorrowerIDNumber,
+		PrincipalAmount:  loan.PrincipalAmount,
+		LoanCurrency:     loan.Currency,
+		ROI:              loan.ROI,
+		FundedPercentage: loan.FundedPercentage(),
+		InvestedAt:       investment.CreatedAt,
+		Language:         investment.Language,
+	}
+	if err := uc.emailService.SendInvestmentConfirmation(ctx, emailRequest); err != nil {
+		uc.logger.ErrorContext(ctx, "failed to send investment confirmation", "loan_id", loan.ID, "investment_id", investment.ID, "error", err)
+	}
+}
+
+// BulkInvest invests in several loans in one call. By default the items are saved
+// in a single transaction and nothing is saved if any item fails; with AllowPartial
+// each item is saved on its own and only the failing ones are skipped.
+func (uc *loanUsecase) BulkInvest(ctx context.Context, params entity.BulkInvestParams) ([]*BulkInvestmentResult, error) {
+	results := make([]*BulkInvestmentResult, len(params.Items))
+	invested := make(map[int64]*entity.Loan)
+
+	if params.AllowPartial {
+		for i, item := range params.Items {
+			results[i] = &BulkInvestmentResult{LoanID: item.LoanID}
+
+			investment, loan, err := uc.createInvestment(ctx, item.LoanID, entity.InvestLoanParams{InvestorEmail: item.InvestorEmail, Amount: item.Amount, Currency: item.Currency, Language: item.Language})
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			results[i].Investment = investment
+			if loan.State == entity.StateInvested {
+				invested[loan.ID] = loan
+			}
+		}
+	} else {
+		failed := false
+		err := uc.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			for i, item := range params.Items {
+				results[i] = &BulkInvestmentResult{LoanID: item.LoanID}
+
+				// Later items see the earlier ones, so every failing item is reported
+				investment, loan, err := uc.createInvestment(ctx, item.LoanID, entity.InvestLoanParams{InvestorEmail: item.InvestorEmail, Amount: item.Amount, Currency: item.Currency, Language: item.Language})
+				if err != nil {
+					if !isBusinessError(err) {
+						return err
+					}
+					results[i].Err = err
+					failed = true
+					continue
+				}
+				results[i].Investment = investment
+				if loan.State == entity.StateInvested {
+					invested[loan.ID] = loan
+				}
+			}
+
+			if failed {
+				return ErrBatchRolledBack
+			}
+			return nil
+		})
+		if failed {
+			// Nothing was saved, report the valid items as rolled back
+			for _, result := range results {
+				if result.Err == nil {
+					result.Investment = nil
+					result.Err = ErrBatchRolledBack
+				}
+			}
+			return results, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create investments: %w", err)
+		}
+	}
+
+	// Notify once per loan this batch fully invested
+	for loanID, loan := range invested {
+		uc.notifyStateChange(ctx, loan, entity.StateApproved)
+		if _, err := uc.sendLoanFullyInvestedNotification(ctx, loanID, loan); err != nil {
+			uc.logger.ErrorContext(ctx, "failed to send loan fully invested notification", "loan_id", loan.ID, "error", err)
+		}
+	}
+
+	return results, nil
+}
+
+// createInvestment validates and saves a single investment, returning the loan as
+// updated by it. When ctx carries a transaction the investment joins it.
+func (uc *loanUsecase) createInvestment(ctx context.Context, loanID int64, params entity.InvestLoanParams) (*entity.Investment, *entity.Loan, error) {
+	// Get existing loan
+	loan, err := uc.loanRepo.GetByID(ctx, loanID)
 	if err != nil {
-		return fmt.Errorf("failed to get investments: %w", err)
+		return nil, nil, fmt.Errorf("failed to get loan: %w", err)
 	}
 
-	// Collect unique investor emails
-	emailMap := make(map[string]bool)
-	for _, inv := range investments {
-		emailMap[inv.InvestorEmail] = true
+	// Check if loan can receive investment
+	if err := loan.CanReceiveInvestment(); err != nil {
+		return nil, nil, err
+	}
+
+	// Round the amount to cents, unless finer amounts are rejected
+	if uc.config.StrictAmounts && !entity.IsWholeCents(params.Amount) {
+		return nil, nil, entity.NewDomainError(entity.ErrValidation, "amount must not have more than two decimals")
+	}
+	originalAmount := entity.NewMoney(params.Amount)
+
+	// Convert the amount to the loan currency, in which it counts toward funding
+	currency := params.Currency
+	if currency == "" {
+		currency = loan.Currency
+	}
+	if err := entity.ValidateCurrency(currency); err != nil {
+		return nil, nil, err
+	}
+	amount, err := uc.fxConverter.Convert(ctx, originalAmount, currency, loan.Currency)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to convert investment amount: %w", err)
+	}
+
+	// Get current total investment
+	totalInvestment, err := uc.investmentRepo.GetTotalByLoanID(ctx, loanID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get total investment: %w", err)
+	}
+
+	// Invest only what the loan still needs when a partial investment is allowed
+	if params.AllowPartial {
+		amount, originalAmount, err = uc.capInvestment(ctx, loan, amount, originalAmount, currency, totalInvestment)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	var investorEmails []string
-	for email := range emailMap {
-		investorEmails = append(investorEmails, email)
+	// Validate investment amount
+	if err := loan.ValidateInvestmentAmount(amount, totalInvestment); err != nil {
+		return nil, nil, err
 	}
 
-	// Prepare email request
-	emailRequest := service.SendLoanNotificationRequest{
-		LoanID:              loanID,
-		InvestorEmails:      investorEmails,
-		BorrowerIDNumber:    loan.BorrowerIDNumber,
-		PrincipalAmount:     loan.PrincipalAmount,
-		AgreementLetterLink: loan.AgreementLetterLink,
+	// Create investment
+	investment := &entity.Investment{
+		// ID will be auto-generated by database
+		LoanID:         loanID,
+		InvestorEmail:  params.InvestorEmail,
+		Amount:         amount,
+		Currency:       currency,
+		OriginalAmount: originalAmount,
+		CreatedAt:      entity.Now(),
+		Language:       params.Language,
+	}
+	if params.IdempotencyKey != "" {
+		investment.IdempotencyKey = &params.IdempotencyKey
+	}
+	if investment.Language == "" {
+		investment.Language = entity.LanguageEnglish
+	}
+
+	// Save the investment, rechecking the funded total in the same transaction
+	// so concurrent investors cannot push the loan over its principal
+	err = uc.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Lock the loan first, so the checks below see every investment committed
+		// before this one and none is committed until this one is saved
+		if _, err := uc.loanRepo.GetByIDForUpdate(ctx, loanID); err != nil {
+			return fmt.Errorf("failed to get loan: %w", err)
+		}
+
+		// Cap again against the investments committed since
+		if params.AllowPartial {
+			totalInvestment, err := uc.investmentRepo.GetTotalByLoanID(ctx, loanID)
+			if err != nil {
+				return fmt.Errorf("failed to get total investment: %w", err)
+			}
+			investment.Amount, investment.OriginalAmount, err = uc.capInvestment(ctx, loan, investment.Amount, investment.OriginalAmount, currency, totalInvestment)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Loans allowing one investment per investor reject a second one
+		if !loan.AllowMultipleInvestmentsPerInvestor {
+			hasInvested, err := uc.investmentRepo.HasInvested(ctx, loanID, params.InvestorEmail)
+			if err != nil {
+				return fmt.Errorf("failed to check existing investments: %w", err)
+			}
+			if err := loan.ValidateRepeatInvestment(hasInvested); err != nil {
+				return err
+			}
+		}
+
+		// Enforce the per-investor cap across all of this investor's investments
+		if loan.MaxPerInvestor != nil {
+			investorTotal, err := uc.investmentRepo.GetTotalByInvestor(ctx, loanID, params.InvestorEmail)
+			if err != nil {
+				return fmt.Errorf("failed to get investor total: %w", err)
+			}
+			if err := loan.ValidateInvestorTotal(investment.Amount, investorTotal); err != nil {
+				return err
+			}
+		}
+
+		updatedLoan, err := uc.investmentRepo.CreateWithinPrincipal(ctx, investment)
+		if err != nil {
+			return err
+		}
+		loan = updatedLoan
+
+		// A fully invested loan accepts no further investment, so an invested
+		// loan here means this investment moved it out of approved
+		if loan.State == entity.StateInvested {
+			return uc.stateTransitionRepo.Append(ctx, entity.NewLoanStateTransition(loan, entity.StateApproved, params.InvestorEmail))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create investment: %w", err)
+	}
+
+	return investment, loan, nil
+}
+
+// capInvestment lowers an investment to the amount the loan still needs, given
+// in the loan currency and in the investor's currency
+func (uc *loanUsecase) capInvestment(ctx context.Context, loan *entity.Loan, amount, originalAmount entity.Money, currency string, totalInvestment entity.Money) (entity.Money, entity.Money, error) {
+	capped := loan.CapInvestmentAmount(amount, totalInvestment)
+	if capped == amount {
+		return amount, originalAmount, nil
+	}
+	if currency == loan.Currency {
+		return capped, capped, nil
+	}
+
+	cappedOriginal, err := uc.fxConverter.Convert(ctx, capped, loan.Currency, currency)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to convert investment amount: %w", err)
+	}
+	return capped, cappedOriginal, nil
+}
+
+// isBusinessError reports whether err breaks a business rule, as opposed to an
+// infrastructure failure
+func isBusinessError(err error) bool {
+	var domainErr *entity.DomainError
+	return errors.As(err, &domainErr) || errors.Is(err, entity.ErrLoanNotFound)
+}
+
+// findIdempotentInvestment returns the investment previously created with the key, or nil if there is none
+func (uc *loanUsecase) findIdempotentInvestment(ctx context.Context, loanID int64, key string) (*entity.Investment, error) {
+	investment, err := uc.investmentRepo.GetByIdempotencyKey(ctx, key)
+	if errors.Is(err, entity.ErrInvestmentNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get investment by idempotency key: %w", err)
+	}
+
+	if investment.LoanID != loanID {
+		return nil, entity.NewDomainError(entity.ErrValidation, "idempotency key was already used for a different loan")
+	}
+
+	return investment, nil
+}
+
+// WithdrawInvestment removes an investment from a loan that has not been disbursed yet
+func (uc *loanUsecase) WithdrawInvestment(ctx context.Context, loanID, investmentID int64) (*LoanSummary, error) {
+	// Get existing loan
+	loan, err := uc.loanRepo.GetByID(ctx, loanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	// Check if loan still allows withdrawals
+	if err := loan.CanWithdrawInvestment(); err != nil {
+		return nil, err
+	}
+
+	// Make sure the investment belongs to this loan
+	investment, err := uc.investmentRepo.GetByID(ctx, investmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get investment: %w", err)
+	}
+	if investment.LoanID != loanID {
+		return nil, entity.NewDomainError(entity.ErrInvestmentNotFound, "investment does not belong to this loan")
+	}
+
+	// Delete the investment and revert the loan state atomically
+	fromState := loan.State
+	err = uc.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := uc.investmentRepo.Delete(ctx, investmentID); err != nil {
+			return fmt.Errorf("failed to delete investment: %w", err)
+		}
+
+		// Revert to approved if the loan is no longer fully funded
+		totalInvestment, err := uc.investmentRepo.GetTotalByLoanID(ctx, loanID)
+		if err != nil {
+			return fmt.Errorf("failed to get total investment: %w", err)
+		}
+
+		loan.RevertToApproved(totalInvestment)
+		if loan.State != fromState {
+			if err := uc.updateLoanState(ctx, loan, fromState, investment.InvestorEmail); err != nil {
+				return fmt.Errorf("failed to update loan state to approved: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if loan.State != fromState {
+		uc.notifyStateChange(ctx, loan, fromState)
+	}
+
+	return uc.GetLoan(ctx, loanID, false)
+}
+
+// ForceInvested marks a fully funded approved or invested loan as invested on
+// behalf of an employee and sends the fully invested notification again
+func (uc *loanUsecase) ForceInvested(ctx context.Context, loanID int64, employeeID string) (*entity.Loan, error) {
+	var loan *entity.Loan
+	var fromState entity.LoanState
+
+	// Lock the loan so the investments cannot change between the check and the write
+	err := uc.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		loan, err = uc.loanRepo.GetByIDForUpdate(ctx, loanID)
+		if err != nil {
+			return fmt.Errorf("failed to get loan: %w", err)
+		}
+
+		totalInvestment, err := uc.investmentRepo.GetTotalByLoanID(ctx, loanID)
+		if err != nil {
+			return fmt.Errorf("failed to get total investment: %w", err)
+		}
+
+		// Apply business rules
+		fromState = loan.State
+		if err := loan.ForceInvested(totalInvestment); err != nil {
+			return err
+		}
+
+		// The transition is recorded even when the loan was already invested, to keep the acting employee
+		if err := uc.updateLoanState(ctx, loan, fromState, employeeID); err != nil {
+			return fmt.Errorf("failed to update loan: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if loan.State != fromState {
+		uc.notifyStateChange(ctx, loan, fromState)
+	}
+
+	if _, err := uc.sendLoanFullyInvestedNotification(ctx, loanID, loan); err != nil {
+		// Log error but don't roll back the state change
+		uc.logger.ErrorContext(ctx, "failed to send loan fully invested notification", "loan_id", loan.ID, "error", err)
+	}
+
+	return loan, nil
+}
+
+// ResendInvestedNotification sends the fully invested notification to the
+// investors of a loan again, returning the number of investors notified
+func (uc *loanUsecase) ResendInvestedNotification(ctx context.Context, loanID int64) (int, error) {
+	loan, err := uc.loanRepo.GetByID(ctx, loanID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	if err := loan.CanResendInvestedNotification(); err != nil {
+		return 0, err
+	}
+
+	recipients, err := uc.sendLoanFullyInvestedNotification(ctx, loanID, loan)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send loan fully invested notification: %w", err)
+	}
+
+	return recipients, nil
+}
+
+// DisburseLoan disburses a fully invested loan
+func (uc *loanUsecase) DisburseLoan(ctx context.Context, loanID int64, params entity.DisburseLoanParams) (*entity.Loan, error) {
+	if len(params.SignedAgreementDocs) == 0 {
+		return nil, entity.NewDomainError(entity.ErrValidation, "at least one signed agreement document is required")
+	}
+	signedAgreementDoc := params.SignedAgreementDocs[0]
+
+	var loan *entity.Loan
+	var fromState entity.LoanState
+
+	// Lock the loan while the tranche is checked against the amount disbursed so far,
+	// so concurrent tranches cannot add up to more than the principal
+	err := uc.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		loan, err = uc.loanRepo.GetByIDForUpdate(ctx, loanID)
+		if err != nil {
+			return fmt.Errorf("failed to get loan: %w", err)
+		}
+
+		disbursedTotal, err := uc.disbursementRepo.GetTotalByLoanID(ctx, loanID)
+		if err != nil {
+			return fmt.Errorf("failed to get disbursed total: %w", err)
+		}
+
+		// Without an amount the remaining principal is disbursed in one tranche
+		amount := loan.GetRemainingDisbursement(disbursedTotal)
+		if params.Amount != nil {
+			amount = *params.Amount
+		}
+
+		// Every approver of the loan, not only the last one, is barred from disbursing it
+		approvals, err := uc.loanApprovalRepo.ListByLoanID(ctx, loanID)
+		if err != nil {
+			return fmt.Errorf("failed to get loan approvals: %w", err)
+		}
+
+		// Apply business rules
+		fromState = loan.State
+		disbursement, err := loan.Disburse(amount, disbursedTotal, approvals, signedAgreementDoc, params.EmployeeID, params.DisbursementDate)
+		if err != nil {
+			return err
+		}
+
+		if err := uc.disbursementRepo.Create(ctx, disbursement); err != nil {
+			return fmt.Errorf("failed to save disbursement: %w", err)
+		}
+		for _, doc := range params.SignedAgreementDocs {
+			document := &entity.LoanDocument{
+				LoanID:         loanID,
+				DisbursementID: disbursement.ID,
+				Document:       doc,
+				CreatedAt:      disbursement.CreatedAt,
+			}
+			if err := uc.loanDocumentRepo.Create(ctx, document); err != nil {
+				return fmt.Errorf("failed to save loan document: %w", err)
+			}
+		}
+		if err := uc.loanRepo.Update(ctx, loan); err != nil {
+			return fmt.Errorf("failed to update loan: %w", err)
+		}
+
+		// Further tranches of a partially disbursed loan keep its state
+		if loan.State == fromState {
+			return nil
+		}
+		if err := uc.stateTransitionRepo.Append(ctx, entity.NewLoanStateTransition(loan, fromState, params.EmployeeID)); err != nil {
+			return fmt.Errorf("failed to update loan: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if loan.State != fromState {
+		uc.notifyStateChange(ctx, loan, fromState)
+	}
+
+	// Notify the borrower once the whole principal is disbursed, when we have an address for them
+	if loan.State == entity.StateDisbursed && loan.BorrowerEmail != nil && *loan.BorrowerEmail != "" {
+		emailRequest := service.SendLoanDisbursedNotificationRequest{
+			LoanID:             loan.ID,
+			BorrowerEmail:      *loan.BorrowerEmail,
+			BorrowerIDNumber:   loan.BorrowerIDNumber,
+			PrincipalAmount:    loan.PrincipalAmount,
+			SignedAgreementDoc: signedAgreementDoc,
+			DisbursementDate:   params.DisbursementDate,
+		}
+		if err := uc.emailService.SendLoanDisbursedNotification(ctx, emailRequest); err != nil {
+			// Log error but don't roll back the disbursement
+			uc.logger.ErrorContext(ctx, "failed to send loan disbursed notification", "loan_id", loan.ID, "error", err)
+		}
+	}
+
+	return loan, nil
+}
+
+// DeleteLoan soft-deletes a proposed or rejected loan
+func (uc *loanUsecase) DeleteLoan(ctx context.Context, loanID int64) error {
+	// Get existing loan
+	loan, err := uc.loanRepo.GetByID(ctx, loanID)
+	if err != nil {
+		return fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	// Apply business rules
+	if err := loan.CanBeDeleted(); err != nil {
+		return err
+	}
+
+	if err := uc.loanRepo.SoftDelete(ctx, loan); err != nil {
+		return fmt.Errorf("failed to delete loan: %w", err)
+	}
+
+	return nil
+}
+
+// GetLoan retrieves a loan with its investment summary.
+// Soft-deleted loans are only returned when includeDeleted is set.
+func (uc *loanUsecase) GetLoan(ctx context.Context, loanID int64, includeDeleted bool) (*LoanSummary, error) {
+	// Get loan
+	var loan *entity.Loan
+	var err error
+	if includeDeleted {
+		loan, err = uc.loanRepo.GetByIDIncludingDeleted(ctx, loanID)
+	} else {
+		loan, err = uc.loanRepo.GetByID(ctx, loanID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	// Get investments
+	investments, err := uc.investmentRepo.GetByLoanID(ctx, loanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get investments: %w", err)
+	}
+
+	// Get signed documents
+	documents, err := uc.loanDocumentRepo.ListByLoanID(ctx, loanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan documents: %w", err)
+	}
+
+	// Get approvals
+	approvals, err := uc.loanApprovalRepo.ListByLoanID(ctx, loanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan approvals: %w", err)
+	}
+
+	// Calculate totals
+	var totalInvested entity.Money
+	for _, inv := range investments {
+		totalInvested += inv.Amount
+	}
+
+	summary := &LoanSummary{
+		Loan:            loan,
+		TotalInvested:   totalInvested,
+		RemainingAmount: loan.GetRemainingAmount(totalInvested),
+		InvestmentCount: len(investments),
+		Investments:     investments,
+		Documents:       documents,
+		Approvals:       approvals,
+	}
+
+	return summary, nil
+}
+
+// GetLoanFunding reports a loan's funding progress from aggregates, without
+// loading its investments
+func (uc *loanUsecase) GetLoanFunding(ctx context.Context, loanID int64) (*entity.LoanFunding, error) {
+	// The loan is read with its invested total
+	loan, err := uc.loanRepo.GetByID(ctx, loanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	investorCount, err := uc.investmentRepo.CountInvestors(ctx, loanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count investors: %w", err)
+	}
+
+	return entity.NewLoanFunding(loan, investorCount), nil
+}
+
+// GetInvestorReturns calculates each investor's share and expected return for a loan
+func (uc *loanUsecase) GetInvestorReturns(ctx context.Context, loanID int64) (*LoanReturns, error) {
+	// Get loan
+	loan, err := uc.loanRepo.GetByID(ctx, loanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	// Get investments
+	investments, err := uc.investmentRepo.GetByLoanID(ctx, loanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get investments: %w", err)
+	}
+
+	// Sum investments per investor, keeping the order of their first investment
+	returnsByEmail := make(map[string]*InvestorReturn)
+	var investors []*InvestorReturn
+	for _, inv := range investments {
+		investorReturn, ok := returnsByEmail[inv.InvestorEmail]
+		if !ok {
+			investorReturn = &InvestorReturn{InvestorEmail: inv.InvestorEmail}
+			returnsByEmail[inv.InvestorEmail] = investorReturn
+			investors = append(investors, investorReturn)
+		}
+		investorReturn.AmountInvested += inv.Amount
+	}
+
+	for _, investorReturn := range investors {
+		investorReturn.SharePercent = float64(investorReturn.AmountInvested) / float64(loan.PrincipalAmount) * 100
+		investorReturn.ExpectedReturn = investorReturn.AmountInvested.Percent(loan.ROI)
+	}
+
+	return &LoanReturns{
+		LoanID:    loan.ID,
+		ROI:       loan.ROI,
+		Investors: investors,
+	}, nil
+}
+
+// ListInvestments retrieves a page of investments for a loan
+func (uc *loanUsecase) ListInvestments(ctx context.Context, loanID int64, filter repository.InvestmentFilter) (*InvestmentPage, error) {
+	// Make sure the loan exists
+	if _, err := uc.loanRepo.GetByID(ctx, loanID); err != nil {
+		return nil, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	filter.LoanID = &loanID
+
+	return uc.listInvestmentPage(ctx, filter, uc.investmentRepo.List)
+}
+
+// GetInvestorPortfolio retrieves a page of an investor's investments across all loans
+func (uc *loanUsecase) GetInvestorPortfolio(ctx context.Context, investorEmail string, filter repository.InvestmentFilter) (*InvestorPortfolio, error) {
+	filter.InvestorEmail = &investorEmail
+
+	page, err := uc.listInvestmentPage(ctx, filter, func(ctx context.Context, filter repository.InvestmentFilter) ([]*entity.Investment, error) {
+		return uc.investmentRepo.GetByInvestor(ctx, investorEmail, filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := uc.investmentRepo.GetInvestorTotals(ctx, investorEmail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get investor totals: %w", err)
+	}
+
+	// Load each loan of the page once
+	loansByID := make(map[int64]*entity.Loan)
+	var loans []*entity.Loan
+	for _, investment := range page.Investments {
+		if _, ok := loansByID[investment.LoanID]; ok {
+			continue
+		}
+		loan, err := uc.loanRepo.GetByIDIncludingDeleted(ctx, investment.LoanID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get loan: %w", err)
+		}
+		loansByID[loan.ID] = loan
+		loans = append(loans, loan)
+	}
+
+	return &InvestorPortfolio{
+		InvestorEmail: investorEmail,
+		Page:          page,
+		Loans:         loans,
+		Totals:        totals,
+	}, nil
+}
+
+// ListAllInvestments retrieves a page of the investments across all loans for the investment ledger
+func (uc *loanUsecase) ListAllInvestments(ctx context.Context, filter repository.InvestmentFilter) (*InvestmentLedger, error) {
+	loanStates := make(map[int64]entity.LoanState)
+	page, err := uc.listInvestmentPage(ctx, filter, func(ctx context.Context, filter repository.InvestmentFilter) ([]*entity.Investment, error) {
+		entries, err := uc.investmentRepo.ListWithLoanState(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		investments := make([]*entity.Investment, 0, len(entries))
+		for _, entry := range entries {
+			loanStates[entry.Investment.ID] = entry.LoanState
+			investments = append(investments, entry.Investment)
+		}
+		return investments, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &InvestmentLedger{
+		Page:       page,
+		LoanStates: loanStates,
+	}, nil
+}
+
+// listInvestmentPage lists one page of the investments matching filter with list
+func (uc *loanUsecase) listInvestmentPage(ctx context.Context, filter repository.InvestmentFilter, list func(context.Context, repository.InvestmentFilter) ([]*entity.Investment, error)) (*InvestmentPage, error) {
+	// With keyset pagination fetch one extra investment to learn whether a next page exists
+	listFilter := filter
+	keyset := filter.AfterID != nil && filter.Limit != nil
+	if keyset {
+		limit := *filter.Limit + 1
+		listFilter.Limit = &limit
+	}
+
+	investments, err := list(ctx, listFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list investments: %w", err)
+	}
+
+	total, err := uc.investmentRepo.Count(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count investments: %w", err)
+	}
+
+	page := &InvestmentPage{
+		Investments: investments,
+		Total:       total,
+	}
+	if keyset && len(investments) > *filter.Limit {
+		page.Investments = investments[:*filter.Limit]
+		page.NextAfterID = &page.Investments[len(page.Investments)-1].ID
+	}
+
+	return page, nil
+}
+
+// ListDisbursements retrieves the disbursement tranches of a loan in the order they were paid out
+func (uc *loanUsecase) ListDisbursements(ctx context.Context, loanID int64) ([]*entity.Disbursement, error) {
+	// Make sure the loan exists
+	if _, err := uc.loanRepo.GetByID(ctx, loanID); err != nil {
+		return nil, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	disbursements, err := uc.disbursementRepo.ListByLoanID(ctx, loanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disbursements: %w", err)
+	}
+
+	return disbursements, nil
+}
+
+// GetInvestmentReceipt renders the PDF receipt of an investment, with the
+// return the investor can expect at the loan's ROI
+func (uc *loanUsecase) GetInvestmentReceipt(ctx context.Context, investmentID int64) ([]byte, error) {
+	investment, err := uc.investmentRepo.GetByID(ctx, investmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get investment: %w", err)
+	}
+
+	// Receipts stay available after the loan is soft-deleted
+	loan, err := uc.loanRepo.GetByIDIncludingDeleted(ctx, investment.LoanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	receipt, err := uc.receiptGenerator.GenerateInvestmentReceipt(ctx, service.InvestmentReceipt{
+		InvestmentID:   investment.ID,
+		LoanID:         loan.ID,
+		InvestorEmail:  investment.InvestorEmail,
+		Amount:         investment.Amount,
+		LoanCurrency:   loan.Currency,
+		OriginalAmount: investment.OriginalAmount,
+		Currency:       investment.Currency,
+		ROI:            loan.ROI,
+		ExpectedReturn: investment.Amount.Percent(loan.ROI),
+		InvestedAt:     investment.CreatedAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate receipt: %w", err)
+	}
+
+	return receipt, nil
+}
+
+// GetLoanHistory retrieves a page of a loan's state transitions, newest first
+// unless the filter asks for the order they happened
+func (uc *loanUsecase) GetLoanHistory(ctx context.Context, loanID int64, filter repository.StateTransitionFilter) (*LoanHistoryPage, error) {
+	// Make sure the loan exists
+	if _, err := uc.loanRepo.GetByID(ctx, loanID); err != nil {
+		return nil, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	transitions, err := uc.stateTransitionRepo.ListByLoanID(ctx, loanID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan history: %w", err)
+	}
+
+	total, err := uc.stateTransitionRepo.CountByLoanID(ctx, loanID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count loan history: %w", err)
+	}
+
+	return &LoanHistoryPage{
+		Transitions: transitions,
+		Total:       total,
+	}, nil
+}
+
+// GetLoanActions retrieves a loan with the actions its state allows
+func (uc *loanUsecase) GetLoanActions(ctx context.Context, loanID int64) (*entity.Loan, []entity.LoanAction, error) {
+	loan, err := uc.loanRepo.GetByID(ctx, loanID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	return loan, loan.AvailableActions(), nil
+}
+
+// GetLoansByIDs retrieves loans in the order of the given IDs, with nil in
+// place of IDs that have no live loan. Repeated IDs are fetched once.
+func (uc *loanUsecase) GetLoansByIDs(ctx context.Context, ids []int64) ([]*entity.Loan, error) {
+	seen := make(map[int64]bool, len(ids))
+	uniqueIDs := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			uniqueIDs = append(uniqueIDs, id)
+		}
+	}
+
+	found, err := uc.loanRepo.GetByIDs(ctx, uniqueIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loans: %w", err)
+	}
+
+	loansByID := make(map[int64]*entity.Loan, len(found))
+	for _, loan := range found {
+		loansByID[loan.ID] = loan
+	}
+
+	loans := make([]*entity.Loan, len(ids))
+	for i, id := range ids {
+		loans[i] = loansByID[id]
+	}
+	return loans, nil
+}
+
+// ListLoans retrieves loans with optional filtering
+func (uc *loanUsecase) ListLoans(ctx context.Context, filter repository.LoanFilter) ([]*entity.Loan, error) {
+	loans, err := uc.loanRepo.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list loans: %w", err)
+	}
+
+	return loans, nil
+}
+
+// ExportLoans streams loans with optional filtering to fn, one loan at a time
+func (uc *loanUsecase) ExportLoans(ctx context.Context, filter repository.LoanFilter, fn func(*entity.Loan) error) error {
+	if err := uc.loanRepo.ForEach(ctx, filter, fn); err != nil {
+		return fmt.Errorf("failed to export loans: %w", err)
+	}
+
+	return nil
+}
+
+// GetStats retrieves aggregate statistics of the loan portfolio
+func (uc *loanUsecase) GetStats(ctx context.Context, filter repository.StatsFilter) (*entity.LoanStats, error) {
+	stats, err := uc.loanRepo.GetStats(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// GetROISummary retrieves the blended ROI of the platform's funded loans
+func (uc *loanUsecase) GetROISummary(ctx context.Context) (*entity.ROISummary, error) {
+	summary, err := uc.loanRepo.GetROISummary(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ROI summary: %w", err)
+	}
+
+	return summary, nil
+}
+
+// GetBorrowerExposure retrieves the platform's exposure to a borrower across
+// all their loans. A borrower without loans has no exposure rather than being
+// not found.
+func (uc *loanUsecase) GetBorrowerExposure(ctx context.Context, borrowerIDNumber string) (*entity.BorrowerExposure, error) {
+	if err := entity.ValidateBorrowerID(borrowerIDNumber); err != nil {
+		return nil, err
+	}
+
+	exposure, err := uc.loanRepo.GetBorrowerExposure(ctx, borrowerIDNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get borrower exposure: %w", err)
+	}
+
+	return exposure, nil
+}
+
+// notifyStateChange tells downstream systems that a committed change moved the
+// loan out of fromState and counts the transition. Delivery is asynchronous,
+// failures are only logged.
+func (uc *loanUsecase) notifyStateChange(ctx context.Context, loan *entity.Loan, fromState entity.LoanState) {
+	uc.loanMetrics.LoanStateChanged(string(fromState), string(loan.State))
+
+	event := service.LoanStateChangedEvent{
+		LoanID:    loan.ID,
+		FromState: string(fromState),
+		ToState:   string(loan.State),
+		At:        loan.UpdatedAt,
+	}
+	if err := uc.webhookNotifier.NotifyLoanStateChanged(ctx, event); err != nil {
+		uc.logger.ErrorContext(ctx, "failed to queue loan state change webhook", "loan_id", loan.ID, "error", err)
+	}
+}
+
+// updateLoanState saves a loan whose state changed from fromState and appends
+// the transition to the audit log in the same transaction
+func (uc *loanUsecase) updateLoanState(ctx context.Context, loan *entity.Loan, fromState entity.LoanState, actor string) error {
+	return uc.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := uc.loanRepo.Update(ctx, loan); err != nil {
+			return err
+		}
+		return uc.stateTransitionRepo.Append(ctx, entity.NewLoanStateTransition(loan, fromState, actor))
+	})
+}
+
+// sendLoanFullyInvestedNotification sends notification when loan is fully invested,
+// returning the number of investors notified
+func (uc *loanUsecase) sendLoanFullyInvestedNotification(ctx context.Context, loanID int64, loan *entity.Loan) (int, error) {
+	// Get all investors for this loan
+	investments, err := uc.investmentRepo.GetByLoanID(ctx, loanID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get investments: %w", err)
+	}
+
+	// Collect unique investor emails, in the language of each investor's latest investment.
+	// An investor who invested several times, also with differently cased addresses, is
+	// emailed once, at the address of their first investment.
+	var investorKeys []string
+	emailByKey := make(map[string]string)
+	languageByKey := make(map[string]string)
+	for _, inv := range investments {
+		key := strings.ToLower(strings.TrimSpace(inv.InvestorEmail))
+		if _, ok := emailByKey[key]; !ok {
+			emailByKey[key] = inv.InvestorEmail
+			investorKeys = append(investorKeys, key)
+		}
+		languageByKey[key] = inv.Language
+	}
+
+	var languages []string
+	emailsByLanguage := make(map[string][]string)
+	for _, key := range investorKeys {
+		language := languageByKey[key]
+		if _, ok := emailsByLanguage[language]; !ok {
+			languages = append(languages, language)
+		}
+		emailsByLanguage[language] = append(emailsByLanguage[language], emailByKey[key])
+	}
+
+	// Send one email notification per language
+	var errs []error
+	for _, language := range languages {
+		emailRequest := service.SendLoanNotificationRequest{
+			LoanID:              loanID,
+			InvestorEmails:      emailsByLanguage[language],
+			BorrowerIDNumber:    loan.BorrowerIDNumber,
+			PrincipalAmount:     loan.PrincipalAmount,
+			AgreementLetterLink: loan.AgreementLetterLink,
+			Language:            language,
+		}
+		if err := uc.emailService.SendLoanFullyInvestedNotification(ctx, emailRequest); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	// Send email notification
-	return uc.emailService.SendLoanFullyInvestedNotification(ctx, emailRequest)
+	return len(investorKeys), errors.Join(errs...)
 }
@@ -0,0 +1,1326 @@
+package usecase
+
+import (
+	"amartha-andreas/internal/domain/entity"
+	domainrepository "amartha-andreas/internal/domain/repository"
+	"amartha-andreas/internal/domain/service"
+	"amartha-andreas/internal/infrastructure/database"
+	"amartha-andreas/internal/infrastructure/email"
+	"amartha-andreas/internal/infrastructure/fx"
+	"amartha-andreas/internal/infrastructure/receipt"
+	"amartha-andreas/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testDSNOptions match the SQLite options the server runs with
+const testDSNOptions = "?_txlock=immediate&_busy_timeout=5000&_foreign_keys=on"
+
+// testOptions configures the usecase built by newTestEnv
+type testOptions struct {
+	fundingWindow         time.Duration
+	strictAmounts         bool
+	agreementDomains      []string
+	dualApprovalThreshold entity.Money
+	warningThresholds     entity.LoanWarningThresholds
+	fxRates               map[fx.Pair]float64
+	fxConverter           service.FXConverter  // Replaces the converter using fxRates when set
+	emailService          service.EmailService // Replaces the recording email service when set
+}
+
+// testEnv is a loan usecase backed by a fresh SQLite database, with the
+// outgoing emails, webhooks and metrics recorded instead of sent
+type testEnv struct {
+	db       *database.Database
+	uc       LoanUsecase
+	emails   *recordingEmailService
+	webhooks *recordingNotifier
+	metrics  *recordingMetrics
+}
+
+func newTestEnv(t *testing.T, opts testOptions) *testEnv {
+	t.Helper()
+
+	db, err := database.NewDatabase(filepath.Join(t.TempDir(), "test.db") + testDSNOptions)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return newTestEnvWithDB(db, opts)
+}
+
+// newTestEnvWithDB is newTestEnv on an already open database
+func newTestEnvWithDB(db *database.Database, opts testOptions) *testEnv {
+	env := &testEnv{
+		db:       db,
+		emails:   &recordingEmailService{},
+		webhooks: &recordingNotifier{},
+		metrics:  &recordingMetrics{},
+	}
+	var emailService service.EmailService = env.emails
+	if opts.emailService != nil {
+		emailService = opts.emailService
+	}
+	fxConverter := fx.NewStaticConverter(opts.fxRates)
+	if opts.fxConverter != nil {
+		fxConverter = opts.fxConverter
+	}
+	env.uc = NewLoanUsecase(
+		repository.NewLoanRepository(db),
+		repository.NewInvestmentRepository(db),
+		repository.NewStateTransitionRepository(db),
+		repository.NewDisbursementRepository(db),
+		repository.NewLoanDocumentRepository(db),
+		repository.NewLoanApprovalRepository(db),
+		db,
+		emailService,
+		env.webhooks,
+		fxConverter,
+		receipt.NewPDFGenerator(),
+		env.metrics,
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		LoanUsecaseConfig{
+			FundingWindow:         opts.fundingWindow,
+			StrictAmounts:         opts.strictAmounts,
+			AgreementDomains:      opts.agreementDomains,
+			DualApprovalThreshold: opts.dualApprovalThreshold,
+			WarningThresholds:     opts.warningThresholds,
+		},
+	)
+	return env
+}
+
+// validLoanParams returns params that pass every creation check
+func validLoanParams(principal float64) entity.CreateLoanParams {
+	return entity.CreateLoanParams{
+		BorrowerIDNumber:    "3171234567890123",
+		PrincipalAmount:     entity.NewMoney(principal),
+		Rate:                12,
+		ROI:                 10,
+		TermWeeks:           52,
+		AgreementLetterLink: "https://example.com/agreements/1.pdf",
+	}
+}
+
+// createLoan creates a proposed loan of principal
+func (env *testEnv) createLoan(t *testing.T, principal float64) *entity.Loan {
+	t.Helper()
+
+	loan, _, err := env.uc.CreateLoan(context.Background(), validLoanParams(principal))
+	if err != nil {
+		t.Fatalf("failed to create loan: %v", err)
+	}
+	return loan
+}
+
+// approveLoan gives loanID the approval of employeeID, dated now
+func (env *testEnv) approveLoan(t *testing.T, loanID int64, employeeID string) *entity.Loan {
+	t.Helper()
+
+	loan, err := env.uc.ApproveLoan(context.Background(), loanID, entity.ApproveLoanParams{
+		ProofPicture: "/files/proof_pictures/proof.jpg",
+		EmployeeID:   employeeID,
+		ApprovalDate: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("failed to approve loan: %v", err)
+	}
+	return loan
+}
+
+// createApprovedLoan creates a loan of principal and approves it
+func (env *testEnv) createApprovedLoan(t *testing.T, principal float64) *entity.Loan {
+	t.Helper()
+
+	loan := env.createLoan(t, principal)
+	return env.approveLoan(t, loan.ID, "EMP-APPROVER")
+}
+
+// invest invests amount in loanID on behalf of investorEmail
+func (env *testEnv) invest(t *testing.T, loanID int64, investorEmail string, amount float64) *entity.Investment {
+	t.Helper()
+
+	investment, _, err := env.uc.InvestInLoan(context.Background(), loanID, entity.InvestLoanParams{
+		InvestorEmail: investorEmail,
+		Amount:        amount,
+	})
+	if err != nil {
+		t.Fatalf("failed to invest %v: %v", amount, err)
+	}
+	return investment
+}
+
+// recordingEmailService implements service.EmailService by recording the requests
+type recordingEmailService struct {
+	mu            sync.Mutex
+	fullyInvested []service.SendLoanNotificationRequest
+	approved      []service.SendLoanApprovedNotificationRequest
+	disbursed     []service.SendLoanDisbursedNotificationRequest
+	confirmations []service.SendInvestmentConfirmationRequest
+}
+
+func (s *recordingEmailService) SendLoanFullyInvestedNotification(ctx context.Context, request service.SendLoanNotificationRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fullyInvested = append(s.fullyInvested, request)
+	return nil
+}
+
+func (s *recordingEmailService) SendLoanApprovedNotification(ctx context.Context, request service.SendLoanApprovedNotificationRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.approved = append(s.approved, request)
+	return nil
+}
+
+func (s *recordingEmailService) SendLoanDisbursedNotification(ctx context.Context, request service.SendLoanDisbursedNotificationRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.disbursed = append(s.disbursed, request)
+	return nil
+}
+
+func (s *recordingEmailService) SendInvestmentConfirmation(ctx context.Context, request service.SendInvestmentConfirmationRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.confirmations = append(s.confirmations, request)
+	return nil
+}
+
+// recordingNotifier implements service.WebhookNotifier by recording the events
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []service.LoanStateChangedEvent
+}
+
+func (n *recordingNotifier) NotifyLoanStateChanged(ctx context.Context, event service.LoanStateChangedEvent) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, event)
+	return nil
+}
+
+// recordingMetrics implements service.LoanMetrics by counting the events
+type recordingMetrics struct {
+	mu           sync.Mutex
+	created      int
+	stateChanges []string // "from->to"
+}
+
+func (m *recordingMetrics) LoanCreated() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.created++
+}
+
+func (m *recordingMetrics) LoanStateChanged(fromState, toState string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stateChanges = append(m.stateChanges, fromState+"->"+toState)
+}
+
+func TestInvestInLoanConcurrentInvestorsNeverExceedPrincipal(t *testing.T) {
+	env := newTestEnv(t, testOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+
+	const investors = 20
+	var wg sync.WaitGroup
+	errs := make([]error, investors)
+	for i := 0; i < investors; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, errs[i] = env.uc.InvestInLoan(context.Background(), loan.ID, entity.InvestLoanParams{
+				InvestorEmail: fmt.Sprintf("investor%d@example.com", i),
+				Amount:        150,
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, entity.ErrInvestmentExceeds), errors.Is(err, entity.ErrInvalidState):
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 6 {
+		t.Errorf("got %d successful investments, want 6", succeeded)
+	}
+
+	summary, err := env.uc.GetLoan(context.Background(), loan.ID, false)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	if summary.TotalInvested > loan.PrincipalAmount {
+		t.Errorf("total invested %s exceeds principal %s", summary.TotalInvested, loan.PrincipalAmount)
+	}
+	if summary.TotalInvested != entity.NewMoney(900) {
+		t.Errorf("got total invested %s, want 900.00", summary.TotalInvested)
+	}
+}
+
+func TestInvestInLoanEnforcesPerInvestorCap(t *testing.T) {
+	env := newTestEnv(t, testOptions{})
+	ctx := context.Background()
+
+	params := validLoanParams(1000)
+	maxPerInvestor := entity.NewMoney(300)
+	params.MaxPerInvestor = &maxPerInvestor
+	loan, _, err := env.uc.CreateLoan(ctx, params)
+	if err != nil {
+		t.Fatalf("failed to create loan: %v", err)
+	}
+	env.approveLoan(t, loan.ID, "EMP-APPROVER")
+
+	// Approaching the cap across several investments is allowed
+	env.invest(t, loan.ID, "alice@example.com", 100)
+	env.invest(t, loan.ID, "alice@example.com", 150)
+
+	// Crossing it is not, and the error reports what the investor already has
+	_, _, err = env.uc.InvestInLoan(ctx, loan.ID, entity.InvestLoanParams{InvestorEmail: "alice@example.com", Amount: 50.01})
+	if !errors.Is(err, entity.ErrValidation) {
+		t.Fatalf("got error %v, want ErrValidation", err)
+	}
+	want := "investment exceeds the per-investor cap of 300.00: investor has already invested 250.00"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %q, want it to contain %q", err.Error(), want)
+	}
+
+	// Reaching the cap exactly is allowed, and other investors have their own cap
+	env.invest(t, loan.ID, "alice@example.com", 50)
+	env.invest(t, loan.ID, "bob@example.com", 300)
+}
+
+// slowFailingEmailService implements service.EmailService by failing every
+// send after delay, counting the attempts
+type slowFailingEmailService struct {
+	delay time.Duration
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *slowFailingEmailService) fail() error {
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	return errors.New("email provider unavailable")
+}
+
+func (s *slowFailingEmailService) SendLoanFullyInvestedNotification(ctx context.Context, request service.SendLoanNotificationRequest) error {
+	return s.fail()
+}
+
+func (s *slowFailingEmailService) SendLoanApprovedNotification(ctx context.Context, request service.SendLoanApprovedNotificationRequest) error {
+	return s.fail()
+}
+
+func (s *slowFailingEmailService) SendLoanDisbursedNotification(ctx context.Context, request service.SendLoanDisbursedNotificationRequest) error {
+	return s.fail()
+}
+
+func (s *slowFailingEmailService) SendInvestmentConfirmation(ctx context.Context, request service.SendInvestmentConfirmationRequest) error {
+	return s.fail()
+}
+
+func TestInvestInLoanDoesNotWaitForSlowFailingEmails(t *testing.T) {
+	slow := &slowFailingEmailService{delay: 200 * time.Millisecond}
+	queue := email.NewAsyncEmailService(slow, 1, 10)
+	queue.Start()
+
+	env := newTestEnv(t, testOptions{emailService: queue})
+	loan := env.createApprovedLoan(t, 1000)
+
+	// Completing the loan queues a confirmation and the fully invested notification
+	start := time.Now()
+	investment, _, err := env.uc.InvestInLoan(context.Background(), loan.ID, entity.InvestLoanParams{
+		InvestorEmail: "alice@example.com",
+		Amount:        1000,
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want the investment saved despite the email failures", err)
+	}
+	if elapsed := time.Since(start); elapsed >= slow.delay {
+		t.Errorf("investing took %s, want it not to wait for the %s email provider", elapsed, slow.delay)
+	}
+	if investment.ID == 0 {
+		t.Error("investment has no ID")
+	}
+
+	// Shutting down drains the queued emails
+	if err := queue.Shutdown(context.Background()); err != nil {
+		t.Fatalf("failed to drain the queue: %v", err)
+	}
+	slow.mu.Lock()
+	defer slow.mu.Unlock()
+	if slow.calls < 3 {
+		t.Errorf("got %d send attempts, want the approval, confirmation and fully invested emails", slow.calls)
+	}
+}
+
+func TestInvestInLoanHonorsAllowMultipleInvestments(t *testing.T) {
+	tests := []struct {
+		name    string
+		allow   *bool
+		wantErr error
+	}{
+		{"default allows repeat investments", nil, nil},
+		{"explicitly allowed", boolPtr(true), nil},
+		{"one investment per investor", boolPtr(false), entity.ErrDuplicateInvestment},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, testOptions{})
+			ctx := context.Background()
+
+			params := validLoanParams(1000)
+			params.AllowMultipleInvestmentsPerInvestor = tt.allow
+			loan, _, err := env.uc.CreateLoan(ctx, params)
+			if err != nil {
+				t.Fatalf("failed to create loan: %v", err)
+			}
+			env.approveLoan(t, loan.ID, "EMP-APPROVER")
+			env.invest(t, loan.ID, "alice@example.com", 100)
+
+			_, _, err = env.uc.InvestInLoan(ctx, loan.ID, entity.InvestLoanParams{InvestorEmail: "alice@example.com", Amount: 100})
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("got error %v, want the second investment accepted", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			// Other investors can still invest
+			env.invest(t, loan.ID, "bob@example.com", 100)
+		})
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestStateTransitionsAdvanceUpdatedAt(t *testing.T) {
+	env := newTestEnv(t, testOptions{})
+	ctx := context.Background()
+
+	loan := env.createLoan(t, 1000)
+	createdAt := loan.CreatedAt
+	previous := loan.UpdatedAt
+
+	// checkTimestamps reloads the loan and checks UpdatedAt moved past the
+	// previous transition while CreatedAt stayed put, both in UTC
+	checkTimestamps := func(step string) {
+		t.Helper()
+
+		summary, err := env.uc.GetLoan(ctx, loan.ID, false)
+		if err != nil {
+			t.Fatalf("failed to get loan after %s: %v", step, err)
+		}
+		got := summary.Loan
+		if !got.CreatedAt.Equal(createdAt) {
+			t.Errorf("after %s: CreatedAt changed from %s to %s", step, createdAt, got.CreatedAt)
+		}
+		if !got.UpdatedAt.After(previous) {
+			t.Errorf("after %s: UpdatedAt %s did not advance past %s", step, got.UpdatedAt, previous)
+		}
+		if got.CreatedAt.Location() != time.UTC || got.UpdatedAt.Location() != time.UTC {
+			t.Errorf("after %s: got timestamps in %s and %s, want UTC", step, got.CreatedAt.Location(), got.UpdatedAt.Location())
+		}
+		previous = got.UpdatedAt
+		// Keep consecutive transitions apart on coarse clocks
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(time.Millisecond)
+	env.approveLoan(t, loan.ID, "EMP-APPROVER")
+	checkTimestamps("approval")
+
+	env.invest(t, loan.ID, "alice@example.com", 1000)
+	checkTimestamps("investment")
+
+	if _, err := env.uc.DisburseLoan(ctx, loan.ID, entity.DisburseLoanParams{
+		SignedAgreementDocs: []string{"/files/signed_agreements/agreement.pdf"},
+		EmployeeID:          "EMP-DISBURSER",
+		DisbursementDate:    entity.Now(),
+	}); err != nil {
+		t.Fatalf("failed to disburse loan: %v", err)
+	}
+	checkTimestamps("disbursement")
+}
+
+func TestDisburseLoanSetsMaturityDate(t *testing.T) {
+	env := newTestEnv(t, testOptions{})
+	ctx := context.Background()
+
+	for _, term := range []int{0, -4} {
+		params := validLoanParams(1000)
+		params.TermWeeks = term
+		if _, _, err := env.uc.CreateLoan(ctx, params); !errors.Is(err, entity.ErrValidation) {
+			t.Errorf("term of %d weeks: got error %v, want ErrValidation", term, err)
+		}
+	}
+
+	loan := env.createApprovedLoan(t, 1000)
+	env.invest(t, loan.ID, "alice@example.com", 1000)
+	summary, err := env.uc.GetLoan(ctx, loan.ID, false)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	if summary.Loan.MaturityDate != nil {
+		t.Errorf("got maturity date %s before disbursement, want none", summary.Loan.MaturityDate)
+	}
+
+	disbursementDate := entity.Now().Truncate(time.Second)
+	disbursed, err := env.uc.DisburseLoan(ctx, loan.ID, entity.DisburseLoanParams{
+		SignedAgreementDocs: []string{"/files/signed_agreements/agreement.pdf"},
+		EmployeeID:          "EMP-DISBURSER",
+		DisbursementDate:    disbursementDate,
+	})
+	if err != nil {
+		t.Fatalf("failed to disburse loan: %v", err)
+	}
+
+	// 52 weeks after the disbursement date
+	want := disbursementDate.AddDate(0, 0, 364)
+	if disbursed.MaturityDate == nil || !disbursed.MaturityDate.Equal(want) {
+		t.Errorf("got maturity date %v, want %s", disbursed.MaturityDate, want)
+	}
+	summary, err = env.uc.GetLoan(ctx, loan.ID, false)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	if summary.Loan.MaturityDate == nil || !summary.Loan.MaturityDate.Equal(want) {
+		t.Errorf("got stored maturity date %v, want %s", summary.Loan.MaturityDate, want)
+	}
+}
+
+// disburse pays out a tranche of amount for loanID, or the remaining principal when amount is zero
+func (env *testEnv) disburse(t *testing.T, loanID int64, amount float64) (*entity.Loan, error) {
+	t.Helper()
+
+	params := entity.DisburseLoanParams{
+		SignedAgreementDocs: []string{"/files/signed_agreements/agreement.pdf"},
+		EmployeeID:          "EMP-DISBURSER",
+		DisbursementDate:    entity.Now(),
+	}
+	if amount > 0 {
+		tranche := entity.NewMoney(amount)
+		params.Amount = &tranche
+	}
+	return env.uc.DisburseLoan(context.Background(), loanID, params)
+}
+
+func TestDisburseLoanInTranches(t *testing.T) {
+	t.Run("single tranche", func(t *testing.T) {
+		env := newTestEnv(t, testOptions{})
+		loan := env.createApprovedLoan(t, 1000)
+		env.invest(t, loan.ID, "alice@example.com", 1000)
+
+		disbursed, err := env.disburse(t, loan.ID, 0)
+		if err != nil {
+			t.Fatalf("failed to disburse loan: %v", err)
+		}
+		if disbursed.State != entity.StateDisbursed {
+			t.Errorf("got state %s, want disbursed", disbursed.State)
+		}
+
+		tranches, err := env.uc.ListDisbursements(context.Background(), loan.ID)
+		if err != nil {
+			t.Fatalf("failed to list disbursements: %v", err)
+		}
+		if len(tranches) != 1 || tranches[0].Amount != entity.NewMoney(1000) {
+			t.Errorf("got tranches %v, want one of the whole principal", tranches)
+		}
+	})
+
+	t.Run("several tranches", func(t *testing.T) {
+		env := newTestEnv(t, testOptions{})
+		loan := env.createApprovedLoan(t, 1000)
+		env.invest(t, loan.ID, "alice@example.com", 1000)
+
+		for _, amount := range []float64{400, 333.33} {
+			partial, err := env.disburse(t, loan.ID, amount)
+			if err != nil {
+				t.Fatalf("failed to disburse %v: %v", amount, err)
+			}
+			if partial.State != entity.StatePartiallyDisbursed {
+				t.Errorf("after %v: got state %s, want partially_disbursed", amount, partial.State)
+			}
+		}
+
+		// Tranches can never add up to more than the principal
+		if _, err := env.disburse(t, loan.ID, 266.68); !errors.Is(err, entity.ErrValidation) {
+			t.Fatalf("got error %v, want ErrValidation for a tranche over the remaining 266.67", err)
+		}
+
+		disbursed, err := env.disburse(t, loan.ID, 266.67)
+		if err != nil {
+			t.Fatalf("failed to disburse the last tranche: %v", err)
+		}
+		if disbursed.State != entity.StateDisbursed {
+			t.Errorf("got state %s, want disbursed", disbursed.State)
+		}
+
+		tranches, err := env.uc.ListDisbursements(context.Background(), loan.ID)
+		if err != nil {
+			t.Fatalf("failed to list disbursements: %v", err)
+		}
+		var total entity.Money
+		for _, tranche := range tranches {
+			total += tranche.Amount
+		}
+		if len(tranches) != 3 || total != entity.NewMoney(1000) {
+			t.Errorf("got %d tranches totalling %s, want 3 totalling 1000.00", len(tranches), total)
+		}
+
+		if _, err := env.disburse(t, loan.ID, 0); !errors.Is(err, entity.ErrInvalidState) {
+			t.Errorf("got error %v, want ErrInvalidState once disbursed", err)
+		}
+	})
+}
+
+func TestForceInvested(t *testing.T) {
+	t.Run("investments match the principal", func(t *testing.T) {
+		env := newTestEnv(t, testOptions{})
+		ctx := context.Background()
+		loan := env.createApprovedLoan(t, 1000)
+
+		// Funding reconciled outside the API leaves the loan approved
+		investments := repository.NewInvestmentRepository(env.db)
+		for _, amount := range []float64{600, 400} {
+			if err := investments.Create(ctx, &entity.Investment{
+				LoanID:        loan.ID,
+				InvestorEmail: "alice@example.com",
+				Amount:        entity.NewMoney(amount),
+				CreatedAt:     entity.Now(),
+			}); err != nil {
+				t.Fatalf("failed to create investment: %v", err)
+			}
+		}
+
+		forced, err := env.uc.ForceInvested(ctx, loan.ID, "EMP-ADMIN")
+		if err != nil {
+			t.Fatalf("failed to force loan to invested: %v", err)
+		}
+		if forced.State != entity.StateInvested {
+			t.Errorf("got state %s, want invested", forced.State)
+		}
+		if len(env.emails.fullyInvested) != 1 {
+			t.Errorf("got %d fully invested notifications, want 1", len(env.emails.fullyInvested))
+		}
+
+		history, err := env.uc.GetLoanHistory(ctx, loan.ID, domainrepository.StateTransitionFilter{})
+		if err != nil {
+			t.Fatalf("failed to get history: %v", err)
+		}
+		// Newest first
+		if latest := history.Transitions[0]; latest.ToState != entity.StateInvested || latest.Actor != "EMP-ADMIN" {
+			t.Errorf("got latest transition to %s by %s, want to invested by EMP-ADMIN", latest.ToState, latest.Actor)
+		}
+	})
+
+	t.Run("investments short of the principal", func(t *testing.T) {
+		env := newTestEnv(t, testOptions{})
+		loan := env.createApprovedLoan(t, 1000)
+		env.invest(t, loan.ID, "alice@example.com", 999.99)
+
+		_, err := env.uc.ForceInvested(context.Background(), loan.ID, "EMP-ADMIN")
+		if !errors.Is(err, entity.ErrValidation) {
+			t.Fatalf("got error %v, want ErrValidation", err)
+		}
+		if want := "investments total 999.99 but the principal is 1000.00"; !strings.Contains(err.Error(), want) {
+			t.Errorf("got error %q, want it to contain %q", err.Error(), want)
+		}
+		if len(env.emails.fullyInvested) != 0 {
+			t.Errorf("got %d fully invested notifications, want none", len(env.emails.fullyInvested))
+		}
+	})
+
+	t.Run("proposed loan", func(t *testing.T) {
+		env := newTestEnv(t, testOptions{})
+		loan := env.createLoan(t, 1000)
+
+		if _, err := env.uc.ForceInvested(context.Background(), loan.ID, "EMP-ADMIN"); !errors.Is(err, entity.ErrInvalidState) {
+			t.Fatalf("got error %v, want ErrInvalidState", err)
+		}
+	})
+}
+
+func TestFullyInvestedNotificationIsSentPerLanguage(t *testing.T) {
+	env := newTestEnv(t, testOptions{})
+	ctx := context.Background()
+	loan := env.createApprovedLoan(t, 1000)
+
+	investments := []entity.InvestLoanParams{
+		{InvestorEmail: "alice@example.com", Amount: 300},
+		{InvestorEmail: "budi@example.com", Amount: 300, Language: entity.LanguageIndonesian},
+		{InvestorEmail: "citra@example.com", Amount: 400, Language: entity.LanguageIndonesian},
+	}
+	for _, params := range investments {
+		if _, _, err := env.uc.InvestInLoan(ctx, loan.ID, params); err != nil {
+			t.Fatalf("failed to invest: %v", err)
+		}
+	}
+
+	got := make(map[string]string)
+	for _, request := range env.emails.fullyInvested {
+		got[request.Language] = strings.Join(request.InvestorEmails, ",")
+	}
+	want := map[string]string{
+		entity.LanguageEnglish:    "alice@example.com",
+		entity.LanguageIndonesian: "budi@example.com,citra@example.com",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got notifications %v, want one per language %v", got, want)
+	}
+	for language, recipients := range want {
+		if got[language] != recipients {
+			t.Errorf("got %s recipients %q, want %q", language, got[language], recipients)
+		}
+	}
+
+	// Confirmations are in each investor's own language
+	for i, confirmation := range env.emails.confirmations {
+		wantLanguage := investments[i].Language
+		if wantLanguage == "" {
+			wantLanguage = entity.LanguageEnglish
+		}
+		if confirmation.Language != wantLanguage {
+			t.Errorf("confirmation to %s is in %q, want %q", confirmation.InvestorEmail, confirmation.Language, wantLanguage)
+		}
+	}
+}
+
+func TestResendInvestedNotification(t *testing.T) {
+	env := newTestEnv(t, testOptions{})
+	ctx := context.Background()
+	loan := env.createApprovedLoan(t, 1000)
+
+	env.invest(t, loan.ID, "alice@example.com", 400)
+	if _, err := env.uc.ResendInvestedNotification(ctx, loan.ID); !errors.Is(err, entity.ErrInvalidState) {
+		t.Fatalf("got error %v, want ErrInvalidState for an approved loan", err)
+	}
+
+	env.invest(t, loan.ID, "budi@example.com", 600)
+	recipients, err := env.uc.ResendInvestedNotification(ctx, loan.ID)
+	if err != nil {
+		t.Fatalf("failed to resend notification: %v", err)
+	}
+	if recipients != 2 {
+		t.Errorf("got %d investors notified, want 2", recipients)
+	}
+
+	// The original notification when the loan became invested, then the resent one
+	if len(env.emails.fullyInvested) != 2 {
+		t.Fatalf("got %d fully invested notifications, want 2", len(env.emails.fullyInvested))
+	}
+	resent := env.emails.fullyInvested[1]
+	if got := strings.Join(resent.InvestorEmails, ","); got != "alice@example.com,budi@example.com" {
+		t.Errorf("got recipients %q, want alice@example.com,budi@example.com", got)
+	}
+	if resent.LoanID != loan.ID || resent.PrincipalAmount != entity.NewMoney(1000) {
+		t.Errorf("got notification for loan %d of %s, want loan %d of 1000.00", resent.LoanID, resent.PrincipalAmount, loan.ID)
+	}
+}
+
+func TestFullyInvestedNotificationEmailsEachInvestorOnce(t *testing.T) {
+	env := newTestEnv(t, testOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+
+	env.invest(t, loan.ID, "alice@example.com", 200)
+	env.invest(t, loan.ID, "budi@example.com", 300)
+	env.invest(t, loan.ID, "Alice@Example.com", 200)
+	env.invest(t, loan.ID, "alice@example.com", 300)
+
+	if len(env.emails.fullyInvested) != 1 {
+		t.Fatalf("got %d fully invested notifications, want 1", len(env.emails.fullyInvested))
+	}
+	// Alice invested three times, with differently cased addresses, and is emailed once
+	if got := strings.Join(env.emails.fullyInvested[0].InvestorEmails, ","); got != "alice@example.com,budi@example.com" {
+		t.Errorf("got recipients %q, want alice@example.com,budi@example.com", got)
+	}
+}
+
+func TestExpireOverdueLoansFollowsTheClock(t *testing.T) {
+	env := newTestEnv(t, testOptions{fundingWindow: 7 * 24 * time.Hour})
+	ctx := context.Background()
+
+	overdue := env.createApprovedLoan(t, 1000)
+	env.invest(t, overdue.ID, "alice@example.com", 400)
+	funded := env.createApprovedLoan(t, 1000)
+	env.invest(t, funded.ID, "alice@example.com", 1000)
+
+	// Nothing is due before the deadline
+	if expired, err := env.uc.ExpireOverdueLoans(ctx); err != nil || expired != 0 {
+		t.Fatalf("got %d expired loans and error %v before the deadline, want 0", expired, err)
+	}
+
+	deadline := *overdue.FundingDeadline
+	restore := entity.SetClock(func() time.Time { return deadline.Add(time.Second) })
+	defer restore()
+
+	expired, err := env.uc.ExpireOverdueLoans(ctx)
+	if err != nil {
+		t.Fatalf("failed to expire overdue loans: %v", err)
+	}
+	if expired != 1 {
+		t.Errorf("got %d expired loans, want 1", expired)
+	}
+
+	summary, err := env.uc.GetLoan(ctx, overdue.ID, false)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	if summary.Loan.State != entity.StateExpired {
+		t.Errorf("got state %s, want expired", summary.Loan.State)
+	}
+	// The investments are kept, but no more can be made
+	if summary.TotalInvested != entity.NewMoney(400) {
+		t.Errorf("got %s invested, want the 400.00 invested before expiry", summary.TotalInvested)
+	}
+	if _, _, err := env.uc.InvestInLoan(ctx, overdue.ID, entity.InvestLoanParams{InvestorEmail: "budi@example.com", Amount: 100}); !errors.Is(err, entity.ErrInvalidState) {
+		t.Errorf("got error %v investing in an expired loan, want ErrInvalidState", err)
+	}
+
+	// A fully funded loan never expires
+	summary, err = env.uc.GetLoan(ctx, funded.ID, false)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	if summary.Loan.State != entity.StateInvested {
+		t.Errorf("got state %s for the funded loan, want invested", summary.Loan.State)
+	}
+}
+
+func TestInvestmentsSummingToThePrincipalCompleteTheLoan(t *testing.T) {
+	env := newTestEnv(t, testOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+
+	// 200.2 + 499.9 + 299.9 is 999.9999999999999 in float64
+	for _, amount := range []float64{200.2, 499.9, 299.9} {
+		env.invest(t, loan.ID, "alice@example.com", amount)
+	}
+
+	summary, err := env.uc.GetLoan(context.Background(), loan.ID, false)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	if summary.Loan.State != entity.StateInvested {
+		t.Errorf("got state %s, want invested", summary.Loan.State)
+	}
+	if summary.TotalInvested != entity.NewMoney(1000) || summary.RemainingAmount != 0 {
+		t.Errorf("got %s invested and %s remaining, want 1000.00 and 0.00", summary.TotalInvested, summary.RemainingAmount)
+	}
+	if len(env.emails.fullyInvested) != 1 {
+		t.Errorf("got %d fully invested notifications, want 1", len(env.emails.fullyInvested))
+	}
+}
+
+func TestThreeThirdsAndACentCompleteTheLoan(t *testing.T) {
+	env := newTestEnv(t, testOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+
+	for _, investor := range []string{"alice@example.com", "budi@example.com", "citra@example.com"} {
+		env.invest(t, loan.ID, investor, 333.33)
+	}
+	env.invest(t, loan.ID, "dewi@example.com", 0.01)
+
+	summary, err := env.uc.GetLoan(context.Background(), loan.ID, false)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	if summary.Loan.State != entity.StateInvested {
+		t.Errorf("got state %s, want invested", summary.Loan.State)
+	}
+	if summary.TotalInvested != entity.NewMoney(1000) {
+		t.Errorf("got %s invested, want exactly 1000.00", summary.TotalInvested)
+	}
+	if len(env.emails.fullyInvested) != 1 || len(env.emails.fullyInvested[0].InvestorEmails) != 4 {
+		t.Errorf("got fully invested notifications %v, want one to the four investors", env.emails.fullyInvested)
+	}
+}
+
+func TestUpdateROI(t *testing.T) {
+	t.Run("approved loan without investments", func(t *testing.T) {
+		env := newTestEnv(t, testOptions{})
+		ctx := context.Background()
+		loan := env.createApprovedLoan(t, 1000)
+
+		updated, err := env.uc.UpdateROI(ctx, loan.ID, 11.5, "EMP-OPS")
+		if err != nil {
+			t.Fatalf("failed to update ROI: %v", err)
+		}
+		if updated.ROI != 11.5 {
+			t.Errorf("got ROI %.2f, want 11.50", updated.ROI)
+		}
+
+		history, err := env.uc.GetLoanHistory(ctx, loan.ID, domainrepository.StateTransitionFilter{})
+		if err != nil {
+			t.Fatalf("failed to get history: %v", err)
+		}
+		latest := history.Transitions[0]
+		if latest.Note != "roi changed from 10.00 to 11.50" || latest.Actor != "EMP-OPS" || latest.FromState != latest.ToState {
+			t.Errorf("got latest audit entry %q by %s from %s to %s, want the ROI change by EMP-OPS without a state change",
+				latest.Note, latest.Actor, latest.FromState, latest.ToState)
+		}
+
+		// The borrower rate is the ceiling
+		if _, err := env.uc.UpdateROI(ctx, loan.ID, 12.5, "EMP-OPS"); !errors.Is(err, entity.ErrValidation) {
+			t.Errorf("got error %v for an ROI above the rate, want ErrValidation", err)
+		}
+	})
+
+	t.Run("approved loan with investments", func(t *testing.T) {
+		env := newTestEnv(t, testOptions{})
+		loan := env.createApprovedLoan(t, 1000)
+		env.invest(t, loan.ID, "alice@example.com", 100)
+
+		_, err := env.uc.UpdateROI(context.Background(), loan.ID, 11.5, "EMP-OPS")
+		if !errors.Is(err, entity.ErrInvalidState) {
+			t.Fatalf("got error %v, want ErrInvalidState", err)
+		}
+
+		summary, err := env.uc.GetLoan(context.Background(), loan.ID, false)
+		if err != nil {
+			t.Fatalf("failed to get loan: %v", err)
+		}
+		if summary.Loan.ROI != 10 {
+			t.Errorf("got ROI %.2f, want the unchanged 10.00", summary.Loan.ROI)
+		}
+	})
+
+	t.Run("proposed loan", func(t *testing.T) {
+		env := newTestEnv(t, testOptions{})
+		loan := env.createLoan(t, 1000)
+
+		if _, err := env.uc.UpdateROI(context.Background(), loan.ID, 11.5, "EMP-OPS"); !errors.Is(err, entity.ErrInvalidState) {
+			t.Errorf("got error %v, want ErrInvalidState", err)
+		}
+	})
+}
+
+// stubFXConverter implements service.FXConverter converting USD to IDR at a
+// fixed rate, recording the conversions
+type stubFXConverter struct {
+	mu          sync.Mutex
+	conversions []string // "amount from->to"
+}
+
+func (c *stubFXConverter) Convert(ctx context.Context, amount entity.Money, from, to string) (entity.Money, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conversions = append(c.conversions, fmt.Sprintf("%s %s->%s", amount, from, to))
+
+	switch {
+	case from == to:
+		return amount, nil
+	case from == "USD" && to == "IDR":
+		return amount.Convert(16000), nil
+	}
+	return 0, entity.NewDomainError(entity.ErrValidation, fmt.Sprintf("no exchange rate from %s to %s", from, to))
+}
+
+func TestInvestInLoanConvertsToTheLoanCurrency(t *testing.T) {
+	converter := &stubFXConverter{}
+	env := newTestEnv(t, testOptions{fxConverter: converter})
+	ctx := context.Background()
+	loan := env.createApprovedLoan(t, 1_600_000)
+
+	investment, _, err := env.uc.InvestInLoan(ctx, loan.ID, entity.InvestLoanParams{InvestorEmail: "alice@example.com", Amount: 60.5, Currency: "USD"})
+	if err != nil {
+		t.Fatalf("failed to invest in USD: %v", err)
+	}
+	// The original amount is kept next to the converted one
+	if investment.Amount != entity.NewMoney(968_000) || investment.OriginalAmount != entity.NewMoney(60.5) || investment.Currency != "USD" {
+		t.Errorf("got %s converted from %s %s, want 968000.00 from USD 60.50", investment.Amount, investment.Currency, investment.OriginalAmount)
+	}
+	if len(converter.conversions) != 1 || converter.conversions[0] != "60.50 USD->IDR" {
+		t.Errorf("got conversions %v, want 60.50 USD->IDR", converter.conversions)
+	}
+
+	if _, _, err := env.uc.InvestInLoan(ctx, loan.ID, entity.InvestLoanParams{InvestorEmail: "budi@example.com", Amount: 10, Currency: "EUR"}); !errors.Is(err, entity.ErrValidation) {
+		t.Errorf("got error %v for a currency without a rate, want ErrValidation", err)
+	}
+
+	// The converted amounts count toward funding
+	env.invest(t, loan.ID, "budi@example.com", 632_000)
+	summary, err := env.uc.GetLoan(ctx, loan.ID, false)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	if summary.Loan.State != entity.StateInvested || summary.TotalInvested != entity.NewMoney(1_600_000) {
+		t.Errorf("got %s loan with %s invested, want invested with 1600000.00", summary.Loan.State, summary.TotalInvested)
+	}
+}
+
+func TestCreateLoanEnforcesAgreementDomains(t *testing.T) {
+	env := newTestEnv(t, testOptions{agreementDomains: []string{"example.com"}})
+
+	params := validLoanParams(1000)
+	params.AgreementLetterLink = "https://evil.com/agreements/1.pdf"
+	if _, _, err := env.uc.CreateLoan(context.Background(), params); !errors.Is(err, entity.ErrValidation) {
+		t.Fatalf("got error %v, want ErrValidation", err)
+	}
+
+	params.AgreementLetterLink = "https://files.example.com/agreements/1.pdf"
+	if _, _, err := env.uc.CreateLoan(context.Background(), params); err != nil {
+		t.Errorf("got error %v for an allowed domain, want none", err)
+	}
+}
+
+func TestInvestInLoanRoundsSubCentAmounts(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   float64
+	}{
+		{100.999, 101},
+		{100.994, 100.99},
+		{1.005, 1.01}, // Half-up, although the float is slightly below 1.005
+		{0.005, 0.01},
+		{250.5, 250.5},
+	}
+
+	env := newTestEnv(t, testOptions{})
+	loan := env.createApprovedLoan(t, 1000)
+	for i, tt := range tests {
+		investment := env.invest(t, loan.ID, fmt.Sprintf("investor%d@example.com", i), tt.amount)
+		if investment.Amount != entity.NewMoney(tt.want) {
+			t.Errorf("investing %v: got %s, want %.2f", tt.amount, investment.Amount, tt.want)
+		}
+	}
+
+	// The stored amounts are the rounded ones
+	page, err := env.uc.ListInvestments(context.Background(), loan.ID, domainrepository.InvestmentFilter{})
+	if err != nil {
+		t.Fatalf("failed to list investments: %v", err)
+	}
+	var total entity.Money
+	for _, investment := range page.Investments {
+		total += investment.Amount
+	}
+	if total != entity.NewMoney(453.51) {
+		t.Errorf("got %s stored in total, want 453.51", total)
+	}
+}
+
+func TestInvestInLoanStrictAmountsRejectsSubCentAmounts(t *testing.T) {
+	env := newTestEnv(t, testOptions{strictAmounts: true})
+	loan := env.createApprovedLoan(t, 1000)
+
+	_, _, err := env.uc.InvestInLoan(context.Background(), loan.ID, entity.InvestLoanParams{InvestorEmail: "alice@example.com", Amount: 100.999})
+	if !errors.Is(err, entity.ErrValidation) {
+		t.Fatalf("got error %v, want ErrValidation", err)
+	}
+
+	if investment := env.invest(t, loan.ID, "alice@example.com", 100.99); investment.Amount != entity.NewMoney(100.99) {
+		t.Errorf("got %s, want 100.99", investment.Amount)
+	}
+}
+
+func TestInvestInLoanRequiresTheAgreementLink(t *testing.T) {
+	env := newTestEnv(t, testOptions{})
+	ctx := context.Background()
+	loan := env.createApprovedLoan(t, 1000)
+
+	// Loans stored before the link was required have it NULL
+	if _, err := env.db.DB.ExecContext(ctx, "UPDATE loans SET agreement_letter_link = NULL WHERE id = ?", loan.ID); err != nil {
+		t.Fatalf("failed to clear the agreement link: %v", err)
+	}
+
+	_, _, err := env.uc.InvestInLoan(ctx, loan.ID, entity.InvestLoanParams{InvestorEmail: "alice@example.com", Amount: 100})
+	if !errors.Is(err, entity.ErrInvalidState) {
+		t.Fatalf("got error %v, want ErrInvalidState", err)
+	}
+	if want := "missing its agreement letter link"; !strings.Contains(err.Error(), want) {
+		t.Errorf("got message %q, want it to contain %q", err.Error(), want)
+	}
+
+	summary, err := env.uc.GetLoan(ctx, loan.ID, false)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	if summary.InvestmentCount != 0 {
+		t.Errorf("got %d investments, want none stored", summary.InvestmentCount)
+	}
+}
+
+func TestScheduledApprovalTakesEffectWhenDue(t *testing.T) {
+	env := newTestEnv(t, testOptions{fundingWindow: 7 * 24 * time.Hour})
+	ctx := context.Background()
+
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	restore := entity.SetClock(func() time.Time { return now })
+	defer restore()
+
+	loan := env.createLoan(t, 1000)
+	effectiveAt := now.Add(24 * time.Hour)
+	scheduled, err := env.uc.ApproveLoan(ctx, loan.ID, entity.ApproveLoanParams{
+		ProofPicture: "/files/proof_pictures/proof.jpg",
+		EmployeeID:   "EMP-APPROVER",
+		ApprovalDate: now,
+		EffectiveAt:  &effectiveAt,
+	})
+	if err != nil {
+		t.Fatalf("failed to schedule approval: %v", err)
+	}
+	if scheduled.State != entity.StateProposed {
+		t.Errorf("got state %s after scheduling, want proposed until the approval is due", scheduled.State)
+	}
+	if _, _, err := env.uc.InvestInLoan(ctx, loan.ID, entity.InvestLoanParams{InvestorEmail: "alice@example.com", Amount: 100}); !errors.Is(err, entity.ErrInvalidState) {
+		t.Errorf("got error %v investing before the approval is due, want ErrInvalidState", err)
+	}
+
+	// One second early, the activator leaves the loan alone
+	now = effectiveAt.Add(-time.Second)
+	if activated, err := env.uc.ActivateScheduledApprovals(ctx); err != nil || activated != 0 {
+		t.Fatalf("got %d activations and error %v before the effective time, want 0", activated, err)
+	}
+
+	now = effectiveAt.Add(time.Minute)
+	activated, err := env.uc.ActivateScheduledApprovals(ctx)
+	if err != nil {
+		t.Fatalf("failed to activate scheduled approvals: %v", err)
+	}
+	if activated != 1 {
+		t.Errorf("got %d activations, want 1", activated)
+	}
+
+	summary, err := env.uc.GetLoan(ctx, loan.ID, false)
+	if err != nil {
+		t.Fatalf("failed to get loan: %v", err)
+	}
+	if summary.Loan.State != entity.StateApproved {
+		t.Fatalf("got state %s after the effective time, want approved", summary.Loan.State)
+	}
+	// The funding window runs from the effective time, not from the late activation
+	if want := effectiveAt.Add(7 * 24 * time.Hour); summary.Loan.FundingDeadline == nil || !summary.Loan.FundingDeadline.Equal(want) {
+		t.Errorf("got funding deadline %v, want %v", summary.Loan.FundingDeadline, want)
+	}
+	if len(env.emails.approved) != 1 {
+		t.Errorf("got %d approval emails, want 1 once the approval took effect", len(env.emails.approved))
+	}
+
+	// A second run finds nothing left to activate
+	if activated, err := env.uc.ActivateScheduledApprovals(ctx); err != nil || activated != 0 {
+		t.Errorf("got %d activations and error %v on the second run, want 0", activated, err)
+	}
+	env.invest(t, loan.ID, "alice@example.com", 100)
+}
+
+func TestApproveLoanRequiresTwoApproversAboveTheThreshold(t *testing.T) {
+	env := newTestEnv(t, testOptions{dualApprovalThreshold: entity.NewMoney(5000)})
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		principal float64
+		required  int
+	}{
+		{"at the threshold", 5000, 1},
+		{"above the threshold", 5000.01, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := env.createLoan(t, tt.principal)
+			if loan.RequiredApprovals != tt.required {
+				t.Fatalf("got %d required approvals, want %d", loan.RequiredApprovals, tt.required)
+			}
+
+			loan = env.approveLoan(t, loan.ID, "EMP-MAKER")
+			if tt.required == 1 {
+				if loan.State != entity.StateApproved {
+					t.Errorf("got state %s after one approval, want approved", loan.State)
+				}
+				return
+			}
+			if loan.State != entity.StatePendingApproval {
+				t.Fatalf("got state %s after one approval, want pending_approval", loan.State)
+			}
+
+			// The maker cannot also be the checker
+			_, err := env.uc.ApproveLoan(ctx, loan.ID, entity.ApproveLoanParams{
+				ProofPicture: "/files/proof_pictures/proof.jpg",
+				EmployeeID:   "EMP-MAKER",
+				ApprovalDate: entity.Now(),
+			})
+			if !errors.Is(err, entity.ErrInvalidState) {
+				t.Fatalf("got error %v approving twice, want ErrInvalidState", err)
+			}
+
+			loan = env.approveLoan(t, loan.ID, "EMP-CHECKER")
+			if loan.State != entity.StateApproved {
+				t.Errorf("got state %s after the second approver, want approved", loan.State)
+			}
+
+			summary, err := env.uc.GetLoan(ctx, loan.ID, false)
+			if err != nil {
+				t.Fatalf("failed to get loan: %v", err)
+			}
+			if len(summary.Approvals) != 2 || summary.Approvals[0].EmployeeID != "EMP-MAKER" || summary.Approvals[1].EmployeeID != "EMP-CHECKER" {
+				t.Errorf("got approvals %v, want the maker's then the checker's", summary.Approvals)
+			}
+
+			// Neither approver may disburse, the maker no more than the checker
+			env.invest(t, loan.ID, "alice@example.com", tt.principal)
+			for _, employeeID := range []string{"EMP-MAKER", "EMP-CHECKER"} {
+				_, err := env.uc.DisburseLoan(ctx, loan.ID, entity.DisburseLoanParams{
+					SignedAgreementDocs: []string{"/files/signed_agreements/agreement.pdf"},
+					EmployeeID:          employeeID,
+					DisbursementDate:    entity.Now(),
+				})
+				if !errors.Is(err, entity.ErrForbidden) {
+					t.Errorf("got error %v disbursing as %s, want ErrForbidden", err, employeeID)
+				}
+			}
+			if _, err := env.disburse(t, loan.ID, 0); err != nil {
+				t.Errorf("got error %v disbursing as a third employee, want none", err)
+			}
+		})
+	}
+}
+
+func TestInvestInLoanQueuesAConfirmationForTheInvestor(t *testing.T) {
+	recorder := &recordingEmailService{}
+	queue := email.NewAsyncEmailService(recorder, 1, 10)
+	queue.Start()
+
+	env := newTestEnv(t, testOptions{emailService: queue})
+	loan := env.createApprovedLoan(t, 1000)
+	investment := env.invest(t, loan.ID, "alice@example.com", 250)
+
+	// Shutting down waits for the queued emails to be sent
+	if err := queue.Shutdown(context.Background()); err != nil {
+		t.Fatalf("failed to drain the queue: %v", err)
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.confirmations) != 1 {
+		t.Fatalf("got %d confirmations, want 1", len(recorder.confirmations))
+	}
+	got := recorder.confirmations[0]
+	if got.InvestorEmail != "alice@example.com" || got.InvestmentID != investment.ID || got.LoanID != loan.ID {
+		t.Errorf("got confirmation to %s for investment %d in loan %d, want alice@example.com for %d in %d",
+			got.InvestorEmail, got.InvestmentID, got.LoanID, investment.ID, loan.ID)
+	}
+	if got.Amount != entity.NewMoney(250) || got.PrincipalAmount != entity.NewMoney(1000) || got.FundedPercentage != 25 {
+		t.Errorf("got %s of %s at %.2f%% funded, want 250.00 of 1000.00 at 25.00%%", got.Amount, got.PrincipalAmount, got.FundedPercentage)
+	}
+	if len(recorder.fullyInvested) != 0 {
+		t.Errorf("got %d fully invested notifications for a partly funded loan, want none", len(recorder.fullyInvested))
+	}
+}
+
+func TestInvestInLoanRejectsApprovalsNotYetInEffect(t *testing.T) {
+	env := newTestEnv(t, testOptions{})
+	ctx := context.Background()
+	invest := func(loanID int64) error {
+		_, _, err := env.uc.InvestInLoan(ctx, loanID, entity.InvestLoanParams{InvestorEmail: "alice@example.com", Amount: 100})
+		return err
+	}
+
+	// An approval scheduled for tomorrow
+	scheduled := env.createLoan(t, 1000)
+	effectiveAt := entity.Now().Add(24 * time.Hour)
+	if _, err := env.uc.ApproveLoan(ctx, scheduled.ID, entity.ApproveLoanParams{
+		ProofPicture: "/files/proof_pictures/proof.jpg",
+		EmployeeID:   "EMP-APPROVER",
+		ApprovalDate: entity.Now(),
+		EffectiveAt:  &effectiveAt,
+	}); err != nil {
+		t.Fatalf("failed to schedule approval: %v", err)
+	}
+
+	// An approved loan whose stored approval date is ahead of the clock
+	aheadOfClock := env.createApprovedLoan(t, 1000)
+	if _, err := env.db.DB.ExecContext(ctx, "UPDATE loans SET approval_date = ? WHERE id = ?", entity.Now().Add(time.Hour), aheadOfClock.ID); err != nil {
+		t.Fatalf("failed to move the approval date: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		loanID  int64
+		wantErr string
+	}{
+		{"scheduled approval", scheduled.ID, "cannot receive investments before then"},
+		{"approval date in the future", aheadOfClock.ID, "is in the future, it cannot receive investments before then"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := invest(tt.loanID)
+			if !errors.Is(err, entity.ErrInvalidState) {
+				t.Fatalf("got error %v, want ErrInvalidState", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got message %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+
+	// Once the clock passes the approval date, investing is allowed
+	restore := entity.SetClock(func() time.Time { return time.Now().Add(2 * time.Hour) })
+	defer restore()
+	if err := invest(aheadOfClock.ID); err != nil {
+		t.Errorf("got error %v after the approval date, want the investment accepted", err)
+	}
+}
+
+func TestCreateLoanWarnsAboutSoftLimits(t *testing.T) {
+	env := newTestEnv(t, testOptions{warningThresholds: entity.LoanWarningThresholds{Rate: 20, Principal: entity.NewMoney(50000)}})
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		principal float64
+		rate      float64
+		want      []string
+	}{
+		{"within the norms", 50000, 20, nil},
+		{"high rate", 1000, 24.5, []string{"rate 24.5% is above the usual 20%"}},
+		{"large principal", 50000.01, 12, []string{"principal 50000.01 IDR is above the usual 50000.00"}},
+		{"both", 80000, 30, []string{"rate 30% is above the usual 20%", "principal 80000.00 IDR is above the usual 50000.00"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := validLoanParams(tt.principal)
+			params.Rate = tt.rate
+			loan, warnings, err := env.uc.CreateLoan(ctx, params)
+			if err != nil {
+				t.Fatalf("got error %v, want the loan created despite the warnings", err)
+			}
+			if strings.Join(warnings, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("got warnings %q, want %q", warnings, tt.want)
+			}
+
+			// The loan is saved as usual
+			summary, err := env.uc.GetLoan(ctx, loan.ID, false)
+			if err != nil {
+				t.Fatalf("failed to get loan: %v", err)
+			}
+			if summary.Loan.State != entity.StateProposed || summary.Loan.Rate != tt.rate {
+				t.Errorf("got %s loan at %v%%, want a proposed loan at %v%%", summary.Loan.State, summary.Loan.Rate, tt.rate)
+			}
+		})
+	}
+}
@@ -1,81 +1,604 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
+	nethttp "net/http"
 	"os"
 	"os/signal"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"amartha-andreas/internal/delivery/http"
+	"amartha-andreas/internal/domain/entity"
+	domainrepository "amartha-andreas/internal/domain/repository"
 	"amartha-andreas/internal/domain/service"
+	"amartha-andreas/internal/infrastructure/approval"
 	"amartha-andreas/internal/infrastructure/database"
 	"amartha-andreas/internal/infrastructure/email"
+	"amartha-andreas/internal/infrastructure/expiry"
+	"amartha-andreas/internal/infrastructure/fx"
+	"amartha-andreas/internal/infrastructure/logging"
+	"amartha-andreas/internal/infrastructure/metrics"
+	"amartha-andreas/internal/infrastructure/ratelimit"
+	"amartha-andreas/internal/infrastructure/receipt"
+	"amartha-andreas/internal/infrastructure/storage"
+	"amartha-andreas/internal/infrastructure/webhook"
 	"amartha-andreas/internal/repository"
 	"amartha-andreas/internal/usecase"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Build identification, set with -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse --short HEAD)"
+var (
+	version = "dev"
+	commit  = ""
 )
 
 func main() {
-	// Initialize database
-	db, err := database.NewDatabase("./loan_engine.db")
+	// Log JSON to stdout at LOG_LEVEL; the standard log package is routed through it too
+	logLevel := slog.LevelInfo
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		if err := logLevel.UnmarshalText([]byte(value)); err != nil {
+			log.Fatal("Invalid LOG_LEVEL:", err)
+		}
+	}
+	logger := logging.NewJSONLogger(os.Stdout, logLevel)
+	slog.SetDefault(logger)
+
+	// Serve HTTPS when a certificate is configured. The pair is loaded before
+	// anything else so a bad TLS setup stops the server right away.
+	tlsConfig, err := loadTLSConfig(os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Initialize database (SQLite by default, Postgres when DB_DRIVER=postgres)
+	dbConfig := database.DBConfig{
+		Driver: os.Getenv("DB_DRIVER"),
+		DSN:    os.Getenv("DATABASE_URL"),
+	}
+	if dbConfig.Driver == "" {
+		dbConfig.Driver = database.DriverSQLite
+	}
+	if dbConfig.DSN == "" && dbConfig.Driver == database.DriverSQLite {
+		// _txlock=immediate makes transactions take the write lock up front so
+		// concurrent investments are serialized instead of failing mid-transaction;
+		// _foreign_keys=on enforces references to loans as Postgres does
+		dbConfig.DSN = "./loan_engine.db?_txlock=immediate&_busy_timeout=5000&_foreign_keys=on"
+	}
+
+	// Optional pool overrides, the driver defaults apply otherwise
+	if value := os.Getenv("DB_MAX_OPEN_CONNS"); value != "" {
+		dbConfig.MaxOpenConns, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatal("Invalid DB_MAX_OPEN_CONNS:", err)
+		}
+	}
+	if value := os.Getenv("DB_MAX_IDLE_CONNS"); value != "" {
+		dbConfig.MaxIdleConns, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatal("Invalid DB_MAX_IDLE_CONNS:", err)
+		}
+	}
+	if value := os.Getenv("DB_CONN_MAX_LIFETIME"); value != "" {
+		dbConfig.ConnMaxLifetime, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatal("Invalid DB_CONN_MAX_LIFETIME:", err)
+		}
+	}
+	if value := os.Getenv("DB_CONNECT_ATTEMPTS"); value != "" {
+		dbConfig.ConnectAttempts, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatal("Invalid DB_CONNECT_ATTEMPTS:", err)
+		}
+	}
+	if value := os.Getenv("DB_CONNECT_RETRY_DELAY"); value != "" {
+		dbConfig.ConnectRetryDelay, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatal("Invalid DB_CONNECT_RETRY_DELAY:", err)
+		}
+	}
+	db, err := database.NewDatabaseWithConfig(dbConfig)
 	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
-	defer db.Close()
 
 	// Initialize repositories
 	loanRepo := repository.NewLoanRepository(db)
 	investmentRepo := repository.NewInvestmentRepository(db)
+	stateTransitionRepo := repository.NewStateTransitionRepository(db)
+	disbursementRepo := repository.NewDisbursementRepository(db)
+	loanDocumentRepo := repository.NewLoanDocumentRepository(db)
+	loanApprovalRepo := repository.NewLoanApprovalRepository(db)
+
+	// Base URL of the uploaded files in local storage, used to identify them and in emails
+	fileBaseURL := os.Getenv("FILE_BASE_URL")
+	if fileBaseURL == "" {
+		fileBaseURL = http.DefaultBaseFileURL
+	}
+
+	// Initialize file storage (local disk by default, S3-compatible bucket when S3_BUCKET is set)
+	var fileStorage service.FileStorage
+	storageBackend := "local"
+	s3Bucket := os.Getenv("S3_BUCKET")
+	if s3Bucket != "" {
+		storageBackend = "s3"
+		s3Config := storage.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    s3Bucket,
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			UseSSL:    os.Getenv("S3_USE_SSL") != "false",
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		}
+		if s3Config.Endpoint == "" {
+			s3Config.Endpoint = "s3.amazonaws.com"
+		}
+		fileStorage, err = storage.NewS3Storage(s3Config)
+		if err != nil {
+			log.Fatal("Failed to initialize file storage:", err)
+		}
+		log.Printf("Using S3 file storage (bucket %s)", s3Bucket)
+	} else {
+		fileStorage = storage.NewLocalStorage("./uploads", fileBaseURL)
+		log.Println("Using local file storage (set S3_BUCKET to use S3)")
+	}
 
-	// Initialize email service
+	// Initialize email service (EMAIL_PROVIDER=smtp or sendgrid; SendGrid when
+	// SENDGRID_API_KEY is set and no provider is chosen, otherwise a mock)
 	var emailService service.EmailService
+	emailProvider := os.Getenv("EMAIL_PROVIDER")
 	sendGridAPIKey := os.Getenv("SENDGRID_API_KEY")
-	if sendGridAPIKey != "" {
+	if emailProvider == "" && sendGridAPIKey != "" {
+		emailProvider = "sendgrid"
+	}
+	switch emailProvider {
+	case "sendgrid":
+		if sendGridAPIKey == "" {
+			log.Fatal("SENDGRID_API_KEY must be set when EMAIL_PROVIDER=sendgrid")
+		}
 		emailConfig := email.SendGridConfig{
-			APIKey:    sendGridAPIKey,
-			FromEmail: os.Getenv("FROM_EMAIL"),
-			FromName:  "Amartha Loan Engine",
+			APIKey:      sendGridAPIKey,
+			FromEmail:   os.Getenv("FROM_EMAIL"),
+			FromName:    "Amartha Loan Engine",
+			OpsEmail:    os.Getenv("OPS_EMAIL"),
+			FileBaseURL: fileBaseURL,
+		}
+		if value := os.Getenv("SENDGRID_MAX_ATTEMPTS"); value != "" {
+			emailConfig.MaxAttempts, err = strconv.Atoi(value)
+			if err != nil {
+				log.Fatal("Invalid SENDGRID_MAX_ATTEMPTS:", err)
+			}
+		}
+		if value := os.Getenv("SENDGRID_RETRY_BASE_DELAY"); value != "" {
+			emailConfig.RetryBaseDelay, err = time.ParseDuration(value)
+			if err != nil {
+				log.Fatal("Invalid SENDGRID_RETRY_BASE_DELAY:", err)
+			}
+		}
+		// Per email type senders, e.g. investment_confirmation=noreply@amartha.com
+		emailConfig.Senders, err = email.ParseSenders(os.Getenv("SENDGRID_SENDERS"))
+		if err != nil {
+			log.Fatal("Invalid SENDGRID_SENDERS:", err)
 		}
 		emailService = email.NewSendGridService(emailConfig)
 		log.Println("Using SendGrid email service")
-	} else {
+	case "smtp":
+		smtpConfig := email.SMTPConfig{
+			Host:        os.Getenv("SMTP_HOST"),
+			Username:    os.Getenv("SMTP_USERNAME"),
+			Password:    os.Getenv("SMTP_PASSWORD"),
+			TLSMode:     os.Getenv("SMTP_TLS"),
+			FromEmail:   os.Getenv("FROM_EMAIL"),
+			FromName:    "Amartha Loan Engine",
+			OpsEmail:    os.Getenv("OPS_EMAIL"),
+			FileBaseURL: fileBaseURL,
+		}
+		if smtpConfig.Host == "" {
+			log.Fatal("SMTP_HOST must be set when EMAIL_PROVIDER=smtp")
+		}
+		if value := os.Getenv("SMTP_PORT"); value != "" {
+			smtpConfig.Port, err = strconv.Atoi(value)
+			if err != nil {
+				log.Fatal("Invalid SMTP_PORT:", err)
+			}
+		}
+		switch smtpConfig.TLSMode {
+		case "", email.SMTPTLSStartTLS, email.SMTPTLSImplicit, email.SMTPTLSNone:
+		default:
+			log.Fatalf("Invalid SMTP_TLS %q, expected %s, %s or %s", smtpConfig.TLSMode, email.SMTPTLSStartTLS, email.SMTPTLSImplicit, email.SMTPTLSNone)
+		}
+		emailService = email.NewSMTPService(smtpConfig)
+		log.Printf("Using SMTP email service (%s)", smtpConfig.Host)
+	case "":
 		emailService = email.NewMockEmailService()
-		log.Println("Using mock email service (set SENDGRID_API_KEY to use real emails)")
+		log.Println("Using mock email service (set SENDGRID_API_KEY or EMAIL_PROVIDER to use real emails)")
+	default:
+		log.Fatalf("Invalid EMAIL_PROVIDER %q, expected sendgrid or smtp", emailProvider)
+	}
+
+	// Send emails from a background worker pool so requests don't wait on the provider
+	emailWorkers, emailQueueSize := 4, 100
+	if value := os.Getenv("EMAIL_WORKERS"); value != "" {
+		emailWorkers, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatal("Invalid EMAIL_WORKERS:", err)
+		}
+	}
+	if value := os.Getenv("EMAIL_QUEUE_SIZE"); value != "" {
+		emailQueueSize, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatal("Invalid EMAIL_QUEUE_SIZE:", err)
+		}
+	}
+	asyncEmailService := email.NewAsyncEmailService(emailService, emailWorkers, emailQueueSize)
+	asyncEmailService.Start()
+
+	// Push loan state changes to WEBHOOK_URL, signed with WEBHOOK_SECRET
+	var webhookNotifier service.WebhookNotifier
+	webhookURL := os.Getenv("WEBHOOK_URL")
+	if webhookURL != "" {
+		webhookConfig := webhook.HTTPConfig{
+			URL:    webhookURL,
+			Secret: os.Getenv("WEBHOOK_SECRET"),
+		}
+		if webhookConfig.Secret == "" {
+			log.Fatal("WEBHOOK_SECRET must be set when WEBHOOK_URL is set")
+		}
+		if value := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); value != "" {
+			webhookConfig.MaxAttempts, err = strconv.Atoi(value)
+			if err != nil {
+				log.Fatal("Invalid WEBHOOK_MAX_ATTEMPTS:", err)
+			}
+		}
+		if value := os.Getenv("WEBHOOK_RETRY_BASE_DELAY"); value != "" {
+			webhookConfig.RetryBaseDelay, err = time.ParseDuration(value)
+			if err != nil {
+				log.Fatal("Invalid WEBHOOK_RETRY_BASE_DELAY:", err)
+			}
+		}
+		webhookNotifier = webhook.NewHTTPNotifier(webhookConfig)
+		log.Printf("Sending loan state change webhooks to %s", webhookURL)
+	} else {
+		webhookNotifier = webhook.NewNoopNotifier()
+		log.Println("Loan state change webhooks disabled (set WEBHOOK_URL to enable)")
+	}
+	asyncWebhookNotifier := webhook.NewAsyncNotifier(webhookNotifier, 100)
+	asyncWebhookNotifier.Start()
+
+	// Prometheus metrics, served on /metrics
+	prometheusMetrics := metrics.NewPrometheus(prometheus.NewRegistry())
+
+	// Approved loans must be fully funded within FUNDING_WINDOW, e.g. 720h; no deadline when unset
+	var fundingWindow time.Duration
+	if value := os.Getenv("FUNDING_WINDOW"); value != "" {
+		fundingWindow, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatal("Invalid FUNDING_WINDOW:", err)
+		}
+	}
+
+	// Reject investment amounts with more than two decimals instead of rounding them
+	var strictAmounts bool
+	if value := os.Getenv("STRICT_AMOUNT_PRECISION"); value != "" {
+		strictAmounts, err = strconv.ParseBool(value)
+		if err != nil {
+			log.Fatal("Invalid STRICT_AMOUNT_PRECISION:", err)
+		}
+	}
+
+	// Loans with a principal above DUAL_APPROVAL_THRESHOLD need two distinct approvers; one approver when unset
+	var dualApprovalThreshold entity.Money
+	if value := os.Getenv("DUAL_APPROVAL_THRESHOLD"); value != "" {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil || threshold <= 0 {
+			log.Fatal("Invalid DUAL_APPROVAL_THRESHOLD: must be a positive amount")
+		}
+		dualApprovalThreshold = entity.NewMoney(threshold)
+	}
+
+	// New loans above LOAN_WARNING_RATE or LOAN_WARNING_PRINCIPAL are created with a warning for ops
+	var warningThresholds entity.LoanWarningThresholds
+	if value := os.Getenv("LOAN_WARNING_RATE"); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate <= 0 {
+			log.Fatal("Invalid LOAN_WARNING_RATE: must be a positive percentage")
+		}
+		warningThresholds.Rate = rate
+	}
+	if value := os.Getenv("LOAN_WARNING_PRINCIPAL"); value != "" {
+		principal, err := strconv.ParseFloat(value, 64)
+		if err != nil || principal <= 0 {
+			log.Fatal("Invalid LOAN_WARNING_PRINCIPAL: must be a positive amount")
+		}
+		warningThresholds.Principal = entity.NewMoney(principal)
 	}
 
+	// Exchange rates for investments made in another currency than the loan's, e.g. USD:IDR=16250
+	fxRates, err := fx.ParseRates(os.Getenv("FX_RATES"))
+	if err != nil {
+		log.Fatal("Invalid FX_RATES:", err)
+	}
+	fxConverter := fx.NewStaticConverter(fxRates)
+
 	// Initialize use cases
-	loanUsecase := usecase.NewLoanUsecase(loanRepo, investmentRepo, emailService)
+	loanUsecase := usecase.NewLoanUsecase(loanRepo, investmentRepo, stateTransitionRepo, disbursementRepo, loanDocumentRepo, loanApprovalRepo, db, asyncEmailService, asyncWebhookNotifier, fxConverter, receipt.NewPDFGenerator(), prometheusMetrics, logger, usecase.LoanUsecaseConfig{
+		FundingWindow:         fundingWindow,
+		StrictAmounts:         strictAmounts,
+		AgreementDomains:      envList("AGREEMENT_LINK_ALLOWED_DOMAINS"),
+		DualApprovalThreshold: dualApprovalThreshold,
+		WarningThresholds:     warningThresholds,
+	})
+
+	// Expire loans past their funding deadline every FUNDING_EXPIRY_INTERVAL
+	fundingExpiryInterval := expiry.DefaultSweepInterval
+	if value := os.Getenv("FUNDING_EXPIRY_INTERVAL"); value != "" {
+		fundingExpiryInterval, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatal("Invalid FUNDING_EXPIRY_INTERVAL:", err)
+		}
+	}
+	expirySweeper := expiry.NewSweeper(loanUsecase.ExpireOverdueLoans, fundingExpiryInterval)
+	expirySweeper.Start()
+
+	// Approve loans whose scheduled approval took effect every APPROVAL_ACTIVATION_INTERVAL
+	approvalActivationInterval := approval.DefaultActivationInterval
+	if value := os.Getenv("APPROVAL_ACTIVATION_INTERVAL"); value != "" {
+		approvalActivationInterval, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatal("Invalid APPROVAL_ACTIVATION_INTERVAL:", err)
+		}
+	}
+	approvalActivator := approval.NewActivator(loanUsecase.ActivateScheduledApprovals, approvalActivationInterval)
+	approvalActivator.Start()
+
+	// Recalculate the outstanding principal gauge every METRICS_REFRESH_INTERVAL
+	metricsRefreshInterval := metrics.DefaultRefreshInterval
+	if value := os.Getenv("METRICS_REFRESH_INTERVAL"); value != "" {
+		metricsRefreshInterval, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatal("Invalid METRICS_REFRESH_INTERVAL:", err)
+		}
+	}
+	principalRefresher := metrics.NewPrincipalRefresher(prometheusMetrics, func(ctx context.Context) (float64, error) {
+		stats, err := loanUsecase.GetStats(ctx, domainrepository.StatsFilter{})
+		if err != nil {
+			return 0, err
+		}
+		return stats.TotalDisbursedPrincipal.Float64(), nil
+	}, metricsRefreshInterval)
+	principalRefresher.Start()
+
+	// Loan state transitions require a bearer JWT signed with JWT_SECRET
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		log.Fatal("JWT_SECRET must be set")
+	}
+	authMiddleware := http.NewJWTAuthMiddleware([]byte(jwtSecret))
+
+	// Upload size limits per file type, in megabytes (unset or non-positive values use the defaults)
+	uploadLimits := http.UploadLimits{MaxImageSize: http.DefaultMaxImageSize, MaxDocumentSize: http.DefaultMaxDocumentSize}
+	if value := os.Getenv("MAX_IMAGE_UPLOAD_MB"); value != "" {
+		megabytes, err := strconv.Atoi(value)
+		if err != nil {
+			log.Fatal("Invalid MAX_IMAGE_UPLOAD_MB:", err)
+		}
+		uploadLimits.MaxImageSize = int64(megabytes) << 20
+	}
+	if value := os.Getenv("MAX_DOCUMENT_UPLOAD_MB"); value != "" {
+		megabytes, err := strconv.Atoi(value)
+		if err != nil {
+			log.Fatal("Invalid MAX_DOCUMENT_UPLOAD_MB:", err)
+		}
+		uploadLimits.MaxDocumentSize = int64(megabytes) << 20
+	}
+
+	// Page sizes of the loan and investment listings: DEFAULT_PAGE_SIZE without a
+	// limit, larger limits lowered to MAX_PAGE_SIZE
+	pageLimits := http.PageLimits{DefaultSize: http.DefaultPageSize, MaxSize: http.DefaultMaxPageSize}
+	if value := os.Getenv("DEFAULT_PAGE_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			log.Fatal("Invalid DEFAULT_PAGE_SIZE: must be a positive number")
+		}
+		pageLimits.DefaultSize = size
+	}
+	if value := os.Getenv("MAX_PAGE_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			log.Fatal("Invalid MAX_PAGE_SIZE: must be a positive number")
+		}
+		pageLimits.MaxSize = size
+	}
 
 	// Initialize handlers
-	loanHandler := http.NewLoanHandler(loanUsecase)
+	loanHandler := http.NewLoanHandler(loanUsecase, fileStorage, fileBaseURL, authMiddleware, uploadLimits, pageLimits)
+	healthHandler := http.NewHealthHandler(db)
+	infoHandler := http.NewInfoHandler(http.ServerInfo{
+		Version:        version,
+		Commit:         buildCommit(),
+		EmailProvider:  cmp.Or(emailProvider, "mock"),
+		StorageBackend: storageBackend,
+		DatabaseDriver: dbConfig.Driver,
+	})
+
+	// Bound every request so a slow query can't hang a handler
+	requestTimeout := http.DefaultRequestTimeout
+	if value := os.Getenv("REQUEST_TIMEOUT"); value != "" {
+		requestTimeout, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatal("Invalid REQUEST_TIMEOUT:", err)
+		}
+	}
+
+	// Limit every client IP to RATE_LIMIT_PER_SECOND requests, with bursts of RATE_LIMIT_BURST (0 disables the limit)
+	rateLimitPerSecond, rateLimitBurst := float64(http.DefaultRateLimitPerSecond), http.DefaultRateLimitBurst
+	if value := os.Getenv("RATE_LIMIT_PER_SECOND"); value != "" {
+		rateLimitPerSecond, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Fatal("Invalid RATE_LIMIT_PER_SECOND:", err)
+		}
+	}
+	if value := os.Getenv("RATE_LIMIT_BURST"); value != "" {
+		rateLimitBurst, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatal("Invalid RATE_LIMIT_BURST:", err)
+		}
+	}
+
+	// Compress JSON and text responses of at least COMPRESSION_MIN_SIZE bytes
+	compressionMinSize := http.DefaultCompressionMinSize
+	if value := os.Getenv("COMPRESSION_MIN_SIZE"); value != "" {
+		compressionMinSize, err = strconv.Atoi(value)
+		if err != nil || compressionMinSize <= 0 {
+			log.Fatal("Invalid COMPRESSION_MIN_SIZE: must be a positive number of bytes")
+		}
+	}
+
+	// Browsers may only call the API from CORS_ALLOWED_ORIGINS, none by default
+	corsConfig := http.CORSConfig{
+		AllowedOrigins: envList("CORS_ALLOWED_ORIGINS"),
+		AllowedMethods: envList("CORS_ALLOWED_METHODS"),
+		AllowedHeaders: envList("CORS_ALLOWED_HEADERS"),
+	}
+	if value := os.Getenv("CORS_ALLOW_CREDENTIALS"); value != "" {
+		corsConfig.AllowCredentials, err = strconv.ParseBool(value)
+		if err != nil {
+			log.Fatal("Invalid CORS_ALLOW_CREDENTIALS:", err)
+		}
+	}
+	corsMiddleware, err := http.NewCORSMiddleware(corsConfig)
+	if err != nil {
+		log.Fatal("Invalid CORS configuration:", err)
+	}
 
 	// Set up Gin router
-	r := gin.Default()
-	r.Use(cors.Default())
+	r := gin.New()
+
+	// Client IPs are only taken from X-Forwarded-For when sent by one of TRUSTED_PROXIES,
+	// otherwise any client could dodge the rate limit by spoofing the header
+	var trustedProxies []string
+	if value := os.Getenv("TRUSTED_PROXIES"); value != "" {
+		trustedProxies = strings.Split(value, ",")
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+	r.Use(http.NewRequestLoggingMiddleware(logger), gin.Recovery())
+	r.MaxMultipartMemory = uploadLimits.MaxMultipartMemory()
+	if corsMiddleware != nil {
+		r.Use(corsMiddleware)
+	}
+	r.Use(http.NewCompressionMiddleware(compressionMinSize))
+	r.Use(http.NewTimeoutMiddleware(requestTimeout))
+	r.Use(http.NewMetricsMiddleware(prometheusMetrics))
+	if rateLimitPerSecond > 0 && rateLimitBurst > 0 {
+		r.Use(http.NewRateLimitMiddleware(ratelimit.NewMemoryStore(rateLimitPerSecond, rateLimitBurst)))
+	}
 
 	// Register routes
 	loanHandler.RegisterRoutes(r)
+	healthHandler.RegisterRoutes(r)
+	infoHandler.RegisterRoutes(r)
+	r.GET("/metrics", gin.WrapH(prometheusMetrics.Handler()))
 
-	// Start server
+	// Describe the routes above; registered last so it sees all of them
+	openAPIHandler := http.NewOpenAPIHandler(http.OpenAPIInfo{Title: "Loan Engine API", Version: "1.0.0"})
+	if err := openAPIHandler.RegisterRoutes(r); err != nil {
+		log.Fatal("Failed to build the OpenAPI document:", err)
+	}
+
+	// Start server, on every interface unless HOST is set
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
+	addr := net.JoinHostPort(os.Getenv("HOST"), port)
 
-	log.Printf("Starting Loan Engine API server on port %s", port)
+	protocol := "HTTP"
+	if tlsConfig != nil {
+		protocol = "HTTPS"
+	}
+	log.Printf("Starting Loan Engine API server over %s on %s", protocol, addr)
 	log.Println("API Documentation:")
+	log.Println("GET    /healthz                - Liveness probe")
+	log.Println("GET    /readyz                 - Readiness probe (pings the database)")
+	log.Println("GET    /metrics                - Prometheus metrics")
+	log.Println("GET    /openapi.json           - OpenAPI 3 document")
+	log.Println("GET    /docs                   - Swagger UI")
+	log.Println("GET    /api/info               - Running version and backends")
 	log.Println("POST   /api/loans              - Create new loan")
 	log.Println("GET    /api/loans              - List all loans (optional filters: ?state=approved&limit=10)")
+	log.Println("GET    /api/loans/export       - Export loans as CSV (same filters as the list)")
+	log.Println("POST   /api/loans/batch-get    - Get several loans by ID in one call")
 	log.Println("GET    /api/loans/:id          - Get loan details with investments")
+	log.Println("PUT    /api/loans/:id          - Edit a proposed loan")
+	log.Println("DELETE /api/loans/:id          - Soft-delete a proposed or rejected loan")
+	log.Println("GET    /api/loans/:id/returns  - Get expected returns per investor")
+	log.Println("GET    /api/loans/:id/funding  - Get funding progress without the investments")
+	log.Println("GET    /api/loans/:id/history  - Get loan state transition history")
+	log.Println("GET    /api/loans/:id/actions  - List the actions the loan's state allows")
+	log.Println("GET    /api/loans/:id/investments - List investments in a loan (optional filters: ?investor_email=&limit=&offset=)")
 	log.Println("POST   /api/loans/:id/approve  - Approve a loan")
+	log.Println("PUT    /api/loans/:id/approval-proof - Replace the approval proof picture")
+	log.Println("PATCH  /api/loans/:id/roi      - Change the ROI of an approved loan before investment")
+	log.Println("POST   /api/loans/:id/reject   - Reject a loan")
+	log.Println("POST   /api/loans/:id/cancel   - Cancel a loan")
+	log.Println("POST   /api/loans/:id/expire   - Expire an approved loan now")
+	log.Println("POST   /api/loans/:id/force-invested - Mark a fully funded loan invested (reconciliation)")
+	log.Println("POST   /api/loans/:id/notify   - Resend the fully invested notification to the investors")
 	log.Println("POST   /api/loans/:id/invest   - Invest in a loan")
-	log.Println("POST   /api/loans/:id/disburse - Disburse a loan")
+	log.Println("DELETE /api/loans/:id/investments/:investment_id - Withdraw an investment")
+	log.Println("POST   /api/loans/:id/disburse - Disburse a loan, in full or in tranches")
+	log.Println("GET    /api/loans/:id/disbursements - List disbursement tranches of a loan")
+	log.Println("GET    /api/loans/:id/files/:type - Download an uploaded document (approval_proof, signed_agreement)")
+	log.Println("GET    /api/stats              - Loan portfolio statistics (optional filters: ?created_after=&created_before=)")
+	log.Println("GET    /api/search?q=          - Search loans by borrower ID or investor email fragment")
+	log.Println("GET    /api/borrowers/:id/exposure - Borrower exposure across all their loans")
+	log.Println("POST   /api/investments/bulk   - Invest in several loans at once")
+	log.Println("GET    /api/investments/:id/receipt - Download the PDF receipt of an investment")
+	log.Println("GET    /api/investors/:email/portfolio - One investor's investments across all loans")
+	log.Println("GET    /api/admin/investments  - Investment ledger across all loans (admin)")
+	log.Println("GET    /api/admin/roi-summary  - Blended ROI of funded loans (admin)")
+
+	// How long in-flight requests get to finish on shutdown
+	shutdownTimeout := 30 * time.Second
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		shutdownTimeout, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatal("Invalid SHUTDOWN_TIMEOUT:", err)
+		}
+	}
+
+	srv := &nethttp.Server{
+		Addr:      addr,
+		Handler:   r,
+		TLSConfig: tlsConfig,
+	}
 
 	// Graceful shutdown
 	go func() {
-		if err := r.Run(":" + port); err != nil {
+		var err error
+		if tlsConfig != nil {
+			// The certificate is already in TLSConfig
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
 			log.Fatal("Failed to start server:", err)
 		}
 	}()
@@ -85,4 +608,77 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down server...")
+
+	// Stop accepting connections and let in-flight requests (e.g. disbursements) finish
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("Server forced to shut down:", err)
+	}
+
+	// Stop expiring loans and activating approvals before draining the notifications they queue
+	expirySweeper.Shutdown()
+	approvalActivator.Shutdown()
+
+	// Send the notifications queued by the drained requests
+	if err := asyncEmailService.Shutdown(ctx); err != nil {
+		log.Println("Email queue not fully drained:", err)
+	}
+	if err := asyncWebhookNotifier.Shutdown(ctx); err != nil {
+		log.Println("Webhook queue not fully drained:", err)
+	}
+
+	principalRefresher.Shutdown()
+	if err := db.Close(); err != nil {
+		log.Println("Failed to close database:", err)
+	}
+	log.Println("Server exited")
+}
+
+// buildCommit returns the commit set at build time, falling back to the VCS
+// revision the Go toolchain stamps into binaries built from a checkout
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// loadTLSConfig loads the certificate and private key to serve HTTPS with.
+// Without either file it returns nil for plain HTTP; only one of them is an error.
+func loadTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	switch {
+	case certFile == "" && keyFile == "":
+		return nil, nil
+	case certFile == "" || keyFile == "":
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS certificate: %w", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// envList splits the comma-separated list in the environment variable name,
+// dropping blank entries
+func envList(name string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
 }
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCert writes a certificate for 127.0.0.1 and its key as PEM
+// files, returning their paths and the certificate
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "loan-engine-test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "tls.crt")
+	keyFile = filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return certFile, keyFile, cert
+}
+
+func TestLoadTLSConfigServesHTTPS(t *testing.T) {
+	certFile, keyFile, cert := writeSelfSignedCert(t)
+
+	tlsConfig, err := loadTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("failed to load TLS config: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := &nethttp.Server{
+		Handler: nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			io.WriteString(w, "ok")
+		}),
+		TLSConfig: tlsConfig,
+	}
+	go srv.ServeTLS(listener, "", "")
+	defer srv.Close()
+
+	// A client trusting only the self-signed certificate completes the handshake
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	client := &nethttp.Client{Transport: &nethttp.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	resp, err := client.Get("https://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to complete the TLS handshake: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.TLS == nil || resp.TLS.Version < tls.VersionTLS12 {
+		t.Fatalf("got connection state %+v, want TLS 1.2 or later", resp.TLS)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "ok" {
+		t.Errorf("got body %q, want ok", body)
+	}
+
+	// TLS 1.1 clients are refused
+	oldClient := &nethttp.Client{Transport: &nethttp.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MaxVersion: tls.VersionTLS11}}}
+	if resp, err := oldClient.Get("https://" + listener.Addr().String()); err == nil {
+		resp.Body.Close()
+		t.Error("got a TLS 1.1 connection, want it refused")
+	}
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	certFile, keyFile, _ := writeSelfSignedCert(t)
+
+	tests := []struct {
+		name      string
+		certFile  string
+		keyFile   string
+		wantTLS   bool
+		wantError string
+	}{
+		{"neither file serves plain HTTP", "", "", false, ""},
+		{"both files", certFile, keyFile, true, ""},
+		{"only the certificate", certFile, "", false, "TLS_CERT_FILE and TLS_KEY_FILE must be set together"},
+		{"only the key", "", keyFile, false, "TLS_CERT_FILE and TLS_KEY_FILE must be set together"},
+		{"missing files", certFile + ".missing", keyFile, false, "invalid TLS certificate"},
+		{"key and certificate swapped", keyFile, certFile, false, "invalid TLS certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := loadTLSConfig(tt.certFile, tt.keyFile)
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("got error %v, want %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v, want none", err)
+			}
+			if (tlsConfig != nil) != tt.wantTLS {
+				t.Errorf("got TLS config %v, want TLS: %t", tlsConfig, tt.wantTLS)
+			}
+		})
+	}
+}